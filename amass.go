@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// hostInputFormats are the input formats which contain complete host names
+// instead of items, the items are extracted with the template.
+var hostInputFormats = map[string]bool{
+	"amass":     true,
+	"subfinder": true,
+}
+
+// amassAddress is an address in the JSON output of amass.
+type amassAddress struct {
+	IP   string `json:"ip"`
+	CIDR string `json:"cidr,omitempty"`
+	ASN  int    `json:"asn,omitempty"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// amassLine is a line in the JSON output of amass (enum -json).
+type amassLine struct {
+	Name      string         `json:"name"`
+	Domain    string         `json:"domain"`
+	Addresses []amassAddress `json:"addresses"`
+	Tag       string         `json:"tag"`
+	Sources   []string       `json:"sources"`
+	Source    string         `json:"source,omitempty"` // older versions
+}
+
+// subfinderLine is a line in the JSON output of subfinder (-oJ).
+type subfinderLine struct {
+	Host    string   `json:"host"`
+	Input   string   `json:"input"`
+	Source  string   `json:"source"`
+	Sources []string `json:"sources"` // with -cs
+}
+
+// parseHostLine returns the host name and the sources from a line of JSON
+// output written by amass or subfinder.
+func parseHostLine(format, line string) (host string, sources []string, err error) {
+	switch format {
+	case "amass":
+		var in amassLine
+		err = json.Unmarshal([]byte(line), &in)
+		host, sources = in.Name, in.Sources
+		if in.Source != "" {
+			sources = append([]string{in.Source}, sources...)
+		}
+
+	case "subfinder":
+		var in subfinderLine
+		err = json.Unmarshal([]byte(line), &in)
+		host, sources = in.Host, in.Sources
+		if in.Source != "" {
+			sources = append([]string{in.Source}, sources...)
+		}
+
+	default:
+		return "", nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid %v line %q: %v", format, line, err)
+	}
+
+	return host, sources, nil
+}
+
+// templateItem returns the item which yields host when inserted into the
+// template. It returns false if host does not match the template, e.g. for
+// host names in other domains.
+func templateItem(template, host string) (string, bool) {
+	pos := strings.Index(template, "FUZZ")
+	if pos < 0 {
+		return "", false
+	}
+
+	prefix := strings.ToLower(template[:pos])
+	suffix := strings.ToLower(strings.TrimSuffix(template[pos+len("FUZZ"):], "."))
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+
+	if len(host) <= len(prefix)+len(suffix) || !strings.HasPrefix(host, prefix) || !strings.HasSuffix(host, suffix) {
+		return "", false
+	}
+
+	return host[len(prefix) : len(host)-len(suffix)], true
+}
+
+// templateDomain returns the domain for the host name, which is the part of
+// the template after FUZZ. If the template does not contain a domain (e.g.
+// "FUZZ"), the last two labels of name are used.
+func templateDomain(template, name string) string {
+	pos := strings.Index(template, "FUZZ")
+	if pos >= 0 {
+		domain := strings.Trim(template[pos+len("FUZZ"):], ".-")
+		if strings.Contains(domain, ".") {
+			return strings.ToLower(domain)
+		}
+	}
+
+	labels := strings.Split(name, ".")
+	if len(labels) > 2 {
+		labels = labels[len(labels)-2:]
+	}
+	return strings.Join(labels, ".")
+}
+
+// AmassWriter writes the host names of all shown results which resolved to a
+// file in the JSON format of amass (one object per line with the name and
+// the addresses), so they can be imported into the tools processing amass
+// output.
+type AmassWriter struct {
+	filename string
+	template string
+}
+
+// NewAmassWriter returns a new writer for filename, the domain of the host
+// names is taken from template.
+func NewAmassWriter(filename, template string) *AmassWriter {
+	return &AmassWriter{filename: filename, template: template}
+}
+
+// Run reads results from in and forwards them to out, writing a line for each
+// resolved result to the file on the way. When in is closed or the context is
+// cancelled, the file is closed and out is closed.
+func (w *AmassWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	f, err := os.Create(w.filename)
+	if err != nil {
+		close(out)
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	err = forward(ctx, in, out, func(res resolve.Result) error {
+		if res.Hide || !res.Resolved() {
+			return nil
+		}
+
+		name := resolve.CleanHostname(res.Hostname)
+		line := amassLine{
+			Name:      name,
+			Domain:    templateDomain(w.template, name),
+			Addresses: []amassAddress{},
+			Tag:       "dns",
+			Sources:   []string{"taifun"},
+		}
+
+		for _, request := range res.Requests {
+			if request.Hide {
+				continue
+			}
+
+			for _, response := range request.Responses {
+				if response.Hide || (response.Type != "A" && response.Type != "AAAA") {
+					continue
+				}
+
+				if ip := net.ParseIP(response.Data); ip != nil {
+					line.Addresses = append(line.Addresses, amassAddress{IP: ip.String()})
+				}
+			}
+		}
+
+		return enc.Encode(line)
+	})
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+)
+
+// ControlAPI serves a small HTTP API for adjusting a running program. All
+// requests must send the token in the header "Authorization: Bearer TOKEN",
+// all responses are JSON encoded. The following endpoints are available:
+//
+//	GET  /status                 current state and statistics
+//	POST /pause                  stop sending new requests
+//	POST /resume                 continue sending requests
+//	POST /rate?value=N           limit the requests per second, 0 means unlimited
+//	POST /threads?value=N        set a fixed number of threads
+//	POST /dump                   write the JSON log file now
+type ControlAPI struct {
+	throttle *producer.Throttle
+	pool     *resolve.Pool // nil for the coordinator
+	reporter *report.Reporter
+	recorder *report.Recorder // may be nil
+	token    string
+}
+
+// ControlStatus is returned by the API.
+type ControlStatus struct {
+	Paused  bool                  `json:"paused"`
+	Rate    float64               `json:"rate"`
+	Threads int                   `json:"threads,omitempty"`
+	Stats   *report.StatsSnapshot `json:"stats,omitempty"`
+}
+
+// NewControlAPI returns a new API for the components, clients must send
+// token.
+func NewControlAPI(throttle *producer.Throttle, pool *resolve.Pool, reporter *report.Reporter, recorder *report.Recorder, token string) *ControlAPI {
+	return &ControlAPI{
+		throttle: throttle,
+		pool:     pool,
+		reporter: reporter,
+		recorder: recorder,
+		token:    token,
+	}
+}
+
+// Start serves the API on addr. The server runs until the program exits.
+func (api *ControlAPI) Start(addr string) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		_ = http.Serve(ln, api.Handler())
+	}()
+
+	return ln.Addr(), nil
+}
+
+// Handler returns the HTTP handler for the API.
+func (api *ControlAPI) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", api.get(func(r *http.Request) error { return nil }))
+
+	mux.HandleFunc("/pause", api.post(func(r *http.Request) error {
+		api.throttle.Pause()
+		return nil
+	}))
+
+	mux.HandleFunc("/resume", api.post(func(r *http.Request) error {
+		api.throttle.Resume()
+		return nil
+	}))
+
+	mux.HandleFunc("/rate", api.post(func(r *http.Request) error {
+		rate, err := strconv.ParseFloat(r.FormValue("value"), 64)
+		if err != nil || rate < 0 {
+			return fmt.Errorf("invalid rate %q", r.FormValue("value"))
+		}
+
+		api.throttle.SetRate(rate)
+		return nil
+	}))
+
+	mux.HandleFunc("/threads", api.post(func(r *http.Request) error {
+		if api.pool == nil {
+			return fmt.Errorf("the number of threads is set on the workers")
+		}
+
+		threads, err := strconv.Atoi(r.FormValue("value"))
+		if err != nil || threads <= 0 {
+			return fmt.Errorf("invalid number of threads %q", r.FormValue("value"))
+		}
+
+		api.pool.SetThreads(threads)
+		return nil
+	}))
+
+	mux.HandleFunc("/dump", api.post(func(r *http.Request) error {
+		if api.recorder == nil {
+			return fmt.Errorf("no log file configured")
+		}
+
+		api.recorder.Dump()
+		return nil
+	}))
+
+	return requireToken(api.token, mux)
+}
+
+// newToken returns a random token for authenticating clients.
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// requireToken returns a handler which only passes requests to h which carry
+// token in the Authorization header as "Bearer TOKEN".
+func requireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing token"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// status returns the current status.
+func (api *ControlAPI) status() ControlStatus {
+	status := ControlStatus{
+		Paused: api.throttle.Paused(),
+		Rate:   api.throttle.Rate(),
+		Stats:  api.reporter.Snapshot(),
+	}
+
+	if api.pool != nil {
+		status.Threads = api.pool.Threads()
+	}
+
+	return status
+}
+
+// get returns a handler for GET requests which runs f and returns the status.
+func (api *ControlAPI) get(f func(*http.Request) error) http.HandlerFunc {
+	return api.handle(http.MethodGet, f)
+}
+
+// post returns a handler for POST requests which runs f and returns the
+// status.
+func (api *ControlAPI) post(f func(*http.Request) error) http.HandlerFunc {
+	return api.handle(http.MethodPost, f)
+}
+
+func (api *ControlAPI) handle(method string, f func(*http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != method {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed, use " + method})
+			return
+		}
+
+		err := f(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.status())
+	}
+}
+
+// writeJSON sends v as the response.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	// nothing we can do when sending the response fails
+	_ = enc.Encode(v)
+}
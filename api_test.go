@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/report"
+)
+
+// testTerminal collects the messages printed to the terminal.
+type testTerminal struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (t *testTerminal) Printf(msg string, data ...interface{}) {
+	t.Print(fmt.Sprintf(msg, data...))
+}
+
+func (t *testTerminal) Print(msg string) {
+	t.mu.Lock()
+	t.lines = append(t.lines, msg)
+	t.mu.Unlock()
+}
+
+func (t *testTerminal) SetStatus([]string)      {}
+func (t *testTerminal) Run(ctx context.Context) {}
+
+// controlRequest sends a request to the control API and decodes the status
+// returned.
+func controlRequest(t testing.TB, h http.Handler, method, path, token string) (int, ControlStatus, string) {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var status ControlStatus
+	var msg struct {
+		Error string `json:"error"`
+	}
+
+	if rec.Code == http.StatusOK {
+		err := json.Unmarshal(rec.Body.Bytes(), &status)
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		err := json.Unmarshal(rec.Body.Bytes(), &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rec.Code, status, msg.Error
+}
+
+func TestControlAPIToken(t *testing.T) {
+	api := NewControlAPI(producer.NewThrottle(0, 1), nil, report.NewReporter(&testTerminal{}, nil), nil, "secret")
+	h := api.Handler()
+
+	for _, token := range []string{"", "wrong", "secre", "secret2"} {
+		code, _, _ := controlRequest(t, h, http.MethodGet, "/status", token)
+		if code != http.StatusUnauthorized {
+			t.Errorf("token %q: wrong status, want %v, got %v", token, http.StatusUnauthorized, code)
+		}
+	}
+
+	code, _, msg := controlRequest(t, h, http.MethodGet, "/status", "secret")
+	if code != http.StatusOK {
+		t.Errorf("wrong status for the correct token, want %v, got %v (%v)", http.StatusOK, code, msg)
+	}
+}
+
+func TestControlAPI(t *testing.T) {
+	throttle := producer.NewThrottle(10, 1)
+	api := NewControlAPI(throttle, nil, report.NewReporter(&testTerminal{}, nil), nil, "secret")
+	h := api.Handler()
+
+	var tests = []struct {
+		method string
+		path   string
+		code   int
+		err    string
+		check  func(t *testing.T, status ControlStatus)
+	}{
+		{
+			method: http.MethodGet, path: "/status", code: http.StatusOK,
+			check: func(t *testing.T, status ControlStatus) {
+				if status.Paused || status.Rate != 10 {
+					t.Errorf("wrong status %+v", status)
+				}
+			},
+		},
+		{
+			method: http.MethodPost, path: "/status", code: http.StatusMethodNotAllowed,
+			err: "use GET",
+		},
+		{
+			method: http.MethodPost, path: "/pause", code: http.StatusOK,
+			check: func(t *testing.T, status ControlStatus) {
+				if !status.Paused || !throttle.Paused() {
+					t.Errorf("not paused: %+v", status)
+				}
+			},
+		},
+		{
+			method: http.MethodPost, path: "/resume", code: http.StatusOK,
+			check: func(t *testing.T, status ControlStatus) {
+				if status.Paused || throttle.Paused() {
+					t.Errorf("still paused: %+v", status)
+				}
+			},
+		},
+		{
+			method: http.MethodPost, path: "/rate?" + url.Values{"value": {"2.5"}}.Encode(), code: http.StatusOK,
+			check: func(t *testing.T, status ControlStatus) {
+				if status.Rate != 2.5 || throttle.Rate() != 2.5 {
+					t.Errorf("wrong rate: %+v", status)
+				}
+			},
+		},
+		{
+			method: http.MethodPost, path: "/rate?value=-1", code: http.StatusBadRequest,
+			err: "invalid rate",
+		},
+		{
+			method: http.MethodGet, path: "/rate?value=1", code: http.StatusMethodNotAllowed,
+			err: "use POST",
+		},
+		{
+			method: http.MethodPost, path: "/threads?value=4", code: http.StatusBadRequest,
+			err: "set on the workers",
+		},
+		{
+			method: http.MethodPost, path: "/dump", code: http.StatusBadRequest,
+			err: "no log file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+test.path, func(t *testing.T) {
+			code, status, msg := controlRequest(t, h, test.method, test.path, "secret")
+			if code != test.code {
+				t.Fatalf("wrong status code, want %v, got %v (%v)", test.code, code, msg)
+			}
+
+			if !strings.Contains(msg, test.err) {
+				t.Errorf("wrong error message, want %q, got %q", test.err, msg)
+			}
+
+			if test.check != nil {
+				test.check(t, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+)
+
+// zoneCandidates returns the names which may be the zone containing the host
+// names built from template, most specific first. For "FUZZ.dev.example.com."
+// these are "dev.example.com", "example.com" and "com".
+func zoneCandidates(template string) (zones []string) {
+	labels := strings.Split(resolve.CleanHostname(template), ".")
+
+	// skip all labels up to the last one containing the placeholder
+	start := 0
+	for i, label := range labels {
+		if strings.Contains(label, "FUZZ") {
+			start = i + 1
+		}
+	}
+
+	for i := start; i < len(labels); i++ {
+		zones = append(zones, strings.Join(labels[i:], "."))
+	}
+
+	return zones
+}
+
+// findAuthoritativeServers returns the zone containing the host names built
+// from template and the addresses of its authoritative name servers. The
+// queries for discovering them are sent to resolver. IPv4 addresses are
+// preferred, IPv6 addresses are only used if a zone has no IPv4 servers. If
+// network is "udp4" or "udp6", only addresses of this family are used.
+func findAuthoritativeServers(template, resolver, network string) (zone string, servers, addrs []string, err error) {
+	for _, candidate := range zoneCandidates(template) {
+		servers, err = lookupNameservers(candidate, resolver)
+		if err == nil {
+			zone = candidate
+			break
+		}
+	}
+
+	if zone == "" {
+		return "", nil, nil, fmt.Errorf("unable to find the zone for %v", resolve.CleanHostname(template))
+	}
+
+	var v4, v6 []string
+	for _, server := range servers {
+		list, err := lookupAddresses(server, resolver)
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range list {
+			host := addr
+			if h, _, err := net.SplitHostPort(addr); err == nil {
+				host = h
+			}
+
+			if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+				v6 = append(v6, addr)
+			} else {
+				v4 = append(v4, addr)
+			}
+		}
+	}
+
+	switch network {
+	case "udp4":
+		addrs = resolve.Unique(v4)
+	case "udp6":
+		addrs = resolve.Unique(v6)
+	default:
+		addrs = resolve.Unique(v4)
+		if len(addrs) == 0 {
+			addrs = resolve.Unique(v6)
+		}
+	}
+
+	if len(addrs) == 0 {
+		return "", nil, nil, fmt.Errorf("no addresses found for the name servers of %v (%v)", zone, strings.Join(servers, ", "))
+	}
+
+	return zone, servers, addrs, nil
+}
+
+// setupAuthoritative replaces the name servers in opts by the authoritative
+// name servers of the target zone, which are discovered via the configured
+// name server. The configured name server is kept for other queries which
+// need a recursive resolver.
+func setupAuthoritative(term cli.Terminal, opts *Options, hostname string) error {
+	zone, servers, addrs, err := findAuthoritativeServers(hostname, opts.Nameserver, opts.network)
+	if err != nil {
+		return err
+	}
+
+	if !opts.quiet() {
+		term.Printf("authoritative name servers for %v: %v (%v)", zone, strings.Join(servers, ", "), strings.Join(addrs, ", "))
+
+		if opts.Threads < len(addrs) && !opts.AutoThreads {
+			term.Printf("only %d of %d name servers are used, increase the number of threads to use all", opts.Threads, len(addrs))
+		}
+	}
+
+	opts.Resolvers = addrs
+	return nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
+)
+
+// query sends a query for name and type to the server and returns the answer section.
+func query(name string, qtype uint16, server string) ([]dns.RR, error) {
+	c := dns.Client{}
+	m := dns.Msg{}
+	m.SetQuestion(dns.Fqdn(name), qtype)
+
+	res, _, err := c.Exchange(&m, resolve.NameserverAddress(server))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("server returned %v", dns.RcodeToString[res.Rcode])
+	}
+
+	return res.Answer, nil
+}
+
+// lookupNameservers returns the name servers for zone.
+func lookupNameservers(zone, resolver string) (servers []string, err error) {
+	answer, err := query(zone, dns.TypeNS, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rr := range answer {
+		if ns, ok := rr.(*dns.NS); ok {
+			servers = append(servers, resolve.CleanHostname(ns.Ns))
+		}
+	}
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no name servers found for %v", zone)
+	}
+
+	return resolve.Unique(servers), nil
+}
+
+// lookupAddresses returns the IPv4 and IPv6 addresses for host. If host is
+// an IP address (optionally with a port), it is returned as is. An error is
+// only returned if no addresses were found, e.g. the addresses are still
+// returned if the query for AAAA records failed.
+func lookupAddresses(host, resolver string) (addrs []string, err error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil && net.ParseIP(h) != nil {
+		return []string{host}, nil
+	}
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		answer, qerr := query(host, qtype, resolver)
+		if qerr != nil {
+			if err == nil {
+				err = qerr
+			}
+			continue
+		}
+
+		for _, rr := range answer {
+			switch rec := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, rec.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, rec.AAAA.String())
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no addresses found for %v", host)
+	}
+
+	return addrs, nil
+}
+
+// transferZone requests a zone transfer (AXFR or IXFR) from server. If tsig
+// is not nil, the request is signed.
+func transferZone(zone, server string, transferType uint16, tsig *resolve.TSIG) (records []dns.RR, err error) {
+	m := &dns.Msg{}
+	if transferType == dns.TypeIXFR {
+		// request all changes since serial 0, which is the complete zone
+		m.SetIxfr(dns.Fqdn(zone), 0, "", "")
+	} else {
+		m.SetAxfr(dns.Fqdn(zone))
+	}
+
+	t := &dns.Transfer{}
+	if tsig != nil {
+		t.TsigSecret = tsig.Sign(m)
+	}
+
+	ch, err := t.In(m, resolve.NameserverAddress(server))
+	if err != nil {
+		return nil, err
+	}
+
+	for env := range ch {
+		if env.Error != nil {
+			err = env.Error
+			continue
+		}
+		records = append(records, env.RR...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("no records transferred")
+	}
+
+	return records, nil
+}
+
+// zoneNames returns the names of all records within zone relative to the
+// zone, which can be used as a wordlist for the template FUZZ.zone.
+func zoneNames(zone string, records []dns.RR) []string {
+	suffix := "." + strings.ToLower(dns.Fqdn(zone))
+
+	var names []string
+	for _, rr := range records {
+		name := strings.ToLower(rr.Header().Name)
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(name, suffix))
+	}
+
+	return resolve.Unique(names)
+}
+
+// addTSIGFlags adds the flags for signing queries with TSIG to flags.
+func addTSIGFlags(flags *pflag.FlagSet, name, algorithm, secret *string) {
+	flags.StringVar(name, "tsig-name", "", "sign queries with the TSIG key `name`")
+	flags.StringVar(algorithm, "tsig-algorithm", "hmac-sha256", "use `algorithm` for the TSIG signatures ("+strings.Join(resolve.TSIGAlgorithmNames(), ", ")+")")
+	flags.StringVar(secret, "tsig-secret", "", "sign queries with the base64 encoded TSIG `secret`")
+}
+
+// writeLines writes the lines to the file filename.
+func writeLines(filename string, lines []string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		_, err = fmt.Fprintln(f, line)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+func newAXFRCommand() *cobra.Command {
+	var (
+		servers    []string
+		resolver   string
+		ixfr       bool
+		output     string
+		writeNames string
+		configFile string
+		profile    string
+
+		tsigName, tsigAlgorithm, tsigSecret string
+	)
+
+	cmd := &cobra.Command{
+		Use:                   "axfr [options] ZONE",
+		Short:                 "Try zone transfers from the name servers of a zone",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("exactly one zone needs to be specified")
+			}
+			zone := resolve.CleanHostname(args[0])
+
+			err := applyProfile(cmd, configFile, profile)
+			if err != nil {
+				return err
+			}
+
+			var tsig *resolve.TSIG
+			if tsigName != "" || tsigSecret != "" {
+				tsig, err = resolve.NewTSIG(tsigName, tsigAlgorithm, tsigSecret)
+				if err != nil {
+					return err
+				}
+			}
+
+			if resolve.IsJSONServer(resolver) {
+				return errors.New("axfr cannot send queries to a JSON API")
+			}
+			for _, server := range servers {
+				if resolve.IsJSONServer(server) {
+					return fmt.Errorf("zone transfers cannot be requested from the JSON API %v", server)
+				}
+			}
+
+			if resolver == "" {
+				resolver, err = resolve.FindSystemNameserver()
+				if err != nil {
+					return err
+				}
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				term, cleanup, err := setupTerminal(ctx, g, "", "", false, logFormat{})
+				defer cleanup()
+				if err != nil {
+					return err
+				}
+
+				if len(servers) == 0 {
+					servers, err = lookupNameservers(zone, resolver)
+					if err != nil {
+						return err
+					}
+					term.Printf("name servers for %v: %v", zone, strings.Join(servers, ", "))
+				}
+
+				transferTypes := []uint16{dns.TypeAXFR}
+				if ixfr {
+					transferTypes = append(transferTypes, dns.TypeIXFR)
+				}
+
+				var records []string
+				var names []string
+				for _, server := range servers {
+					addrs, err := lookupAddresses(server, resolver)
+					if err != nil {
+						term.Printf("%v: %v", server, err)
+						continue
+					}
+
+					for _, addr := range addrs {
+						for _, transferType := range transferTypes {
+							rrs, err := transferZone(zone, addr, transferType, tsig)
+							if err != nil {
+								term.Printf("%v (%v): %v failed: %v", server, addr, dns.TypeToString[transferType], err)
+								continue
+							}
+
+							term.Printf("%v (%v): %v succeeded, %d records", server, addr, dns.TypeToString[transferType], len(rrs))
+							records = append(records, resolve.RawValues(rrs)...)
+							names = append(names, zoneNames(zone, rrs)...)
+						}
+					}
+				}
+
+				if len(records) == 0 {
+					return errors.New("no zone transfer succeeded")
+				}
+
+				records = resolve.Unique(records)
+				names = resolve.Unique(names)
+
+				term.Printf("\n")
+				for _, record := range records {
+					term.Printf("%s", record)
+				}
+
+				if output != "" {
+					err = writeLines(output, records)
+					if err != nil {
+						return err
+					}
+				}
+
+				if writeNames != "" {
+					err = writeLines(writeNames, names)
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVar(&servers, "server", nil, "request the transfer from `server` instead of the name servers of the zone (can be specified multiple times)")
+	flags.StringVar(&resolver, "nameserver", "", "send DNS queries for name servers and addresses to `server`, if empty, the system resolver is used")
+	flags.BoolVar(&ixfr, "ixfr", false, "also try an incremental zone transfer (IXFR)")
+	flags.StringVarP(&output, "output", "o", "", "write the transferred records to `filename`")
+	flags.StringVar(&writeNames, "write-names", "", "write the names in the zone to `filename`, usable as a wordlist for FUZZ.ZONE")
+	addTSIGFlags(flags, &tsigName, &tsigAlgorithm, &tsigSecret)
+	addProfileFlags(flags, &configFile, &profile)
+
+	return cmd
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/happal/taifun/dnstest"
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+)
+
+func axfrZone(t testing.TB) *dnstest.Zone {
+	zone, err := dnstest.NewZone("example.com",
+		"example.com. 300 IN NS ns1.example.com.",
+		"ns1.example.com. 300 IN A 192.0.2.53",
+		"WWW.example.com. 300 IN A 192.0.2.1",
+		"www.example.com. 300 IN AAAA 2001:db8::1",
+		"*.wild.example.com. 300 IN A 192.0.2.3",
+		"a.b.example.com. 300 IN CNAME www.example.com.",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zone
+}
+
+func TestTransferZone(t *testing.T) {
+	zone := axfrZone(t)
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	records, err := transferZone("example.com", srv.Addr, dns.TypeAXFR, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the SOA record is sent at the beginning and at the end
+	if len(records) != len(zone.Records)+2 {
+		t.Errorf("wrong number of records, want %d, got %d:\n%v", len(zone.Records)+2, len(records), records)
+	}
+
+	want := []string{"*.wild", "a.b", "ns1", "www"}
+	names := zoneNames("example.com", records)
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong names, want %q, got %q", want, names)
+	}
+
+	// the server only serves example.com
+	_, err = transferZone("example.net", srv.Addr, dns.TypeAXFR, nil)
+	if err == nil {
+		t.Errorf("no error returned for a refused transfer")
+	}
+}
+
+func TestTransferZoneTSIG(t *testing.T) {
+	zone := axfrZone(t)
+	zone.RequireTSIG = true
+
+	const secret = "c2VjcmV0IGtleSBmb3IgdGVzdGluZw=="
+	srv, err := dnstest.NewTSIGServer(zone, map[string]string{"test-key.": secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	_, err = transferZone("example.com", srv.Addr, dns.TypeAXFR, nil)
+	if err == nil {
+		t.Errorf("no error returned for an unsigned transfer")
+	}
+
+	key, err := resolve.NewTSIG("test-key", "hmac-sha256", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := transferZone("example.com", srv.Addr, dns.TypeAXFR, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != len(zone.Records)+2 {
+		t.Errorf("wrong number of records, want %d, got %d", len(zone.Records)+2, len(records))
+	}
+}
+
+func TestZoneNames(t *testing.T) {
+	var tests = []struct {
+		zone    string
+		records []string
+		want    []string
+	}{
+		{
+			zone: "example.com",
+			records: []string{
+				// the zone itself and names outside of it are skipped
+				"example.com. 300 IN SOA ns.example.com. hostmaster.example.com. 1 3600 600 86400 300",
+				"www.example.net. 300 IN A 192.0.2.9",
+				"badexample.com. 300 IN A 192.0.2.8",
+				"WWW.Example.com. 300 IN A 192.0.2.1",
+				"www.example.com. 300 IN AAAA 2001:db8::1",
+				"mail.example.com. 300 IN A 192.0.2.2",
+			},
+			want: []string{"mail", "www"},
+		},
+		{
+			zone: "Sub.Example.com.",
+			records: []string{
+				"a.b.sub.example.com. 300 IN A 192.0.2.1",
+				"www.example.com. 300 IN A 192.0.2.2",
+			},
+			want: []string{"a.b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			var records []dns.RR
+			for _, s := range test.records {
+				rr, err := dns.NewRR(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				records = append(records, rr)
+			}
+
+			got := zoneNames(test.zone, records)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong names, want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+// refusingHandler refuses queries for one type and passes all other queries
+// to the zone.
+type refusingHandler struct {
+	zone  *dnstest.Zone
+	qtype uint16
+}
+
+func (h *refusingHandler) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	if len(req.Question) == 1 && req.Question[0].Qtype == h.qtype {
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeRefused)
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	h.zone.ServeDNS(w, req)
+}
+
+func TestLookupAddresses(t *testing.T) {
+	var tests = []struct {
+		host    string
+		refused uint16
+		want    []string
+		err     bool
+	}{
+		{host: "192.0.2.10", want: []string{"192.0.2.10"}},
+		{host: "192.0.2.10:5353", want: []string{"192.0.2.10:5353"}},
+		{host: "www.example.com", want: []string{"192.0.2.1", "2001:db8::1"}},
+		// the addresses found are kept if a query fails
+		{host: "www.example.com", refused: dns.TypeAAAA, want: []string{"192.0.2.1"}},
+		{host: "www.example.com", refused: dns.TypeA, want: []string{"2001:db8::1"}},
+		{host: "ns1.example.com", refused: dns.TypeAAAA, want: []string{"192.0.2.53"}},
+		// no addresses found
+		{host: "ns1.example.com", refused: dns.TypeA, err: true},
+		{host: "missing.example.com", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			srv, err := dnstest.NewServer(&refusingHandler{zone: axfrZone(t), qtype: test.refused})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = srv.Close()
+			}()
+
+			addrs, err := lookupAddresses(test.host, srv.Addr)
+			if test.err {
+				if err == nil {
+					t.Errorf("no error returned, addresses %v", addrs)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(addrs, test.want) {
+				t.Errorf("wrong addresses, want %v, got %v", test.want, addrs)
+			}
+		})
+	}
+}
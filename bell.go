@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+)
+
+// bellInterval is the minimal time between two bells, so that a burst of
+// results does not ring the bell continuously.
+const bellInterval = time.Second
+
+// desktopNotifyInterval is the minimal time between two desktop notifications.
+const desktopNotifyInterval = 5 * time.Second
+
+// errorTerminal is implemented by terminals which write error messages to
+// stderr, e.g. termstatus.Terminal.
+type errorTerminal interface {
+	Error(line string)
+}
+
+// Bell rings the terminal bell and optionally shows a desktop notification
+// when a shown result matches one of the patterns, so that hits are noticed
+// in a terminal in the background.
+type Bell struct {
+	term     cli.Terminal
+	bell     bool
+	patterns []*regexp.Regexp
+	desktop  bool
+
+	lastBell, lastNotify time.Time
+	notifyFailed         sync.Once
+}
+
+// NewBell returns a new Bell which rings the terminal bell if bell is true.
+// Without patterns, all shown results match.
+func NewBell(term cli.Terminal, bell bool, patterns []*regexp.Regexp, desktop bool) *Bell {
+	return &Bell{
+		term:     term,
+		bell:     bell,
+		patterns: patterns,
+		desktop:  desktop,
+	}
+}
+
+// match returns true if the host name or the data of a shown response matches
+// one of the patterns.
+func (b *Bell) match(res resolve.Result) bool {
+	if len(b.patterns) == 0 {
+		return true
+	}
+
+	for _, pat := range b.patterns {
+		if pat.MatchString(res.Hostname) {
+			return true
+		}
+
+		for _, req := range res.Requests {
+			if req.Hide {
+				continue
+			}
+
+			for _, resp := range req.Responses {
+				if !resp.Hide && pat.MatchString(resp.Data) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Run reads results from in and forwards them to out, ringing the bell for
+// matching results on the way. When in is closed or the context is cancelled,
+// out is closed.
+func (b *Bell) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forward(ctx, in, out, func(res resolve.Result) error {
+		if res.Hide || !b.match(res) {
+			return nil
+		}
+
+		now := time.Now()
+		if b.bell && now.Sub(b.lastBell) >= bellInterval {
+			b.lastBell = now
+			b.ring()
+		}
+
+		if b.desktop && now.Sub(b.lastNotify) >= desktopNotifyInterval {
+			b.lastNotify = now
+			go b.notifyDesktop(ctx, res.Hostname)
+		}
+
+		return nil
+	})
+}
+
+// ring writes the bell character to stderr via the terminal, so it does not
+// end up in the output (which may be redirected) and does not interfere with
+// the status lines. The terminal terminates the line.
+func (b *Bell) ring() {
+	if t, ok := b.term.(errorTerminal); ok {
+		t.Error("\a")
+	}
+}
+
+// notifyDesktop shows a desktop notification for hostname with notify-send
+// on Linux and the BSDs or osascript on macOS.
+func (b *Bell) notifyDesktop(ctx context.Context, hostname string) {
+	msg := fmt.Sprintf("found %v", hostname)
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "osascript", "-e", fmt.Sprintf("display notification %q with title \"taifun\"", msg))
+	default:
+		cmd = exec.CommandContext(ctx, "notify-send", "taifun", msg)
+	}
+
+	err := cmd.Run()
+	if err != nil && ctx.Err() == nil {
+		// report the error only once, the command is probably missing
+		b.notifyFailed.Do(func() {
+			cli.Warnf(b.term, "desktop notification failed: %v", err)
+		})
+	}
+}
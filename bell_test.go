@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// bellTerminal records the errors printed via the terminal, which include
+// the bell.
+type bellTerminal struct {
+	testTerminal
+	errors []string
+}
+
+func (t *bellTerminal) Error(line string) {
+	t.mu.Lock()
+	t.errors = append(t.errors, line)
+	t.mu.Unlock()
+}
+
+func TestBell(t *testing.T) {
+	found := func(hostname string, responses ...resolve.Response) resolve.Result {
+		return resolve.Result{
+			Hostname: hostname,
+			Requests: []resolve.Request{{Type: "A", Responses: responses}},
+		}
+	}
+
+	hidden := found("admin.example.com")
+	hidden.Hide = true
+
+	var tests = []struct {
+		name     string
+		patterns []string
+		results  []resolve.Result
+		want     []string
+	}{
+		{
+			name: "no-results",
+			want: nil,
+		},
+		{
+			name:    "all",
+			results: []resolve.Result{found("www.example.com")},
+			want:    []string{"\a"},
+		},
+		{
+			name:    "hidden",
+			results: []resolve.Result{hidden},
+			want:    nil,
+		},
+		{
+			// a burst of results rings the bell only once
+			name: "burst",
+			results: []resolve.Result{
+				found("www.example.com"),
+				found("mail.example.com"),
+				found("ftp.example.com"),
+			},
+			want: []string{"\a"},
+		},
+		{
+			name:     "hostname",
+			patterns: []string{"^admin"},
+			results: []resolve.Result{
+				found("www.example.com"),
+				found("admin.example.com"),
+			},
+			want: []string{"\a"},
+		},
+		{
+			name:     "hostname-no-match",
+			patterns: []string{"^admin"},
+			results: []resolve.Result{
+				found("www.example.com"),
+				hidden,
+			},
+			want: nil,
+		},
+		{
+			name:     "response",
+			patterns: []string{`^10\.`},
+			results: []resolve.Result{
+				found("www.example.com", resolve.Response{Type: "A", Data: "10.0.0.1"}),
+			},
+			want: []string{"\a"},
+		},
+		{
+			name:     "hidden-response",
+			patterns: []string{`^10\.`},
+			results: []resolve.Result{
+				found("www.example.com", resolve.Response{Type: "A", Data: "10.0.0.1", Hide: true}),
+			},
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var patterns []*regexp.Regexp
+			for _, pat := range test.patterns {
+				patterns = append(patterns, regexp.MustCompile(pat))
+			}
+
+			in := make(chan resolve.Result, len(test.results))
+			for _, res := range test.results {
+				in <- res
+			}
+			close(in)
+
+			out := make(chan resolve.Result, len(test.results))
+
+			term := &bellTerminal{}
+			err := NewBell(term, true, patterns, false).Run(context.Background(), in, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(term.errors, test.want) {
+				t.Errorf("wrong output, want %q, got %q", test.want, term.errors)
+			}
+
+			if len(out) != len(test.results) {
+				t.Errorf("wrong number of results forwarded, want %d, got %d", len(test.results), len(out))
+			}
+		})
+	}
+}
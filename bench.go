@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// percentile returns the p-th percentile (0 <= p <= 100) of the sorted list.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+// BenchResult contains the measurements for one resolver.
+type BenchResult struct {
+	Resolver  string
+	Queries   int
+	Errors    int
+	Duration  time.Duration
+	Latencies []time.Duration
+}
+
+// QPS returns the number of successful queries per second.
+func (b BenchResult) QPS() float64 {
+	if b.Duration <= 0 {
+		return 0
+	}
+	return float64(b.Queries-b.Errors) / b.Duration.Seconds()
+}
+
+// ErrorRate returns the percentage of failed queries.
+func (b BenchResult) ErrorRate() float64 {
+	if b.Queries == 0 {
+		return 0
+	}
+	return float64(b.Errors) * 100 / float64(b.Queries)
+}
+
+// randomLabel returns a random label which is very unlikely to be cached by
+// the resolver.
+func randomLabel(rnd *rand.Rand) string {
+	return "taifun-bench-" + strconv.FormatUint(rnd.Uint64(), 36)
+}
+
+// benchResolver sends queries for random names in zone to the resolver with
+// the given number of parallel workers until the duration has passed or the
+// context is cancelled.
+func benchResolver(ctx context.Context, resolver, zone, requestType string, workers int, duration time.Duration) BenchResult {
+	res := BenchResult{Resolver: resolver}
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				name := randomLabel(rnd) + "." + zone + "."
+				request := resolve.Query(name, requestType, resolver)
+
+				mu.Lock()
+				res.Queries++
+				// NXDOMAIN is the expected answer for the random names
+				if request.Error != nil || (request.Failure && !request.NotFound) {
+					res.Errors++
+				} else {
+					res.Latencies = append(res.Latencies, request.RTT)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	res.Duration = time.Since(start)
+
+	sort.Slice(res.Latencies, func(i, j int) bool { return res.Latencies[i] < res.Latencies[j] })
+	return res
+}
+
+func newBenchCommand() *cobra.Command {
+	var (
+		resolversFile string
+		resolvers     []string
+		zone          string
+		requestType   string
+		workers       int
+		duration      time.Duration
+		configFile    string
+		profile       string
+	)
+
+	cmd := &cobra.Command{
+		Use:                   "bench [options]",
+		Short:                 "Measure the throughput, latency and error rate of resolvers",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := applyProfile(cmd, configFile, profile)
+			if err != nil {
+				return err
+			}
+
+			if resolversFile != "" {
+				list, _, err := readWeightedResolvers(resolversFile)
+				if err != nil {
+					return err
+				}
+				resolvers = append(resolvers, list...)
+			}
+
+			if len(resolvers) == 0 {
+				return errors.New("no resolvers specified")
+			}
+
+			if workers <= 0 {
+				return errors.New("invalid number of threads")
+			}
+
+			if _, ok := validRequestTypes[requestType]; !ok {
+				return fmt.Errorf("invalid request type %q", requestType)
+			}
+
+			zone = resolve.CleanHostname(zone)
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				term, cleanup, err := setupTerminal(ctx, g, "", "", false, logFormat{})
+				defer cleanup()
+				if err != nil {
+					return err
+				}
+
+				term.Printf("benchmarking %d resolvers for %v each, %d parallel queries for random names in %v\n\n",
+					len(resolvers), duration, workers, zone)
+				term.Printf("%39s  %8s  %7s  %8s  %8s  %8s  %8s", "resolver", "queries", "errors", "req/s", "p50", "p90", "p99")
+
+				for i, resolver := range resolvers {
+					if ctx.Err() != nil {
+						break
+					}
+
+					term.SetStatus([]string{"", fmt.Sprintf("[%d/%d] benchmarking %v", i+1, len(resolvers), resolver)})
+					res := benchResolver(ctx, resolver, zone, requestType, workers, duration)
+
+					term.Printf("%39s  %8d  %6.1f%%  %8.1f  %8s  %8s  %8s",
+						res.Resolver, res.Queries, res.ErrorRate(), res.QPS(),
+						report.FormatLatency(percentile(res.Latencies, 50)),
+						report.FormatLatency(percentile(res.Latencies, 90)),
+						report.FormatLatency(percentile(res.Latencies, 99)))
+				}
+
+				term.SetStatus(nil)
+				return nil
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&resolversFile, "resolvers-file", "", "read resolvers to benchmark from `filename`, one per line")
+	flags.StringArrayVar(&resolvers, "nameserver", nil, "benchmark resolver `server` (can be specified multiple times)")
+	flags.StringVar(&zone, "zone", "example.com", "send queries for random names in `zone`")
+	flags.StringVar(&requestType, "request-type", "A", "send queries of `type`")
+	flags.IntVarP(&workers, "threads", "t", 10, "send `n` queries in parallel")
+	flags.DurationVar(&duration, "duration", 10*time.Second, "benchmark each resolver for `duration`")
+	addProfileFlags(flags, &configFile, &profile)
+
+	return cmd
+}
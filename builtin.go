@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// builtinResolversName selects the built-in resolvers for --resolvers.
+const builtinResolversName = "builtin"
+
+// builtinResolvers are public resolvers operated by large providers, which
+// do not filter or rewrite answers and cope with high request rates.
+var builtinResolvers = []string{
+	// Cloudflare
+	"1.1.1.1",
+	"1.0.0.1",
+	// Google Public DNS
+	"8.8.8.8",
+	"8.8.4.4",
+	// Quad9 (unfiltered)
+	"9.9.9.10",
+	"149.112.112.10",
+	// Cisco OpenDNS
+	"208.67.222.222",
+	"208.67.220.220",
+	// AdGuard DNS (unfiltered)
+	"94.140.14.140",
+	"94.140.14.141",
+	// Control D (unfiltered)
+	"76.76.2.0",
+	"76.76.10.0",
+}
+
+// builtinResolversFile returns the path to the refreshed list of built-in
+// resolvers in the user's cache directory.
+func builtinResolversFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "taifun", "resolvers.txt")
+}
+
+// readBuiltinResolvers returns the list written by update-resolvers, or the
+// built-in list if it has not been refreshed yet.
+func readBuiltinResolvers() ([]string, error) {
+	filename := builtinResolversFile()
+	if filename == "" {
+		return builtinResolvers, nil
+	}
+
+	list, err := readResolvers(filename)
+	if os.IsNotExist(err) {
+		return builtinResolvers, nil
+	}
+
+	return list, err
+}
+
+// resolverSourceTimeout limits the time for downloading a list of resolvers.
+const resolverSourceTimeout = 30 * time.Second
+
+// loadResolverSource returns the resolvers listed in source, which is either
+// a file name or an http(s) URL. The format is the same as for --resolvers.
+func loadResolverSource(ctx context.Context, source string) ([]string, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return readResolvers(source)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolverSourceTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("download %v: unexpected status %v", source, res.Status)
+	}
+
+	list, err := parseResolvers(res.Body)
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("download %v: %v", source, err)
+	}
+
+	return list, res.Body.Close()
+}
+
+// mergeResolvers returns the servers from all lists, each only once, in the
+// order they appear first.
+func mergeResolvers(lists ...[]string) []string {
+	seen := make(map[string]struct{})
+	var servers []string
+	for _, list := range lists {
+		for _, server := range list {
+			if _, ok := seen[server]; ok {
+				continue
+			}
+			seen[server] = struct{}{}
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+func runUpdateResolvers(ctx context.Context, g *errgroup.Group, sources []string, output, name string, threads int) error {
+	// start with the built-in list, so servers dropped earlier get another
+	// chance, and the list written by the last update
+	current, err := readBuiltinResolvers()
+	if err != nil {
+		return err
+	}
+	lists := [][]string{builtinResolvers, current}
+
+	for _, source := range sources {
+		list, err := loadResolverSource(ctx, source)
+		if err != nil {
+			return err
+		}
+		lists = append(lists, list)
+	}
+	servers := mergeResolvers(lists...)
+
+	if output == "" {
+		output = builtinResolversFile()
+		if output == "" {
+			return errors.New("unable to find the cache directory, please specify a file with --output")
+		}
+	}
+
+	term, cleanup, err := setupTerminal(ctx, g, "", "", false, logFormat{})
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	term.Printf("checking %d resolvers", len(servers))
+	usable, report := checkResolvers(servers, name, []string{"A", "AAAA"}, threads, "drop")
+	for _, line := range report {
+		term.Print(line)
+	}
+
+	if len(usable) == 0 {
+		return errors.New("no resolver passed the checks, the list was not updated")
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(output, []byte(strings.Join(usable, "\n")+"\n"), 0644)
+	if err != nil {
+		return err
+	}
+
+	term.Printf("wrote %d of %d resolvers to %v", len(usable), len(servers), output)
+	return nil
+}
+
+func newUpdateResolversCommand() *cobra.Command {
+	var (
+		sources []string
+		output  string
+		name    string
+		threads int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update-resolvers [options]",
+		Short: "Update the list of resolvers used for --resolvers builtin",
+		Long: "Send test queries to the built-in resolvers, the resolvers stored by the last update " +
+			"and new resolvers read from --source (a file or an http(s) URL), and store the ones " +
+			"which pass in the cache directory, where --resolvers builtin finds them. Run it " +
+			"regularly to add new resolvers and drop resolvers which stopped working.",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", strings.Join(args, " "))
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				return runUpdateResolvers(ctx, g, sources, output, name, threads)
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVar(&sources, "source", nil, "also check the resolvers read from `source`, a file or an http(s) URL with one server per line (can be specified multiple times)")
+	flags.StringVarP(&output, "output", "o", "", "write the list to `filename` (default: resolvers.txt in the cache directory)")
+	flags.StringVar(&name, "check-name", "example.com", "resolve `name` and a random name below it to check the resolvers")
+	flags.IntVarP(&threads, "threads", "t", 4, "check `n` resolvers in parallel")
+
+	return cmd
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeResolvers(t *testing.T) {
+	var tests = []struct {
+		lists [][]string
+		want  []string
+	}{
+		{
+			lists: nil,
+			want:  nil,
+		},
+		{
+			lists: [][]string{{"1.1.1.1", "8.8.8.8"}},
+			want:  []string{"1.1.1.1", "8.8.8.8"},
+		},
+		{
+			lists: [][]string{
+				{"1.1.1.1", "8.8.8.8"},
+				{"8.8.8.8", "9.9.9.10", "1.1.1.1"},
+				{"192.0.2.1"},
+			},
+			want: []string{"1.1.1.1", "8.8.8.8", "9.9.9.10", "192.0.2.1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			got := mergeResolvers(test.lists...)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result, want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadResolverSource(t *testing.T) {
+	list := "# new resolvers\n192.0.2.1\n\n192.0.2.2:5353\n"
+	want := []string{"192.0.2.1", "192.0.2.2:5353"}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/resolvers.txt" {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, list)
+	}))
+	defer srv.Close()
+
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "resolvers.txt")
+	err = ioutil.WriteFile(filename, []byte(list), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		source string
+		want   []string
+		err    bool
+	}{
+		{source: filename, want: want},
+		{source: srv.URL + "/resolvers.txt", want: want},
+		{source: srv.URL + "/missing.txt", err: true},
+		{source: filepath.Join(tempdir, "missing.txt"), err: true},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			got, err := loadResolverSource(context.Background(), test.source)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result, want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
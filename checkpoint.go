@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/happal/taifun/report"
+	"github.com/spf13/pflag"
+)
+
+// newFilterState returns the state of the value filters configured in opts
+// after position values.
+func newFilterState(opts *Options, position int) *report.FilterState {
+	if opts == nil {
+		return nil
+	}
+
+	state := &report.FilterState{
+		Skip: opts.Skip + position,
+	}
+
+	if opts.Limit > 0 {
+		state.Limit = opts.Limit - position
+		if state.Limit < 0 {
+			state.Limit = 0
+		}
+	}
+
+	return state
+}
+
+// checkpointOptions returns the options and the host name template for
+// continuing the run from the checkpoint in filename. The log files are
+// written with the suffix _resumed.
+func checkpointOptions(filename string) (*Options, string, error) {
+	cp, err := report.ReadCheckpoint(filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cp.Complete {
+		return nil, "", errors.New("the run is already complete, nothing to do")
+	}
+
+	// the checkpoint contains the same information as the status in the log
+	data := &report.Data{
+		SchemaVersion: cp.SchemaVersion,
+		TotalRequests: cp.TotalRequests,
+		SentRequests:  cp.Position,
+		Position:      cp.Position,
+		Cancelled:     true,
+		Options:       cp.Options,
+	}
+
+	// continue with the recorded state of the filters if available
+	if cp.Filters != nil {
+		data.Position = 0
+		data.SentRequests = 0
+	}
+
+	opts, err := resumeOptions(data, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cp.Filters != nil {
+		if opts.Limit > 0 && cp.Filters.Limit == 0 {
+			return nil, "", errors.New("the limit has already been reached, nothing to do")
+		}
+
+		opts.Skip = cp.Filters.Skip
+		opts.Limit = cp.Filters.Limit
+	}
+
+	opts.Logdir = ""
+	opts.Logfile = strings.TrimSuffix(filename, ".checkpoint.json") + "_resumed"
+
+	return opts, cp.Hostname, nil
+}
+
+// checkpointFlags are the flags which can be used together with
+// --resume-checkpoint. The secrets and the addresses of the local servers are
+// not saved in the checkpoint, all other options are read from it.
+var checkpointFlags = map[string]bool{
+	"resume-checkpoint": true,
+	"config":            true,
+	"profile":           true,
+	"tsig-secret":       true,
+	"kafka-password":    true,
+	"notify-slack":      true,
+	"notify-discord":    true,
+	"notify-telegram":   true,
+	"control-addr":      true,
+	"control-token":     true,
+	"pprof-addr":        true,
+}
+
+// checkCheckpointFlags returns an error if an option which is read from the
+// checkpoint has been specified in flags.
+func checkCheckpointFlags(flags *pflag.FlagSet) error {
+	var err error
+	flags.Visit(func(flag *pflag.Flag) {
+		if err == nil && !checkpointFlags[flag.Name] {
+			err = fmt.Errorf("--%v cannot be used with --resume-checkpoint, the options are read from the checkpoint", flag.Name)
+		}
+	})
+	return err
+}
+
+// resumeCheckpoint returns the options and the host name template for
+// continuing the run from the checkpoint configured in opts. The secrets and
+// the addresses of the local servers are taken from opts.
+func resumeCheckpoint(opts *Options) (*Options, string, error) {
+	resumed, hostname, err := checkpointOptions(opts.resumeCheckpoint)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = resumeSecrets(resumed, opts.tsigSecret, opts.kafkaPassword)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resumed.notifySlack = opts.notifySlack
+	resumed.notifyDiscord = opts.notifyDiscord
+	resumed.notifyTelegram = opts.notifyTelegram
+	resumed.controlAddr = opts.controlAddr
+	resumed.controlToken = opts.controlToken
+	resumed.pprofAddr = opts.pprofAddr
+
+	return resumed, hostname, nil
+}
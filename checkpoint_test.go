@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happal/taifun/report"
+	"github.com/spf13/pflag"
+)
+
+func TestNewFilterState(t *testing.T) {
+	var tests = []struct {
+		opts     *Options
+		position int
+		want     *report.FilterState
+	}{
+		{nil, 10, nil},
+		{&Options{}, 0, &report.FilterState{}},
+		{&Options{}, 10, &report.FilterState{Skip: 10}},
+		{&Options{Skip: 5}, 10, &report.FilterState{Skip: 15}},
+		{&Options{Skip: 5, Limit: 20}, 10, &report.FilterState{Skip: 15, Limit: 10}},
+		{&Options{Limit: 20}, 20, &report.FilterState{Skip: 20}},
+		{&Options{Limit: 20}, 25, &report.FilterState{Skip: 25}},
+	}
+
+	for _, test := range tests {
+		state := newFilterState(test.opts, test.position)
+		if (state == nil) != (test.want == nil) || (state != nil && *state != *test.want) {
+			t.Errorf("newFilterState(%+v, %d): want %+v, got %+v", test.opts, test.position, test.want, state)
+		}
+	}
+}
+
+func TestCheckpointOptions(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	var tests = []struct {
+		name  string
+		opts  *Options
+		cp    report.Checkpoint
+		skip  int
+		limit int
+		err   bool
+	}{
+		{
+			name: "complete",
+			opts: &Options{Filename: "words.txt"},
+			cp:   report.Checkpoint{Position: 10, Complete: true},
+			err:  true,
+		},
+		{
+			name: "stopped",
+			opts: &Options{Filename: "words.txt", StopAfterFound: 3},
+			cp: report.Checkpoint{
+				Position: 10,
+				Stopped:  true,
+				Filters:  &report.FilterState{Skip: 10},
+			},
+			skip: 10,
+		},
+		{
+			name: "filters",
+			opts: &Options{Filename: "words.txt", Skip: 5, Limit: 100, Dedup: true},
+			cp: report.Checkpoint{
+				Position:  10,
+				Cancelled: true,
+				Filters:   &report.FilterState{Skip: 15, Limit: 90},
+			},
+			skip:  15,
+			limit: 90,
+		},
+		{
+			name: "limit-reached",
+			opts: &Options{Filename: "words.txt", Limit: 10},
+			cp: report.Checkpoint{
+				Position: 10,
+				Filters:  &report.FilterState{Skip: 10},
+			},
+			err: true,
+		},
+		{
+			// written by an older version
+			name: "no-filters",
+			opts: &Options{Filename: "words.txt", Skip: 5, Limit: 100},
+			cp: report.Checkpoint{
+				SchemaVersion: 3,
+				Position:      10,
+			},
+			skip:  15,
+			limit: 90,
+		},
+		{
+			name: "stdin",
+			opts: &Options{Filename: "-"},
+			cp:   report.Checkpoint{Position: 10, Filters: &report.FilterState{Skip: 10}},
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf, err := json.Marshal(test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.cp.Options = buf
+			test.cp.Hostname = "FUZZ.example.com"
+			filename := filepath.Join(tempdir, test.name+".checkpoint.json")
+			err = report.WriteCheckpoint(filename, test.cp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opts, hostname, err := checkpointOptions(filename)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error not returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if hostname != "FUZZ.example.com" {
+				t.Errorf("wrong host name %q", hostname)
+			}
+
+			if opts.Skip != test.skip || opts.Limit != test.limit {
+				t.Errorf("wrong filters, want skip %d and limit %d, got %d and %d", test.skip, test.limit, opts.Skip, opts.Limit)
+			}
+
+			if want := filepath.Join(tempdir, test.name+"_resumed"); opts.Logfile != want {
+				t.Errorf("wrong log file, want %v, got %v", want, opts.Logfile)
+			}
+		})
+	}
+}
+
+func TestResumeCheckpointTSIG(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	secret := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	data := recordedRun(t, "--tsig-name", "key.example.com.", "--tsig-secret", secret)
+
+	filename := filepath.Join(tempdir, "tsig.checkpoint.json")
+	err = report.WriteCheckpoint(filename, report.Checkpoint{
+		Hostname: "FUZZ.example.com",
+		Position: 10,
+		Filters:  &report.FilterState{Skip: 10},
+		Options:  data.Options,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the secret is not saved in the checkpoint
+	_, _, err = resumeCheckpoint(&Options{resumeCheckpoint: filename})
+	if err == nil {
+		t.Fatal("expected error for the missing secret not returned")
+	}
+
+	opts, hostname, err := resumeCheckpoint(&Options{
+		resumeCheckpoint: filename,
+		tsigSecret:       secret,
+		controlToken:     "token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hostname != "FUZZ.example.com" {
+		t.Errorf("wrong host name %q", hostname)
+	}
+
+	if opts.TSIGName != "key.example.com." || opts.tsigSecret != secret {
+		t.Errorf("wrong TSIG key, got name %q and secret %q", opts.TSIGName, opts.tsigSecret)
+	}
+
+	if opts.controlToken != "token" {
+		t.Errorf("control token not taken from the command line, got %q", opts.controlToken)
+	}
+
+	// the options for the resumed run must be valid
+	err = opts.valid()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckCheckpointFlags(t *testing.T) {
+	var tests = []struct {
+		args []string
+		err  bool
+	}{
+		{args: []string{"--resume-checkpoint", "x.checkpoint.json"}},
+		{args: []string{"--resume-checkpoint", "x.checkpoint.json", "--tsig-secret", "c2VjcmV0", "--kafka-password", "secret"}},
+		{args: []string{"--resume-checkpoint", "x.checkpoint.json", "--control-addr", "localhost:8053", "--control-token", "token"}},
+		{args: []string{"--resume-checkpoint", "x.checkpoint.json", "--requests-per-second", "10"}, err: true},
+		{args: []string{"--resume-checkpoint", "x.checkpoint.json", "--hide-empty"}, err: true},
+	}
+
+	for _, test := range tests {
+		opts := Options{Threads: 2}
+		flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+		addRunFlags(flags, &opts)
+		addDisplayFlags(flags, &opts)
+		flags.StringVar(&opts.resumeCheckpoint, "resume-checkpoint", "", "")
+
+		err := flags.Parse(test.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = checkCheckpointFlags(flags)
+		if test.err && err == nil {
+			t.Errorf("%v: expected error not returned", test.args)
+		}
+		if !test.err && err != nil {
+			t.Errorf("%v: unexpected error %v", test.args, err)
+		}
+	}
+}
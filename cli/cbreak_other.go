@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package cli
+
+import "errors"
+
+// SetCbreak is not supported on this platform.
+func SetCbreak(fd int) (restore func() error, err error) {
+	return nil, errors.New("reading key presses is not supported on this platform")
+}
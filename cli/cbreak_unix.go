@@ -0,0 +1,33 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package cli
+
+import "golang.org/x/sys/unix"
+
+// SetCbreak switches the terminal referenced by fd to cbreak mode: key presses
+// are available immediately and are not echoed, but signals (e.g. for Ctrl-C)
+// and the output processing work as before. The returned function restores
+// the previous state of the terminal.
+func SetCbreak(fd int) (restore func() error, err error) {
+	state, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	cbreak := *state
+	cbreak.Lflag &^= unix.ECHO | unix.ICANON
+	cbreak.Cc[unix.VMIN] = 1
+	cbreak.Cc[unix.VTIME] = 0
+
+	err = unix.IoctlSetTermios(fd, ioctlSetTermios, &cbreak)
+	if err != nil {
+		return nil, err
+	}
+
+	restore = func() error {
+		return unix.IoctlSetTermios(fd, ioctlSetTermios, state)
+	}
+
+	return restore, nil
+}
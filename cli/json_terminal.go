@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fd0/termstatus"
+)
+
+// Levels for log records.
+const (
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+// LogRecord is a message written by JSONTerminal.
+type LogRecord struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// WriteRecords writes one JSON record with level per non-empty line of msg
+// to w.
+func WriteRecords(w io.Writer, level string, msg string, fields map[string]string) error {
+	now := time.Now()
+	enc := json.NewEncoder(w)
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		err := enc.Encode(LogRecord{
+			Time:    now,
+			Level:   level,
+			Message: line,
+			Fields:  fields,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Printer prints messages with formatting.
+type Printer interface {
+	Printf(msg string, data ...interface{})
+}
+
+// LevelPrinter prints messages with a level and additional fields, e.g.
+// JSONTerminal.
+type LevelPrinter interface {
+	PrintLevel(level string, fields map[string]string, msg string)
+}
+
+// Logf prints a message with formatting on term. If term is a LevelPrinter,
+// the message is printed with level and fields, otherwise they are dropped.
+func Logf(term Printer, level string, fields map[string]string, msg string, data ...interface{}) {
+	if lp, ok := term.(LevelPrinter); ok {
+		lp.PrintLevel(level, fields, fmt.Sprintf(msg, data...))
+		return
+	}
+	term.Printf(msg, data...)
+}
+
+// Warnf prints a warning with formatting on term, see Logf.
+func Warnf(term Printer, msg string, data ...interface{}) {
+	Logf(term, LevelWarning, nil, msg, data...)
+}
+
+// JSONTerminal writes messages as JSON records to Writer (if set) in
+// addition to the terminal. If Stdout is set, the terminal also receives the
+// JSON records instead of the plain messages.
+type JSONTerminal struct {
+	*termstatus.Terminal
+	Writer io.Writer
+	Stdout bool
+
+	// Fields are added to all records, it may be nil.
+	Fields map[string]string
+
+	mu sync.Mutex
+}
+
+// Printf prints a messsage with formatting.
+func (jt *JSONTerminal) Printf(msg string, data ...interface{}) {
+	jt.Print(fmt.Sprintf(msg, data...))
+}
+
+// Print prints a message with the level info.
+func (jt *JSONTerminal) Print(msg string) {
+	jt.PrintLevel(LevelInfo, nil, msg)
+}
+
+// PrintLevel prints a message with level, the fields are added to the ones
+// configured for the terminal.
+func (jt *JSONTerminal) PrintLevel(level string, fields map[string]string, msg string) {
+	if len(fields) > 0 {
+		all := make(map[string]string, len(jt.Fields)+len(fields))
+		for k, v := range jt.Fields {
+			all[k] = v
+		}
+		for k, v := range fields {
+			all[k] = v
+		}
+		fields = all
+	} else {
+		fields = jt.Fields
+	}
+
+	if jt.Stdout {
+		var buf strings.Builder
+		_ = WriteRecords(&buf, level, msg, fields)
+		if buf.Len() > 0 {
+			jt.Terminal.Print(buf.String())
+		}
+	} else {
+		if !strings.HasSuffix(msg, "\n") {
+			msg += "\n"
+		}
+		jt.Terminal.Print(msg)
+	}
+
+	if jt.Writer != nil {
+		jt.mu.Lock()
+		_ = WriteRecords(jt.Writer, level, msg, fields)
+		jt.mu.Unlock()
+	}
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestWriteRecords(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteRecords(&buf, LevelWarning, "\n  first line  \n\nsecond line\n", map[string]string{"template": "FUZZ.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(&buf)
+	var records []LogRecord
+	for dec.More() {
+		var rec LogRecord
+		err := dec.Decode(&rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("wrong number of records, want 2, got %d", len(records))
+	}
+
+	want := []struct{ msg, level string }{
+		{"first line", LevelWarning},
+		{"second line", LevelWarning},
+	}
+	for i, rec := range records {
+		if rec.Message != want[i].msg || rec.Level != want[i].level {
+			t.Errorf("record %d: want %q (%v), got %q (%v)", i, want[i].msg, want[i].level, rec.Message, rec.Level)
+		}
+		if rec.Fields["template"] != "FUZZ.example.com" {
+			t.Errorf("record %d: fields are missing: %v", i, rec.Fields)
+		}
+		if rec.Time.IsZero() {
+			t.Errorf("record %d: time is not set", i)
+		}
+	}
+}
+
+// levelRecorder records the messages printed via Logf.
+type levelRecorder struct {
+	levels []string
+	fields []map[string]string
+	msgs   []string
+}
+
+func (r *levelRecorder) Printf(msg string, data ...interface{}) {
+	r.PrintLevel("", nil, fmt.Sprintf(msg, data...))
+}
+
+func (r *levelRecorder) PrintLevel(level string, fields map[string]string, msg string) {
+	r.levels = append(r.levels, level)
+	r.fields = append(r.fields, fields)
+	r.msgs = append(r.msgs, msg)
+}
+
+// plainPrinter records the messages printed via Printf.
+type plainPrinter struct {
+	msgs []string
+}
+
+func (p *plainPrinter) Printf(msg string, data ...interface{}) {
+	p.msgs = append(p.msgs, fmt.Sprintf(msg, data...))
+}
+
+func TestLogf(t *testing.T) {
+	rec := &levelRecorder{}
+	Logf(rec, LevelError, map[string]string{"host": "www.example.com"}, "lookup %v failed", "www")
+	Warnf(rec, "slow down")
+
+	if len(rec.msgs) != 2 {
+		t.Fatalf("wrong number of messages, want 2, got %d", len(rec.msgs))
+	}
+
+	if rec.levels[0] != LevelError || rec.msgs[0] != "lookup www failed" || rec.fields[0]["host"] != "www.example.com" {
+		t.Errorf("wrong first message %q (%v, %v)", rec.msgs[0], rec.levels[0], rec.fields[0])
+	}
+
+	if rec.levels[1] != LevelWarning || rec.msgs[1] != "slow down" {
+		t.Errorf("wrong second message %q (%v)", rec.msgs[1], rec.levels[1])
+	}
+
+	// terminals without levels just print the message
+	p := &plainPrinter{}
+	Warnf(p, "slow %s", "down")
+	if len(p.msgs) != 1 || p.msgs[0] != "slow down" {
+		t.Errorf("wrong messages %q", p.msgs)
+	}
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
 	"github.com/fd0/termstatus"
@@ -27,3 +28,16 @@ func (lt *LogTerminal) Print(msg string) {
 	lt.Terminal.Print(msg)
 	_, _ = lt.Writer.Write([]byte(msg))
 }
+
+// Secretf prints a message with formatting which must not end up in a log
+// file, e.g. a generated token. It is printed as an error on the terminal,
+// which LogTerminal and JSONTerminal do not copy to their writers.
+func Secretf(term Printer, msg string, data ...interface{}) {
+	if et, ok := term.(interface {
+		Errorf(string, ...interface{})
+	}); ok {
+		et.Errorf(msg, data...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, msg, data...)
+}
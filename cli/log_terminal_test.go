@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fd0/termstatus"
+)
+
+func TestSecretf(t *testing.T) {
+	var stdout, stderr, logfile bytes.Buffer
+	lt := &LogTerminal{
+		Terminal: termstatus.New(&stdout, &stderr, false),
+		Writer:   &logfile,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		lt.Run(ctx)
+		close(done)
+	}()
+
+	lt.Printf("listening on %v", "127.0.0.1:8080")
+	Secretf(lt, "token is %v\n", "secret")
+	cancel()
+	<-done
+
+	if strings.Contains(logfile.String(), "secret") {
+		t.Errorf("secret was written to the log file: %q", logfile.String())
+	}
+
+	if !strings.Contains(logfile.String(), "listening on 127.0.0.1:8080") {
+		t.Errorf("message is missing in the log file: %q", logfile.String())
+	}
+
+	if !strings.Contains(stderr.String(), "token is secret") {
+		t.Errorf("secret was not printed to stderr: %q", stderr.String())
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/report"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func runCluster(ctx context.Context, g *errgroup.Group, filename string, minSize int) error {
+	data, err := report.ReadData(filename)
+	if err != nil {
+		return fmt.Errorf("reading %v failed: %v", filename, err)
+	}
+
+	term, cleanup, err := setupTerminal(ctx, g, "", "", false, logFormat{})
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	summary := report.NewSummary()
+	for _, res := range data.Results {
+		if res.Hidden {
+			continue
+		}
+		summary.Add(res.ToResult())
+	}
+
+	term.Printf("hostname template: %v, %d results", data.Hostname, summary.Results)
+	report.PrintClusters(term, report.FindClusters(summary, minSize))
+	return nil
+}
+
+func newClusterCommand() *cobra.Command {
+	var minSize int
+
+	cmd := &cobra.Command{
+		Use:                   "cluster [options] LOGFILE.json",
+		Short:                 "Group the host names in a JSON log by shared addresses and CNAME targets",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("exactly one JSON log needs to be specified")
+			}
+
+			if minSize < 2 {
+				return errors.New("the minimum cluster size must be at least two")
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				return runCluster(ctx, g, args[0], minSize)
+			})
+		},
+	}
+
+	cmd.Flags().IntVar(&minSize, "min-size", 2, "only print clusters with at least `n` host names")
+
+	return cmd
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net"
+
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/resolve"
+)
+
+// Comparer sends the requests for each result to additional name servers and
+// flags results for which the answers differ, e.g. to detect split-horizon
+// DNS by comparing an internal and a public resolver.
+type Comparer struct {
+	Servers      []string
+	RequestTypes []string
+	Threads      int
+
+	// ClientSubnet is attached to the requests if set, see resolve.QueryOptions.
+	ClientSubnet *net.IPNet
+
+	// Throttle limits the requests together with the requests to the
+	// primary name servers if set: the requests for a result to each server
+	// count as one value.
+	Throttle *producer.Throttle
+}
+
+// NewComparer returns a new Comparer which sends the requests to servers,
+// using threads requests in parallel.
+func NewComparer(servers, requestTypes []string, threads int) *Comparer {
+	if threads < 1 {
+		threads = 1
+	}
+
+	return &Comparer{
+		Servers:      servers,
+		RequestTypes: requestTypes,
+		Threads:      threads,
+	}
+}
+
+// compare sends the requests for res to all servers. If the answers of a
+// server differ, its requests are added to res and the server is recorded
+// in res.Differing.
+func (c *Comparer) compare(ctx context.Context, res resolve.Result) resolve.Result {
+	primary := make(map[string]resolve.Request)
+	for _, req := range res.Requests {
+		primary[req.Type] = req
+	}
+
+	name := res.Hostname + "."
+	var added []resolve.Request
+	for _, server := range c.Servers {
+		if c.Throttle != nil && c.Throttle.Wait(ctx) != nil {
+			return res
+		}
+
+		var requests []resolve.Request
+		differing := false
+		for _, requestType := range c.RequestTypes {
+			req := resolve.QueryWith(ctx, name, requestType, server, resolve.QueryOptions{ClientSubnet: c.ClientSubnet})
+			requests = append(requests, req)
+
+			if p, ok := primary[requestType]; ok && p.Differs(req) {
+				differing = true
+			}
+		}
+
+		// the requests were cancelled, the answers cannot be compared
+		if ctx.Err() != nil {
+			return res
+		}
+
+		if differing {
+			res.Differing = append(res.Differing, server)
+			added = append(added, requests...)
+		}
+	}
+
+	if len(res.Differing) > 0 {
+		// record the primary server, too
+		for _, req := range res.Requests {
+			if req.Server != "" {
+				res.Differing = append([]string{req.Server}, res.Differing...)
+				break
+			}
+		}
+
+		// copy the list so the original result is not modified
+		res.Requests = append(append([]resolve.Request{}, res.Requests...), added...)
+	}
+
+	return res
+}
+
+// Run compares the answers for all results from in and sends them to out.
+func (c *Comparer) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forwardParallel(ctx, c.Threads, in, out, func(res resolve.Result) resolve.Result {
+		return c.compare(ctx, res)
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/happal/taifun/cli"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// compatOutputFormats maps the output flags of massdns to output formats.
+var compatOutputFormats = map[string]string{
+	"S": "massdns",
+	"J": "massdns-ndjson",
+}
+
+// compatOptions returns the options for resolving the domains in list with the
+// conventions of massdns and dnsx: the list contains complete host names (no
+// FUZZ template), the resolvers are read from a file.
+func compatOptions(list, resolvers string, types []string, output string, concurrency int) (*Options, error) {
+	format, ok := compatOutputFormats[strings.ToUpper(output)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output flag %q, valid: S (simple text), J (ndjson)", output)
+	}
+
+	if list == "" {
+		list = "-"
+	}
+
+	for i := range types {
+		types[i] = strings.ToUpper(types[i])
+	}
+
+	opts := &Options{
+		Filename:      list,
+		resolversFile: resolvers,
+		RequestTypes:  types,
+		Threads:       concurrency,
+		BufferSize:    100000,
+		RangeFormat:   "%d",
+		OutputFormat:  format,
+		Quiet:         true,
+		compat:        true,
+	}
+
+	return opts, nil
+}
+
+func newCompatCommand() *cobra.Command {
+	var (
+		list        string
+		resolvers   string
+		types       []string
+		output      string
+		concurrency int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "compat [options] [DOMAINS]",
+		Short: "Resolve a list of host names with the options of massdns and dnsx",
+		Long: "Resolve the host names read from the file DOMAINS (or --list, or stdin) " +
+			"with the name servers read from --resolvers, like massdns and dnsx do. No FUZZ " +
+			"template is needed, the results are printed in the output formats of massdns, " +
+			"so existing scripts and tools (e.g. puredns) keep working. Redirect stdout to " +
+			"write the results to a file.",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("unexpected arguments: %v", strings.Join(args[1:], " "))
+			}
+
+			if len(args) == 1 {
+				if list != "" {
+					return errors.New("the domains are specified twice, as an argument and with --list")
+				}
+				list = args[0]
+			}
+
+			opts, err := compatOptions(list, resolvers, types, output, concurrency)
+			if err != nil {
+				return err
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				return run(ctx, g, opts, []string{"FUZZ"})
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&list, "list", "l", "", "read the host names from `filename` (dnsx), \"-\" for stdin")
+	flags.StringVarP(&resolvers, "resolvers", "r", "", "send the queries to the name servers read from `filename`, one per line (if empty, the system resolver is used)")
+	flags.StringSliceVarP(&types, "type", "t", []string{"A"}, "request records of `type` (can be specified multiple times)")
+	flags.StringVarP(&output, "output", "o", "S", "print results in the massdns format `flag`: S (simple text) or J (ndjson)")
+	flags.IntVarP(&concurrency, "hashmap-size", "s", 10, "resolve `n` host names in parallel")
+
+	return cmd
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// defaultProfile is used when no profile is selected on the command line.
+const defaultProfile = "default"
+
+// Config is read from the configuration file. Each profile maps the long
+// names of command line flags to their values, e.g.:
+//
+//	profiles:
+//	  default:
+//	    threads: 10
+//	  slow:
+//	    resolvers: /home/user/resolvers.txt
+//	    requests-per-second: 5
+//	    hide-network:
+//	      - 10.0.0.0/8
+//	      - 192.168.0.0/16
+type Config struct {
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// defaultConfigFile returns the path to the configuration file,
+// ~/.config/taifun/config.yaml on all platforms.
+func defaultConfigFile() string {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".config", "taifun", "config.yaml")
+}
+
+// readConfig reads the configuration from filename.
+func readConfig(filename string) (*Config, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	err = yaml.UnmarshalStrict(buf, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %v failed: %v", filename, err)
+	}
+
+	return &cfg, nil
+}
+
+// configValues returns the strings to pass to the flag for a value from the
+// configuration file.
+func configValues(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			switch item.(type) {
+			case []interface{}, map[interface{}]interface{}:
+				return nil, fmt.Errorf("nested value %v not supported", item)
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+		return values, nil
+	case map[interface{}]interface{}:
+		return nil, fmt.Errorf("nested value %v not supported", v)
+	case nil:
+		return nil, nil
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// knownOption returns true if cmd or any of its subcommands has the flag.
+func knownOption(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil {
+		return true
+	}
+
+	for _, sub := range cmd.Commands() {
+		if knownOption(sub, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyProfile sets the flags for cmd from the profile in the configuration
+// file. Flags set on the command line take precedence. Options which only
+// exist for other commands are ignored, so profiles can be shared. If profile
+// is empty, the profile "default" is used if it exists. A missing
+// configuration file is only an error if it has been specified explicitly.
+func applyProfile(cmd *cobra.Command, filename, profile string) error {
+	flags := cmd.Flags()
+	explicit := flags.Changed("config")
+	if filename == "" {
+		return nil
+	}
+
+	cfg, err := readConfig(filename)
+	if os.IsNotExist(err) && !explicit && profile == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	name := profile
+	if name == "" {
+		name = defaultProfile
+	}
+
+	settings, ok := cfg.Profiles[name]
+	if !ok {
+		if profile == "" {
+			return nil
+		}
+		return fmt.Errorf("profile %q not found in %v", profile, filename)
+	}
+
+	// apply the settings in a stable order, so errors are reproducible
+	var keys []string
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "config" || key == "profile" || !knownOption(cmd.Root(), key) {
+			return fmt.Errorf("profile %q: unknown option %q", name, key)
+		}
+
+		flag := flags.Lookup(key)
+		if flag == nil {
+			continue
+		}
+
+		// the command line overrides the profile
+		if flag.Changed {
+			continue
+		}
+
+		values, err := configValues(settings[key])
+		if err != nil {
+			return fmt.Errorf("profile %q: option %q: %v", name, key, err)
+		}
+
+		for _, value := range values {
+			err := flags.Set(key, value)
+			if err != nil {
+				return fmt.Errorf("profile %q: invalid value %q for option %q: %v", name, value, key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// addProfileFlags adds the flags for selecting a profile from the
+// configuration file.
+func addProfileFlags(flags *pflag.FlagSet, filename, profile *string) {
+	flags.StringVar(filename, "config", defaultConfigFile(), "read profiles from `filename`")
+	flags.StringVar(profile, "profile", "", "use the options from profile `name` in the configuration file (default: the profile \"default\", if present)")
+}
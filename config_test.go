@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+const testConfig = `
+profiles:
+  default:
+    threads: 10
+    nameserver: 192.0.2.1
+  slow:
+    threads: 1
+    hide-network:
+      - 10.0.0.0/8
+      - 192.168.0.0/16
+  other:
+    only-other: true
+  invalid:
+    foo: bar
+`
+
+// profileCommand returns a command with some flags and the profile flags, and
+// a subcommand with a flag which only it has.
+func profileCommand(threads *int, nameserver *string, networks *[]string) *cobra.Command {
+	root := &cobra.Command{Use: "taifun"}
+
+	var configFile, profile string
+	flags := root.Flags()
+	flags.IntVarP(threads, "threads", "t", 2, "")
+	flags.StringVar(nameserver, "nameserver", "", "")
+	flags.StringArrayVar(networks, "hide-network", nil, "")
+	addProfileFlags(flags, &configFile, &profile)
+
+	root.RunE = func(cmd *cobra.Command, args []string) error {
+		return applyProfile(cmd, configFile, profile)
+	}
+
+	var onlyOther bool
+	sub := &cobra.Command{Use: "other", RunE: func(*cobra.Command, []string) error { return nil }}
+	sub.Flags().BoolVar(&onlyOther, "only-other", false, "")
+	root.AddCommand(sub)
+
+	return root
+}
+
+func TestApplyProfile(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "config.yaml")
+	err = ioutil.WriteFile(filename, []byte(testConfig), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		args       []string
+		threads    int
+		nameserver string
+		networks   []string
+		err        bool
+	}{
+		// the default profile is used if none is selected
+		{args: []string{}, threads: 10, nameserver: "192.0.2.1"},
+		// the command line overrides the profile
+		{args: []string{"--threads", "3"}, threads: 3, nameserver: "192.0.2.1"},
+		{args: []string{"-t", "3", "--nameserver", "192.0.2.2"}, threads: 3, nameserver: "192.0.2.2"},
+		// a selected profile replaces the default profile
+		{args: []string{"--profile", "slow"}, threads: 1, networks: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+		{args: []string{"--profile", "slow", "--threads", "5", "--hide-network", "127.0.0.0/8"}, threads: 5, networks: []string{"127.0.0.0/8"}},
+		// options of other commands are ignored
+		{args: []string{"--profile", "other"}, threads: 2},
+		{args: []string{"--profile", "invalid"}, err: true},
+		{args: []string{"--profile", "missing"}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			var (
+				threads    int
+				nameserver string
+				networks   []string
+			)
+
+			cmd := profileCommand(&threads, &nameserver, &networks)
+			cmd.SetArgs(append([]string{"--config", filename}, test.args...))
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+
+			err := cmd.Execute()
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected error not returned for %v", test.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if threads != test.threads {
+				t.Errorf("wrong threads, want %d, got %d", test.threads, threads)
+			}
+
+			if nameserver != test.nameserver {
+				t.Errorf("wrong nameserver, want %q, got %q", test.nameserver, nameserver)
+			}
+
+			if !reflect.DeepEqual(networks, test.networks) {
+				t.Errorf("wrong networks, want %q, got %q", test.networks, networks)
+			}
+		})
+	}
+}
+
+func TestApplyProfileMissingFile(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "config.yaml")
+
+	var (
+		threads    int
+		nameserver string
+		networks   []string
+	)
+
+	// the default file may be missing
+	cmd := profileCommand(&threads, &nameserver, &networks)
+	cmd.SetArgs([]string{})
+	err = cmd.Flags().Set("config", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// like the default value, the file was not specified on the command line
+	cmd.Flags().Lookup("config").Changed = false
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("missing default file returned error: %v", err)
+	}
+
+	// an explicitly specified file must exist
+	cmd = profileCommand(&threads, &nameserver, &networks)
+	cmd.SetArgs([]string{"--config", filename})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	if err := cmd.Execute(); err == nil {
+		t.Errorf("missing file specified on the command line did not return an error")
+	}
+}
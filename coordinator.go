@@ -0,0 +1,668 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
+)
+
+// Job describes the work distributed by the coordinator.
+type Job struct {
+	Hostname      string   `json:"hostname"`
+	RequestTypes  []string `json:"request_types"`
+	ClientSubnets []string `json:"client_subnets,omitempty"`
+	FollowCNAMEs  bool     `json:"follow_cnames,omitempty"`
+	Jitter        string   `json:"jitter,omitempty"`
+
+	ParallelRequests int `json:"parallel_requests,omitempty"`
+}
+
+// Batch is a set of items handed out to a worker. If no items are available
+// at the moment and Done is not set, the worker should ask again later.
+type Batch struct {
+	ID    int      `json:"id"`
+	Items []string `json:"items,omitempty"`
+	Done  bool     `json:"done,omitempty"`
+}
+
+// BatchResults is sent by a worker after resolving all items of a batch.
+type BatchResults struct {
+	ID      int          `json:"id"`
+	Results []WireResult `json:"results"`
+}
+
+// WireResult is a resolve.Result which can be encoded as JSON.
+type WireResult struct {
+	Item     string
+	Hostname string
+	Requests []WireRequest
+
+	DifferingSubnets bool
+}
+
+// WireRequest is a resolve.Request which can be encoded as JSON. The error
+// is sent as a string.
+type WireRequest struct {
+	resolve.Request
+	Error string
+}
+
+// NewWireResult converts res so it can be sent to the coordinator.
+func NewWireResult(res resolve.Result) WireResult {
+	w := WireResult{
+		Item:             res.Item,
+		Hostname:         res.Hostname,
+		DifferingSubnets: res.DifferingSubnets,
+	}
+
+	for _, req := range res.Requests {
+		wr := WireRequest{Request: req}
+		if req.Error != nil {
+			wr.Error = req.Error.Error()
+		}
+		wr.Request.Error = nil
+		w.Requests = append(w.Requests, wr)
+	}
+
+	return w
+}
+
+// Result returns the resolve.Result.
+func (w WireResult) Result() resolve.Result {
+	res := resolve.Result{
+		Item:             w.Item,
+		Hostname:         w.Hostname,
+		DifferingSubnets: w.DifferingSubnets,
+	}
+
+	for _, wr := range w.Requests {
+		req := wr.Request
+		if wr.Error != "" {
+			req.Error = errors.New(wr.Error)
+		}
+		res.Requests = append(res.Requests, req)
+	}
+
+	return res
+}
+
+// lease is a batch handed out to a worker.
+type lease struct {
+	batch      Batch
+	worker     string
+	expires    time.Time
+	submitting bool // set while the results are processed
+}
+
+// workerStats are collected for each worker.
+type workerStats struct {
+	Batches  int
+	Results  int
+	LastSeen time.Time
+	Finished bool // the worker has been told that there is no more work
+}
+
+const (
+	// batchWait is the time to wait for more items before handing out an
+	// incomplete batch.
+	batchWait = 500 * time.Millisecond
+
+	// pollTimeout is the time a request for a new batch waits until it is
+	// answered with an empty batch.
+	pollTimeout = 5 * time.Second
+
+	// lingerTimeout is the time the coordinator waits after all work is done
+	// so idle workers learn that they can exit.
+	lingerTimeout = 10 * time.Second
+)
+
+// Coordinator hands out the items in batches to workers via HTTP and
+// collects their results. If a worker does not return the results for a
+// batch in time, the batch is handed out again.
+type Coordinator struct {
+	term         cli.Terminal
+	quiet        bool
+	job          Job
+	token        string
+	batchSize    int
+	leaseTimeout time.Duration
+
+	batches chan Batch
+
+	mu        sync.Mutex
+	ctx       context.Context
+	out       chan<- resolve.Result
+	leases    map[int]*lease
+	completed map[int]int // sizes of the batches processed out of order
+	nextDone  int         // ID of the next batch which advances position
+	position  int         // number of items processed without a gap
+	retry     []int       // IDs of expired leases
+	workers   map[string]*workerStats
+	inputDone bool
+	closed    bool
+	done      chan struct{} // closed when all batches have been processed
+	inflight  sync.WaitGroup
+}
+
+// NewCoordinator returns a new coordinator for the job. Workers must send
+// token with each request.
+func NewCoordinator(term cli.Terminal, job Job, token string, batchSize int, leaseTimeout time.Duration) *Coordinator {
+	return &Coordinator{
+		term:         term,
+		job:          job,
+		token:        token,
+		batchSize:    batchSize,
+		leaseTimeout: leaseTimeout,
+		batches:      make(chan Batch),
+		leases:       make(map[int]*lease),
+		completed:    make(map[int]int),
+		nextDone:     1,
+		workers:      make(map[string]*workerStats),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start serves the API for the workers on addr. The server runs until the
+// program exits.
+func (c *Coordinator) Start(addr string) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		_ = http.Serve(ln, c.Handler())
+	}()
+
+	return ln.Addr(), nil
+}
+
+// Handler returns the HTTP handler for the workers, all requests must carry
+// the token. The following endpoints are available:
+//
+//	GET  /job               the host name template and request types
+//	POST /batch?worker=NAME request the next batch of items
+//	POST /results?worker=NAME
+//	                        submit the results for a batch
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/job", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed, use GET"})
+			return
+		}
+		writeJSON(w, http.StatusOK, c.job)
+	})
+
+	mux.HandleFunc("/batch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed, use POST"})
+			return
+		}
+		writeJSON(w, http.StatusOK, c.nextBatch(r.Context(), workerName(r)))
+	})
+
+	mux.HandleFunc("/results", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed, use POST"})
+			return
+		}
+
+		var res BatchResults
+		err := json.NewDecoder(r.Body).Decode(&res)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		err = c.submit(workerName(r), res)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{})
+	})
+
+	return requireToken(c.token, mux)
+}
+
+// workerName returns the name of the worker sending the request.
+func workerName(r *http.Request) string {
+	if name := r.FormValue("worker"); name != "" {
+		return name
+	}
+	return r.RemoteAddr
+}
+
+// seen records that the worker has contacted the coordinator. The caller
+// must hold c.mu.
+func (c *Coordinator) seen(worker string) *workerStats {
+	stats, ok := c.workers[worker]
+	if !ok {
+		stats = &workerStats{}
+		c.workers[worker] = stats
+		if !c.quiet {
+			c.term.Printf("worker %v connected", worker)
+		}
+	}
+	stats.LastSeen = time.Now()
+	return stats
+}
+
+// handOut leases the batch to worker. The caller must hold c.mu.
+func (c *Coordinator) handOut(worker string, l *lease) Batch {
+	l.worker = worker
+	l.expires = time.Now().Add(c.leaseTimeout)
+	c.seen(worker).Batches++
+	return l.batch
+}
+
+// nextBatch returns the next batch for worker.
+func (c *Coordinator) nextBatch(ctx context.Context, worker string) Batch {
+	c.mu.Lock()
+	c.seen(worker)
+
+	// hand out expired batches first
+	for len(c.retry) > 0 {
+		id := c.retry[0]
+		c.retry = c.retry[1:]
+
+		l, ok := c.leases[id]
+		if !ok || l.submitting {
+			continue
+		}
+
+		batch := c.handOut(worker, l)
+		c.mu.Unlock()
+		return batch
+	}
+	c.mu.Unlock()
+
+	select {
+	case batch := <-c.batches:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		l := &lease{batch: batch}
+		c.leases[batch.ID] = l
+		return c.handOut(worker, l)
+
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.seen(worker).Finished = true
+		return Batch{Done: true}
+
+	case <-time.After(pollTimeout):
+	case <-ctx.Done():
+	}
+
+	return Batch{}
+}
+
+// submit passes the results for a batch on. Results for unknown batches
+// (e.g. when the batch has been processed by another worker in the
+// meantime) are ignored. Once started, all results are passed on even if the
+// worker disconnects, since the results sent so far cannot be taken back.
+func (c *Coordinator) submit(worker string, res BatchResults) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errors.New("coordinator is shutting down")
+	}
+
+	stats := c.seen(worker)
+	l, ok := c.leases[res.ID]
+	if !ok || l.submitting {
+		c.mu.Unlock()
+		return nil
+	}
+
+	l.submitting = true
+	c.inflight.Add(1)
+	out := c.out
+	runCtx := c.ctx
+	c.mu.Unlock()
+
+	defer c.inflight.Done()
+
+	for _, wr := range res.Results {
+		select {
+		case out <- wr.Result():
+		case <-runCtx.Done():
+			return runCtx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats.Results += len(res.Results)
+	delete(c.leases, res.ID)
+	c.advance(res.ID, len(l.batch.Items))
+	c.checkDone()
+
+	return nil
+}
+
+// advance records that the batch with id has been processed and moves the
+// position past all batches processed without a gap. The caller must hold
+// c.mu.
+func (c *Coordinator) advance(id, size int) {
+	c.completed[id] = size
+	for {
+		n, ok := c.completed[c.nextDone]
+		if !ok {
+			return
+		}
+		delete(c.completed, c.nextDone)
+		c.position += n
+		c.nextDone++
+	}
+}
+
+// Position returns the number of items from the start of the input which
+// have been processed. Items of batches processed while an earlier batch is
+// still outstanding are not included, so a resumed run skips only items
+// which have been processed.
+func (c *Coordinator) Position() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.position
+}
+
+// checkDone closes c.done when all batches have been processed. The caller
+// must hold c.mu.
+func (c *Coordinator) checkDone() {
+	if !c.inputDone || len(c.leases) > 0 {
+		return
+	}
+
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
+
+// expireLeases schedules batches which have not been returned in time to be
+// handed out again.
+func (c *Coordinator) expireLeases() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for id, l := range c.leases {
+		if l.submitting || l.expires.IsZero() || now.Before(l.expires) {
+			continue
+		}
+
+		if !c.quiet {
+			c.term.Printf("worker %v did not return batch %d in time, handing it out again", l.worker, id)
+		}
+
+		// the lease is renewed when the batch is handed out again
+		l.expires = time.Time{}
+		c.retry = append(c.retry, id)
+	}
+}
+
+// finished returns true if all workers have been told that there is no more
+// work. Workers which have not asked for work recently are ignored.
+func (c *Coordinator) finished() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, stats := range c.workers {
+		if !stats.Finished && time.Since(stats.LastSeen) < 2*pollTimeout {
+			return false
+		}
+	}
+	return true
+}
+
+// Run collects the items from in into batches for the workers and sends the
+// results to out. Run returns when all items have been processed or the
+// context is cancelled, out is closed afterwards.
+func (c *Coordinator) Run(ctx context.Context, in <-chan string, out chan<- resolve.Result) error {
+	c.mu.Lock()
+	c.ctx = ctx
+	c.out = out
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+
+		c.inflight.Wait()
+		close(out)
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var items []string
+	var flush <-chan time.Time
+	var ready bool
+
+	// the IDs are assigned here so they follow the order of the input
+	nextID := 1
+
+	for in != nil || len(items) > 0 {
+		// only send complete batches, unless the input is done or no new
+		// items arrived for some time
+		var sendCh chan<- Batch
+		if len(items) >= c.batchSize || (len(items) > 0 && (in == nil || ready)) {
+			sendCh = c.batches
+		}
+
+		// do not collect more items than fit into a batch
+		recvCh := in
+		if len(items) >= c.batchSize {
+			recvCh = nil
+		}
+
+		select {
+		case s, ok := <-recvCh:
+			if !ok {
+				in = nil
+				continue
+			}
+
+			if len(items) == 0 {
+				flush = time.After(batchWait)
+			}
+			items = append(items, s)
+
+		case sendCh <- Batch{ID: nextID, Items: items}:
+			nextID++
+			items = nil
+			flush = nil
+			ready = false
+
+		case <-flush:
+			flush = nil
+			ready = true
+
+		case <-ticker.C:
+			c.expireLeases()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	c.mu.Lock()
+	c.inputDone = true
+	c.checkDone()
+	c.mu.Unlock()
+
+	for {
+		select {
+		case <-c.done:
+			// give idle workers the chance to learn that there's no more work
+			linger := time.After(lingerTimeout)
+			for !c.finished() {
+				select {
+				case <-linger:
+					return nil
+				case <-time.After(100 * time.Millisecond):
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			return nil
+
+		case <-ticker.C:
+			c.expireLeases()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Report returns a summary of the work done by each worker.
+func (c *Coordinator) Report() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var names []string
+	for name := range c.workers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		stats := c.workers[name]
+		lines = append(lines, fmt.Sprintf("  %v: %d batches, %d results", name, stats.Batches, stats.Results))
+	}
+	return lines
+}
+
+// startCoordinator hands out the values to workers and returns the channel
+// with the results received from them.
+func startCoordinator(ctx context.Context, g *errgroup.Group, term cli.Terminal, opts *Options, hostname string, in <-chan string) (<-chan resolve.Result, *Coordinator, error) {
+	job := Job{
+		Hostname:      hostname,
+		RequestTypes:  opts.RequestTypes,
+		ClientSubnets: opts.ClientSubnets,
+		FollowCNAMEs:  opts.FollowCNAMEs,
+		Jitter:        opts.Jitter,
+
+		ParallelRequests: opts.ParallelRequests,
+	}
+
+	token := opts.serveToken
+	if token == "" {
+		var err error
+		token, err = newToken()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	coord := NewCoordinator(term, job, token, opts.BatchSize, opts.LeaseTimeout)
+	coord.quiet = opts.quiet()
+
+	addr, err := coord.Start(opts.serveAddr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to start coordinator: %v", err)
+	}
+
+	if !opts.quiet() {
+		term.Printf("waiting for workers on http://%v\n", addr)
+	}
+
+	// the workers cannot connect without the generated token, it is not
+	// written to the log file
+	if opts.serveToken == "" {
+		cli.Secretf(term, "start workers with: taifun worker --token %v http://%v\n", token, addr)
+	}
+
+	out := make(chan resolve.Result)
+	g.Go(func() error {
+		return coord.Run(ctx, in, out)
+	})
+
+	return out, coord, nil
+}
+
+// serveIgnoredFlags returns the flags for sending the requests which were set
+// in flags. The coordinator does not send the requests itself and the
+// workers use their own settings, so the flags would have no effect.
+func serveIgnoredFlags(flags *pflag.FlagSet) []string {
+	var names []string
+	for _, name := range []string{"client-subnet", "tsig-name", "tsig-algorithm", "tsig-secret", "prune-nxdomain", "ipv4", "ipv6", "interface", "cache-size"} {
+		if flags.Changed(name) {
+			names = append(names, "--"+name)
+		}
+	}
+
+	return names
+}
+
+func newServeCommand() *cobra.Command {
+	var opts Options
+
+	cmd := &cobra.Command{
+		Use:   "serve [options] HOSTNAME",
+		Short: "Hand out the values to workers on other hosts and collect the results",
+		Long: "Serve the values in batches to workers (started with 'taifun worker') via HTTP, " +
+			"collect their results, display them and write a single log. " +
+			"The workers send the DNS requests, so the number of threads and the name servers are configured there.",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := applyProfile(cmd, opts.configFile, opts.Profile)
+			if err != nil {
+				return err
+			}
+
+			if ignored := serveIgnoredFlags(cmd.Flags()); len(ignored) > 0 {
+				return fmt.Errorf("%s cannot be used with serve, the workers send the requests", strings.Join(ignored, ", "))
+			}
+
+			if opts.BatchSize <= 0 {
+				return errors.New("invalid batch size")
+			}
+
+			if opts.LeaseTimeout <= 0 {
+				return errors.New("invalid lease timeout")
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				return run(ctx, g, &opts, args)
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	addProfileFlags(flags, &opts.configFile, &opts.Profile)
+	opts.Threads = 1
+	flags.StringVar(&opts.serveAddr, "listen", "localhost:8054", "listen for workers on `addr`")
+	flags.StringVar(&opts.serveToken, "token", "", "require workers to authenticate with `token` (default: generate a random token)")
+	flags.IntVar(&opts.BatchSize, "batch-size", 1000, "hand out `n` values to a worker at once")
+	flags.DurationVar(&opts.LeaseTimeout, "lease-timeout", 5*time.Minute, "hand out a batch again if a worker has not returned the results after `duration`")
+	addRunFlags(flags, &opts)
+	addDisplayFlags(flags, &opts)
+
+	return cmd
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// startTestCoordinator runs a coordinator for items, the results are
+// collected by the returned function, which waits until Run has returned.
+func startTestCoordinator(ctx context.Context, t testing.TB, items []string, batchSize int, leaseTimeout time.Duration) (*Coordinator, func() []resolve.Result) {
+	coord := NewCoordinator(&testTerminal{}, Job{Hostname: "FUZZ.example.com.", RequestTypes: []string{"A"}}, "secret", batchSize, leaseTimeout)
+	coord.quiet = true
+
+	in := make(chan string, len(items))
+	for _, item := range items {
+		in <- item
+	}
+	close(in)
+
+	out := make(chan resolve.Result)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- coord.Run(ctx, in, out)
+	}()
+
+	var results []resolve.Result
+	done := make(chan struct{})
+	go func() {
+		for res := range out {
+			results = append(results, res)
+		}
+		close(done)
+	}()
+
+	return coord, func() []resolve.Result {
+		err := <-errCh
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-done
+		return results
+	}
+}
+
+// batchResults returns the results a worker sends for batch.
+func batchResults(batch Batch) BatchResults {
+	res := BatchResults{ID: batch.ID}
+	for _, item := range batch.Items {
+		res.Results = append(res.Results, NewWireResult(resolve.Result{Item: item, Hostname: item + ".example.com"}))
+	}
+	return res
+}
+
+func TestCoordinatorToken(t *testing.T) {
+	coord := NewCoordinator(&testTerminal{}, Job{Hostname: "FUZZ.example.com"}, "secret", 10, time.Minute)
+	srv := httptest.NewServer(coord.Handler())
+	defer srv.Close()
+
+	var tests = []struct {
+		auth   string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"secret", http.StatusUnauthorized},
+		{"Basic secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.auth, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/job", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.auth != "" {
+				req.Header.Set("Authorization", test.auth)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+
+			if res.StatusCode != test.status {
+				t.Errorf("wrong status, want %v, got %v", test.status, res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestCoordinatorPosition(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var items []string
+	for i := 0; i < 8; i++ {
+		items = append(items, fmt.Sprintf("item%d", i))
+	}
+
+	coord, wait := startTestCoordinator(ctx, t, items, 3, time.Minute)
+
+	var batches []Batch
+	for len(batches) < 3 {
+		batch := coord.nextBatch(ctx, "w")
+		if len(batch.Items) == 0 {
+			continue
+		}
+		batches = append(batches, batch)
+	}
+
+	for i, batch := range batches {
+		if batch.ID != i+1 {
+			t.Fatalf("batch %d has wrong ID %d", i, batch.ID)
+		}
+	}
+
+	// the batches are returned out of order, the position only moves when
+	// there is no gap
+	var tests = []struct {
+		batch    int
+		position int
+	}{
+		{1, 0},
+		{2, 0},
+		{0, 8},
+	}
+
+	for _, test := range tests {
+		err := coord.submit("w", batchResults(batches[test.batch]))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pos := coord.Position(); pos != test.position {
+			t.Errorf("after submitting batch %d: wrong position, want %d, got %d", batches[test.batch].ID, test.position, pos)
+		}
+	}
+
+	if batch := coord.nextBatch(ctx, "w"); !batch.Done {
+		t.Errorf("coordinator did not send done, got %v", batch)
+	}
+
+	results := wait()
+	if len(results) != len(items) {
+		t.Errorf("wrong number of results, want %d, got %d", len(items), len(results))
+	}
+}
+
+func TestCoordinatorReLease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	coord, wait := startTestCoordinator(ctx, t, []string{"a", "b", "c"}, 3, time.Millisecond)
+
+	var first Batch
+	for len(first.Items) == 0 {
+		first = coord.nextBatch(ctx, "slow")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	coord.expireLeases()
+
+	second := coord.nextBatch(ctx, "fast")
+	if second.ID != first.ID {
+		t.Fatalf("expired batch was not handed out again, got batch %d", second.ID)
+	}
+
+	// both workers return the results, only the first one is used
+	for _, worker := range []string{"slow", "fast"} {
+		err := coord.submit(worker, batchResults(first))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pos := coord.Position(); pos != 3 {
+			t.Errorf("wrong position, want 3, got %d", pos)
+		}
+	}
+
+	for _, worker := range []string{"slow", "fast"} {
+		if batch := coord.nextBatch(ctx, worker); !batch.Done {
+			t.Errorf("coordinator did not send done to %v, got %v", worker, batch)
+		}
+	}
+
+	results := wait()
+	if len(results) != 3 {
+		t.Errorf("wrong number of results, want 3, got %d", len(results))
+	}
+
+	report := coord.Report()
+	want := []string{"  fast: 1 batches, 0 results", "  slow: 1 batches, 3 results"}
+	if fmt.Sprint(report) != fmt.Sprint(want) {
+		t.Errorf("wrong report, want %q, got %q", want, report)
+	}
+}
+
+func TestServeIgnoredFlags(t *testing.T) {
+	var tests = []struct {
+		args []string
+		want []string
+	}{
+		{[]string{}, nil},
+		// the options for the results are used by the coordinator
+		{[]string{"--request-types", "A", "--ecs", "192.0.2.0/24", "--follow-cnames"}, nil},
+		{[]string{"--client-subnet", "192.0.2.0/24"}, []string{"--client-subnet"}},
+		{[]string{"--tsig-name", "key", "--tsig-secret", "c2VjcmV0"}, []string{"--tsig-name", "--tsig-secret"}},
+		{[]string{"-4", "--interface", "wg0", "--prune-nxdomain"}, []string{"--prune-nxdomain", "--ipv4", "--interface"}},
+		// the cache is disabled explicitly, this has no effect either
+		{[]string{"--cache-size", "0"}, []string{"--cache-size"}},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			flags := newServeCommand().Flags()
+			err := flags.Parse(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := serveIgnoredFlags(flags)
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("wrong flags, want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
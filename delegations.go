@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+)
+
+// formatDelegation returns a line describing a delegation: the host name
+// followed by the name servers, separated by spaces.
+func formatDelegation(hostname string, nameservers []string) string {
+	return hostname + " " + strings.Join(nameservers, " ")
+}
+
+// DelegationWriter writes all shown potential delegations to a file.
+type DelegationWriter struct {
+	filename string
+}
+
+// NewDelegationWriter returns a new writer for filename.
+func NewDelegationWriter(filename string) *DelegationWriter {
+	return &DelegationWriter{filename: filename}
+}
+
+// Run reads results from in and forwards them to out, writing potential
+// delegations to the file on the way. When in is closed or the context is
+// cancelled, the file is closed and out is closed.
+func (w *DelegationWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	f, err := os.Create(w.filename)
+	if err != nil {
+		close(out)
+		return err
+	}
+
+	err = forward(ctx, in, out, func(res resolve.Result) error {
+		if res.Hide || !res.Delegation() {
+			return nil
+		}
+
+		_, err := fmt.Fprintln(f, formatDelegation(res.Hostname, res.Nameservers()))
+		return err
+	})
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func newDelegationsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "delegations LOGFILE.json",
+		Short:                 "List the potential delegations recorded in a JSON log",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("no JSON log specified")
+			}
+
+			for _, filename := range args {
+				data, err := report.ReadData(filename)
+				if err != nil {
+					return fmt.Errorf("reading %v failed: %v", filename, err)
+				}
+
+				delegations := data.PotentialDelegations()
+				for _, hostname := range resolve.SortedKeys(delegations) {
+					fmt.Println(formatDelegation(hostname, delegations[hostname]))
+				}
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+)
+
+// hostAnswers returns the set of answers (as "TYPE data") per host name for
+// all results recorded in data which are not hidden. Name servers of
+// potential delegations are included as "NS server".
+func hostAnswers(data *report.Data) map[string]map[string]struct{} {
+	hosts := make(map[string]map[string]struct{})
+	for _, res := range data.Results {
+		if res.Hidden {
+			continue
+		}
+
+		set, ok := hosts[res.Hostname]
+		if !ok {
+			set = make(map[string]struct{})
+			hosts[res.Hostname] = set
+		}
+
+		for _, ns := range res.Nameservers {
+			set["NS "+ns] = struct{}{}
+		}
+
+		for _, req := range res.Requests {
+			if req.Hidden {
+				continue
+			}
+
+			for _, response := range req.Responses {
+				if response.Hidden {
+					continue
+				}
+				set[response.Type+" "+response.Data] = struct{}{}
+			}
+		}
+	}
+
+	return hosts
+}
+
+func setKeys(set map[string]struct{}) []string {
+	var list []string
+	for k := range set {
+		list = append(list, k)
+	}
+	return resolve.Unique(list)
+}
+
+// ChangeKind describes how the answers for a host name changed.
+type ChangeKind int
+
+// The kinds of changes between two runs.
+const (
+	HostAdded ChangeKind = iota
+	HostRemoved
+	HostChanged
+)
+
+// Change describes the difference of the answers for a host name between
+// two runs.
+type Change struct {
+	Kind     ChangeKind
+	Hostname string
+	Added    []string
+	Removed  []string
+}
+
+// String returns a line describing the change, prefixed by "+" for new
+// host names, "-" for host names which are gone and "~" for changed host
+// names.
+func (c Change) String() string {
+	switch c.Kind {
+	case HostAdded:
+		return fmt.Sprintf("+ %s: %s", c.Hostname, strings.Join(c.Added, ", "))
+	case HostRemoved:
+		return fmt.Sprintf("- %s: %s", c.Hostname, strings.Join(c.Removed, ", "))
+	default:
+		return fmt.Sprintf("~ %s: %s", c.Hostname, formatChanges(c.Added, c.Removed))
+	}
+}
+
+// DiffData compares the results of two runs and returns the changes, sorted
+// by host name.
+func DiffData(old, cur *report.Data) (changes []Change) {
+	oldHosts := hostAnswers(old)
+	curHosts := hostAnswers(cur)
+
+	names := make(map[string][]string)
+	for name := range oldHosts {
+		names[name] = nil
+	}
+	for name := range curHosts {
+		names[name] = nil
+	}
+
+	for _, name := range resolve.SortedKeys(names) {
+		oldSet, inOld := oldHosts[name]
+		curSet, inCur := curHosts[name]
+
+		switch {
+		case !inOld:
+			changes = append(changes, Change{Kind: HostAdded, Hostname: name, Added: setKeys(curSet)})
+		case !inCur:
+			changes = append(changes, Change{Kind: HostRemoved, Hostname: name, Removed: setKeys(oldSet)})
+		default:
+			added, removed := diffSets(oldSet, curSet)
+			if len(added) > 0 || len(removed) > 0 {
+				changes = append(changes, Change{Kind: HostChanged, Hostname: name, Added: added, Removed: removed})
+			}
+		}
+	}
+
+	return changes
+}
+
+// printChanges writes the changes to wr, followed by a summary.
+func printChanges(wr io.Writer, changes []Change) {
+	var added, removed, changed int
+	for _, c := range changes {
+		fmt.Fprintln(wr, c.String())
+		switch c.Kind {
+		case HostAdded:
+			added++
+		case HostRemoved:
+			removed++
+		default:
+			changed++
+		}
+	}
+
+	fmt.Fprintf(wr, "\n%d new, %d removed, %d changed\n", added, removed, changed)
+}
+
+func newDiffCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "diff OLD.json NEW.json",
+		Short:                 "Compare the results recorded in two JSON logs",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("exactly two JSON logs need to be specified")
+			}
+
+			var data [2]*report.Data
+			for i, filename := range args {
+				d, err := report.ReadData(filename)
+				if err != nil {
+					return fmt.Errorf("reading %v failed: %v", filename, err)
+				}
+				data[i] = d
+			}
+
+			printChanges(os.Stdout, DiffData(data[0], data[1]))
+			return nil
+		},
+	}
+}
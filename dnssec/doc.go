@@ -0,0 +1,5 @@
+// Package dnssec validates DNSSEC signatures of answers by following the
+// chain of trust (DS, DNSKEY and RRSIG records) up to the root zone. The
+// records are requested from a recursive name server with checking disabled,
+// so the server's own validation does not hide the cause of failures.
+package dnssec
@@ -0,0 +1,534 @@
+package dnssec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// State is the result of validating an answer.
+type State int
+
+// The validation states as defined in RFC 4033, section 5.
+const (
+	Indeterminate State = iota // the validation could not be completed
+	Insecure                   // the zone is not signed or not delegated securely
+	Secure                     // the chain of trust up to the root is valid
+	Bogus                      // the signatures or the chain of trust are invalid
+)
+
+func (s State) String() string {
+	switch s {
+	case Insecure:
+		return "insecure"
+	case Secure:
+		return "secure"
+	case Bogus:
+		return "bogus"
+	default:
+		return "indeterminate"
+	}
+}
+
+// Result is the state of an answer together with the reason.
+type Result struct {
+	State  State
+	Reason string
+}
+
+// RootAnchors are the DS records of the root zone's key signing keys
+// (KSK-2017 and KSK-2024).
+var RootAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// zoneState is the cached result of validating the keys of a zone.
+type zoneState struct {
+	keys   []*dns.DNSKEY
+	result Result
+}
+
+// Validator validates answers by requesting the signatures, keys and DS
+// records from a (recursive) name server. Validated keys are cached, so a
+// Validator should be reused for many names.
+type Validator struct {
+	Server  string
+	Anchors []*dns.DS
+
+	// Now returns the time used for checking the validity period of
+	// signatures.
+	Now func() time.Time
+
+	client *dns.Client
+
+	mu    sync.Mutex
+	zones map[string]zoneState
+}
+
+// NewValidator returns a validator sending queries to server (host:port)
+// with the DS records in anchors as the trust anchors for the root zone.
+func NewValidator(server string, anchors []string) (*Validator, error) {
+	v := &Validator{
+		Server: server,
+		Now:    time.Now,
+		client: &dns.Client{UDPSize: dns.DefaultMsgSize},
+		zones:  make(map[string]zoneState),
+	}
+
+	for _, anchor := range anchors {
+		rr, err := dns.NewRR(anchor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust anchor %q: %v", anchor, err)
+		}
+
+		ds, ok := rr.(*dns.DS)
+		if !ok {
+			return nil, fmt.Errorf("trust anchor %q is not a DS record", anchor)
+		}
+		v.Anchors = append(v.Anchors, ds)
+	}
+
+	return v, nil
+}
+
+// exchange sends a query with the DO and CD bits set, so the server returns
+// the signatures and does not validate itself. Truncated answers are
+// requested again via TCP.
+func (v *Validator) exchange(name string, qtype uint16) (*dns.Msg, error) {
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.CheckingDisabled = true
+	m.SetEdns0(dns.DefaultMsgSize, true)
+
+	res, _, err := v.client.Exchange(m, v.Server)
+	if err == nil && res.Truncated {
+		tcp := &dns.Client{Net: "tcp"}
+		res, _, err = tcp.Exchange(m, v.Server)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Rcode != dns.RcodeSuccess && res.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("server returned %v for %v %v", dns.RcodeToString[res.Rcode], name, dns.TypeToString[qtype])
+	}
+
+	return res, nil
+}
+
+// rrsetKey identifies an RRset in a message.
+type rrsetKey struct {
+	name  string
+	rtype uint16
+}
+
+// splitRRsets groups the records by name and type and returns the
+// signatures separately. The order of the RRsets is preserved.
+func splitRRsets(records []dns.RR) (keys []rrsetKey, rrsets map[rrsetKey][]dns.RR, sigs []*dns.RRSIG) {
+	rrsets = make(map[rrsetKey][]dns.RR)
+	for _, rr := range records {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			sigs = append(sigs, sig)
+			continue
+		}
+
+		key := rrsetKey{strings.ToLower(rr.Header().Name), rr.Header().Rrtype}
+		if _, ok := rrsets[key]; !ok {
+			keys = append(keys, key)
+		}
+		rrsets[key] = append(rrsets[key], rr)
+	}
+	return keys, rrsets, sigs
+}
+
+// covering returns the signatures for the RRset.
+func covering(sigs []*dns.RRSIG, key rrsetKey) (list []*dns.RRSIG) {
+	for _, sig := range sigs {
+		if strings.EqualFold(sig.Hdr.Name, key.name) && sig.TypeCovered == key.rtype {
+			list = append(list, sig)
+		}
+	}
+	return list
+}
+
+// verify checks that one of the signatures over rrset is valid and made
+// with one of the keys.
+func (v *Validator) verify(rrset []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) error {
+	if len(sigs) == 0 {
+		return errors.New("no signature")
+	}
+
+	err := errors.New("no signature made with a known key")
+	for _, sig := range sigs {
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+
+			verr := sig.Verify(key, rrset)
+			if verr != nil {
+				err = fmt.Errorf("signature by key %d invalid: %v", sig.KeyTag, verr)
+				continue
+			}
+
+			if !sig.ValidityPeriod(v.Now()) {
+				err = fmt.Errorf("signature by key %d expired or not yet valid", sig.KeyTag)
+				continue
+			}
+
+			return nil
+		}
+	}
+
+	return err
+}
+
+// parentZone returns the name of the zone which contains the DS records
+// for zone, taken from the signer of the records or the SOA record in the
+// answer. Only zones above zone are accepted, so that the DS records can not
+// be attributed to an unrelated zone.
+func parentZone(res *dns.Msg, zone string) string {
+	for _, rr := range append(res.Answer, res.Ns...) {
+		var name string
+		switch rr := rr.(type) {
+		case *dns.RRSIG:
+			name = rr.SignerName
+		case *dns.SOA:
+			name = rr.Hdr.Name
+		default:
+			continue
+		}
+
+		name = strings.ToLower(dns.Fqdn(name))
+		if name != zone && dns.IsSubDomain(name, zone) {
+			return name
+		}
+	}
+	return ""
+}
+
+// hasType returns true if the type bitmap of an NSEC or NSEC3 record
+// contains rtype.
+func hasType(bitmap []uint16, rtype uint16) bool {
+	for _, t := range bitmap {
+		if t == rtype {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyNoDS checks that the authority section of res proves that there are
+// no DS records for zone (RFC 4035, section 5.2): an NSEC or NSEC3 record for
+// the delegation without the DS type, or an NSEC3 opt-out span covering it
+// (RFC 5155, section 8.9). The records must be signed with the keys of the
+// parent zone.
+func (v *Validator) verifyNoDS(res *dns.Msg, zone string, keys []*dns.DNSKEY) error {
+	names, rrsets, sigs := splitRRsets(res.Ns)
+
+	var nsecs []*dns.NSEC
+	var nsec3s []*dns.NSEC3
+	for _, key := range names {
+		if key.rtype != dns.TypeNSEC && key.rtype != dns.TypeNSEC3 {
+			continue
+		}
+
+		err := v.verify(rrsets[key], covering(sigs, key), keys)
+		if err != nil {
+			return fmt.Errorf("%v %v: %v", key.name, dns.TypeToString[key.rtype], err)
+		}
+
+		for _, rr := range rrsets[key] {
+			switch rr := rr.(type) {
+			case *dns.NSEC:
+				nsecs = append(nsecs, rr)
+			case *dns.NSEC3:
+				nsec3s = append(nsec3s, rr)
+			}
+		}
+	}
+
+	for _, nsec := range nsecs {
+		if strings.EqualFold(nsec.Hdr.Name, zone) {
+			return checkDelegation(nsec.TypeBitMap)
+		}
+	}
+
+	if len(nsec3s) > 0 {
+		return nsec3NoDS(nsec3s, zone)
+	}
+
+	return errors.New("no NSEC or NSEC3 records")
+}
+
+// checkDelegation checks that the type bitmap of the NSEC or NSEC3 record for
+// a name in the parent zone describes an insecure delegation.
+func checkDelegation(bitmap []uint16) error {
+	switch {
+	case hasType(bitmap, dns.TypeDS):
+		return errors.New("the NSEC record lists DS records")
+	case hasType(bitmap, dns.TypeSOA):
+		return errors.New("the NSEC record is from the child zone")
+	case !hasType(bitmap, dns.TypeNS):
+		return errors.New("the NSEC record does not show a delegation")
+	}
+	return nil
+}
+
+// nsec3NoDS checks that the NSEC3 records prove that there are no DS records
+// for zone.
+func nsec3NoDS(records []*dns.NSEC3, zone string) error {
+	for _, rr := range records {
+		if rr.Match(zone) {
+			return checkDelegation(rr.TypeBitMap)
+		}
+	}
+
+	// find the closest encloser, the next closer name must be covered by
+	// an NSEC3 record with the opt-out flag
+	labels := dns.SplitDomainName(zone)
+	for i := 1; i <= len(labels); i++ {
+		encloser := dns.Fqdn(strings.Join(labels[i:], "."))
+		for _, rr := range records {
+			if !rr.Match(encloser) {
+				continue
+			}
+
+			// names below a delegation are not part of the zone
+			if hasType(rr.TypeBitMap, dns.TypeNS) && !hasType(rr.TypeBitMap, dns.TypeSOA) {
+				return fmt.Errorf("closest encloser %v is a delegation", encloser)
+			}
+
+			next := dns.Fqdn(strings.Join(labels[i-1:], "."))
+			for _, rr := range records {
+				if rr.Flags&1 == 1 && rr.Cover(next) {
+					return nil
+				}
+			}
+
+			return fmt.Errorf("no opt-out NSEC3 record covers %v", next)
+		}
+	}
+
+	return errors.New("no NSEC3 record for the closest encloser")
+}
+
+// zoneKeys returns the validated keys of zone.
+func (v *Validator) zoneKeys(zone string) ([]*dns.DNSKEY, Result) {
+	zone = strings.ToLower(dns.Fqdn(zone))
+
+	v.mu.Lock()
+	state, ok := v.zones[zone]
+	v.mu.Unlock()
+	if ok {
+		return state.keys, state.result
+	}
+
+	keys, result := v.validateZone(zone)
+
+	// do not cache temporary errors
+	if result.State != Indeterminate {
+		v.mu.Lock()
+		v.zones[zone] = zoneState{keys: keys, result: result}
+		v.mu.Unlock()
+	}
+
+	return keys, result
+}
+
+// validateZone validates the DS records of zone with the keys of the parent
+// zone, and the keys of zone with the DS records.
+func (v *Validator) validateZone(zone string) ([]*dns.DNSKEY, Result) {
+	var dsSet []*dns.DS
+	if zone == "." {
+		dsSet = v.Anchors
+	} else {
+		res, err := v.exchange(zone, dns.TypeDS)
+		if err != nil {
+			return nil, Result{Indeterminate, err.Error()}
+		}
+
+		var rrset []dns.RR
+		var sigs []*dns.RRSIG
+		for _, rr := range res.Answer {
+			switch rr := rr.(type) {
+			case *dns.DS:
+				dsSet = append(dsSet, rr)
+				rrset = append(rrset, rr)
+			case *dns.RRSIG:
+				if rr.TypeCovered == dns.TypeDS {
+					sigs = append(sigs, rr)
+				}
+			}
+		}
+
+		parent := parentZone(res, zone)
+		if parent == "" || parent == zone {
+			return nil, Result{Indeterminate, fmt.Sprintf("unable to find the parent zone of %v", zone)}
+		}
+
+		parentKeys, parentResult := v.zoneKeys(parent)
+		if parentResult.State != Secure {
+			return nil, parentResult
+		}
+
+		if len(dsSet) == 0 {
+			// without a proof, the DS records may have been removed to
+			// downgrade the zone to insecure
+			err = v.verifyNoDS(res, zone, parentKeys)
+			if err != nil {
+				return nil, Result{Bogus, fmt.Sprintf("no DS records for %v and no valid proof of their absence: %v", zone, err)}
+			}
+			return nil, Result{Insecure, fmt.Sprintf("no DS records for %v", zone)}
+		}
+
+		err = v.verify(rrset, sigs, parentKeys)
+		if err != nil {
+			return nil, Result{Bogus, fmt.Sprintf("DS records for %v: %v", zone, err)}
+		}
+	}
+
+	res, err := v.exchange(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, Result{Indeterminate, err.Error()}
+	}
+
+	var keys []*dns.DNSKEY
+	var rrset []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range res.Answer {
+		switch rr := rr.(type) {
+		case *dns.DNSKEY:
+			keys = append(keys, rr)
+			rrset = append(rrset, rr)
+		case *dns.RRSIG:
+			if rr.TypeCovered == dns.TypeDNSKEY {
+				sigs = append(sigs, rr)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, Result{Bogus, fmt.Sprintf("DS records for %v exist, but no DNSKEY records", zone)}
+	}
+
+	// find the key signing keys referenced by the DS records
+	var trusted []*dns.DNSKEY
+	for _, key := range keys {
+		for _, ds := range dsSet {
+			if key.KeyTag() != ds.KeyTag || key.Algorithm != ds.Algorithm {
+				continue
+			}
+
+			digest := key.ToDS(ds.DigestType)
+			if digest != nil && strings.EqualFold(digest.Digest, ds.Digest) {
+				trusted = append(trusted, key)
+				break
+			}
+		}
+	}
+
+	if len(trusted) == 0 {
+		return nil, Result{Bogus, fmt.Sprintf("no DNSKEY of %v matches the DS records", zone)}
+	}
+
+	err = v.verify(rrset, sigs, trusted)
+	if err != nil {
+		return nil, Result{Bogus, fmt.Sprintf("DNSKEY records of %v: %v", zone, err)}
+	}
+
+	return keys, Result{Secure, ""}
+}
+
+// findZone returns the zone containing name.
+func (v *Validator) findZone(name string) (string, error) {
+	res, err := v.exchange(name, dns.TypeSOA)
+	if err != nil {
+		return "", err
+	}
+
+	for _, rr := range append(res.Answer, res.Ns...) {
+		// the SOA of an unrelated (insecure) zone must not be used
+		if soa, ok := rr.(*dns.SOA); ok && dns.IsSubDomain(soa.Hdr.Name, name) {
+			return strings.ToLower(soa.Hdr.Name), nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to find the zone of %v", name)
+}
+
+// Validate requests the records of type qtype for name and validates the
+// answer, including CNAMEs, up to the root zone. Denial of existence (NSEC
+// and NSEC3) is only validated for DS records, answers without records are
+// indeterminate.
+func (v *Validator) Validate(name string, qtype uint16) Result {
+	res, err := v.exchange(name, qtype)
+	if err != nil {
+		return Result{Indeterminate, err.Error()}
+	}
+
+	keys, rrsets, sigs := splitRRsets(res.Answer)
+	if len(keys) == 0 {
+		return Result{Indeterminate, "no records to validate"}
+	}
+
+	state := Secure
+	for _, key := range keys {
+		// only the zone of the RRset (or a zone above it) may sign it,
+		// otherwise the key of any secure zone would be accepted
+		var rrsetSigs []*dns.RRSIG
+		var unrelated []string
+		for _, sig := range covering(sigs, key) {
+			if dns.IsSubDomain(sig.SignerName, key.name) {
+				rrsetSigs = append(rrsetSigs, sig)
+			} else {
+				unrelated = append(unrelated, sig.SignerName)
+			}
+		}
+		if len(rrsetSigs) == 0 && len(unrelated) > 0 {
+			return Result{Bogus, fmt.Sprintf("%v %v signed by unrelated zone %v", key.name, dns.TypeToString[key.rtype], strings.Join(unrelated, ", "))}
+		}
+
+		if len(rrsetSigs) == 0 {
+			// an unsigned answer is fine if the zone is not signed
+			zone, err := v.findZone(key.name)
+			if err != nil {
+				return Result{Indeterminate, err.Error()}
+			}
+
+			_, result := v.zoneKeys(zone)
+			if result.State == Secure {
+				return Result{Bogus, fmt.Sprintf("no signature for %v %v in signed zone %v", key.name, dns.TypeToString[key.rtype], zone)}
+			}
+			if result.State != Insecure {
+				return result
+			}
+			state = Insecure
+			continue
+		}
+
+		zoneKeys, result := v.zoneKeys(rrsetSigs[0].SignerName)
+		if result.State == Insecure {
+			state = Insecure
+			continue
+		}
+		if result.State != Secure {
+			return result
+		}
+
+		err := v.verify(rrsets[key], rrsetSigs, zoneKeys)
+		if err != nil {
+			return Result{Bogus, fmt.Sprintf("%v %v: %v", key.name, dns.TypeToString[key.rtype], err)}
+		}
+	}
+
+	if state == Insecure {
+		return Result{Insecure, ""}
+	}
+	return Result{Secure, ""}
+}
@@ -0,0 +1,284 @@
+package dnssec
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/dnstest"
+	"github.com/miekg/dns"
+)
+
+// testZones answers queries from a fixed set of records, including the
+// signatures covering them.
+type testZones struct {
+	records []dns.RR
+}
+
+func (z *testZones) add(t testing.TB, records ...string) []dns.RR {
+	var list []dns.RR
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, rr)
+	}
+	z.records = append(z.records, list...)
+	return list
+}
+
+func (z *testZones) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	q := req.Question[0]
+	for _, rr := range z.records {
+		if !strings.EqualFold(rr.Header().Name, q.Name) {
+			continue
+		}
+
+		if rr.Header().Rrtype == q.Qtype {
+			m.Answer = append(m.Answer, rr)
+		}
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == q.Qtype {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+
+	// find the zone for SOA and DS queries
+	if len(m.Answer) == 0 {
+		labels := dns.SplitDomainName(q.Name)
+		for i := 0; i <= len(labels); i++ {
+			zone := dns.Fqdn(strings.Join(labels[i:], "."))
+			if q.Qtype == dns.TypeDS && i == 0 {
+				continue
+			}
+			for _, rr := range z.records {
+				if rr.Header().Rrtype == dns.TypeSOA && strings.EqualFold(rr.Header().Name, zone) {
+					m.Ns = append(m.Ns, rr)
+				}
+			}
+			if len(m.Ns) > 0 {
+				z.addDenial(m, q.Name, zone)
+				break
+			}
+		}
+	}
+
+	_ = w.WriteMsg(m)
+}
+
+// addDenial adds the NSEC records for name and the NSEC3 records of zone to
+// the authority section, together with their signatures.
+func (z *testZones) addDenial(m *dns.Msg, name, zone string) {
+	for _, rr := range z.records {
+		var rtype uint16
+		switch rr := rr.(type) {
+		case *dns.NSEC:
+			if !strings.EqualFold(rr.Hdr.Name, name) {
+				continue
+			}
+			rtype = dns.TypeNSEC
+		case *dns.NSEC3:
+			labels := dns.SplitDomainName(rr.Hdr.Name)
+			if !strings.EqualFold(dns.Fqdn(strings.Join(labels[1:], ".")), zone) {
+				continue
+			}
+			rtype = dns.TypeNSEC3
+		default:
+			continue
+		}
+
+		m.Ns = append(m.Ns, rr)
+		for _, sig := range z.records {
+			if sig, ok := sig.(*dns.RRSIG); ok && sig.TypeCovered == rtype && strings.EqualFold(sig.Hdr.Name, rr.Header().Name) {
+				m.Ns = append(m.Ns, sig)
+			}
+		}
+	}
+}
+
+type testKey struct {
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newKey(t testing.TB, zone string, flags uint16) testKey {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKey{key: key, priv: priv.(crypto.Signer)}
+}
+
+func (k testKey) sign(t testing.TB, rrset []dns.RR, inception, expiration time.Time) *dns.RRSIG {
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rrset[0].Header().Ttl},
+		KeyTag:     k.key.KeyTag(),
+		SignerName: k.key.Hdr.Name,
+		Algorithm:  k.key.Algorithm,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+
+	err := sig.Sign(k.priv, rrset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	valid := func(k testKey, rrset []dns.RR) *dns.RRSIG {
+		return k.sign(t, rrset, now.Add(-time.Hour), now.Add(time.Hour))
+	}
+
+	zones := &testZones{}
+
+	root := newKey(t, ".", 257)
+	example := newKey(t, "example.", 257)
+
+	// root zone
+	zones.records = append(zones.records, root.key, valid(root, []dns.RR{root.key}))
+	zones.add(t, ". 3600 IN SOA a.root. admin.root. 1 3600 600 86400 300")
+
+	// example is delegated securely
+	ds := example.key.ToDS(dns.SHA256)
+	zones.records = append(zones.records, ds, valid(root, []dns.RR{ds}))
+	zones.records = append(zones.records, example.key, valid(example, []dns.RR{example.key}))
+	zones.add(t, "example. 3600 IN SOA ns.example. admin.example. 1 3600 600 86400 300")
+
+	www := zones.add(t, "www.example. 300 IN A 192.0.2.1")
+	zones.records = append(zones.records, valid(example, www))
+
+	// signature made with an unknown key
+	other := newKey(t, "example.", 256)
+	bad := zones.add(t, "bad.example. 300 IN A 192.0.2.2")
+	zones.records = append(zones.records, valid(other, bad))
+
+	// expired signature
+	old := zones.add(t, "old.example. 300 IN A 192.0.2.3")
+	zones.records = append(zones.records, example.sign(t, old, now.Add(-48*time.Hour), now.Add(-24*time.Hour)))
+
+	// missing signature in a signed zone
+	zones.add(t, "unsigned.example. 300 IN A 192.0.2.4")
+
+	// the zone insecure is not signed and has no DS record
+	zones.add(t, "insecure. 3600 IN SOA ns.insecure. admin.insecure. 1 3600 600 86400 300")
+	zones.add(t, "www.insecure. 300 IN A 192.0.2.5")
+	nsec := zones.add(t, "insecure. 3600 IN NSEC stripped. NS RRSIG NSEC")
+	zones.records = append(zones.records, valid(root, nsec))
+
+	// records in the insecure zone signed with the key of another zone
+	hijack := zones.add(t, "hijack.insecure. 300 IN A 192.0.2.6")
+	zones.records = append(zones.records, valid(example, hijack))
+
+	// the zone stripped is signed, but the DS records were removed from
+	// the answer and there is no proof that they do not exist
+	stripped := newKey(t, "stripped.", 257)
+	zones.add(t, "stripped. 3600 IN SOA ns.stripped. admin.stripped. 1 3600 600 86400 300")
+	zones.records = append(zones.records, stripped.key, valid(stripped, []dns.RR{stripped.key}))
+	www = zones.add(t, "www.stripped. 300 IN A 192.0.2.7")
+	zones.records = append(zones.records, valid(stripped, www))
+
+	// the zone optout.example is not signed, the parent uses NSEC3 with an
+	// opt-out span covering the delegation
+	zones.add(t, "optout.example. 3600 IN SOA ns.optout.example. admin.optout.example. 1 3600 600 86400 300")
+	zones.add(t, "www.optout.example. 300 IN A 192.0.2.8")
+	hash := dns.HashName("example.", dns.SHA1, 0, "")
+	nsec3 := zones.add(t, fmt.Sprintf("%s.example. 3600 IN NSEC3 1 1 0 - %s NS SOA RRSIG DNSKEY NSEC3PARAM", hash, hash))
+	zones.records = append(zones.records, valid(example, nsec3))
+
+	srv, err := dnstest.NewServer(zones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	anchor := root.key.ToDS(dns.SHA256)
+	v, err := NewValidator(srv.Addr, []string{anchor.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name  string
+		state State
+	}{
+		{"www.example.", Secure},
+		{"bad.example.", Bogus},
+		{"old.example.", Bogus},
+		{"unsigned.example.", Bogus},
+		{"www.insecure.", Insecure},
+		{"hijack.insecure.", Bogus},
+		{"www.stripped.", Bogus},
+		{"www.optout.example.", Insecure},
+		{"nope.example.", Indeterminate},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := v.Validate(test.name, dns.TypeA)
+			if res.State != test.state {
+				t.Errorf("wrong state, want %v, got %v (%v)", test.state, res.State, res.Reason)
+			}
+		})
+	}
+
+	// a wrong trust anchor makes everything bogus
+	v, err = NewValidator(srv.Addr, []string{". IN DS 12345 13 2 0000000000000000000000000000000000000000000000000000000000000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := v.Validate("www.example.", dns.TypeA)
+	if res.State != Bogus {
+		t.Errorf("wrong state for invalid trust anchor, want bogus, got %v (%v)", res.State, res.Reason)
+	}
+}
+
+func TestParentZone(t *testing.T) {
+	var tests = []struct {
+		zone    string
+		records []string
+		parent  string
+	}{
+		{"example.com.", []string{"com. 300 IN SOA a.gtld. admin.gtld. 1 3600 600 86400 300"}, "com."},
+		{"example.com.", []string{"example.com. 300 IN RRSIG DS 13 2 300 20300101000000 20200101000000 1234 com. AAAA"}, "com."},
+		// unrelated zones and the zone itself are ignored
+		{"example.com.", []string{"org. 300 IN SOA a.gtld. admin.gtld. 1 3600 600 86400 300"}, ""},
+		{"example.com.", []string{"example.com. 300 IN RRSIG DS 13 2 300 20300101000000 20200101000000 1234 example.org. AAAA"}, ""},
+		{"example.com.", []string{"example.com. 300 IN SOA ns. admin. 1 3600 600 86400 300"}, ""},
+		{"example.com.", []string{
+			"example.com. 300 IN RRSIG DS 13 2 300 20300101000000 20200101000000 1234 evil.test. AAAA",
+			"com. 300 IN SOA a.gtld. admin.gtld. 1 3600 600 86400 300",
+		}, "com."},
+	}
+
+	for _, test := range tests {
+		m := &dns.Msg{}
+		for _, s := range test.records {
+			rr, err := dns.NewRR(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Ns = append(m.Ns, rr)
+		}
+
+		if parent := parentZone(m, test.zone); parent != test.parent {
+			t.Errorf("records %v: wrong parent, want %q, got %q", test.records, test.parent, parent)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package dnstest implements an authoritative DNS server for tests, which
+// serves a zone from memory.
+package dnstest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Zone contains the records served by a Server.
+type Zone struct {
+	Origin  string   // name of the zone, e.g. "example.com."
+	SOA     dns.RR   // returned in the authority section of negative answers
+	Records []dns.RR // all records, including wildcards (*.name) and NS records for delegated sub domains
+
+	// RequireTSIG refuses queries without a valid TSIG signature, the keys
+	// are configured with NewTSIGServer.
+	RequireTSIG bool
+}
+
+// NewZone parses the records (in zone file format) and returns a zone for
+// origin. A default SOA record is added.
+func NewZone(origin string, records ...string) (*Zone, error) {
+	origin = dns.Fqdn(strings.ToLower(origin))
+
+	soa, err := dns.NewRR(fmt.Sprintf("%s 300 IN SOA ns.%s hostmaster.%s 1 3600 600 86400 300", origin, origin, origin))
+	if err != nil {
+		return nil, err
+	}
+
+	zone := &Zone{Origin: origin, SOA: soa}
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			return nil, fmt.Errorf("parsing record %q failed: %v", record, err)
+		}
+		zone.Records = append(zone.Records, rr)
+	}
+
+	return zone, nil
+}
+
+// lookup returns all records for name. If no records exist, wildcard records
+// are returned. The name is set to the one requested.
+func (z *Zone) lookup(name string) (records []dns.RR) {
+	for _, rr := range z.Records {
+		if strings.EqualFold(rr.Header().Name, name) {
+			records = append(records, rr)
+		}
+	}
+
+	if len(records) > 0 {
+		return records
+	}
+
+	// try wildcards for all parent names within the zone
+	labels := dns.SplitDomainName(name)
+	for i := 1; i < len(labels); i++ {
+		wildcard := "*." + strings.Join(labels[i:], ".") + "."
+		if !dns.IsSubDomain(z.Origin, wildcard) {
+			break
+		}
+
+		for _, rr := range z.Records {
+			if strings.EqualFold(rr.Header().Name, wildcard) {
+				rr = dns.Copy(rr)
+				rr.Header().Name = name
+				records = append(records, rr)
+			}
+		}
+
+		if len(records) > 0 {
+			return records
+		}
+	}
+
+	return nil
+}
+
+// delegation returns the NS records if name is within a delegated sub
+// domain.
+func (z *Zone) delegation(name string) (records []dns.RR) {
+	for _, rr := range z.Records {
+		if rr.Header().Rrtype != dns.TypeNS || strings.EqualFold(rr.Header().Name, z.Origin) {
+			continue
+		}
+
+		if dns.IsSubDomain(rr.Header().Name, name) {
+			records = append(records, rr)
+		}
+	}
+	return records
+}
+
+// exists returns true if there are any records at or below name.
+func (z *Zone) exists(name string) bool {
+	for _, rr := range z.Records {
+		if dns.IsSubDomain(name, rr.Header().Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxChain is the maximum number of CNAME records followed within the zone.
+const maxChain = 8
+
+// ServeDNS answers a request.
+func (z *Zone) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+
+	if len(req.Question) != 1 {
+		m.Rcode = dns.RcodeFormatError
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	tsig := req.IsTsig()
+	if tsig != nil && w.TsigStatus() != nil {
+		m.Rcode = dns.RcodeNotAuth
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	if tsig == nil && z.RequireTSIG {
+		m.Rcode = dns.RcodeRefused
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	q := req.Question[0]
+
+	switch {
+	case !dns.IsSubDomain(z.Origin, q.Name):
+		m.Rcode = dns.RcodeRefused
+
+	case q.Qtype == dns.TypeAXFR:
+		m.Answer = append([]dns.RR{z.SOA}, z.Records...)
+		m.Answer = append(m.Answer, z.SOA)
+
+	case len(z.delegation(q.Name)) > 0:
+		// referral to the name servers of the sub domain
+		m.Authoritative = false
+		m.Ns = z.delegation(q.Name)
+
+	default:
+		name := q.Name
+		for i := 0; i < maxChain; i++ {
+			var cname *dns.CNAME
+			for _, rr := range z.lookup(name) {
+				if rr.Header().Rrtype == q.Qtype {
+					m.Answer = append(m.Answer, rr)
+				} else if rec, ok := rr.(*dns.CNAME); ok {
+					cname = rec
+				}
+			}
+
+			if cname == nil || q.Qtype == dns.TypeCNAME {
+				break
+			}
+
+			// follow the CNAME within the zone
+			m.Answer = append(m.Answer, cname)
+			name = cname.Target
+			if !dns.IsSubDomain(z.Origin, name) {
+				break
+			}
+		}
+
+		if len(m.Answer) == 0 {
+			if !z.exists(q.Name) {
+				m.Rcode = dns.RcodeNameError
+			}
+			m.Ns = []dns.RR{z.SOA}
+		}
+	}
+
+	// sign the response with the key of the query
+	if tsig != nil {
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+	}
+
+	_ = w.WriteMsg(m)
+}
+
+// Server is a DNS server listening on UDP and TCP on the same port.
+type Server struct {
+	Addr string // address in the form host:port
+
+	udp, tcp *dns.Server
+}
+
+// NewServer starts a new server listening on a random port on localhost,
+// requests are answered by handler.
+func NewServer(handler dns.Handler) (*Server, error) {
+	return NewTSIGServer(handler, nil)
+}
+
+// NewTSIGServer starts a new server like NewServer, which verifies the TSIG
+// signatures of the requests with the secrets (key name to base64 encoded
+// secret). Handlers can check the result with the TsigStatus method of the
+// dns.ResponseWriter.
+func NewTSIGServer(handler dns.Handler, secrets map[string]string) (*Server, error) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	// use the same port for TCP (required for zone transfers)
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		_ = pc.Close()
+		return nil, err
+	}
+
+	srv := &Server{
+		Addr: pc.LocalAddr().String(),
+		udp:  &dns.Server{PacketConn: pc, Handler: handler, TsigSecret: secrets},
+		tcp:  &dns.Server{Listener: l, Handler: handler, TsigSecret: secrets},
+	}
+
+	started := make(chan struct{}, 2)
+	srv.udp.NotifyStartedFunc = func() { started <- struct{}{} }
+	srv.tcp.NotifyStartedFunc = func() { started <- struct{}{} }
+
+	go func() { _ = srv.udp.ActivateAndServe() }()
+	go func() { _ = srv.tcp.ActivateAndServe() }()
+
+	<-started
+	<-started
+
+	return srv, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	err := s.udp.Shutdown()
+	if err2 := s.tcp.Shutdown(); err == nil {
+		err = err2
+	}
+	return err
+}
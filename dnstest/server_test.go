@@ -0,0 +1,80 @@
+package dnstest
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func testZone(t testing.TB) *Zone {
+	zone, err := NewZone("example.com",
+		"www.example.com. 300 IN A 192.0.2.1",
+		"cdn.example.com. 300 IN CNAME edge.example.com.",
+		"edge.example.com. 300 IN A 192.0.2.2",
+		"*.wild.example.com. 300 IN A 192.0.2.3",
+		"dev.example.com. 300 IN NS ns1.example.net.",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zone
+}
+
+func exchange(t testing.TB, addr, name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+
+	res, _, err := new(dns.Client).Exchange(m, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestServer(t *testing.T) {
+	srv, err := NewServer(testZone(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err := srv.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var tests = []struct {
+		name    string
+		qtype   uint16
+		rcode   int
+		answers int
+		ns      int
+	}{
+		{"www.example.com.", dns.TypeA, dns.RcodeSuccess, 1, 0},
+		{"www.example.com.", dns.TypeAAAA, dns.RcodeSuccess, 0, 1},
+		{"cdn.example.com.", dns.TypeA, dns.RcodeSuccess, 2, 0},
+		{"foo.wild.example.com.", dns.TypeA, dns.RcodeSuccess, 1, 0},
+		{"x.dev.example.com.", dns.TypeA, dns.RcodeSuccess, 0, 1},
+		{"nope.example.com.", dns.TypeA, dns.RcodeNameError, 0, 1},
+		{"example.net.", dns.TypeA, dns.RcodeRefused, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := exchange(t, srv.Addr, test.name, test.qtype)
+
+			if res.Rcode != test.rcode {
+				t.Errorf("wrong rcode, want %v, got %v", dns.RcodeToString[test.rcode], dns.RcodeToString[res.Rcode])
+			}
+
+			if len(res.Answer) != test.answers {
+				t.Errorf("wrong number of answers, want %d, got %d: %v", test.answers, len(res.Answer), res.Answer)
+			}
+
+			if len(res.Ns) != test.ns {
+				t.Errorf("wrong number of authority records, want %d, got %d: %v", test.ns, len(res.Ns), res.Ns)
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultDKIMSelectors are commonly used DKIM selectors, tried for each
+// domain since selectors cannot be enumerated.
+var defaultDKIMSelectors = []string{
+	"default", "dkim", "google", "k1", "k2", "mail", "mandrill", "mx",
+	"s1", "s2", "selector1", "selector2", "smtp", "zoho",
+}
+
+// Severity describes how bad a finding of the email audit is.
+type Severity int
+
+// The severities, from good to bad.
+const (
+	SeverityOK Severity = iota
+	SeverityInfo
+	SeverityWeak
+	SeverityMissing
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityOK:
+		return "ok"
+	case SeverityInfo:
+		return "info"
+	case SeverityWeak:
+		return "weak"
+	default:
+		return "missing"
+	}
+}
+
+// Finding is the result of one check for a domain.
+type Finding struct {
+	Check    string // MX, SPF, DMARC, DKIM
+	Severity Severity
+	Text     string
+}
+
+// EmailAudit contains the findings for a domain.
+type EmailAudit struct {
+	Domain   string
+	Findings []Finding
+	Error    error
+}
+
+// lookupRecords returns the answer for name and type. A name which does not
+// exist is not an error. EDNS0 is used so that large answers (e.g. long TXT
+// records) fit into a UDP response, truncated responses are requested again
+// via TCP.
+func lookupRecords(name string, qtype uint16, resolver string) ([]dns.RR, error) {
+	c := dns.Client{}
+	m := dns.Msg{}
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.SetEdns0(dns.DefaultMsgSize, false)
+
+	res, _, err := c.Exchange(&m, resolve.NameserverAddress(resolver))
+	if err == nil && res.Truncated {
+		c.Net = "tcp"
+		res, _, err = c.Exchange(&m, resolve.NameserverAddress(resolver))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+		return res.Answer, nil
+	default:
+		return nil, fmt.Errorf("server returned %v", dns.RcodeToString[res.Rcode])
+	}
+}
+
+// txtRecords returns the TXT records for name, the strings of each record
+// are joined.
+func txtRecords(name, resolver string) ([]string, error) {
+	answer, err := lookupRecords(name, dns.TypeTXT, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []string
+	for _, rr := range answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			records = append(records, strings.Join(txt.Txt, ""))
+		}
+	}
+	return records, nil
+}
+
+// withPrefix returns the records starting with prefix (case insensitive).
+func withPrefix(records []string, prefix string) (list []string) {
+	for _, record := range records {
+		if strings.HasPrefix(strings.ToLower(record), strings.ToLower(prefix)) {
+			list = append(list, record)
+		}
+	}
+	return list
+}
+
+// checkMX returns the findings for the MX records of a domain.
+func checkMX(answer []dns.RR) []Finding {
+	var servers []string
+	for _, rr := range answer {
+		mx, ok := rr.(*dns.MX)
+		if !ok {
+			continue
+		}
+
+		if mx.Mx == "." {
+			return []Finding{{"MX", SeverityInfo, "null MX record, the domain does not receive email"}}
+		}
+		servers = append(servers, fmt.Sprintf("%v (%d)", resolve.CleanHostname(mx.Mx), mx.Preference))
+	}
+
+	if len(servers) == 0 {
+		return []Finding{{"MX", SeverityInfo, "no MX records, the domain does not receive email"}}
+	}
+
+	sort.Strings(servers)
+	return []Finding{{"MX", SeverityOK, strings.Join(servers, ", ")}}
+}
+
+// checkSPF returns the findings for the TXT records of a domain.
+func checkSPF(records []string) []Finding {
+	spf := withPrefix(records, "v=spf1")
+
+	switch len(spf) {
+	case 0:
+		return []Finding{{"SPF", SeverityMissing, "no SPF record, anyone can send email for the domain"}}
+	case 1:
+	default:
+		return []Finding{{"SPF", SeverityWeak, fmt.Sprintf("%d SPF records, receivers treat this as an error", len(spf))}}
+	}
+
+	record := spf[0]
+	var all string
+	for _, term := range strings.Fields(strings.ToLower(record))[1:] {
+		switch term {
+		case "all", "+all", "-all", "~all", "?all":
+			all = term
+		}
+	}
+
+	switch all {
+	case "-all":
+		return []Finding{{"SPF", SeverityOK, record}}
+	case "~all":
+		return []Finding{{"SPF", SeverityInfo, "soft fail for other senders (~all): " + record}}
+	case "?all":
+		return []Finding{{"SPF", SeverityWeak, "neutral for other senders (?all): " + record}}
+	case "all", "+all":
+		return []Finding{{"SPF", SeverityWeak, "all senders allowed (+all): " + record}}
+	default:
+		if strings.Contains(strings.ToLower(record), "redirect=") {
+			return []Finding{{"SPF", SeverityOK, record}}
+		}
+		return []Finding{{"SPF", SeverityWeak, "no 'all' mechanism, other senders are neutral: " + record}}
+	}
+}
+
+// recordTags returns the tags of a DMARC or DKIM record (tag=value;...).
+func recordTags(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, field := range strings.Split(record, ";") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		tags[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+	return tags
+}
+
+// checkDMARC returns the findings for the TXT records of _dmarc.domain.
+func checkDMARC(records []string) (findings []Finding) {
+	dmarc := withPrefix(records, "v=DMARC1")
+
+	switch len(dmarc) {
+	case 0:
+		return []Finding{{"DMARC", SeverityMissing, "no DMARC record"}}
+	case 1:
+	default:
+		return []Finding{{"DMARC", SeverityWeak, fmt.Sprintf("%d DMARC records, receivers ignore all of them", len(dmarc))}}
+	}
+
+	record := dmarc[0]
+	tags := recordTags(record)
+
+	switch strings.ToLower(tags["p"]) {
+	case "reject", "quarantine":
+		findings = append(findings, Finding{"DMARC", SeverityOK, record})
+	case "none":
+		findings = append(findings, Finding{"DMARC", SeverityWeak, "policy none, failing email is delivered: " + record})
+	default:
+		findings = append(findings, Finding{"DMARC", SeverityWeak, "invalid or missing policy: " + record})
+	}
+
+	if pct, ok := tags["pct"]; ok && pct != "100" {
+		findings = append(findings, Finding{"DMARC", SeverityWeak, fmt.Sprintf("policy only applied to %v%% of email", pct)})
+	}
+
+	if _, ok := tags["rua"]; !ok {
+		findings = append(findings, Finding{"DMARC", SeverityInfo, "no aggregate reports requested (rua)"})
+	}
+
+	return findings
+}
+
+// checkDKIM returns the findings for the TXT records found for the DKIM
+// selectors, records maps the selectors to the records and errs the selectors
+// to the errors of the failed queries.
+func checkDKIM(records map[string][]string, errs map[string]error) (findings []Finding) {
+	var selectors []string
+	for selector := range records {
+		selectors = append(selectors, selector)
+	}
+	for selector := range errs {
+		selectors = append(selectors, selector)
+	}
+	sort.Strings(selectors)
+
+	found := false
+	for _, selector := range selectors {
+		if err, ok := errs[selector]; ok {
+			findings = append(findings, Finding{"DKIM", SeverityInfo, fmt.Sprintf("selector %v: query failed: %v", selector, err)})
+			continue
+		}
+
+		for _, record := range records[selector] {
+			tags := recordTags(record)
+			if _, ok := tags["p"]; !ok {
+				continue
+			}
+
+			found = true
+			if tags["p"] == "" {
+				findings = append(findings, Finding{"DKIM", SeverityInfo, fmt.Sprintf("selector %v: key revoked", selector)})
+				continue
+			}
+			findings = append(findings, Finding{"DKIM", SeverityOK, fmt.Sprintf("selector %v found", selector)})
+		}
+	}
+
+	if !found {
+		findings = append(findings, Finding{"DKIM", SeverityInfo, "no key found for the common selectors"})
+	}
+
+	return findings
+}
+
+// auditDomain runs all checks for domain.
+func auditDomain(domain, resolver string, selectors []string) EmailAudit {
+	audit := EmailAudit{Domain: domain}
+
+	answer, err := lookupRecords(domain, dns.TypeMX, resolver)
+	if err != nil {
+		audit.Error = err
+		return audit
+	}
+	audit.Findings = append(audit.Findings, checkMX(answer)...)
+
+	records, err := txtRecords(domain, resolver)
+	if err != nil {
+		audit.Error = err
+		return audit
+	}
+	audit.Findings = append(audit.Findings, checkSPF(records)...)
+
+	records, err = txtRecords("_dmarc."+domain, resolver)
+	if err != nil {
+		audit.Error = err
+		return audit
+	}
+	audit.Findings = append(audit.Findings, checkDMARC(records)...)
+
+	// the selectors are only guesses, so a failed query does not abort the
+	// audit of the domain
+	dkim := make(map[string][]string)
+	dkimErrors := make(map[string]error)
+	for _, selector := range selectors {
+		records, err := txtRecords(selector+"._domainkey."+domain, resolver)
+		if err != nil {
+			dkimErrors[selector] = err
+			continue
+		}
+		if len(records) > 0 {
+			dkim[selector] = records
+		}
+	}
+	audit.Findings = append(audit.Findings, checkDKIM(dkim, dkimErrors)...)
+
+	return audit
+}
+
+// readDomains returns the domains from a file, one per line. If the file is
+// a JSON log, the host names of the shown results are returned.
+func readDomains(filename string) ([]string, error) {
+	if strings.HasSuffix(filename, ".json") || strings.HasSuffix(filename, ".json.gz") {
+		data, err := report.ReadData(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		var domains []string
+		for _, res := range data.Results {
+			if !res.Hidden && !res.Empty() {
+				domains = append(domains, res.Hostname)
+			}
+		}
+		return resolve.Unique(domains), nil
+	}
+
+	return readResolvers(filename)
+}
+
+// printAudit prints the findings for a domain.
+func printAudit(term report.Printer, audit EmailAudit) {
+	if audit.Error != nil {
+		cli.Logf(term, cli.LevelError, map[string]string{"host": audit.Domain}, "%v: %v\n", audit.Domain, audit.Error)
+		return
+	}
+
+	term.Printf("%v\n", audit.Domain)
+	for _, f := range audit.Findings {
+		term.Printf("  %-5s  %-7s  %v\n", f.Check, f.Severity, f.Text)
+	}
+}
+
+func newEmailAuditCommand() *cobra.Command {
+	var (
+		filename  string
+		resolver  string
+		threads   int
+		selectors []string
+		problems  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:                   "email-audit [options] [DOMAIN...]",
+		Short:                 "Check MX, SPF, DMARC and DKIM records of domains for weak or missing policies",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			domains := args
+			if filename != "" {
+				list, err := readDomains(filename)
+				if err != nil {
+					return err
+				}
+				domains = append(domains, list...)
+			}
+
+			if len(domains) == 0 {
+				return errors.New("no domains specified")
+			}
+
+			if threads <= 0 {
+				return errors.New("invalid number of threads")
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				term, cleanup, err := setupTerminal(ctx, g, "", "", false, logFormat{})
+				defer cleanup()
+				if err != nil {
+					return err
+				}
+
+				if resolve.IsJSONServer(resolver) {
+					return errors.New("email-audit cannot send queries to a JSON API")
+				}
+
+				if resolver == "" {
+					resolver, err = resolve.FindSystemNameserver()
+					if err != nil {
+						return err
+					}
+				}
+
+				ch := make(chan string)
+				go func() {
+					defer close(ch)
+					for _, domain := range domains {
+						select {
+						case ch <- resolve.CleanHostname(domain):
+						case <-ctx.Done():
+							return
+						}
+					}
+				}()
+
+				var (
+					mu     sync.Mutex
+					done   int
+					counts = make(map[string]int)
+				)
+
+				var wg sync.WaitGroup
+				for i := 0; i < threads; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for domain := range ch {
+							audit := auditDomain(domain, resolver, selectors)
+
+							mu.Lock()
+							done++
+							worst := SeverityOK
+							for _, f := range audit.Findings {
+								if f.Severity >= SeverityWeak {
+									counts[f.Check+" "+f.Severity.String()]++
+								}
+								if f.Severity > worst {
+									worst = f.Severity
+								}
+							}
+							if !problems || worst >= SeverityWeak || audit.Error != nil {
+								printAudit(term, audit)
+							}
+							term.SetStatus([]string{"", fmt.Sprintf("%d of %d domains checked", done, len(domains))})
+							mu.Unlock()
+						}
+					}()
+				}
+				wg.Wait()
+				term.SetStatus(nil)
+
+				var keys []string
+				for key := range counts {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+
+				term.Printf("summary for %d domains:\n", done)
+				if len(keys) == 0 {
+					term.Printf("  no weak or missing policies found\n")
+				}
+				for _, key := range keys {
+					term.Printf("  %-15s %d\n", key, counts[key])
+				}
+
+				return nil
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&filename, "file", "f", "", "read domains from `filename`, one per line, or the shown host names from a JSON log")
+	flags.StringVar(&resolver, "nameserver", "", "send DNS queries to `server`, if empty, the system resolver is used")
+	flags.IntVarP(&threads, "threads", "t", 4, "check `n` domains in parallel")
+	flags.StringSliceVar(&selectors, "dkim-selectors", defaultDKIMSelectors, "try the DKIM `selector,selector2`")
+	flags.BoolVar(&problems, "problems-only", false, "only print domains with weak or missing policies")
+
+	return cmd
+}
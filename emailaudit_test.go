@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/happal/taifun/dnstest"
+	"github.com/miekg/dns"
+)
+
+// formatFindings returns the findings as "check severity: text" lines.
+func formatFindings(findings []Finding) []string {
+	var lines []string
+	for _, f := range findings {
+		lines = append(lines, f.Check+" "+f.Severity.String()+": "+f.Text)
+	}
+	return lines
+}
+
+func checkFindings(t testing.TB, want []string, findings []Finding) {
+	got := formatFindings(findings)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong findings, want:\n  %s\ngot:\n  %s", strings.Join(want, "\n  "), strings.Join(got, "\n  "))
+	}
+}
+
+func TestCheckSPF(t *testing.T) {
+	var tests = []struct {
+		records []string
+		want    []string
+	}{
+		{nil, []string{"SPF missing: no SPF record, anyone can send email for the domain"}},
+		{[]string{"google-site-verification=abc"}, []string{"SPF missing: no SPF record, anyone can send email for the domain"}},
+		{[]string{"v=spf1 mx -all"}, []string{"SPF ok: v=spf1 mx -all"}},
+		{[]string{"V=SPF1 MX -ALL"}, []string{"SPF ok: V=SPF1 MX -ALL"}},
+		{[]string{"v=spf1 include:_spf.example.net ~all"}, []string{"SPF info: soft fail for other senders (~all): v=spf1 include:_spf.example.net ~all"}},
+		{[]string{"v=spf1 ?all"}, []string{"SPF weak: neutral for other senders (?all): v=spf1 ?all"}},
+		{[]string{"v=spf1 +all"}, []string{"SPF weak: all senders allowed (+all): v=spf1 +all"}},
+		{[]string{"v=spf1 a all"}, []string{"SPF weak: all senders allowed (+all): v=spf1 a all"}},
+		{[]string{"v=spf1 redirect=_spf.example.net"}, []string{"SPF ok: v=spf1 redirect=_spf.example.net"}},
+		{[]string{"v=spf1 mx"}, []string{"SPF weak: no 'all' mechanism, other senders are neutral: v=spf1 mx"}},
+		{[]string{"v=spf1"}, []string{"SPF weak: no 'all' mechanism, other senders are neutral: v=spf1"}},
+		{[]string{"v=spf1 mx -all", "v=spf1 a -all"}, []string{"SPF weak: 2 SPF records, receivers treat this as an error"}},
+	}
+
+	for _, test := range tests {
+		checkFindings(t, test.want, checkSPF(test.records))
+	}
+}
+
+func TestCheckDMARC(t *testing.T) {
+	var tests = []struct {
+		records []string
+		want    []string
+	}{
+		{nil, []string{"DMARC missing: no DMARC record"}},
+		{
+			[]string{"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+			[]string{"DMARC ok: v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+		},
+		{
+			[]string{"v=DMARC1;p=Quarantine;rua=mailto:dmarc@example.com;pct=100"},
+			[]string{"DMARC ok: v=DMARC1;p=Quarantine;rua=mailto:dmarc@example.com;pct=100"},
+		},
+		{
+			[]string{"v=DMARC1; p=none"},
+			[]string{
+				"DMARC weak: policy none, failing email is delivered: v=DMARC1; p=none",
+				"DMARC info: no aggregate reports requested (rua)",
+			},
+		},
+		{
+			[]string{"v=DMARC1; p=reject; pct=20; rua=mailto:dmarc@example.com"},
+			[]string{
+				"DMARC ok: v=DMARC1; p=reject; pct=20; rua=mailto:dmarc@example.com",
+				"DMARC weak: policy only applied to 20% of email",
+			},
+		},
+		{
+			[]string{"v=DMARC1; rua=mailto:dmarc@example.com"},
+			[]string{"DMARC weak: invalid or missing policy: v=DMARC1; rua=mailto:dmarc@example.com"},
+		},
+		{
+			[]string{"v=DMARC1; p=reject", "v=DMARC1; p=none"},
+			[]string{"DMARC weak: 2 DMARC records, receivers ignore all of them"},
+		},
+	}
+
+	for _, test := range tests {
+		checkFindings(t, test.want, checkDMARC(test.records))
+	}
+}
+
+func TestCheckDKIM(t *testing.T) {
+	var tests = []struct {
+		records map[string][]string
+		errs    map[string]error
+		want    []string
+	}{
+		{nil, nil, []string{"DKIM info: no key found for the common selectors"}},
+		{
+			map[string][]string{
+				"selector2": {"v=DKIM1; k=rsa; p=MIGfMA0"},
+				"google":    {"v=DKIM1; k=rsa; p=MIIBIj"},
+			},
+			nil,
+			[]string{"DKIM ok: selector google found", "DKIM ok: selector selector2 found"},
+		},
+		{
+			map[string][]string{"s1": {"v=DKIM1; p="}},
+			nil,
+			[]string{"DKIM info: selector s1: key revoked"},
+		},
+		{
+			// records without a key are ignored
+			map[string][]string{"mail": {"some text"}},
+			nil,
+			[]string{"DKIM info: no key found for the common selectors"},
+		},
+		{
+			map[string][]string{"s1": {"v=DKIM1; p=MIGfMA0"}},
+			map[string]error{"k1": errors.New("server returned SERVFAIL")},
+			[]string{"DKIM info: selector k1: query failed: server returned SERVFAIL", "DKIM ok: selector s1 found"},
+		},
+		{
+			nil,
+			map[string]error{"k1": errors.New("i/o timeout")},
+			[]string{"DKIM info: selector k1: query failed: i/o timeout", "DKIM info: no key found for the common selectors"},
+		},
+	}
+
+	for _, test := range tests {
+		checkFindings(t, test.want, checkDKIM(test.records, test.errs))
+	}
+}
+
+// truncatingHandler answers queries via UDP with an empty truncated response,
+// all other queries are answered by the zone.
+type truncatingHandler struct {
+	zone *dnstest.Zone
+
+	mu   sync.Mutex
+	edns bool // set if a query with EDNS0 was received
+}
+
+func (h *truncatingHandler) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	if req.IsEdns0() != nil {
+		h.mu.Lock()
+		h.edns = true
+		h.mu.Unlock()
+	}
+
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Truncated = true
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	h.zone.ServeDNS(w, req)
+}
+
+func TestLookupRecordsTruncated(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com", `example.com. 300 IN TXT "v=spf1 mx -all"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &truncatingHandler{zone: zone}
+	srv, err := dnstest.NewServer(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	records, err := txtRecords("example.com", srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0] != "v=spf1 mx -all" {
+		t.Errorf("wrong records %q", records)
+	}
+
+	h.mu.Lock()
+	edns := h.edns
+	h.mu.Unlock()
+
+	if !edns {
+		t.Errorf("query was sent without EDNS0")
+	}
+}
+
+func TestAuditDomainDKIMError(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com",
+		"example.com. 300 IN MX 10 mail.example.com.",
+		`example.com. 300 IN TXT "v=spf1 mx -all"`,
+		`_dmarc.example.com. 300 IN TXT "v=DMARC1; p=reject; rua=mailto:dmarc@example.com"`,
+		`s1._domainkey.example.com. 300 IN TXT "v=DKIM1; p=MIGfMA0"`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	// the selector is not a valid label, the query fails
+	invalid := strings.Repeat("x", 64)
+	audit := auditDomain("example.com", srv.Addr, []string{"s1", invalid})
+	if audit.Error != nil {
+		t.Fatal(audit.Error)
+	}
+
+	got := formatFindings(audit.Findings)
+	if len(got) != 5 || got[3] != "DKIM ok: selector s1 found" || !strings.HasPrefix(got[4], "DKIM info: selector "+invalid+": query failed: ") {
+		t.Errorf("wrong findings:\n  %s", strings.Join(got, "\n  "))
+	}
+}
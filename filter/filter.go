@@ -0,0 +1,341 @@
+// Package filter decides which results are hidden from the user.
+package filter
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+)
+
+// Request decides whether to reject a request and all of its responses.
+type Request interface {
+	Reject(resolve.Request) bool
+}
+
+// RequestFunc wraps a function so that it implements the Request interface.
+type RequestFunc func(resolve.Request) bool
+
+// Reject runs f on the request.
+func (f RequestFunc) Reject(r resolve.Request) bool {
+	return f(r)
+}
+
+// Result decides whether to reject a result.
+type Result interface {
+	Reject(resolve.Result) bool
+}
+
+// ResultFunc wraps a function so that it implements the Result interface.
+type ResultFunc func(resolve.Result) bool
+
+// Reject runs f on the result.
+func (f ResultFunc) Reject(r resolve.Result) bool {
+	return f(r)
+}
+
+// Response decides whether to reject a response.
+type Response interface {
+	Reject(resolve.Response) bool
+}
+
+// ResponseFunc wraps a function so that it implements the Response interface.
+type ResponseFunc func(resolve.Response) bool
+
+// Reject runs f on the response.
+func (f ResponseFunc) Reject(r resolve.Response) bool {
+	return f(r)
+}
+
+// NotFound returns a filter which hides "not found" responses.
+func NotFound() Request {
+	return RequestFunc(func(r resolve.Request) (reject bool) {
+		return r.NotFound
+	})
+}
+
+// InSubnet returns a filter which hides responses with addresses in one
+// of the subnets.
+func InSubnet(subnets []*net.IPNet) Response {
+	return ResponseFunc(func(res resolve.Response) (reject bool) {
+		// don't process anything except v4/v6 responses
+		if res.Type != "A" && res.Type != "AAAA" {
+			return false
+		}
+
+		ip := net.ParseIP(res.Data)
+		if ip == nil {
+			return false
+		}
+
+		for _, subnet := range subnets {
+			if subnet.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// NotInSubnet returns a filter which hides responses with addresses
+// which are not in one of the subnets.
+func NotInSubnet(subnets []*net.IPNet) Response {
+	return ResponseFunc(func(res resolve.Response) (reject bool) {
+		// don't process anything except v4/v6 responses
+		if res.Type != "A" && res.Type != "AAAA" {
+			return false
+		}
+
+		ip := net.ParseIP(res.Data)
+		if ip == nil {
+			return false
+		}
+
+		for _, subnet := range subnets {
+			if subnet.Contains(ip) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// EmptyResults returns a filter which hides responses.
+func EmptyResults() Result {
+	return ResultFunc(func(r resolve.Result) (reject bool) {
+		return r.Empty()
+	})
+}
+
+// Delegations returns a filter which hides potential delegations.
+func Delegations() Result {
+	return ResultFunc(func(r resolve.Result) (reject bool) {
+		return r.Delegation()
+	})
+}
+
+// MinConfidence returns a filter which hides results rated below level
+// compared to the wildcard. Results without a rating are not hidden.
+func MinConfidence(level string) Result {
+	min := resolve.ConfidenceRank(level)
+	return ResultFunc(func(r resolve.Result) (reject bool) {
+		if r.Confidence == "" {
+			return false
+		}
+		return resolve.ConfidenceRank(r.Confidence) < min
+	})
+}
+
+// WithoutLabels returns a filter which hides results which do not have any of
+// the labels.
+func WithoutLabels(labels []string) Result {
+	return ResultFunc(func(r resolve.Result) (reject bool) {
+		return !hasLabel(r, labels)
+	})
+}
+
+// WithLabels returns a filter which hides results which have any of the
+// labels.
+func WithLabels(labels []string) Result {
+	return ResultFunc(func(r resolve.Result) (reject bool) {
+		return hasLabel(r, labels)
+	})
+}
+
+// hasLabel returns true if r has any of the labels.
+func hasLabel(r resolve.Result, labels []string) bool {
+	for _, label := range r.Labels {
+		for _, l := range labels {
+			if label == l {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RejectCNAMEs return a filter which hides cnames matching any of the patterns.
+func RejectCNAMEs(patterns []*regexp.Regexp) Response {
+	return RejectData(typePatterns("CNAME", patterns))
+}
+
+// RejectPTR returns a filter which hides PTR responses matching one of the patterns.
+func RejectPTR(patterns []*regexp.Regexp) Response {
+	return RejectData(typePatterns("PTR", patterns))
+}
+
+// DataPattern matches the data of responses of a type, or of all types if
+// Type is empty.
+type DataPattern struct {
+	Type    string
+	Pattern *regexp.Regexp
+}
+
+// ParseDataPattern parses a pattern in the form "regex" or "TYPE:regex", e.g.
+// "TXT:^v=spf1". The prefix is only taken as the type if it is the name of a
+// record type, so patterns like "^2001:db8:" work as expected.
+func ParseDataPattern(s string) (DataPattern, error) {
+	var typ string
+	if i := strings.Index(s, ":"); i > 0 {
+		if _, ok := dns.StringToType[strings.ToUpper(s[:i])]; ok {
+			typ = strings.ToUpper(s[:i])
+			s = s[i+1:]
+		}
+	}
+
+	pat, err := regexp.Compile(s)
+	if err != nil {
+		return DataPattern{}, fmt.Errorf("regexp %q failed to compile: %v", s, err)
+	}
+
+	return DataPattern{Type: typ, Pattern: pat}, nil
+}
+
+// typePatterns returns data patterns for the response type.
+func typePatterns(typ string, patterns []*regexp.Regexp) []DataPattern {
+	list := make([]DataPattern, 0, len(patterns))
+	for _, pat := range patterns {
+		list = append(list, DataPattern{Type: typ, Pattern: pat})
+	}
+	return list
+}
+
+// matchData returns whether the data of r matches one of the patterns for its
+// type, and whether there are patterns for the type at all.
+func matchData(r resolve.Response, patterns []DataPattern) (match, applies bool) {
+	for _, pat := range patterns {
+		if pat.Type != "" && pat.Type != r.Type {
+			continue
+		}
+
+		applies = true
+		if pat.Pattern.MatchString(r.Data) {
+			return true, true
+		}
+	}
+
+	return false, applies
+}
+
+// RejectData returns a filter which hides responses whose data matches one of
+// the patterns.
+func RejectData(patterns []DataPattern) Response {
+	return ResponseFunc(func(r resolve.Response) (reject bool) {
+		match, _ := matchData(r, patterns)
+		return match
+	})
+}
+
+// RequireData returns a filter which hides responses whose data does not
+// match any of the patterns. Responses of types without patterns are not
+// hidden.
+func RequireData(patterns []DataPattern) Response {
+	return ResponseFunc(func(r resolve.Response) (reject bool) {
+		match, applies := matchData(r, patterns)
+		return applies && !match
+	})
+}
+
+// RejectOwners returns a filter which hides address responses whose owner or
+// netname (from RDAP) matches one of the patterns.
+func RejectOwners(patterns []*regexp.Regexp) Response {
+	return ResponseFunc(func(r resolve.Response) (reject bool) {
+		return matchOwner(r, patterns)
+	})
+}
+
+// RequireOwners returns a filter which hides address responses whose owner
+// and netname (from RDAP) do not match any of the patterns. Addresses without
+// owner information are hidden as well.
+func RequireOwners(patterns []*regexp.Regexp) Response {
+	return ResponseFunc(func(r resolve.Response) (reject bool) {
+		if r.Type != "A" && r.Type != "AAAA" {
+			return false
+		}
+
+		return !matchOwner(r, patterns)
+	})
+}
+
+// matchOwner returns true if the owner or the netname of r matches one of the
+// patterns.
+func matchOwner(r resolve.Response, patterns []*regexp.Regexp) bool {
+	for _, pat := range patterns {
+		if (r.Owner != "" && pat.MatchString(r.Owner)) || (r.Netname != "" && pat.MatchString(r.Netname)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Set collects all filters executed on results.
+type Set struct {
+	Result   []Result
+	Request  []Request
+	Response []Response
+}
+
+// Run runs the filters on the result and marks it (and its requests and
+// responses) as hidden accordingly.
+func (filters Set) Run(result resolve.Result) resolve.Result {
+	for _, f := range filters.Result {
+		if f.Reject(result) {
+			result.Hide = true
+			return result
+		}
+	}
+
+	allRequestsHidden := true
+	for i, request := range result.Requests {
+		requestHidden := false
+		for _, requestFilter := range filters.Request {
+			if requestFilter.Reject(request) {
+				requestHidden = true
+				result.Requests[i].Hide = true
+				break
+			}
+		}
+
+		if requestHidden {
+			continue // continue to next request
+		}
+		allRequestsHidden = false
+
+		for j, response := range request.Responses {
+			for _, responseFilter := range filters.Response {
+				if responseFilter.Reject(response) {
+					request.Responses[j].Hide = true
+					break // continue to next response
+				}
+			}
+		}
+	}
+
+	// mark the whole result as hidden there are no requests
+	if allRequestsHidden {
+		result.Hide = true
+	}
+
+	return result
+}
+
+// Mark runs the filters on all results and marks those that should be hidden.
+func Mark(in <-chan resolve.Result, filters Set) <-chan resolve.Result {
+	ch := make(chan resolve.Result)
+
+	go func() {
+		defer close(ch)
+		for res := range in {
+			res = filters.Run(res)
+			ch <- res
+		}
+	}()
+
+	return ch
+}
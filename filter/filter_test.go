@@ -0,0 +1,185 @@
+package filter
+
+import (
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/happal/taifun/resolve"
+)
+
+func TestParseDataPattern(t *testing.T) {
+	var tests = []struct {
+		input   string
+		typ     string
+		pattern string
+		err     bool
+	}{
+		{input: "example", pattern: "example"},
+		{input: "TXT:^v=spf1", typ: "TXT", pattern: "^v=spf1"},
+		{input: "txt:^v=spf1", typ: "TXT", pattern: "^v=spf1"},
+		{input: "cname:\\.cdn\\.", typ: "CNAME", pattern: "\\.cdn\\."},
+		// the prefix is not a record type, so it is part of the regex
+		{input: "^2001:db8:", pattern: "^2001:db8:"},
+		{input: "foo:bar", pattern: "foo:bar"},
+		// "ns" is a record type
+		{input: "ns:foo", typ: "NS", pattern: "foo"},
+		{input: ":foo", pattern: ":foo"},
+		{input: "A:", typ: "A", pattern: ""},
+		{input: "A:(", err: true},
+		{input: "[", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			pat, err := ParseDataPattern(test.input)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected error not returned, got %+v", pat)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pat.Type != test.typ {
+				t.Errorf("wrong type, want %q, got %q", test.typ, pat.Type)
+			}
+
+			if pat.Pattern.String() != test.pattern {
+				t.Errorf("wrong pattern, want %q, got %q", test.pattern, pat.Pattern.String())
+			}
+		})
+	}
+}
+
+func TestRequireData(t *testing.T) {
+	patterns := []DataPattern{
+		{Type: "TXT", Pattern: regexp.MustCompile("^v=spf1")},
+		{Type: "AAAA", Pattern: regexp.MustCompile("^2001:db8:")},
+	}
+
+	var tests = []struct {
+		response resolve.Response
+		reject   bool
+	}{
+		{resolve.Response{Type: "TXT", Data: "v=spf1 -all"}, false},
+		{resolve.Response{Type: "TXT", Data: "google-site-verification=x"}, true},
+		{resolve.Response{Type: "AAAA", Data: "2001:db8::1"}, false},
+		{resolve.Response{Type: "AAAA", Data: "2001:4860::1"}, true},
+		// no patterns for the type
+		{resolve.Response{Type: "A", Data: "192.0.2.1"}, false},
+		{resolve.Response{Type: "CNAME", Data: "www.example.com"}, false},
+	}
+
+	f := RequireData(patterns)
+	for _, test := range tests {
+		t.Run(test.response.Type+" "+test.response.Data, func(t *testing.T) {
+			if reject := f.Reject(test.response); reject != test.reject {
+				t.Errorf("wrong result, want %v, got %v", test.reject, reject)
+			}
+		})
+	}
+
+	// patterns without a type apply to all responses
+	f = RequireData([]DataPattern{{Pattern: regexp.MustCompile("example")}})
+	if !f.Reject(resolve.Response{Type: "A", Data: "192.0.2.1"}) {
+		t.Errorf("response not matching an untyped pattern was not rejected")
+	}
+	if f.Reject(resolve.Response{Type: "CNAME", Data: "www.example.com"}) {
+		t.Errorf("response matching an untyped pattern was rejected")
+	}
+}
+
+func TestRejectData(t *testing.T) {
+	pat, err := ParseDataPattern("^2001:db8:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := RejectData([]DataPattern{pat})
+	if !f.Reject(resolve.Response{Type: "AAAA", Data: "2001:db8::1"}) {
+		t.Errorf("matching response was not rejected")
+	}
+	if f.Reject(resolve.Response{Type: "AAAA", Data: "2001:4860::1"}) {
+		t.Errorf("response not matching was rejected")
+	}
+}
+
+func TestSetRun(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newResult := func() resolve.Result {
+		return resolve.Result{
+			Hostname: "www.example.com",
+			Requests: []resolve.Request{
+				{Type: "A", Responses: []resolve.Response{
+					{Type: "A", Data: "192.0.2.1"},
+					{Type: "A", Data: "198.51.100.1"},
+				}},
+				{Type: "AAAA", NotFound: true},
+			},
+		}
+	}
+
+	var tests = []struct {
+		name         string
+		filters      Set
+		hideResult   bool
+		hideRequests []bool
+		hideA        []bool // responses of the first request
+	}{
+		{
+			name:         "none",
+			hideRequests: []bool{false, false},
+			hideA:        []bool{false, false},
+		},
+		{
+			// the response filters run without any request filters
+			name:         "response-only",
+			filters:      Set{Response: []Response{InSubnet([]*net.IPNet{subnet})}},
+			hideRequests: []bool{false, false},
+			hideA:        []bool{true, false},
+		},
+		{
+			name:         "request-and-response",
+			filters:      Set{Request: []Request{NotFound()}, Response: []Response{InSubnet([]*net.IPNet{subnet})}},
+			hideRequests: []bool{false, true},
+			hideA:        []bool{true, false},
+		},
+		{
+			name:         "all-requests-hidden",
+			filters:      Set{Request: []Request{NotFound(), RequestFunc(func(r resolve.Request) bool { return r.Type == "A" })}},
+			hideResult:   true,
+			hideRequests: []bool{true, true},
+			hideA:        []bool{false, false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := test.filters.Run(newResult())
+
+			if res.Hide != test.hideResult {
+				t.Errorf("wrong state of the result, want hidden %v, got %v", test.hideResult, res.Hide)
+			}
+
+			for i, req := range res.Requests {
+				if req.Hide != test.hideRequests[i] {
+					t.Errorf("request %v: want hidden %v, got %v", req.Type, test.hideRequests[i], req.Hide)
+				}
+			}
+
+			for i, response := range res.Requests[0].Responses {
+				if response.Hide != test.hideA[i] {
+					t.Errorf("response %v: want hidden %v, got %v", response.Data, test.hideA[i], response.Hide)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/happal/taifun/report"
+)
+
+// outputFormats lists the valid values for --output-format.
+var outputFormats = []string{"text", "wide", "csv", "massdns", "massdns-ndjson"}
+
+// contains returns true if list contains s.
+func contains(list []string, s string) bool {
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
+
+func validOutputFormat(format string) bool {
+	return contains(outputFormats, format)
+}
+
+// newResultPrinter returns a report.ResultPrinter for the format selected in opts.
+// The hostname template is used to compute the column width for the text
+// output.
+func newResultPrinter(opts *Options, hostname string) (report.ResultPrinter, error) {
+	if opts.template != nil {
+		return &report.TemplatePrinter{Template: opts.template}, nil
+	}
+
+	switch opts.OutputFormat {
+	case "text":
+		return &report.TextPrinter{Width: len(hostname) + 10, NoHeader: opts.quiet(), ShowRTT: opts.ShowRTT, ShowConfidence: opts.ShowConfidence, ShowFlags: opts.ShowFlags}, nil
+	case "wide":
+		return &report.WidePrinter{}, nil
+	case "csv":
+		return &report.CSVPrinter{}, nil
+	case "massdns":
+		return &report.MassdnsPrinter{}, nil
+	case "massdns-ndjson":
+		return &report.MassdnsJSONPrinter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, valid formats: %s", opts.OutputFormat, strings.Join(outputFormats, ", "))
+	}
+}
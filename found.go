@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// forward passes all results from in to out and calls f for each of them. When
+// in is closed or the context is cancelled, out is closed. Processing stops
+// when f returns an error.
+func forward(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result, f func(resolve.Result) error) error {
+	defer close(out)
+
+	for res := range in {
+		err := f(res)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- res:
+		}
+	}
+
+	return nil
+}
+
+// forwardParallel passes all results from in to out like forward, but calls
+// f for up to threads results in parallel and sends the results it returns,
+// so the order of the results may change. When in is closed or the context
+// is cancelled, out is closed after all calls to f have returned.
+func forwardParallel(ctx context.Context, threads int, in <-chan resolve.Result, out chan<- resolve.Result, f func(resolve.Result) resolve.Result) error {
+	defer close(out)
+
+	if threads < 1 {
+		threads = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for res := range in {
+				res = f(res)
+
+				select {
+				case <-ctx.Done():
+					return
+				case out <- res:
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// FoundWriter appends the host names of all shown results which resolved to
+// something to a file, one per line.
+type FoundWriter struct {
+	filename string
+}
+
+// NewFoundWriter returns a new writer for filename.
+func NewFoundWriter(filename string) *FoundWriter {
+	return &FoundWriter{filename: filename}
+}
+
+// Run reads results from in and forwards them to out, writing the host name
+// of each resolved result to the file on the way. When in is closed or the
+// context is cancelled, the file is closed and out is closed.
+func (w *FoundWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	f, err := os.OpenFile(w.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		close(out)
+		return err
+	}
+
+	err = forward(ctx, in, out, func(res resolve.Result) error {
+		if res.Hide || !res.Resolved() {
+			return nil
+		}
+
+		_, err := fmt.Fprintln(f, res.Hostname)
+		return err
+	})
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// FailedWriter writes the items for which all requests failed to a file,
+// one per line, so they can be retried later.
+type FailedWriter struct {
+	filename string
+}
+
+// NewFailedWriter returns a new writer for filename.
+func NewFailedWriter(filename string) *FailedWriter {
+	return &FailedWriter{filename: filename}
+}
+
+// Run reads results from in and forwards them to out, writing the items of
+// failed results to the file on the way. When in is closed or the context is
+// cancelled, the file is closed and out is closed.
+func (w *FailedWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	f, err := os.Create(w.filename)
+	if err != nil {
+		close(out)
+		return err
+	}
+
+	err = forward(ctx, in, out, func(res resolve.Result) error {
+		if !res.Failed() {
+			return nil
+		}
+
+		_, err := fmt.Fprintln(f, res.Item)
+		return err
+	})
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
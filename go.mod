@@ -2,13 +2,18 @@ module github.com/happal/taifun
 
 require (
 	github.com/fd0/termstatus v1.0.1
+	github.com/golang/protobuf v1.3.2
 	github.com/juju/ratelimit v1.0.1
-	github.com/kr/pretty v0.1.0 // indirect
-	github.com/mattn/go-isatty v0.0.4 // indirect
-	github.com/miekg/dns v1.1.22
+	github.com/mattn/go-isatty v0.0.4
+	github.com/miekg/dns v1.1.47
+	github.com/segmentio/kafka-go v0.4.20
 	github.com/spf13/cobra v0.0.5
-	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
+	github.com/spf13/pflag v1.0.3
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c
+	google.golang.org/grpc v1.27.1
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	gopkg.in/yaml.v2 v2.2.2
 )
 
-go 1.13
+go 1.16
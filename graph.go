@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+)
+
+// Graph collects the relations between host names, CNAME targets, name
+// servers and addresses.
+type Graph struct {
+	nodes map[string]string                 // node -> kind (host, address, nameserver)
+	edges map[[2]string]map[string]struct{} // (from, to) -> labels
+}
+
+// NewGraph returns a new, empty graph.
+func NewGraph() *Graph {
+	return &Graph{
+		nodes: make(map[string]string),
+		edges: make(map[[2]string]map[string]struct{}),
+	}
+}
+
+func (g *Graph) addNode(name, kind string) {
+	// do not overwrite host names with other kinds, e.g. for name servers
+	// which have also been found during the enumeration
+	if g.nodes[name] == "host" {
+		return
+	}
+	g.nodes[name] = kind
+}
+
+func (g *Graph) addEdge(from, to, label string) {
+	key := [2]string{from, to}
+	if g.edges[key] == nil {
+		g.edges[key] = make(map[string]struct{})
+	}
+	g.edges[key][label] = struct{}{}
+}
+
+// Add adds all shown responses of res to the graph. The raw answer section is
+// used so that complete CNAME chains are included, records for hidden
+// responses are skipped.
+func (g *Graph) Add(res resolve.Result) {
+	if res.Hide {
+		return
+	}
+
+	if res.Delegation() {
+		g.addNode(res.Hostname, "host")
+		for _, server := range res.Nameservers() {
+			g.addNode(server, "nameserver")
+			g.addEdge(res.Hostname, server, "NS")
+		}
+		return
+	}
+
+	if !res.Resolved() {
+		return
+	}
+
+	g.addNode(res.Hostname, "host")
+
+	for _, request := range res.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for _, rr := range report.ShownAnswers(res.Hostname, request) {
+			owner := resolve.CleanHostname(rr.Header().Name)
+
+			switch rec := rr.(type) {
+			case *dns.CNAME:
+				target := resolve.CleanHostname(rec.Target)
+				g.addNode(owner, "host")
+				g.addNode(target, "host")
+				g.addEdge(owner, target, "CNAME")
+			case *dns.A:
+				g.addNode(owner, "host")
+				g.addNode(rec.A.String(), "address")
+				g.addEdge(owner, rec.A.String(), "A")
+			case *dns.AAAA:
+				g.addNode(owner, "host")
+				g.addNode(rec.AAAA.String(), "address")
+				g.addEdge(owner, rec.AAAA.String(), "AAAA")
+			}
+		}
+	}
+}
+
+var graphNodeShapes = map[string]string{
+	"host":       "ellipse",
+	"address":    "box",
+	"nameserver": "diamond",
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language to wr.
+func (g *Graph) WriteDOT(wr io.Writer) {
+	fmt.Fprintln(wr, "digraph taifun {")
+	fmt.Fprintln(wr, "  rankdir=LR;")
+
+	var nodes []string
+	for node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		fmt.Fprintf(wr, "  %s [shape=%s];\n", strconv.Quote(node), graphNodeShapes[g.nodes[node]])
+	}
+
+	var edges [][2]string
+	for edge := range g.edges {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+
+	for _, edge := range edges {
+		var labels []string
+		for label := range g.edges[edge] {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+
+		label := strings.Join(labels, ",")
+		fmt.Fprintf(wr, "  %s -> %s [label=%s];\n", strconv.Quote(edge[0]), strconv.Quote(edge[1]), strconv.Quote(label))
+	}
+
+	fmt.Fprintln(wr, "}")
+}
+
+// renderSVG returns true if the graph for filename is rendered as SVG.
+func renderSVG(filename string) bool {
+	return filepath.Ext(filename) == ".svg"
+}
+
+// checkGraphRenderer returns an error if the graph for filename is rendered
+// as SVG and the Graphviz "dot" program cannot be found, so the run does not
+// fail when it is done.
+func checkGraphRenderer(filename string) error {
+	if !renderSVG(filename) {
+		return nil
+	}
+
+	_, err := exec.LookPath("dot")
+	if err != nil {
+		return fmt.Errorf("writing the graph as SVG needs the Graphviz program \"dot\", install it or write a .dot file instead (%v)", err)
+	}
+
+	return nil
+}
+
+// GraphWriter collects results and writes a graph when done. If the file
+// name ends with ".svg", the graph is rendered by calling the Graphviz "dot"
+// program.
+type GraphWriter struct {
+	filename string
+	*Graph
+}
+
+// NewGraphWriter returns a new writer for filename.
+func NewGraphWriter(filename string) *GraphWriter {
+	return &GraphWriter{
+		filename: filename,
+		Graph:    NewGraph(),
+	}
+}
+
+// Run reads results from in and forwards them to out, collecting the shown
+// results on the way. When in is closed, the graph is written and out is
+// closed. When the context is cancelled, out is closed, the remaining results
+// from in are added and the partial graph is written.
+func (w *GraphWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	err := forward(ctx, in, out, func(res resolve.Result) error {
+		w.Add(res)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the previous stages close in when they notice the cancellation
+	for res := range in {
+		w.Add(res)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	w.WriteDOT(buf)
+
+	if !renderSVG(w.filename) {
+		return ioutil.WriteFile(w.filename, buf.Bytes(), 0644)
+	}
+
+	// use a new context here, the one passed to Run may already be cancelled
+	cmd := exec.CommandContext(context.Background(), "dot", "-Tsvg", "-o", w.filename)
+	cmd.Stdin = buf
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running graphviz failed: %v\n%s", err, output)
+	}
+
+	return nil
+}
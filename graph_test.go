@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// answered returns a result for hostname with the raw answer section.
+func answered(hostname string, answer ...string) resolve.Result {
+	req := resolve.Request{Type: "A", Status: "NOERROR"}
+	req.Raw.Answer = answer
+	for range answer {
+		// the graph uses the raw records, the responses only need to be
+		// present so the result counts as resolved
+		req.Responses = append(req.Responses, resolve.Response{Type: "A"})
+	}
+
+	return resolve.Result{Hostname: hostname, Requests: []resolve.Request{req}}
+}
+
+func graphResults() []resolve.Result {
+	hidden := answered("hidden.example.com", "hidden.example.com. 300 IN A 198.51.100.1")
+	hidden.Hide = true
+
+	return []resolve.Result{
+		answered("www.example.com",
+			"www.example.com. 300 IN CNAME web.example.com.",
+			"web.example.com. 300 IN A 192.0.2.1",
+		),
+		answered("mail.example.com",
+			"mail.example.com. 300 IN A 192.0.2.1",
+			"mail.example.com. 300 IN AAAA 2001:db8::1",
+		),
+		{
+			Hostname: "sub.example.com",
+			Requests: []resolve.Request{{Type: "A", Status: "NOERROR", Nameserver: []resolve.Response{
+				{Type: "NS", Data: "ns1.example.net."},
+			}}},
+		},
+		hidden,
+	}
+}
+
+const wantGraph = `digraph taifun {
+  rankdir=LR;
+  "192.0.2.1" [shape=box];
+  "2001:db8::1" [shape=box];
+  "mail.example.com" [shape=ellipse];
+  "ns1.example.net." [shape=diamond];
+  "sub.example.com" [shape=ellipse];
+  "web.example.com" [shape=ellipse];
+  "www.example.com" [shape=ellipse];
+  "mail.example.com" -> "192.0.2.1" [label="A"];
+  "mail.example.com" -> "2001:db8::1" [label="AAAA"];
+  "sub.example.com" -> "ns1.example.net." [label="NS"];
+  "web.example.com" -> "192.0.2.1" [label="A"];
+  "www.example.com" -> "web.example.com" [label="CNAME"];
+}
+`
+
+func TestGraphWriteDOT(t *testing.T) {
+	g := NewGraph()
+	for _, res := range graphResults() {
+		g.Add(res)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	g.WriteDOT(buf)
+
+	if buf.String() != wantGraph {
+		t.Errorf("wrong graph, want:\n%s\ngot:\n%s", wantGraph, buf.String())
+	}
+}
+
+func TestGraphHiddenResponses(t *testing.T) {
+	res := answered("www.example.com",
+		"www.example.com. 300 IN CNAME web.example.com.",
+		"www.example.com. 300 IN A 10.0.0.1",
+		"web.example.com. 300 IN A 192.0.2.1",
+	)
+
+	// the private address has been hidden by a filter, e.g. --hide-network
+	res.Requests[0].Responses = []resolve.Response{
+		{Type: "CNAME", Data: "web.example.com"},
+		{Type: "A", Data: "10.0.0.1", Hide: true},
+	}
+
+	g := NewGraph()
+	g.Add(res)
+
+	buf := bytes.NewBuffer(nil)
+	g.WriteDOT(buf)
+
+	want := `digraph taifun {
+  rankdir=LR;
+  "192.0.2.1" [shape=box];
+  "web.example.com" [shape=ellipse];
+  "www.example.com" [shape=ellipse];
+  "web.example.com" -> "192.0.2.1" [label="A"];
+  "www.example.com" -> "web.example.com" [label="CNAME"];
+}
+`
+
+	if buf.String() != want {
+		t.Errorf("wrong graph, want:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestGraphWriterCancelled(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	results := graphResults()
+	in := make(chan resolve.Result, len(results))
+	for _, res := range results {
+		in <- res
+	}
+	close(in)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// nobody reads the results, they are still added to the graph
+	out := make(chan resolve.Result)
+
+	filename := filepath.Join(tempdir, "graph.dot")
+	err = NewGraphWriter(filename).Run(ctx, in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != wantGraph {
+		t.Errorf("wrong graph, want:\n%s\ngot:\n%s", wantGraph, buf)
+	}
+}
+
+func TestCheckGraphRenderer(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	// make sure dot cannot be found
+	path := os.Getenv("PATH")
+	defer func() {
+		_ = os.Setenv("PATH", path)
+	}()
+	err = os.Setenv("PATH", tempdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filename := range []string{"", "graph.dot", "graph.gv"} {
+		err := checkGraphRenderer(filename)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", filename, err)
+		}
+	}
+
+	err = checkGraphRenderer("graph.svg")
+	if err == nil {
+		t.Fatal("no error returned for SVG without dot")
+	}
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/happal/taifun/rpc"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// progressInterval is the time between progress updates sent to clients.
+const progressInterval = time.Second
+
+// maxJobResults is the number of results kept for each job, so clients can
+// call StreamResults after the results were received. Older results are
+// dropped.
+const maxJobResults = 100000
+
+// maxJobThreads is the maximal number of threads a client can request for a
+// job.
+const maxJobThreads = 200
+
+// maxJobValues is the maximal number of values a client can send with a job.
+const maxJobValues = 100000
+
+// maxRunningJobs is the maximal number of jobs which run at the same time.
+const maxRunningJobs = 4
+
+// Rate limits for jobs: jobs without a rate use defaultJobRate, higher rates
+// than maxJobRate are rejected.
+const (
+	defaultJobRate = 100
+	maxJobRate     = 1000
+)
+
+// jobRetention is the time a finished job is kept for clients to fetch its
+// status and results.
+const jobRetention = time.Hour
+
+// job is an enumeration started via gRPC.
+type job struct {
+	id            string
+	cancel        context.CancelFunc
+	throttle      *producer.Throttle
+	includeHidden bool
+
+	mu       sync.Mutex
+	state    rpc.State
+	err      error
+	results  []*rpc.Result
+	dropped  int // number of results dropped from the start of results
+	progress rpc.Progress
+	changed  chan struct{} // closed and replaced when new results arrive or the state changes
+	done     chan struct{} // closed when the job has finished
+}
+
+// notify wakes up all clients waiting for changes. The caller must hold j.mu.
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// status returns the current status of the job.
+func (j *job) status() *rpc.JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	st := &rpc.JobStatus{Id: j.id, State: j.state}
+	if j.err != nil {
+		st.Error = j.err.Error()
+	}
+	return st
+}
+
+// add records a result.
+func (j *job) add(res resolve.Result) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.progress.Sent++
+	for _, req := range res.Requests {
+		if req.Error != nil {
+			j.progress.Errors++
+		}
+	}
+
+	if res.Hide {
+		j.progress.Hidden++
+	} else {
+		j.progress.Shown++
+	}
+
+	if res.Hide && !j.includeHidden {
+		return
+	}
+
+	j.results = append(j.results, newRPCResult(report.NewResult(res, j.includeHidden)))
+
+	// drop old results in chunks, so they are not copied for each new result
+	if len(j.results) >= 2*maxJobResults {
+		n := len(j.results) - maxJobResults
+		j.results = append([]*rpc.Result(nil), j.results[n:]...)
+		j.dropped += n
+	}
+
+	j.notify()
+}
+
+// finish records the final state of the job.
+func (j *job) finish(ctx context.Context, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch {
+	case err != nil:
+		j.state = rpc.State_FAILED
+		j.err = err
+	case ctx.Err() != nil:
+		j.state = rpc.State_CANCELLED
+	default:
+		j.state = rpc.State_DONE
+	}
+	j.notify()
+	close(j.done)
+}
+
+// newRPCResult converts a result to the message sent to clients.
+func newRPCResult(r report.RecordedResult) *rpc.Result {
+	res := &rpc.Result{
+		Item:                r.Item,
+		Hostname:            r.Hostname,
+		Hidden:              r.Hidden,
+		PotentialSuffix:     r.PotentialSuffix,
+		PotentialDelegation: r.PotentialDelegation,
+		Nameservers:         r.Nameservers,
+	}
+
+	for _, req := range r.Requests {
+		request := &rpc.Request{
+			Type:   req.Type,
+			Status: req.Status,
+			Error:  req.Error,
+			Server: req.Server,
+			RttMs:  req.RTT,
+			Hidden: req.Hidden,
+		}
+
+		for _, response := range req.Responses {
+			request.Responses = append(request.Responses, &rpc.Response{
+				Type:   response.Type,
+				Data:   response.Data,
+				Ttl:    uint32(response.TTL),
+				Hidden: response.Hidden,
+			})
+		}
+
+		res.Requests = append(res.Requests, request)
+	}
+
+	return res
+}
+
+// JobServer implements the gRPC service, jobs run in the same process.
+type JobServer struct {
+	// wordlistDir is the directory jobs may read wordlists from, reading
+	// files is not allowed if it is empty.
+	wordlistDir string
+
+	// retention is the time finished jobs are kept.
+	retention time.Duration
+
+	// maxRunning is the number of jobs which may run at the same time.
+	maxRunning int
+
+	mu      sync.Mutex
+	nextID  int
+	running int
+	jobs    map[string]*job
+}
+
+// NewJobServer returns a new server without any jobs. Jobs can read wordlists
+// from files in wordlistDir, if it is not empty.
+func NewJobServer(wordlistDir string) *JobServer {
+	return &JobServer{
+		wordlistDir: wordlistDir,
+		retention:   jobRetention,
+		maxRunning:  maxRunningJobs,
+		jobs:        make(map[string]*job),
+	}
+}
+
+// wordlistFile returns the path of the wordlist name within dir. Paths
+// leading outside of dir (also via symlinks) are rejected.
+func wordlistFile(dir, name string) (string, error) {
+	if dir == "" {
+		return "", errors.New("reading wordlists is not enabled on the server")
+	}
+
+	if filepath.IsAbs(name) {
+		return "", errors.New("filename must be relative to the wordlist directory")
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	filename, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("wordlist %v not found", name)
+		}
+		return "", fmt.Errorf("wordlist %v is not accessible", name)
+	}
+
+	rel, err := filepath.Rel(root, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("wordlist %v is outside of the wordlist directory", name)
+	}
+
+	return filename, nil
+}
+
+// jobOptions returns the options for running the job described in req.
+// Wordlists are read from wordlistDir.
+func jobOptions(req *rpc.JobRequest, wordlistDir string) (*Options, error) {
+	opts := &Options{
+		Range:             req.Range,
+		RangeFormat:       req.RangeFormat,
+		RequestTypes:      req.RequestTypes,
+		Nameserver:        req.Nameserver,
+		Threads:           int(req.Threads),
+		RequestsPerSecond: req.RequestsPerSecond,
+		BufferSize:        100000,
+		OutputFormat:      "text",
+		Quiet:             true,
+		ShowNotFound:      req.ShowNotFound,
+		HideEmpty:         req.HideEmpty,
+		HideDelegations:   req.HideDelegations,
+		HideNetworks:      req.HideNetworks,
+		ShowNetworks:      req.ShowNetworks,
+	}
+
+	if opts.RangeFormat == "" {
+		opts.RangeFormat = "%d"
+	}
+
+	if len(opts.RequestTypes) == 0 {
+		opts.RequestTypes = []string{"A", "AAAA"}
+	}
+
+	if opts.Threads == 0 {
+		opts.Threads = 2
+	}
+
+	if opts.Threads > maxJobThreads {
+		return nil, fmt.Errorf("at most %d threads are allowed for a job", maxJobThreads)
+	}
+
+	if opts.RequestsPerSecond == 0 {
+		opts.RequestsPerSecond = defaultJobRate
+	}
+
+	if opts.RequestsPerSecond > maxJobRate {
+		return nil, fmt.Errorf("at most %d requests per second are allowed for a job", maxJobRate)
+	}
+
+	if len(req.Values) > maxJobValues {
+		return nil, fmt.Errorf("at most %d values are allowed for a job", maxJobValues)
+	}
+
+	if len(req.Values) > 0 {
+		if req.Filename != "" || opts.Range != "" {
+			return nil, errors.New("only one of values, filename and range can be specified")
+		}
+
+		// the values are read from the request instead of a file, see run
+		opts.Filename = "-"
+	}
+
+	if req.Filename != "" {
+		filename, err := wordlistFile(wordlistDir, req.Filename)
+		if err != nil {
+			return nil, err
+		}
+		opts.Filename = filename
+	}
+
+	err := opts.valid()
+	if err != nil {
+		return nil, err
+	}
+
+	// not a terminal, but results are not printed anyway
+	opts.Quiet = true
+
+	return opts, nil
+}
+
+// StartJob starts a new job.
+func (s *JobServer) StartJob(_ context.Context, req *rpc.JobRequest) (*rpc.JobStatus, error) {
+	hostname := req.Hostname
+	if !strings.Contains(hostname, "FUZZ") {
+		return nil, status.Error(codes.InvalidArgument, `hostname does not contain the string "FUZZ"`)
+	}
+
+	if !strings.HasSuffix(hostname, ".") {
+		hostname += "."
+	}
+
+	opts, err := jobOptions(req, s.wordlistDir)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if opts.Nameserver == "" {
+		opts.Nameserver, err = resolve.FindSystemNameserver()
+		if err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+
+	s.mu.Lock()
+	if s.running >= s.maxRunning {
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.ResourceExhausted, "at most %d jobs can run at the same time", s.maxRunning)
+	}
+
+	// the job runs until it is done or cancelled, independent of the request
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.running++
+	s.nextID++
+	j := &job{
+		id:            strconv.Itoa(s.nextID),
+		cancel:        cancel,
+		throttle:      producer.NewThrottle(opts.RequestsPerSecond, 1),
+		includeHidden: req.IncludeHidden,
+		state:         rpc.State_RUNNING,
+		changed:       make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	s.jobs[j.id] = j
+	s.mu.Unlock()
+
+	go func() {
+		err := s.run(ctx, j, opts, hostname, req.Values)
+
+		// make room for a new job before clients waiting for this one
+		// are woken up
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+
+		j.finish(ctx, err)
+		cancel()
+
+		time.AfterFunc(s.retention, func() {
+			s.mu.Lock()
+			delete(s.jobs, j.id)
+			s.mu.Unlock()
+		})
+	}()
+
+	return j.status(), nil
+}
+
+// run processes all values for the job.
+func (s *JobServer) run(ctx context.Context, j *job, opts *Options, hostname string, values []string) error {
+	setTotal := func(total int) {
+		j.mu.Lock()
+		j.progress.Total = int64(total)
+		j.mu.Unlock()
+	}
+
+	return resolveAll(ctx, opts, hostname, values, j.throttle, setTotal, j.add)
+}
+
+// job returns the job with the ID.
+func (s *JobServer) job(id *rpc.JobID) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id.Id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", id.Id)
+	}
+	return j, nil
+}
+
+// CancelJob stops a running job.
+func (s *JobServer) CancelJob(_ context.Context, id *rpc.JobID) (*rpc.JobStatus, error) {
+	j, err := s.job(id)
+	if err != nil {
+		return nil, err
+	}
+
+	j.cancel()
+
+	// wait until the job has stopped
+	<-j.done
+	return j.status(), nil
+}
+
+// StreamResults sends the results of the job to the client. Results which
+// have already been dropped are skipped.
+func (s *JobServer) StreamResults(id *rpc.JobID, stream rpc.Taifun_StreamResultsServer) error {
+	j, err := s.job(id)
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	for {
+		j.mu.Lock()
+		if sent < j.dropped {
+			sent = j.dropped
+		}
+		results := j.results[sent-j.dropped:]
+		state, changed := j.state, j.changed
+		j.mu.Unlock()
+
+		for _, res := range results {
+			err := stream.Send(res)
+			if err != nil {
+				return err
+			}
+			sent++
+		}
+
+		// all results have been sent when the job was done before
+		if state != rpc.State_RUNNING {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// StreamProgress sends the progress of the job to the client regularly.
+func (s *JobServer) StreamProgress(id *rpc.JobID, stream rpc.Taifun_StreamProgressServer) error {
+	j, err := s.job(id)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		j.mu.Lock()
+		progress := j.progress
+		progress.State = j.state
+		j.mu.Unlock()
+
+		progress.Rate = j.throttle.Throughput()
+
+		err := stream.Send(&progress)
+		if err != nil {
+			return err
+		}
+
+		if progress.State != rpc.State_RUNNING {
+			return nil
+		}
+
+		// send an update once per interval, and when the job is done
+		select {
+		case <-ticker.C:
+		case <-j.done:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// checkToken returns an error unless the metadata of the call in ctx carries
+// token as "authorization: Bearer TOKEN", like the HTTP APIs.
+func checkToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		if strings.HasPrefix(auth, "Bearer ") && subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) == 1 {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
+
+// tokenInterceptors returns the server options which reject all calls
+// without token.
+func tokenInterceptors(token string) []grpc.ServerOption {
+	unary := func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		err := checkToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+
+	stream := func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := checkToken(ss.Context(), token)
+		if err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+
+	return []grpc.ServerOption{grpc.UnaryInterceptor(unary), grpc.StreamInterceptor(stream)}
+}
+
+func newGRPCServerCommand() *cobra.Command {
+	var listen, wordlistDir, token string
+
+	cmd := &cobra.Command{
+		Use:                   "grpc-server [options]",
+		Short:                 "Run jobs controlled via gRPC (see rpc/taifun.proto)",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return errors.New("no arguments expected")
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				generated := token == ""
+				if generated {
+					var err error
+					token, err = newToken()
+					if err != nil {
+						return err
+					}
+				}
+
+				ln, err := net.Listen("tcp", listen)
+				if err != nil {
+					return err
+				}
+
+				srv := grpc.NewServer(tokenInterceptors(token)...)
+				rpc.RegisterTaifunServer(srv, NewJobServer(wordlistDir))
+
+				fmt.Printf("serving gRPC on %v\n", ln.Addr())
+
+				// clients cannot connect without the generated token
+				if generated {
+					fmt.Printf("clients must send the metadata \"authorization: Bearer %v\"\n", token)
+				}
+
+				g.Go(func() error {
+					<-ctx.Done()
+					srv.Stop()
+					return nil
+				})
+
+				return srv.Serve(ln)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", "localhost:8055", "serve gRPC on `addr`")
+	cmd.Flags().StringVar(&wordlistDir, "wordlist-dir", "", "allow jobs to read wordlists from files in `dir`")
+	cmd.Flags().StringVar(&token, "token", "", "require clients to send `token` as the metadata \"authorization: Bearer TOKEN\" (default: generate a random token)")
+
+	return cmd
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/dnstest"
+	"github.com/happal/taifun/resolve"
+	"github.com/happal/taifun/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestWordlistFile(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	dir := filepath.Join(tempdir, "wordlists")
+	for _, name := range []string{"wordlists/sub", "secret"} {
+		err = os.MkdirAll(filepath.Join(tempdir, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"wordlists/words.txt", "wordlists/sub/more.txt", "secret/passwd"} {
+		err = ioutil.WriteFile(filepath.Join(tempdir, name), []byte("www\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = os.Symlink(filepath.Join(tempdir, "secret", "passwd"), filepath.Join(dir, "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		dir  string
+		name string
+		want string // relative to dir, empty if an error is expected
+	}{
+		{dir, "words.txt", "words.txt"},
+		{dir, "sub/more.txt", "sub/more.txt"},
+		{dir, "sub/../words.txt", "words.txt"},
+		{dir, "../secret/passwd", ""},
+		{dir, "sub/../../secret/passwd", ""},
+		{dir, filepath.Join(tempdir, "secret", "passwd"), ""},
+		{dir, filepath.Join(dir, "words.txt"), ""},
+		{dir, "link.txt", ""},
+		{dir, "missing.txt", ""},
+		{"", "words.txt", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename, err := wordlistFile(test.dir, test.name)
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("expected error not returned, got %v", filename)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			root, err := filepath.EvalSymlinks(test.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := filepath.Join(root, test.want); filename != want {
+				t.Errorf("wrong filename, want %v, got %v", want, filename)
+			}
+		})
+	}
+}
+
+func TestJobDropResults(t *testing.T) {
+	j := &job{changed: make(chan struct{})}
+
+	for i := 0; i < 2*maxJobResults; i++ {
+		j.add(resolve.Result{Item: "www", Hostname: "www.example.com"})
+	}
+
+	if len(j.results) != maxJobResults {
+		t.Errorf("wrong number of results kept, want %d, got %d", maxJobResults, len(j.results))
+	}
+
+	if j.dropped != maxJobResults {
+		t.Errorf("wrong number of results dropped, want %d, got %d", maxJobResults, j.dropped)
+	}
+
+	if j.progress.Sent != 2*maxJobResults {
+		t.Errorf("wrong number of results sent, want %d, got %d", 2*maxJobResults, j.progress.Sent)
+	}
+}
+
+func TestJobServerRetention(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com", "www.example.com. 300 IN A 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	s := NewJobServer("")
+	s.retention = 50 * time.Millisecond
+
+	_, err = s.StartJob(context.Background(), &rpc.JobRequest{
+		Hostname:   "FUZZ.example.com",
+		Values:     []string{"www", "mail"},
+		Nameserver: srv.Addr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := &rpc.JobID{Id: "1"}
+	j, err := s.job(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-j.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("job did not finish")
+	}
+
+	if st := j.status(); st.State != rpc.State_DONE {
+		t.Fatalf("job has wrong state %v: %v", st.State, st.Error)
+	}
+
+	// the result for mail is hidden since it does not exist
+	if len(j.results) != 1 || j.progress.Sent != 2 {
+		t.Errorf("wrong number of results, want 1 of 2, got %d of %d", len(j.results), j.progress.Sent)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, err = s.job(id)
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("job was not removed, got error %v", err)
+	}
+}
+
+func TestJobServerRejectFilename(t *testing.T) {
+	s := NewJobServer("")
+	_, err := s.StartJob(context.Background(), &rpc.JobRequest{
+		Hostname:   "FUZZ.example.com",
+		Filename:   "/etc/passwd",
+		Nameserver: "127.0.0.1:53",
+	})
+
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("wrong error returned: %v", err)
+	}
+}
+
+func TestJobServerRejectThreads(t *testing.T) {
+	s := NewJobServer("")
+	_, err := s.StartJob(context.Background(), &rpc.JobRequest{
+		Hostname:   "FUZZ.example.com",
+		Values:     []string{"www"},
+		Nameserver: "127.0.0.1:53",
+		Threads:    maxJobThreads + 1,
+	})
+
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("wrong error returned: %v", err)
+	}
+}
+
+func TestJobServerRejectLimits(t *testing.T) {
+	var tests = []struct {
+		name string
+		req  *rpc.JobRequest
+	}{
+		{
+			name: "values",
+			req: &rpc.JobRequest{
+				Hostname:   "FUZZ.example.com",
+				Values:     make([]string, maxJobValues+1),
+				Nameserver: "127.0.0.1:53",
+			},
+		},
+		{
+			name: "rate",
+			req: &rpc.JobRequest{
+				Hostname:          "FUZZ.example.com",
+				Values:            []string{"www"},
+				Nameserver:        "127.0.0.1:53",
+				RequestsPerSecond: maxJobRate + 1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewJobServer("")
+			_, err := s.StartJob(context.Background(), test.req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("wrong error returned: %v", err)
+			}
+		})
+	}
+}
+
+func TestJobOptionsDefaultRate(t *testing.T) {
+	opts, err := jobOptions(&rpc.JobRequest{Values: []string{"www"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.RequestsPerSecond != defaultJobRate {
+		t.Errorf("wrong rate, want %v, got %v", defaultJobRate, opts.RequestsPerSecond)
+	}
+}
+
+func TestJobServerRunningJobs(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com", "www.example.com. 300 IN A 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	s := NewJobServer("")
+	s.maxRunning = 1
+
+	// the first job runs until it is cancelled because of the low rate
+	req := &rpc.JobRequest{
+		Hostname:          "FUZZ.example.com",
+		Range:             "1-1000",
+		Nameserver:        srv.Addr,
+		RequestsPerSecond: 1,
+	}
+
+	st, err := s.StartJob(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.StartJob(context.Background(), req)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("wrong error returned: %v", err)
+	}
+
+	_, err = s.CancelJob(context.Background(), &rpc.JobID{Id: st.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = s.StartJob(context.Background(), req)
+	if err != nil {
+		t.Fatalf("job was not started after the first one was cancelled: %v", err)
+	}
+
+	_, err = s.CancelJob(context.Background(), &rpc.JobID{Id: st.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGRPCServerToken(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer(tokenInterceptors("secret")...)
+	rpc.RegisterTaifunServer(srv, NewJobServer(""))
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := rpc.NewTaifunClient(conn)
+
+	var tests = []struct {
+		token string
+		code  codes.Code
+	}{
+		{"", codes.Unauthenticated},
+		{"wrong", codes.Unauthenticated},
+		{"secre", codes.Unauthenticated},
+		// the job does not exist, but the call was accepted
+		{"secret", codes.NotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.token, func(t *testing.T) {
+			ctx := context.Background()
+			if test.token != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+test.token)
+			}
+
+			_, err := client.CancelJob(ctx, &rpc.JobID{Id: "1"})
+			if status.Code(err) != test.code {
+				t.Errorf("unary call: wrong error, want %v, got %v", test.code, err)
+			}
+
+			stream, err := client.StreamProgress(ctx, &rpc.JobID{Id: "1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = stream.Recv()
+			if status.Code(err) != test.code {
+				t.Errorf("streaming call: wrong error, want %v, got %v", test.code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package hyperloglog estimates the number of distinct values in a set with a
+// fixed amount of memory.
+package hyperloglog
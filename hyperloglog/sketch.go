@@ -0,0 +1,91 @@
+package hyperloglog
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Sketch estimates the number of distinct strings added to it. It uses 2^p
+// bytes of memory, the standard error of the estimate is about 1.04/sqrt(2^p).
+type Sketch struct {
+	p         uint8
+	registers []uint8
+}
+
+// New returns a new sketch with precision p, which must be between 4 and 18.
+func New(p uint8) *Sketch {
+	if p < 4 || p > 18 {
+		panic("invalid precision")
+	}
+
+	return &Sketch{
+		p:         p,
+		registers: make([]uint8, 1<<p),
+	}
+}
+
+// hash returns a 64 bit hash for s. The FNV hash is mixed with the finalizer
+// from SplitMix64 so that similar strings (e.g. addresses from the same
+// network) are distributed evenly.
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	x := h.Sum64()
+
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Add adds s to the sketch.
+func (s *Sketch) Add(str string) {
+	x := hash(str)
+
+	// the first p bits select the register, the rest is used to count the
+	// leading zeroes
+	index := x >> (64 - s.p)
+	rank := uint8(bits.LeadingZeros64(x<<s.p|1<<(s.p-1))) + 1
+
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+	}
+}
+
+// Count returns the estimated number of distinct strings added to the sketch.
+func (s *Sketch) Count() uint64 {
+	m := float64(len(s.registers))
+
+	var sum float64
+	var zeroes int
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeroes++
+		}
+	}
+
+	var alpha float64
+	switch len(s.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+
+	estimate := alpha * m * m / sum
+
+	// use linear counting for small cardinalities
+	if estimate <= 2.5*m && zeroes > 0 {
+		estimate = m * math.Log(m/float64(zeroes))
+	}
+
+	return uint64(estimate + 0.5)
+}
@@ -0,0 +1,29 @@
+package hyperloglog
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestSketch(t *testing.T) {
+	var tests = []int{0, 1, 100, 1000, 50000, 500000}
+
+	for _, n := range tests {
+		t.Run(fmt.Sprintf("%d", n), func(t *testing.T) {
+			s := New(14)
+			for i := 0; i < n; i++ {
+				value := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+				// add each value twice, duplicates must not be counted
+				s.Add(value)
+				s.Add(value)
+			}
+
+			count := s.Count()
+			diff := math.Abs(float64(count) - float64(n))
+			if diff > 0.03*float64(n)+1 {
+				t.Fatalf("estimate is too far off, want %v, got %v", n, count)
+			}
+		})
+	}
+}
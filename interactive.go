@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/happal/taifun/filter"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+)
+
+// minRate is the lowest rate limit which can be selected interactively.
+const minRate = 0.5
+
+// Controller reacts to key presses while the program is running: it can pause
+// the producer, change the rate limit, toggle the display of "not found"
+// responses and print the details of the last result.
+type Controller struct {
+	term     report.Printer
+	throttle *producer.Throttle
+
+	mu           sync.Mutex
+	showNotFound bool
+	last         *resolve.Result
+}
+
+// NewController returns a new controller which prints messages to term.
+func NewController(term report.Printer, throttle *producer.Throttle, showNotFound bool) *Controller {
+	return &Controller{
+		term:         term,
+		throttle:     throttle,
+		showNotFound: showNotFound,
+	}
+}
+
+const controllerHelp = "keys: p pause/resume, n show/hide 'not found' responses, +/- change rate limit, 0 remove rate limit, d details for last result, h help"
+
+// FilterNotFound returns a filter which hides "not found" responses unless
+// they have been enabled via the controller.
+func (c *Controller) FilterNotFound() filter.Request {
+	return filter.RequestFunc(func(r resolve.Request) (reject bool) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		return r.NotFound && !c.showNotFound
+	})
+}
+
+// Run passes all results from in to out and remembers the last one.
+func (c *Controller) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forward(ctx, in, out, func(res resolve.Result) error {
+		c.mu.Lock()
+		c.last = &res
+		c.mu.Unlock()
+		return nil
+	})
+}
+
+// ReadKeys processes the key presses read from rd until the context is
+// cancelled or rd returns an error.
+func (c *Controller) ReadKeys(ctx context.Context, rd io.Reader) {
+	keys := make(chan byte)
+
+	// reading from the terminal blocks and cannot be interrupted, so the
+	// goroutine is not waited for
+	go func() {
+		br := bufio.NewReader(rd)
+		for {
+			key, err := br.ReadByte()
+			if err != nil {
+				close(keys)
+				return
+			}
+
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	c.term.Printf("%s\n", controllerHelp)
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return
+			}
+			c.handleKey(key)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// formatRate returns a human-readable description of the rate limit.
+func formatRate(perSecond float64) string {
+	if perSecond == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%.1f requests per second", perSecond)
+}
+
+// handleKey runs the action for a key.
+func (c *Controller) handleKey(key byte) {
+	switch key {
+	case 'p', ' ':
+		if c.throttle.Paused() {
+			c.throttle.Resume()
+			c.term.Printf("resumed")
+		} else {
+			c.throttle.Pause()
+			c.term.Printf("paused, requests in flight are still processed, press p to resume")
+		}
+
+	case 'n':
+		c.mu.Lock()
+		c.showNotFound = !c.showNotFound
+		show := c.showNotFound
+		c.mu.Unlock()
+
+		if show {
+			c.term.Printf("showing 'not found' responses")
+		} else {
+			c.term.Printf("hiding 'not found' responses")
+		}
+
+	case '+', '=':
+		rate := c.throttle.Rate()
+		if rate == 0 {
+			c.term.Printf("rate limit: %s", formatRate(rate))
+			return
+		}
+		c.throttle.SetRate(rate * 2)
+		c.term.Printf("rate limit: %s", formatRate(c.throttle.Rate()))
+
+	case '-':
+		rate := c.throttle.Rate()
+		if rate == 0 {
+			// start from the current throughput
+			rate = c.throttle.Throughput()
+		}
+
+		rate /= 2
+		if rate < minRate {
+			rate = minRate
+		}
+		c.throttle.SetRate(rate)
+		c.term.Printf("rate limit: %s", formatRate(rate))
+
+	case '0':
+		c.throttle.SetRate(0)
+		c.term.Printf("rate limit: %s", formatRate(0))
+
+	case 'd':
+		c.mu.Lock()
+		last := c.last
+		c.mu.Unlock()
+
+		if last == nil {
+			c.term.Printf("no result received yet")
+			return
+		}
+		c.term.Printf("%s", formatDetails(*last))
+
+	case 'h', '?':
+		c.term.Printf("%s", controllerHelp)
+	}
+}
+
+// formatDetails returns a multi-line description of all requests and
+// responses in a result, including the hidden ones.
+func formatDetails(result resolve.Result) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("details for %v (item %q):", result.Hostname, result.Item))
+
+	hidden := func(hide bool) string {
+		if hide {
+			return " (hidden)"
+		}
+		return ""
+	}
+
+	for _, request := range result.Requests {
+		status := request.Status
+		if request.Error != nil {
+			status = request.Error.Error()
+		}
+
+		lines = append(lines, fmt.Sprintf("  %-5s %s from %s in %s%s",
+			request.Type, status, request.Server, report.FormatLatency(request.RTT), hidden(request.Hide)))
+
+		for _, response := range request.Responses {
+			lines = append(lines, fmt.Sprintf("        %-5s %6d  %s%s",
+				response.Type, response.TTL, response.Data, hidden(response.Hide)))
+		}
+
+		for _, response := range request.Nameserver {
+			lines = append(lines, fmt.Sprintf("        authority %s %s", response.Type, response.Data))
+		}
+
+		for _, response := range request.SOA {
+			lines = append(lines, fmt.Sprintf("        authority %s %s", response.Type, response.Data))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
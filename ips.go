@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// IPWriter collects the addresses in the shown responses and writes the
+// unique addresses to a file at the end of the run, sorted numerically.
+type IPWriter struct {
+	filename string
+	split    bool
+
+	addrs map[string]net.IP
+}
+
+// NewIPWriter returns a new writer for filename. If split is set, IPv4 and
+// IPv6 addresses are written to separate files, with the suffixes -v4 and -v6
+// added to the name (e.g. ips-v4.txt).
+func NewIPWriter(filename string, split bool) *IPWriter {
+	return &IPWriter{
+		filename: filename,
+		split:    split,
+		addrs:    make(map[string]net.IP),
+	}
+}
+
+// splitFilename inserts suffix into filename before the extension.
+func splitFilename(filename, suffix string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + suffix + ext
+}
+
+// writeAddresses writes the addresses to filename, one per line.
+func writeAddresses(filename string, addrs []net.IP) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		_, err = fmt.Fprintln(f, addr)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// Run reads results from in and forwards them to out, collecting the
+// addresses on the way. When in is closed, the addresses are written and out
+// is closed.
+func (w *IPWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	err := forward(ctx, in, out, func(res resolve.Result) error {
+		if res.Hide {
+			return nil
+		}
+
+		for _, request := range res.Requests {
+			if request.Hide {
+				continue
+			}
+
+			for _, response := range request.Responses {
+				if response.Hide || (response.Type != "A" && response.Type != "AAAA") {
+					continue
+				}
+
+				if ip := net.ParseIP(response.Data); ip != nil {
+					w.addrs[ip.String()] = ip
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var v4, v6 []net.IP
+	for _, ip := range w.addrs {
+		if ip.To4() != nil {
+			v4 = append(v4, ip.To4())
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+
+	for _, list := range [][]net.IP{v4, v6} {
+		sort.Slice(list, func(i, j int) bool {
+			return bytes.Compare(list[i], list[j]) < 0
+		})
+	}
+
+	if !w.split {
+		return writeAddresses(w.filename, append(v4, v6...))
+	}
+
+	err = writeAddresses(splitFilename(w.filename, "-v4"), v4)
+	if err != nil {
+		return err
+	}
+
+	return writeAddresses(splitFilename(w.filename, "-v6"), v6)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happal/taifun/resolve"
+)
+
+func TestIPWriter(t *testing.T) {
+	answers := func(responses ...resolve.Response) []resolve.Request {
+		return []resolve.Request{{Type: "A", Responses: responses}}
+	}
+
+	results := []resolve.Result{
+		{Item: "www", Requests: answers(
+			resolve.Response{Type: "A", Data: "192.0.2.10"},
+			resolve.Response{Type: "A", Data: "192.0.2.9"},
+			resolve.Response{Type: "AAAA", Data: "2001:db8::10"},
+		)},
+		{Item: "mail", Requests: answers(
+			// addresses are written once, IPv6 addresses in the short form
+			resolve.Response{Type: "A", Data: "192.0.2.10"},
+			resolve.Response{Type: "AAAA", Data: "2001:0db8:0000::2"},
+			resolve.Response{Type: "CNAME", Data: "www.example.com."},
+			resolve.Response{Type: "MX", Data: "10 mx.example.com."},
+		)},
+		// hidden results and responses are ignored
+		{Item: "hidden", Hide: true, Requests: answers(
+			resolve.Response{Type: "A", Data: "198.51.100.1"},
+		)},
+		{Item: "ftp", Requests: answers(
+			resolve.Response{Type: "A", Data: "198.51.100.2", Hide: true},
+			resolve.Response{Type: "A", Data: "10.0.0.1"},
+		)},
+	}
+
+	var tests = []struct {
+		name  string
+		split bool
+		want  map[string]string
+	}{
+		{
+			name: "combined",
+			want: map[string]string{
+				"ips.txt": "10.0.0.1\n192.0.2.9\n192.0.2.10\n2001:db8::2\n2001:db8::10\n",
+			},
+		},
+		{
+			name:  "split",
+			split: true,
+			want: map[string]string{
+				"ips-v4.txt": "10.0.0.1\n192.0.2.9\n192.0.2.10\n",
+				"ips-v6.txt": "2001:db8::2\n2001:db8::10\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempdir, err := ioutil.TempDir("", "taifun-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = os.RemoveAll(tempdir)
+			}()
+
+			in := make(chan resolve.Result, len(results))
+			for _, res := range results {
+				in <- res
+			}
+			close(in)
+
+			out := make(chan resolve.Result, len(results))
+
+			err = NewIPWriter(filepath.Join(tempdir, "ips.txt"), test.split).Run(context.Background(), in, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(out) != len(results) {
+				t.Errorf("wrong number of results forwarded, want %d, got %d", len(results), len(out))
+			}
+
+			files, err := ioutil.ReadDir(tempdir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(files) != len(test.want) {
+				t.Errorf("wrong number of files written, want %d, got %d", len(test.want), len(files))
+			}
+
+			for name, want := range test.want {
+				buf, err := ioutil.ReadFile(filepath.Join(tempdir, name))
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+
+				if string(buf) != want {
+					t.Errorf("wrong content for %v, want:\n%s\ngot:\n%s", name, want, buf)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitFilename(t *testing.T) {
+	var tests = []struct {
+		filename string
+		suffix   string
+		want     string
+	}{
+		{"ips.txt", "-v4", "ips-v4.txt"},
+		{"/tmp/run/ips.txt", "-v6", "/tmp/run/ips-v6.txt"},
+		{"ips", "-v4", "ips-v4"},
+		{"scan.ips.txt", "-v6", "scan.ips-v6.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			got := splitFilename(test.filename, test.suffix)
+			if got != test.want {
+				t.Errorf("wrong name, want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
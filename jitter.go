@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseJitter parses a range of durations like "50ms-500ms". A single
+// duration is used as the maximum with a minimum of zero.
+func parseJitter(s string) (min, max time.Duration, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	if i := strings.Index(s, "-"); i >= 0 {
+		min, err = time.ParseDuration(s[:i])
+		if err == nil {
+			max, err = time.ParseDuration(s[i+1:])
+		}
+	} else {
+		max, err = time.ParseDuration(s)
+	}
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid jitter %q, expected a range like 50ms-500ms: %v", s, err)
+	}
+
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("invalid jitter %q, expected a range like 50ms-500ms", s)
+	}
+
+	return min, max, nil
+}
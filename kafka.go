@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Limits for batching the results published to Kafka.
+const (
+	kafkaBatchSize     = 100
+	kafkaFlushInterval = time.Second
+
+	// kafkaQueueSize is the number of batches waiting to be published,
+	// further batches are dropped so that slow brokers do not stall the scan.
+	kafkaQueueSize = 10
+
+	// kafkaBatchTimeout is the time the producer waits for more messages
+	// for a partition. The batches are collected before, so they are sent
+	// right away instead of after the default of one second.
+	kafkaBatchTimeout = 10 * time.Millisecond
+
+	// kafkaMaxAttempts is the number of attempts the producer makes to
+	// publish a batch before it is dropped.
+	kafkaMaxAttempts = 3
+
+	// kafkaFinishTimeout is the time to publish the last batch after the
+	// context has been cancelled.
+	kafkaFinishTimeout = 10 * time.Second
+)
+
+// kafkaSASLMechanisms are the values accepted by --kafka-sasl.
+var kafkaSASLMechanisms = []string{"plain", "scram-sha-256", "scram-sha-512"}
+
+// kafkaProducer publishes messages, it is implemented by kafka.Writer.
+type kafkaProducer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaWriter publishes the shown results to a Kafka topic, in the same JSON
+// format as the results in the log file. The host name is used as the key, so
+// all results for a host name end up in the same partition.
+//
+// The batches are published in a separate goroutine. Errors publishing the
+// results do not abort the scan: the producer retries a batch, when this
+// fails the batch is dropped and the error is printed. When the brokers are
+// too slow and the queue is full, new batches are dropped as well.
+type KafkaWriter struct {
+	term     cli.Terminal
+	producer kafkaProducer
+
+	batch []kafka.Message
+	queue chan []kafka.Message
+	done  chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+	pending [][]kafka.Message // batches not published because the context was cancelled
+}
+
+// kafkaMechanism returns the SASL mechanism for name.
+func kafkaMechanism(name, username, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, username, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, username, password)
+	default:
+		return nil, fmt.Errorf("unknown SASL mechanism %q, valid: %v", name, strings.Join(kafkaSASLMechanisms, ", "))
+	}
+}
+
+// NewKafkaWriter returns a new writer publishing to the topic and brokers
+// configured in opts.
+func NewKafkaWriter(term cli.Terminal, opts *Options) (*KafkaWriter, error) {
+	mechanism, err := kafkaMechanism(opts.KafkaSASL, opts.KafkaUsername, opts.kafkaPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &kafka.Transport{
+		SASL: mechanism,
+	}
+
+	if opts.KafkaTLS {
+		transport.TLS = &tls.Config{}
+	}
+
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(opts.KafkaBrokers...),
+		Topic:        opts.KafkaTopic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    kafkaBatchSize,
+		BatchTimeout: kafkaBatchTimeout,
+		MaxAttempts:  kafkaMaxAttempts,
+		Transport:    transport,
+	}
+
+	return &KafkaWriter{
+		term:     term,
+		producer: w,
+	}, nil
+}
+
+// publish sends the batch to the brokers. When this fails, the results are
+// dropped, unless the context was cancelled: then they are kept for finish.
+func (w *KafkaWriter) publish(ctx context.Context, batch []kafka.Message) {
+	err := w.producer.WriteMessages(ctx, batch...)
+	if err == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if ctx.Err() != nil {
+		w.pending = append(w.pending, batch)
+		return
+	}
+
+	cli.Logf(w.term, cli.LevelError, nil, "publishing %d results to Kafka failed, dropping them: %v", len(batch), err)
+	w.dropped += len(batch)
+}
+
+// publishQueued publishes the batches from the queue until it is closed.
+// After the context has been cancelled, the batches are kept for finish.
+func (w *KafkaWriter) publishQueued(ctx context.Context) {
+	defer close(w.done)
+
+	for batch := range w.queue {
+		if ctx.Err() != nil {
+			w.mu.Lock()
+			w.pending = append(w.pending, batch)
+			w.mu.Unlock()
+			continue
+		}
+
+		w.publish(ctx, batch)
+	}
+}
+
+// flush queues the collected results for publishing. If the queue is full,
+// the results are dropped.
+func (w *KafkaWriter) flush() {
+	if len(w.batch) == 0 {
+		return
+	}
+
+	select {
+	case w.queue <- w.batch:
+	default:
+		cli.Logf(w.term, cli.LevelError, nil, "publishing to Kafka is too slow, dropping %d results", len(w.batch))
+		w.mu.Lock()
+		w.dropped += len(w.batch)
+		w.mu.Unlock()
+	}
+
+	// the queued batch belongs to the publishing goroutine now
+	w.batch = nil
+}
+
+// finish waits for the queued batches and publishes the remaining results
+// with a new context, since ctx may already have been cancelled, and closes
+// the producer.
+func (w *KafkaWriter) finish() {
+	close(w.queue)
+	<-w.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaFinishTimeout)
+	defer cancel()
+
+	batches := append(w.pending, w.batch)
+	w.pending, w.batch = nil, nil
+	for _, batch := range batches {
+		if len(batch) > 0 {
+			w.publish(ctx, batch)
+		}
+	}
+
+	// batches which could not be published before the timeout
+	for _, batch := range w.pending {
+		w.dropped += len(batch)
+	}
+
+	if w.dropped > 0 {
+		w.term.Printf("%d results were not published to Kafka", w.dropped)
+	}
+
+	err := w.producer.Close()
+	if err != nil {
+		cli.Warnf(w.term, "closing the Kafka producer failed: %v", err)
+	}
+}
+
+// Run reads results from in and forwards them to out, publishing them on the
+// way in batches. When in is closed or the context is cancelled, the
+// remaining results are published and out is closed.
+func (w *KafkaWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	defer close(out)
+
+	w.queue = make(chan []kafka.Message, kafkaQueueSize)
+	w.done = make(chan struct{})
+	go w.publishQueued(ctx)
+	defer w.finish()
+
+	ticker := time.NewTicker(kafkaFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		var res resolve.Result
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			w.flush()
+			continue
+
+		case res, ok = <-in:
+			if !ok {
+				return nil
+			}
+		}
+
+		if !res.Hide {
+			buf, err := json.Marshal(report.NewResult(res, false))
+			if err != nil {
+				return err
+			}
+
+			w.batch = append(w.batch, kafka.Message{
+				Key:   []byte(res.Hostname),
+				Value: buf,
+			})
+
+			if len(w.batch) >= kafkaBatchSize {
+				w.flush()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- res:
+		}
+	}
+}
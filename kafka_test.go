@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+	"github.com/segmentio/kafka-go"
+)
+
+// testProducer records the messages published, the first failures calls to
+// WriteMessages return an error. When block is set, the first call to
+// WriteMessages waits until the context is cancelled, blocking is closed
+// (if set) when it starts waiting.
+type testProducer struct {
+	mu       sync.Mutex
+	failures int
+	block    bool
+	blocking chan struct{}
+	calls    int
+	messages []kafka.Message
+	closed   bool
+}
+
+func (p *testProducer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	p.mu.Lock()
+	p.calls++
+	block := p.block
+	p.block = false
+	if p.failures > 0 {
+		p.failures--
+		p.mu.Unlock()
+		return errors.New("broker not available")
+	}
+	p.mu.Unlock()
+
+	if block {
+		if p.blocking != nil {
+			close(p.blocking)
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	p.mu.Lock()
+	p.messages = append(p.messages, msgs...)
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *testProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+// runKafkaWriter sends results through w and returns the number of results
+// forwarded. The context is cancelled after cancelAfter results if it is
+// positive.
+func runKafkaWriter(t testing.TB, w *KafkaWriter, results []resolve.Result, cancelAfter int) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan resolve.Result)
+	out := make(chan resolve.Result)
+
+	go func() {
+		defer close(in)
+		for i, res := range results {
+			if cancelAfter > 0 && i == cancelAfter {
+				cancel()
+				return
+			}
+			select {
+			case in <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var forwarded int
+	done := make(chan struct{})
+	go func() {
+		for range out {
+			forwarded++
+		}
+		close(done)
+	}()
+
+	err := w.Run(ctx, in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	return forwarded
+}
+
+func kafkaTestResults(n int) []resolve.Result {
+	var results []resolve.Result
+	for i := 0; i < n; i++ {
+		results = append(results, resolve.Result{Item: fmt.Sprintf("%d", i), Hostname: fmt.Sprintf("%d.example.com", i)})
+	}
+	return results
+}
+
+func TestKafkaWriter(t *testing.T) {
+	var tests = []struct {
+		name        string
+		results     int
+		cancelAfter int
+		producer    *testProducer
+		published   int
+		errors      []string
+	}{
+		{
+			name:      "all",
+			results:   2*kafkaBatchSize + 10,
+			producer:  &testProducer{},
+			published: 2*kafkaBatchSize + 10,
+		},
+		{
+			name:      "drop-failed-batch",
+			results:   2*kafkaBatchSize + 10,
+			producer:  &testProducer{failures: 1},
+			published: kafkaBatchSize + 10,
+			errors: []string{
+				"publishing 100 results to Kafka failed, dropping them: broker not available",
+				"100 results were not published to Kafka",
+			},
+		},
+		{
+			name:        "flush-on-cancel",
+			results:     kafkaBatchSize + 20,
+			cancelAfter: 10,
+			producer:    &testProducer{},
+			published:   10,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			term := &testTerminal{}
+			w := &KafkaWriter{term: term, producer: test.producer}
+
+			results := kafkaTestResults(test.results)
+			results = append(results, resolve.Result{Hostname: "hidden.example.com", Hide: true})
+			runKafkaWriter(t, w, results, test.cancelAfter)
+
+			if len(test.producer.messages) != test.published {
+				t.Errorf("wrong number of messages published, want %d, got %d", test.published, len(test.producer.messages))
+			}
+
+			for _, msg := range test.producer.messages {
+				if string(msg.Key) == "hidden.example.com" {
+					t.Errorf("hidden result was published")
+				}
+				if !strings.Contains(string(msg.Value), `"hostname":"`+string(msg.Key)+`"`) {
+					t.Errorf("message for %s has wrong value %s", msg.Key, msg.Value)
+				}
+			}
+
+			if !test.producer.closed {
+				t.Errorf("producer was not closed")
+			}
+
+			if strings.Join(term.lines, "\n") != strings.Join(test.errors, "\n") {
+				t.Errorf("wrong messages printed, want:\n  %q\ngot:\n  %q", test.errors, term.lines)
+			}
+		})
+	}
+}
+
+func TestKafkaWriterCancelDuringFlush(t *testing.T) {
+	term := &testTerminal{}
+	p := &testProducer{block: true, blocking: make(chan struct{})}
+	w := &KafkaWriter{term: term, producer: p}
+
+	// the first batch is still being published when the context is
+	// cancelled, it is published again when the writer finishes
+	results := kafkaTestResults(kafkaBatchSize + 10)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	in := make(chan resolve.Result)
+	out := make(chan resolve.Result)
+	go func() {
+		for _, res := range results[:kafkaBatchSize] {
+			in <- res
+		}
+		<-p.blocking
+		cancel()
+	}()
+	go func() {
+		for range out {
+		}
+	}()
+
+	err := w.Run(ctx, in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.messages) != kafkaBatchSize || p.calls != 2 {
+		t.Errorf("wrong number of messages published, want %d in 2 calls, got %d in %d", kafkaBatchSize, len(p.messages), p.calls)
+	}
+
+	if len(term.lines) > 0 {
+		t.Errorf("unexpected messages: %q", term.lines)
+	}
+}
+
+func TestKafkaWriterSlowProducer(t *testing.T) {
+	term := &testTerminal{}
+	p := &testProducer{block: true, blocking: make(chan struct{})}
+	w := &KafkaWriter{term: term, producer: p}
+
+	// more batches than fit into the queue while the first one is still
+	// being published
+	results := kafkaTestResults((kafkaQueueSize + 5) * kafkaBatchSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan resolve.Result)
+	out := make(chan resolve.Result)
+	go func() {
+		defer close(in)
+		for _, res := range results {
+			select {
+			case in <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- w.Run(ctx, in, out)
+	}()
+
+	timeout := time.After(10 * time.Second)
+	for i := range results {
+		select {
+		case <-out:
+		case <-timeout:
+			t.Fatalf("results stalled after %d of %d", i, len(results))
+		}
+	}
+
+	select {
+	case <-p.blocking:
+	case <-timeout:
+		t.Fatal("producer was not called")
+	}
+
+	// the results have been forwarded while the producer was blocked, now
+	// let the writer finish
+	cancel()
+	for range out {
+	}
+
+	err := <-errCh
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dropped bool
+	for _, line := range term.lines {
+		if strings.HasPrefix(line, "publishing to Kafka is too slow, dropping") {
+			dropped = true
+		}
+	}
+
+	if !dropped {
+		t.Errorf("no batches dropped, messages: %q", term.lines)
+	}
+
+	if !p.closed {
+		t.Errorf("producer was not closed")
+	}
+}
+
+func TestKafkaMechanism(t *testing.T) {
+	for _, name := range append(kafkaSASLMechanisms, "", "PLAIN") {
+		_, err := kafkaMechanism(name, "user", "password")
+		if err != nil {
+			t.Errorf("mechanism %q: %v", name, err)
+		}
+	}
+
+	_, err := kafkaMechanism("gssapi", "user", "password")
+	if err == nil {
+		t.Errorf("expected error for unknown mechanism not returned")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// inputFormats lists the valid values for --input-format.
+var inputFormats = []string{"text", "csv", "json", "amass", "subfinder"}
+
+// inputLine is a line of input in the JSON format.
+type inputLine struct {
+	Item   string   `json:"item"`
+	Label  string   `json:"label"`
+	Labels []string `json:"labels"`
+}
+
+// parseInputLine returns the item and the labels from a line of input in
+// format: for "csv", the item is the first field and the other fields are
+// labels; for "json", the line contains an object with the item and a label
+// or a list of labels. For "amass" and "subfinder", the line contains the
+// JSON output of these tools and the host name is returned with the sources
+// as labels. Lines in the "text" format only contain the item.
+func parseInputLine(format, line string) (item string, labels []string, err error) {
+	switch format {
+	case "amass", "subfinder":
+		return parseHostLine(format, line)
+
+	case "csv":
+		fields, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid CSV line %q: %v", line, err)
+		}
+
+		for _, field := range fields[1:] {
+			if field = strings.TrimSpace(field); field != "" {
+				labels = append(labels, field)
+			}
+		}
+		return strings.TrimSpace(fields[0]), labels, nil
+
+	case "json":
+		var in inputLine
+		err := json.Unmarshal([]byte(line), &in)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid JSON line %q: %v", line, err)
+		}
+
+		labels = in.Labels
+		if in.Label != "" {
+			labels = append([]string{in.Label}, labels...)
+		}
+		return in.Item, labels, nil
+
+	default:
+		return line, nil, nil
+	}
+}
+
+// Labeler reads structured input and attaches the labels of the items to the
+// results.
+type Labeler struct {
+	format   string
+	template string
+
+	mu     sync.Mutex
+	labels map[string][]string // labels for the items which have been sent
+}
+
+// NewLabeler returns a new Labeler for input in format. For input containing
+// host names, the items are extracted with the template.
+func NewLabeler(format, template string) *Labeler {
+	return &Labeler{
+		format:   format,
+		template: template,
+		labels:   make(map[string][]string),
+	}
+}
+
+// Parse reads lines from in, remembers the labels and sends the items to out.
+// Empty lines and host names which do not match the template are skipped. When in is closed or the context is cancelled, out
+// is closed.
+func (l *Labeler) Parse(ctx context.Context, in <-chan string, out chan<- string) error {
+	defer close(out)
+
+	for line := range in {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		item, labels, err := parseInputLine(l.format, line)
+		if err != nil {
+			return err
+		}
+
+		if hostInputFormats[l.format] {
+			var ok bool
+			item, ok = templateItem(l.template, item)
+			if !ok {
+				continue
+			}
+		}
+
+		if len(labels) > 0 {
+			l.mu.Lock()
+			l.labels[item] = resolve.Unique(append(l.labels[item], labels...))
+			l.mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- item:
+		}
+	}
+
+	return nil
+}
+
+// Run reads results from in and forwards them to out, attaching the labels of
+// the items on the way. When in is closed or the context is cancelled, out is
+// closed.
+func (l *Labeler) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	defer close(out)
+
+	for res := range in {
+		l.mu.Lock()
+		res.Labels = l.labels[res.Item]
+		delete(l.labels, res.Item)
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- res:
+		}
+	}
+
+	return nil
+}
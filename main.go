@@ -1,57 +1,238 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
-	"sync"
+	"text/template"
 	"time"
 
 	"github.com/fd0/termstatus"
 	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/filter"
 	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/rdap"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
 	"github.com/happal/taifun/shell"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/sync/errgroup"
 )
 
 // Options collect global options for the program.
 type Options struct {
-	Range        string
-	RangeFormat  string
-	Filename     string
-	RequestTypes []string
-
-	BufferSize int
-	Skip       int
-	Limit      int
-
-	Logfile string
-	Logdir  string
-	Threads int
-
-	Nameserver string
-
-	RequestsPerSecond float64
-
-	ShowNotFound bool
-
-	HideNetworks    []string
+	Range        string   `json:"range,omitempty"`
+	RangeFormat  string   `json:"range_format,omitempty"`
+	Filename     string   `json:"filename,omitempty"`
+	RequestTypes []string `json:"request_types"`
+	InputFormat  string   `json:"input_format,omitempty"`
+	TXTSegments  bool     `json:"txt_segments,omitempty"`
+
+	ParallelRequests int `json:"parallel_requests,omitempty"`
+
+	BufferSize int    `json:"buffer_size"`
+	SpillDir   string `json:"spill_dir,omitempty"`
+	Skip       int    `json:"skip,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+	Loop       int    `json:"loop,omitempty"`
+	Forever    bool   `json:"forever,omitempty"`
+
+	MaxDuration    time.Duration `json:"max_duration,omitempty"`
+	StopAfterFound int           `json:"stop_after_found,omitempty"`
+
+	RecurseDepth int    `json:"recurse_depth,omitempty"`
+	RecurseFile  string `json:"recurse_file,omitempty"`
+	recurseWords *Wordlist
+
+	Dedup              bool    `json:"dedup,omitempty"`
+	DedupExpected      int     `json:"dedup_expected,omitempty"`
+	DedupFalsePositive float64 `json:"dedup_false_positive,omitempty"`
+
+	Logfile string `json:"logfile,omitempty"`
+	Logdir  string `json:"logdir,omitempty"`
+	Threads int    `json:"threads"`
+
+	LogFormat string `json:"log_format,omitempty"`
+	LogStdout bool   `json:"log_stdout,omitempty"`
+
+	AutoThreads bool `json:"auto_threads,omitempty"`
+
+	Compress bool `json:"compress,omitempty"`
+
+	RecordHidden  bool   `json:"record_hidden,omitempty"`
+	WriteFound    string `json:"write_found,omitempty"`
+	WriteAmass    string `json:"write_amass,omitempty"`
+	WriteMarkdown string `json:"write_markdown,omitempty"`
+	WriteGraph    string `json:"write_graph,omitempty"`
+	WriteTypes    string `json:"write_types,omitempty"`
+
+	WriteDelegations string `json:"write_delegations,omitempty"`
+
+	WriteIPs string `json:"write_ips,omitempty"`
+	SplitIPs bool   `json:"split_ips,omitempty"`
+
+	WriteFailed   string `json:"write_failed,omitempty"`
+	NoWriteFailed bool   `json:"no_write_failed,omitempty"`
+
+	WriteSuggestions   string `json:"write_suggestions,omitempty"`
+	NoWriteSuggestions bool   `json:"no_write_suggestions,omitempty"`
+
+	KafkaBrokers  []string `json:"kafka_brokers,omitempty"`
+	KafkaTopic    string   `json:"kafka_topic,omitempty"`
+	KafkaTLS      bool     `json:"kafka_tls,omitempty"`
+	KafkaSASL     string   `json:"kafka_sasl,omitempty"`
+	KafkaUsername string   `json:"kafka_username,omitempty"`
+
+	// the password is a secret, it is not saved with the options
+	kafkaPassword string
+
+	NotifyEvents    []string `json:"notify_events,omitempty"`
+	NotifyErrorRate float64  `json:"notify_error_rate,omitempty"`
+
+	// the webhook URLs contain secrets, they are not saved with the options
+	notifySlack    string
+	notifyDiscord  string
+	notifyTelegram string
+
+	Bell          bool     `json:"bell,omitempty"`
+	BellPatterns  []string `json:"bell_patterns,omitempty"`
+	DesktopNotify bool     `json:"desktop_notify,omitempty"`
+	bellPatterns  []*regexp.Regexp
+
+	Plugins []string `json:"plugins,omitempty"`
+
+	// the plugins run code on this host, they are not saved with the options
+	// so a log cannot start them when the run is resumed
+	PluginFiles    []string `json:"-"`
+	PluginCommands []string `json:"-"`
+	skippedPlugins []string // plugins of a resumed run, which are not loaded
+
+	Nameserver      string             `json:"nameserver"`
+	Resolvers       []string           `json:"resolvers,omitempty"`
+	ResolverWeights map[string]float64 `json:"resolver_weights,omitempty"`
+	IPv4            bool               `json:"ipv4,omitempty"`
+	IPv6            bool               `json:"ipv6,omitempty"`
+	network         string
+	Interface       string `json:"interface,omitempty"`
+	localAddrs      []net.IP
+	resolversFile   string
+	Authoritative   bool     `json:"authoritative,omitempty"`
+	Search          bool     `json:"search,omitempty"`
+	CompareWith     []string `json:"compare_with,omitempty"`
+	DNSSEC          bool     `json:"dnssec,omitempty"`
+	ClientSubnets   []string `json:"client_subnets,omitempty"`
+	clientSubnets   []*net.IPNet
+	ClientSubnet    string `json:"client_subnet,omitempty"`
+	clientSubnet    *net.IPNet
+	FollowCNAMEs    bool   `json:"follow_cnames,omitempty"`
+	TSIGName        string `json:"tsig_name,omitempty"`
+	TSIGAlgorithm   string `json:"tsig_algorithm,omitempty"`
+	tsigSecret      string
+	tsig            *resolve.TSIG
+	wildcard        *resolve.Wildcard
+	compat          bool // set for runs via the compat command
+	PruneNXDomain   bool `json:"prune_nxdomain,omitempty"`
+	CacheSize       int  `json:"cache_size,omitempty"`
+	nxdomains       *resolve.NXDomainCache
+	health          *resolve.Health
+
+	VerifyWith     string `json:"verify_with,omitempty"`
+	KeepUnverified bool   `json:"keep_unverified,omitempty"`
+	CheckResolvers string `json:"check_resolvers,omitempty"`
+	CheckName      string `json:"check_name,omitempty"`
+
+	pprofAddr    string
+	controlAddr  string
+	controlToken string
+
+	configFile string
+	Profile    string `json:"profile,omitempty"`
+
+	resumeCheckpoint string
+
+	Watch         bool          `json:"watch,omitempty"`
+	WatchInterval time.Duration `json:"watch_interval,omitempty"`
+	WatchState    string        `json:"watch_state,omitempty"`
+
+	// the command runs on this host, it is not saved with the options
+	OnChange string `json:"-"`
+
+	// set for the coordinator (taifun serve)
+	serveAddr    string
+	serveToken   string
+	BatchSize    int           `json:"batch_size,omitempty"`
+	LeaseTimeout time.Duration `json:"lease_timeout,omitempty"`
+
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+	Interactive       bool    `json:"interactive,omitempty"`
+	Jitter            string  `json:"jitter,omitempty"`
+	jitterMin         time.Duration
+	jitterMax         time.Duration
+
+	OutputFormat string `json:"output_format"`
+	Format       string `json:"format,omitempty"`
+	template     *template.Template
+	Quiet        bool   `json:"quiet,omitempty"`
+	SortResults  string `json:"sort_results,omitempty"`
+	GroupSummary bool   `json:"group_summary,omitempty"`
+	Cluster      bool   `json:"cluster,omitempty"`
+	ShowRTT      bool   `json:"show_rtt,omitempty"`
+	Verbose      int    `json:"verbose,omitempty"`
+	ExactStats   bool   `json:"exact_stats,omitempty"`
+
+	ShowConfidence bool   `json:"show_confidence,omitempty"`
+	ShowFlags      bool   `json:"show_flags,omitempty"`
+	MinConfidence  string `json:"min_confidence,omitempty"`
+
+	ShowNotFound bool `json:"show_not_found,omitempty"`
+
+	HideNetworks    []string `json:"hide_networks,omitempty"`
 	hideNetworks    []*net.IPNet
-	ShowNetworks    []string
+	ShowNetworks    []string `json:"show_networks,omitempty"`
 	showNetworks    []*net.IPNet
-	HideEmpty       bool
-	HideDelegations bool
-	HideCNAMEs      []string
+	HideEmpty       bool     `json:"hide_empty,omitempty"`
+	HideDelegations bool     `json:"hide_delegations,omitempty"`
+	HideCNAMEs      []string `json:"hide_cnames,omitempty"`
 	hideCNAMEs      []*regexp.Regexp
-	HidePTR         []string
+	HidePTR         []string `json:"hide_ptr,omitempty"`
 	hidePTR         []*regexp.Regexp
+	HideData        []string `json:"hide_data,omitempty"`
+	hideData        []filter.DataPattern
+	ShowData        []string `json:"show_data,omitempty"`
+	showData        []filter.DataPattern
+
+	ShowLabels []string `json:"show_labels,omitempty"`
+	HideLabels []string `json:"hide_labels,omitempty"`
+
+	HTTPProbe   bool          `json:"http_probe,omitempty"`
+	HTTPTimeout time.Duration `json:"http_timeout,omitempty"`
+
+	CheckPorts  []int         `json:"check_ports,omitempty"`
+	PortTimeout time.Duration `json:"port_timeout,omitempty"`
+
+	HarvestSANs bool `json:"tls_sans,omitempty"`
+	SANLimit    int  `json:"tls_sans_limit,omitempty"`
+
+	RDAP       bool     `json:"rdap,omitempty"`
+	RDAPServer string   `json:"rdap_server,omitempty"`
+	RDAPRate   float64  `json:"rdap_rate,omitempty"`
+	ShowOwners []string `json:"show_owners,omitempty"`
+	showOwners []*regexp.Regexp
+	HideOwners []string `json:"hide_owners,omitempty"`
+	hideOwners []*regexp.Regexp
 }
 
 func parseNetworks(nets []string) ([]*net.IPNet, error) {
@@ -80,16 +261,30 @@ func compileRegexps(pattern []string) (res []*regexp.Regexp, err error) {
 	return res, nil
 }
 
+func parseDataPatterns(patterns []string) (res []filter.DataPattern, err error) {
+	for _, s := range patterns {
+		pat, err := filter.ParseDataPattern(s)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, pat)
+	}
+
+	return res, nil
+}
+
 var validRequestTypes = map[string]struct{}{
 	"A":     struct{}{},
 	"AAAA":  struct{}{},
 	"CNAME": struct{}{},
 	"MX":    struct{}{},
 	"PTR":   struct{}{},
+	"TXT":   struct{}{},
 }
 
 func (opts *Options) valid() (err error) {
-	if opts.Threads <= 0 {
+	if opts.Threads <= 0 && !opts.AutoThreads {
 		return errors.New("invalid number of threads")
 	}
 
@@ -101,349 +296,1728 @@ func (opts *Options) valid() (err error) {
 		return errors.New("neither file nor range specified, nothing to do")
 	}
 
-	opts.hideNetworks, err = parseNetworks(opts.HideNetworks)
-	if err != nil {
-		return err
+	for _, t := range opts.RequestTypes {
+		if _, ok := validRequestTypes[t]; !ok {
+			return fmt.Errorf("invalid request type %q", t)
+		}
 	}
 
-	opts.showNetworks, err = parseNetworks(opts.ShowNetworks)
-	if err != nil {
-		return err
+	switch opts.LogFormat {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid log format %q, valid formats are: text, json", opts.LogFormat)
 	}
 
-	opts.hideCNAMEs, err = compileRegexps(opts.HideCNAMEs)
-	if err != nil {
-		return err
+	if opts.LogStdout && opts.LogFormat != "json" {
+		return errors.New("--log-stdout requires --log-format json")
 	}
 
-	opts.hidePTR, err = compileRegexps(opts.HidePTR)
-	if err != nil {
-		return err
+	if opts.InputFormat != "" && !contains(inputFormats, opts.InputFormat) {
+		return fmt.Errorf("invalid input format %q, valid formats are: %s", opts.InputFormat, strings.Join(inputFormats, ", "))
 	}
 
-	for _, t := range opts.RequestTypes {
-		if _, ok := validRequestTypes[t]; !ok {
-			return fmt.Errorf("invalid request type %q", t)
-		}
+	if opts.ParallelRequests < 0 {
+		return errors.New("the number of parallel requests must not be negative")
 	}
 
-	return nil
-}
+	// a burst of zero is treated as one (e.g. for logs written before
+	// --burst was introduced)
+	if opts.Burst < 0 {
+		return errors.New("burst must not be negative")
+	}
 
-// logfilePath returns the prefix for the logfiles, if any.
-func logfilePath(opts *Options, hostname string) (prefix string, err error) {
-	if opts.Logdir != "" && opts.Logfile == "" {
-		ts := time.Now().Format("20060102_150405")
-		fn := fmt.Sprintf("taifun_%s_%s", hostname, ts)
-		p := filepath.Join(opts.Logdir, fn)
-		return p, nil
+	if opts.MaxDuration < 0 {
+		return errors.New("the maximum duration must not be negative")
 	}
 
-	return opts.Logfile, nil
-}
+	if opts.StopAfterFound < 0 {
+		return errors.New("the number of results for --stop-after-found must not be negative")
+	}
 
-func setupTerminal(ctx context.Context, g *errgroup.Group, logfilePrefix string) (term cli.Terminal, cleanup func(), err error) {
-	ctx, cancel := context.WithCancel(context.Background())
+	if len(opts.KafkaBrokers) > 0 && opts.KafkaTopic == "" {
+		return errors.New("--kafka-brokers needs a topic, please specify one with --kafka-topic")
+	}
 
-	if logfilePrefix != "" {
-		fmt.Printf("logfile is %s.log\n", logfilePrefix)
+	if opts.KafkaTopic != "" && len(opts.KafkaBrokers) == 0 {
+		return errors.New("--kafka-topic needs at least one broker, please specify them with --kafka-brokers")
+	}
 
-		logfile, err := os.Create(logfilePrefix + ".log")
-		if err != nil {
-			return nil, cancel, err
-		}
+	if opts.KafkaSASL != "" && !contains(kafkaSASLMechanisms, strings.ToLower(opts.KafkaSASL)) {
+		return fmt.Errorf("unknown SASL mechanism %q for --kafka-sasl, valid: %v", opts.KafkaSASL, strings.Join(kafkaSASLMechanisms, ", "))
+	}
 
-		fmt.Fprintln(logfile, shell.Join(os.Args))
+	if opts.KafkaSASL != "" && opts.KafkaUsername == "" {
+		return errors.New("--kafka-sasl needs a user name, please specify one with --kafka-username")
+	}
 
-		// write copies of messages to logfile
-		term = &cli.LogTerminal{
-			Terminal: termstatus.New(os.Stdout, os.Stderr, false),
-			Writer:   logfile,
+	for _, event := range opts.NotifyEvents {
+		if !contains(notifyEvents, event) {
+			return fmt.Errorf("unknown event %q for --notify-events, valid: %v", event, strings.Join(notifyEvents, ", "))
 		}
-	} else {
-		term = termstatus.New(os.Stdout, os.Stderr, false)
 	}
 
-	// make sure error messages logged via the log package are printed nicely
-	w := cli.NewStdioWrapper(term)
-	log.SetOutput(w.Stderr())
+	if opts.NotifyErrorRate < 0 || opts.NotifyErrorRate > 1 {
+		return errors.New("the rate for --notify-error-rate must be between 0 and 1")
+	}
 
-	g.Go(func() error {
-		term.Run(ctx)
-		return nil
-	})
+	if len(opts.BellPatterns) > 0 && !opts.Bell && !opts.DesktopNotify {
+		return errors.New("--bell-pattern needs --bell or --desktop-notify")
+	}
 
-	return term, cancel, nil
-}
+	if opts.DesktopNotify && runtime.GOOS == "windows" {
+		return errors.New("--desktop-notify is not supported on Windows")
+	}
 
-func setupProducer(ctx context.Context, g *errgroup.Group, opts *Options, ch chan<- string, count chan<- int) error {
-	switch {
-	case opts.Range != "":
-		var first, last int
-		_, err := fmt.Sscanf(opts.Range, "%d-%d", &first, &last)
-		if err != nil {
-			return errors.New("wrong format for range, expected: first-last")
-		}
+	opts.bellPatterns, err = compileRegexps(opts.BellPatterns)
+	if err != nil {
+		return err
+	}
 
-		g.Go(func() error {
-			return producer.Range(ctx, first, last, opts.RangeFormat, ch, count)
-		})
-		return nil
+	err = checkGraphRenderer(opts.WriteGraph)
+	if err != nil {
+		return err
+	}
 
-	case opts.Filename == "-":
-		g.Go(func() error {
-			return producer.Reader(ctx, os.Stdin, ch, count)
-		})
-		return nil
+	if opts.HTTPProbe && opts.HTTPTimeout <= 0 {
+		return errors.New("the timeout for --http-timeout must be positive")
+	}
 
-	case opts.Filename != "":
-		file, err := os.Open(opts.Filename)
-		if err != nil {
-			return err
+	for _, port := range opts.CheckPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d for --check-ports", port)
 		}
+	}
 
-		g.Go(func() error {
-			return producer.Reader(ctx, file, ch, count)
-		})
-		return nil
+	if (len(opts.CheckPorts) > 0 || opts.HarvestSANs) && opts.PortTimeout <= 0 {
+		return errors.New("the timeout for --port-timeout must be positive")
+	}
 
-	default:
-		return errors.New("neither file nor range specified, nothing to do")
+	if opts.RDAP && opts.RDAPRate <= 0 {
+		return errors.New("the rate for --rdap-requests-per-second must be positive")
 	}
-}
 
-func setupValueFilters(ctx context.Context, opts *Options, valueCh <-chan string, countCh <-chan int) (<-chan string, <-chan int) {
-	if opts.Skip > 0 {
-		f := &producer.FilterSkip{Skip: opts.Skip}
-		countCh = f.Count(ctx, countCh)
-		valueCh = f.Select(ctx, valueCh)
+	if (len(opts.ShowOwners) > 0 || len(opts.HideOwners) > 0) && !opts.RDAP {
+		return errors.New("--show-owner and --hide-owner need the owners looked up with --rdap")
 	}
 
-	if opts.Limit > 0 {
-		f := &producer.FilterLimit{Max: opts.Limit}
-		countCh = f.Count(ctx, countCh)
-		valueCh = f.Select(ctx, valueCh)
+	if opts.RecurseDepth < 0 {
+		return errors.New("the depth for --recurse-depth must not be negative")
 	}
 
-	return valueCh, countCh
-}
+	if opts.RecurseDepth > 0 {
+		filename := opts.RecurseFile
+		if filename == "" {
+			filename = opts.Filename
+		}
 
-// Filters collects all filters executed on Results.
-type Filters struct {
-	Result   []ResultFilter
-	Request  []RequestFilter
-	Response []ResponseFilter
-}
+		if filename == "" || filename == "-" {
+			return errors.New("--recurse-depth needs a wordlist, please specify one with --recurse-file")
+		}
+
+		opts.recurseWords, err = NewWordlist(filename)
+		if err != nil {
+			return err
+		}
 
-func setupResultFilters(opts *Options) (filters Filters, err error) {
-	if !opts.ShowNotFound {
-		filters.Request = append(filters.Request, FilterNotFound())
+		if opts.Watch {
+			return errors.New("--recurse-depth cannot be used with --watch")
+		}
 	}
 
-	if opts.HideEmpty {
-		filters.Result = append(filters.Result, FilterEmptyResults())
+	if opts.HarvestSANs && opts.Watch {
+		return errors.New("--tls-sans cannot be used with --watch")
 	}
 
-	if opts.HideDelegations {
-		filters.Result = append(filters.Result, FilterDelegations())
+	if opts.SANLimit < 0 {
+		return errors.New("the limit for --tls-sans-limit must not be negative")
 	}
 
-	if len(opts.hideNetworks) != 0 {
-		filters.Response = append(filters.Response, FilterInSubnet(opts.hideNetworks))
+	if opts.CacheSize < 0 {
+		return errors.New("the size for --cache-size must not be negative")
 	}
 
-	if len(opts.showNetworks) != 0 {
-		filters.Response = append(filters.Response, FilterNotInSubnet(opts.showNetworks))
+	if opts.Loop < 0 {
+		return errors.New("the number of iterations for --loop must not be negative")
 	}
 
-	if len(opts.hideCNAMEs) != 0 {
-		filters.Response = append(filters.Response, FilterRejectCNAMEs(opts.hideCNAMEs))
+	if opts.looping() {
+		if opts.Loop > 0 && opts.Forever {
+			return errors.New("--loop and --forever cannot be used together")
+		}
+
+		switch {
+		case opts.Watch:
+			return errors.New("--loop and --forever cannot be used with --watch")
+		case opts.Dedup:
+			return errors.New("--loop and --forever cannot be used with --dedup")
+		case opts.Skip > 0:
+			return errors.New("--loop and --forever cannot be used with --skip")
+		case opts.RecurseDepth > 0 || opts.HarvestSANs:
+			return errors.New("--loop and --forever cannot be used with --recurse-depth or --tls-sans")
+		}
 	}
 
-	if len(opts.hidePTR) != 0 {
-		filters.Response = append(filters.Response, FilterRejectPTR(opts.hidePTR))
+	if opts.Dedup && (opts.DedupFalsePositive <= 0 || opts.DedupFalsePositive >= 1) {
+		return errors.New("the false positive rate for --dedup must be between 0 and 1")
 	}
 
-	return filters, nil
-}
+	if opts.Nameserver != "" && opts.resolversFile != "" {
+		return errors.New("only one of --nameserver and --resolvers can be specified")
+	}
 
-func startResolvers(ctx context.Context, opts *Options, hostname string, in <-chan string) (<-chan Result, error) {
-	out := make(chan Result)
+	switch {
+	case opts.IPv4 && opts.IPv6:
+		return errors.New("only one of -4 and -6 can be specified")
+	case opts.IPv4:
+		opts.network = "udp4"
+	case opts.IPv6:
+		opts.network = "udp6"
+	}
+
+	if opts.Interface != "" {
+		opts.localAddrs, err = interfaceAddrs(opts.Interface, opts.network)
+		if err != nil {
+			return fmt.Errorf("invalid interface for --interface: %v", err)
+		}
+	}
 
-	resolver, err := NewResolver(in, out, hostname, opts.Nameserver, opts.RequestTypes)
+	opts.clientSubnets, err = parseNetworks(opts.ClientSubnets)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var wg sync.WaitGroup
-	for i := 0; i < opts.Threads; i++ {
-		wg.Add(1)
-		go func() {
-			resolver.Run(ctx)
-			wg.Done()
-		}()
+	if opts.ClientSubnet != "" {
+		if len(opts.ClientSubnets) > 0 {
+			return errors.New("only one of --ecs and --client-subnet can be specified")
+		}
+
+		_, opts.clientSubnet, err = net.ParseCIDR(opts.ClientSubnet)
+		if err != nil {
+			return fmt.Errorf("invalid subnet for --client-subnet: %v", err)
+		}
 	}
 
-	go func() {
-		// wait until the resolvers are done, then close the output channel
-		wg.Wait()
-		close(out)
-	}()
+	opts.jitterMin, opts.jitterMax, err = parseJitter(opts.Jitter)
+	if err != nil {
+		return err
+	}
 
-	return out, nil
-}
+	if opts.TSIGName != "" || opts.tsigSecret != "" {
+		opts.tsig, err = resolve.NewTSIG(opts.TSIGName, opts.TSIGAlgorithm, opts.tsigSecret)
+		if err != nil {
+			return err
+		}
 
-func run(ctx context.Context, g *errgroup.Group, opts *Options, args []string) error {
-	if len(args) == 0 {
-		return errors.New("last argument needs to be the host name")
+		if resolve.IsJSONServer(opts.Nameserver) {
+			return errors.New("queries to a JSON API cannot be signed with TSIG")
+		}
 	}
 
-	if len(args) > 1 {
-		return errors.New("more than one target host name specified")
+	if opts.Authoritative && opts.resolversFile != "" {
+		return errors.New("--authoritative cannot be used with --resolvers")
 	}
 
-	hostname := args[0]
+	if opts.CheckResolvers != "" {
+		if !contains(checkModes, opts.CheckResolvers) {
+			return fmt.Errorf("invalid mode %q for --check-resolvers, valid values: %s", opts.CheckResolvers, strings.Join(checkModes, ", "))
+		}
 
-	if !strings.Contains(hostname, "FUZZ") {
-		return errors.New(`hostname does not contain the string "FUZZ"`)
+		if opts.Authoritative {
+			return errors.New("--check-resolvers cannot be used with --authoritative")
+		}
 	}
 
-	// make sure the hostname is absolute
-	if !strings.HasSuffix(hostname, ".") {
-		hostname += "."
+	if opts.Watch {
+		if opts.Filename == "-" {
+			return errors.New("--watch cannot be used when reading values from stdin")
+		}
+
+		if opts.Interactive {
+			return errors.New("--watch cannot be used with --interactive")
+		}
+
+		if opts.WatchInterval <= 0 {
+			return errors.New("the interval for --watch must be positive")
+		}
+
+		if ignored := watchIgnoredFlags(opts); len(ignored) > 0 {
+			return fmt.Errorf("%s cannot be used with --watch", strings.Join(ignored, ", "))
+		}
+	}
+
+	if opts.Interactive {
+		if opts.Filename == "-" {
+			return errors.New("--interactive cannot be used when reading values from stdin")
+		}
+
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			return errors.New("--interactive needs a terminal on stdin")
+		}
 	}
 
-	err := opts.valid()
+	return opts.validDisplay()
+}
+
+// quiet returns true if only the results are printed, without status lines.
+// This is the default when stdout is not a terminal. The terminal is checked
+// here instead of setting Quiet, which is recorded with the options and
+// would be inherited by a resumed run.
+func (opts *Options) quiet() bool {
+	return opts.Quiet || !isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// validDisplay checks the options for filtering and displaying results.
+func (opts *Options) validDisplay() (err error) {
+	opts.hideNetworks, err = parseNetworks(opts.HideNetworks)
 	if err != nil {
 		return err
 	}
 
-	// setup logging and the terminal
-	logfilePrefix, err := logfilePath(opts, hostname)
+	opts.showNetworks, err = parseNetworks(opts.ShowNetworks)
 	if err != nil {
 		return err
 	}
 
-	term, cleanup, err := setupTerminal(ctx, g, logfilePrefix)
-	defer cleanup()
+	opts.hideCNAMEs, err = compileRegexps(opts.HideCNAMEs)
 	if err != nil {
 		return err
 	}
 
-	// use the system nameserver if none has been specified
-	if opts.Nameserver == "" {
-		opts.Nameserver, err = FindSystemNameserver()
-		if err != nil {
-			return err
-		}
-
-		term.Printf("found system nameserver %v", opts.Nameserver)
+	opts.hidePTR, err = compileRegexps(opts.HidePTR)
+	if err != nil {
+		return err
 	}
 
-	// collect the filters for the responses
-	responseFilters, err := setupResultFilters(opts)
+	opts.hideData, err = parseDataPatterns(opts.HideData)
 	if err != nil {
 		return err
 	}
 
-	// setup the pipeline for the values
-	vch := make(chan string, opts.BufferSize)
-	var valueCh <-chan string = vch
-	cch := make(chan int, 1)
-	var countCh <-chan int = cch
-
-	// start a producer from the options
-	err = setupProducer(ctx, g, opts, vch, cch)
+	opts.showData, err = parseDataPatterns(opts.ShowData)
 	if err != nil {
 		return err
 	}
 
-	// filter values (skip, limit)
-	valueCh, countCh = setupValueFilters(ctx, opts, valueCh, countCh)
-
-	// limit the throughput (if requested)
-	if opts.RequestsPerSecond > 0 {
-		valueCh = producer.Limit(ctx, opts.RequestsPerSecond, valueCh)
+	opts.showOwners, err = compileRegexps(opts.ShowOwners)
+	if err != nil {
+		return err
 	}
 
-	// start the resolvers
-	responseCh, err := startResolvers(ctx, opts, hostname, valueCh)
+	opts.hideOwners, err = compileRegexps(opts.HideOwners)
 	if err != nil {
 		return err
 	}
 
-	// filter the responses
-	responseCh = Mark(responseCh, responseFilters)
-
-	if logfilePrefix != "" {
-		rec, err := NewRecorder(logfilePrefix+".json", cleanHostname(hostname))
+	if opts.Format != "" {
+		// make sure each response is printed on its own line
+		opts.template, err = template.New("format").Parse(strings.TrimRight(opts.Format, "\n"))
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid format: %v", err)
 		}
+	}
 
-		// fill in information for generating the request
-		rec.Data.InputFile = opts.Filename
-		rec.Data.Range = opts.Range
-		rec.Data.RangeFormat = opts.RangeFormat
+	if opts.MinConfidence != "" && resolve.ConfidenceRank(opts.MinConfidence) < 0 {
+		return fmt.Errorf("invalid confidence %q, valid values: %s", opts.MinConfidence, strings.Join(resolve.ConfidenceLevels, ", "))
+	}
 
-		out := make(chan Result)
-		in := responseCh
-		responseCh = out
+	if opts.SortResults != "" && !contains(report.SortOrders, opts.SortResults) {
+		return fmt.Errorf("invalid sort order %q, valid values: %s", opts.SortResults, strings.Join(report.SortOrders, ", "))
+	}
 
-		outCount := make(chan int)
-		inCount := countCh
-		countCh = outCount
+	if !validOutputFormat(opts.OutputFormat) {
+		return fmt.Errorf("invalid output format %q, valid formats: %s", opts.OutputFormat, strings.Join(outputFormats, ", "))
+	}
 
-		g.Go(func() error {
-			return rec.Run(ctx, in, out, inCount, outCount)
-		})
+	if opts.Verbose > 0 && (opts.OutputFormat != "text" || opts.template != nil) {
+		return errors.New("--verbose can only be used with the text output format")
 	}
 
-	// run the reporter
-	term.Printf("hostname template: %v\n\n", hostname)
-	reporter := NewReporter(term, len(hostname)+10)
-	return reporter.Display(responseCh, countCh)
+	return nil
 }
 
-func main() {
-	var opts Options
+// looping returns true if the input is replayed with --loop or --forever.
+func (opts *Options) looping() bool {
+	return opts.Loop > 0 || opts.Forever
+}
 
-	cmd := &cobra.Command{
-		Use:                   "taifun [options] HOSTNAME",
-		DisableFlagsInUseLine: true,
-		SilenceErrors:         true,
-		SilenceUsage:          true,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
-				return run(ctx, g, &opts, args)
-			})
-		},
+// trackPosition returns true if the values need to pass through a tracker.
+// The recorder marks the values as done, so the tracker is only used when a
+// log is written. The coordinator tracks the batches itself.
+func trackPosition(opts *Options, logfilePrefix string) bool {
+	return logfilePrefix != "" && opts.serveAddr == ""
+}
+
+// logfilePath returns the prefix for the logfiles, if any.
+func logfilePath(opts *Options, hostname string) (prefix string, err error) {
+	if opts.Logdir != "" && opts.Logfile == "" {
+		ts := time.Now().Format("20060102_150405")
+		fn := fmt.Sprintf("taifun_%s_%s", hostname, ts)
+		p := filepath.Join(opts.Logdir, fn)
+		return p, nil
 	}
 
-	flags := cmd.Flags()
-	flags.IntVarP(&opts.Threads, "threads", "t", 2, "resolve `n` DNS queries in parallel")
-	flags.Float64Var(&opts.RequestsPerSecond, "requests-per-second", 0, "do at most `n` requests per seconds (e.g. 0.5)")
-	flags.IntVar(&opts.BufferSize, "buffer-size", 100000, "set number of buffered items to `n`")
-	flags.StringVar(&opts.Logfile, "logfile", "", "write copy of printed messages to `filename`.log")
-	flags.StringVar(&opts.Logdir, "logdir", os.Getenv("TAIFUN_LOG_DIR"), "automatically log all output to files in `dir`")
+	return opts.Logfile, nil
+}
+
+// logfileSuffix returns the suffix for the file name of an output file.
+func logfileSuffix(opts *Options, ext string) string {
+	if opts.Compress {
+		return ext + report.CompressedSuffix
+	}
+	return ext
+}
+
+// outputFile returns the name of an output file which is written next to the
+// log file by default: filename if set, otherwise the log file prefix with
+// suffix. It is empty if the output is disabled or no log file is written.
+func outputFile(filename string, disabled bool, logfilePrefix, suffix string) string {
+	switch {
+	case disabled:
+		return ""
+	case filename != "":
+		return filename
+	case logfilePrefix != "":
+		return logfilePrefix + suffix
+	default:
+		return ""
+	}
+}
+
+// logFormat configures how messages are written to the log file.
+type logFormat struct {
+	JSON   bool              // write JSON records instead of plain text
+	Stdout bool              // print the JSON records on stdout, too
+	Fields map[string]string // added to all JSON records
+}
+
+// logFormat returns the format for the log file of a run for hostname.
+func (opts *Options) logFormat(hostname string) logFormat {
+	if opts.LogFormat != "json" {
+		return logFormat{}
+	}
+
+	return logFormat{
+		JSON:   true,
+		Stdout: opts.LogStdout,
+		Fields: map[string]string{"template": resolve.CleanHostname(hostname)},
+	}
+}
+
+// secretFlags lists the flags whose values are replaced in the command line
+// written to the log file.
+var secretFlags = []string{
+	"kafka-password",
+	"notify-slack",
+	"notify-discord",
+	"notify-telegram",
+	"tsig-secret",
+	"control-token",
+	"token",
+}
+
+// setupTerminal starts the terminal. If quiet is set, no status lines are
+// displayed.
+func setupTerminal(ctx context.Context, g *errgroup.Group, logfilePrefix, logfileSuffix string, quiet bool, format logFormat) (term cli.Terminal, cleanup func(), err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cleanup = cancel
+
+	var logfile *report.File
+	if logfilePrefix != "" {
+		if !quiet {
+			fmt.Printf("logfile is %s%s\n", logfilePrefix, logfileSuffix)
+		}
+
+		logfile, err = report.CreateFile(logfilePrefix + logfileSuffix)
+		if err != nil {
+			return nil, cancel, err
+		}
+	}
+
+	switch {
+	case format.JSON:
+		jt := &cli.JSONTerminal{
+			Terminal: termstatus.New(os.Stdout, os.Stderr, quiet),
+			Stdout:   format.Stdout,
+			Fields:   format.Fields,
+		}
+
+		if logfile != nil {
+			jt.Writer = logfile
+			fields := map[string]string{"command": shell.Join(shell.Redact(os.Args, secretFlags...))}
+			for k, v := range format.Fields {
+				fields[k] = v
+			}
+			_ = cli.WriteRecords(logfile, cli.LevelInfo, "started", fields)
+		}
+
+		term = jt
+
+	case logfile != nil:
+		fmt.Fprintln(logfile, shell.Join(shell.Redact(os.Args, secretFlags...)))
+
+		// write copies of messages to logfile
+		term = &cli.LogTerminal{
+			Terminal: termstatus.New(os.Stdout, os.Stderr, quiet),
+			Writer:   logfile,
+		}
+
+	default:
+		term = termstatus.New(os.Stdout, os.Stderr, quiet)
+	}
+
+	// make sure error messages logged via the log package are printed nicely
+	w := cli.NewStdioWrapper(term)
+	log.SetOutput(w.Stderr())
+
+	done := make(chan struct{})
+	g.Go(func() error {
+		defer close(done)
+		term.Run(ctx)
+		return nil
+	})
+
+	// stop the terminal and wait until it has written the remaining
+	// messages before the log file is closed
+	cleanup = func() {
+		cancel()
+		<-done
+
+		if logfile != nil {
+			// ignore error
+			_ = logfile.Close()
+		}
+	}
+
+	return term, cleanup, nil
+}
+
+func setupProducer(ctx context.Context, g *errgroup.Group, opts *Options, ch chan<- string, count chan<- int, iterationSize chan<- int) error {
+	start, err := producerSource(opts)
+	if err != nil {
+		return err
+	}
+
+	if !opts.looping() {
+		source, err := start()
+		if err != nil {
+			return err
+		}
+
+		g.Go(func() error {
+			return source(ctx, ch, count)
+		})
+		return nil
+	}
+
+	loop := &producer.Loop{
+		Iterations: opts.Loop,
+		Size: func(n int) {
+			iterationSize <- n
+		},
+	}
+
+	g.Go(func() error {
+		return loop.Run(ctx, start, ch, count)
+	})
+	return nil
+}
+
+// producerSource returns a function which starts the producer configured in
+// the options, it is called again for each iteration with --loop.
+func producerSource(opts *Options) (func() (producer.Source, error), error) {
+	switch {
+	case opts.Range != "":
+		var first, last int
+		_, err := fmt.Sscanf(opts.Range, "%d-%d", &first, &last)
+		if err != nil {
+			return nil, errors.New("wrong format for range, expected: first-last")
+		}
+
+		return func() (producer.Source, error) {
+			return func(ctx context.Context, ch chan<- string, count chan<- int) error {
+				return producer.Range(ctx, first, last, opts.RangeFormat, ch, count)
+			}, nil
+		}, nil
+
+	case opts.Filename == "-" && opts.looping():
+		// stdin can only be read once, so keep the values for the next
+		// iterations
+		values, err := readLines(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() (producer.Source, error) {
+			return producer.Values(values), nil
+		}, nil
+
+	case opts.Filename == "-":
+		return func() (producer.Source, error) {
+			return func(ctx context.Context, ch chan<- string, count chan<- int) error {
+				return producer.Reader(ctx, os.Stdin, ch, count)
+			}, nil
+		}, nil
+
+	case opts.Filename != "":
+		// open the file once so errors are reported before starting
+		file, err := os.Open(opts.Filename)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() (producer.Source, error) {
+			if file == nil {
+				file, err = os.Open(opts.Filename)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			rd := file
+			file = nil
+			return func(ctx context.Context, ch chan<- string, count chan<- int) error {
+				return producer.Reader(ctx, rd, ch, count)
+			}, nil
+		}, nil
+
+	default:
+		return nil, errors.New("neither file nor range specified, nothing to do")
+	}
+}
+
+// readLines returns all lines read from rd.
+func readLines(rd io.Reader) (lines []string, err error) {
+	sc := bufio.NewScanner(rd)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+
+	return lines, sc.Err()
+}
+
+func setupValueFilters(ctx context.Context, opts *Options, valueCh <-chan string, countCh <-chan int) (<-chan string, <-chan int) {
+	// drop duplicates first so that skipping values works the same when
+	// resuming a run
+	if opts.Dedup {
+		f := &producer.FilterDuplicates{
+			Expected:          opts.DedupExpected,
+			FalsePositiveRate: opts.DedupFalsePositive,
+		}
+		countCh = f.Count(ctx, countCh)
+		valueCh = f.Select(ctx, valueCh)
+	}
+
+	if opts.Skip > 0 {
+		f := &producer.FilterSkip{Skip: opts.Skip}
+		countCh = f.Count(ctx, countCh)
+		valueCh = f.Select(ctx, valueCh)
+	}
+
+	if opts.Limit > 0 {
+		f := &producer.FilterLimit{Max: opts.Limit}
+		countCh = f.Count(ctx, countCh)
+		valueCh = f.Select(ctx, valueCh)
+	}
+
+	return valueCh, countCh
+}
+
+func setupResultFilters(opts *Options) (filters filter.Set, err error) {
+	// in interactive mode, the controller hides "not found" responses
+	if !opts.ShowNotFound && !opts.Interactive {
+		filters.Request = append(filters.Request, filter.NotFound())
+	}
+
+	if opts.HideEmpty {
+		filters.Result = append(filters.Result, filter.EmptyResults())
+	}
+
+	if opts.HideDelegations {
+		filters.Result = append(filters.Result, filter.Delegations())
+	}
+
+	if opts.MinConfidence != "" {
+		filters.Result = append(filters.Result, filter.MinConfidence(opts.MinConfidence))
+	}
+
+	if len(opts.ShowLabels) > 0 {
+		filters.Result = append(filters.Result, filter.WithoutLabels(opts.ShowLabels))
+	}
+
+	if len(opts.HideLabels) > 0 {
+		filters.Result = append(filters.Result, filter.WithLabels(opts.HideLabels))
+	}
+
+	if len(opts.hideNetworks) != 0 {
+		filters.Response = append(filters.Response, filter.InSubnet(opts.hideNetworks))
+	}
+
+	if len(opts.showNetworks) != 0 {
+		filters.Response = append(filters.Response, filter.NotInSubnet(opts.showNetworks))
+	}
+
+	if len(opts.hideCNAMEs) != 0 {
+		filters.Response = append(filters.Response, filter.RejectCNAMEs(opts.hideCNAMEs))
+	}
+
+	if len(opts.hidePTR) != 0 {
+		filters.Response = append(filters.Response, filter.RejectPTR(opts.hidePTR))
+	}
+
+	if len(opts.hideData) != 0 {
+		filters.Response = append(filters.Response, filter.RejectData(opts.hideData))
+	}
+
+	if len(opts.showData) != 0 {
+		filters.Response = append(filters.Response, filter.RequireData(opts.showData))
+	}
+
+	if len(opts.showOwners) != 0 {
+		filters.Response = append(filters.Response, filter.RequireOwners(opts.showOwners))
+	}
+
+	if len(opts.hideOwners) != 0 {
+		filters.Response = append(filters.Response, filter.RejectOwners(opts.hideOwners))
+	}
+
+	return filters, nil
+}
+
+// startResolvers starts a pool of resolvers which process the values from
+// in. The number of threads is adjusted automatically if requested.
+func startResolvers(ctx context.Context, g *errgroup.Group, opts *Options, hostname string, in <-chan string) (<-chan resolve.Result, *resolve.Pool, error) {
+	out := make(chan resolve.Result)
+
+	servers := opts.Resolvers
+	if len(servers) == 0 {
+		servers = []string{opts.Nameserver}
+	}
+
+	// creating a resolver only fails for invalid parameters, so check them
+	// once before starting
+	_, err := resolve.NewResolver(in, out, hostname, servers[0], opts.RequestTypes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// host names produced more than once are answered from the cache
+	var cache *resolve.ResponseCache
+	// with --loop, all names are requested again to test the resolvers
+	if opts.CacheSize > 0 && !opts.looping() {
+		cache = resolve.NewResponseCache(opts.CacheSize)
+	}
+
+	// pick the server for each query if weights are configured
+	var weighted *resolve.WeightedServers
+	if len(opts.ResolverWeights) > 0 && len(servers) > 1 {
+		weighted = resolve.NewWeightedServers(servers, opts.ResolverWeights)
+	}
+
+	// distribute the threads evenly across the servers
+	newResolver := func(n int) *resolve.Resolver {
+		resolver, _ := resolve.NewResolver(in, out, hostname, servers[n%len(servers)], opts.RequestTypes)
+		resolver.ClientSubnets = opts.clientSubnets
+		resolver.ClientSubnet = opts.clientSubnet
+		resolver.FollowCNAMEs = opts.FollowCNAMEs
+		resolver.TSIG = opts.tsig
+		resolver.Wildcard = opts.wildcard
+		resolver.NXDomains = opts.nxdomains
+		resolver.Cache = cache
+		resolver.Servers = weighted
+		resolver.Network = opts.network
+		resolver.LocalAddrs = opts.localAddrs
+		resolver.JitterMin, resolver.JitterMax = opts.jitterMin, opts.jitterMax
+		resolver.Health = opts.health
+		resolver.Concurrency = opts.ParallelRequests
+		return resolver
+	}
+
+	threads := opts.Threads
+	if opts.AutoThreads {
+		threads = 0
+	}
+
+	pool := resolve.NewPool(newResolver, threads)
+	g.Go(func() error {
+		return pool.Run(ctx, out)
+	})
+
+	return out, pool, nil
+}
+
+// startComparer sends the queries for the results from in to the name servers
+// to compare with. Like the resolvers, it respects the rate limit of
+// throttle and attaches the client subnet.
+func startComparer(ctx context.Context, g *errgroup.Group, opts *Options, throttle *producer.Throttle, in <-chan resolve.Result) <-chan resolve.Result {
+	out := make(chan resolve.Result)
+
+	comparer := NewComparer(opts.CompareWith, opts.RequestTypes, opts.Threads)
+	comparer.ClientSubnet = opts.clientSubnet
+	comparer.Throttle = throttle
+	g.Go(func() error {
+		return comparer.Run(ctx, in, out)
+	})
+
+	return out
+}
+
+// startVerifier checks that the shown results from in can be reproduced by
+// the trusted resolver. Like the resolvers, it respects the rate limit of
+// throttle and attaches the client subnet.
+func startVerifier(ctx context.Context, g *errgroup.Group, opts *Options, throttle *producer.Throttle, in <-chan resolve.Result) <-chan resolve.Result {
+	out := make(chan resolve.Result)
+
+	verifier := NewVerifier(opts.VerifyWith, opts.Threads, opts.KeepUnverified)
+	verifier.ClientSubnet = opts.clientSubnet
+	verifier.Throttle = throttle
+	g.Go(func() error {
+		return verifier.Run(ctx, in, out)
+	})
+
+	return out
+}
+
+// parseHostname returns the absolute host name template from the arguments.
+func parseHostname(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("last argument needs to be the host name")
+	}
+
+	if len(args) > 1 {
+		return "", errors.New("more than one target host name specified")
+	}
+
+	hostname := args[0]
+
+	if !strings.Contains(hostname, "FUZZ") {
+		return "", errors.New(`hostname does not contain the string "FUZZ"`)
+	}
+
+	// make sure the hostname is absolute
+	if !strings.HasSuffix(hostname, ".") {
+		hostname += "."
+	}
+
+	return hostname, nil
+}
+
+// hostname returns the host name template from args. With --search, the
+// first search domain of the system is appended to relative templates.
+func (opts *Options) hostname(args []string) (string, error) {
+	if opts.Search && len(args) == 1 {
+		cfg, err := resolve.ReadSystemConfig()
+		if err != nil {
+			return "", err
+		}
+
+		if cfg.Relative(args[0]) {
+			if len(cfg.Search) == 0 {
+				return "", errors.New("no search domains configured for the system")
+			}
+			args = []string{args[0] + "." + strings.TrimSuffix(cfg.Search[0], ".")}
+		}
+	}
+
+	return parseHostname(args)
+}
+
+// setupNameservers reads the resolvers, selects and checks the name servers
+// to use and sets up the wildcard detection and pruning for hostname. Most
+// steps are skipped for the coordinator, which does not send requests itself.
+// The requests for detecting a wildcard respect the rate limit of throttle.
+func setupNameservers(ctx context.Context, term cli.Terminal, opts *Options, hostname string, throttle *producer.Throttle) (err error) {
+	if opts.resolversFile != "" {
+		opts.Resolvers, opts.ResolverWeights, err = readWeightedResolvers(opts.resolversFile)
+		if err != nil {
+			return err
+		}
+
+		if len(opts.Resolvers) == 0 {
+			return fmt.Errorf("no resolvers found in %v", opts.resolversFile)
+		}
+
+		// with weights, the server is picked for each query
+		if opts.Threads < len(opts.Resolvers) && len(opts.ResolverWeights) == 0 && !opts.AutoThreads && !opts.quiet() {
+			cli.Warnf(term, "only %d of %d resolvers are used, increase the number of threads to use all", opts.Threads, len(opts.Resolvers))
+		}
+
+		if opts.resolversFile == builtinResolversName && opts.RequestsPerSecond == 0 && !opts.quiet() {
+			cli.Warnf(term, "using %d public resolvers, please be considerate and limit the rate with --requests-per-second", len(opts.Resolvers))
+		}
+	}
+
+	// use the system nameservers if none has been specified, the coordinator
+	// does not send requests itself
+	if opts.Nameserver == "" && len(opts.Resolvers) == 0 && opts.serveAddr == "" {
+		err = useSystemNameservers(term, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// only use the resolvers which can be reached via the selected network
+	if opts.serveAddr == "" {
+		err = restrictTransport(term, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// send test queries to the resolvers before using them
+	if opts.CheckResolvers != "" && opts.serveAddr == "" {
+		servers := opts.Resolvers
+		if len(servers) == 0 {
+			servers = []string{opts.Nameserver}
+		}
+
+		usable, report := checkResolvers(servers, opts.CheckName, opts.RequestTypes, opts.Threads, opts.CheckResolvers)
+		if !opts.quiet() {
+			for _, line := range report {
+				term.Print(line)
+			}
+		}
+
+		if len(usable) == 0 {
+			return errors.New("no resolver passed the checks")
+		}
+
+		if len(opts.Resolvers) > 0 {
+			opts.Resolvers = usable
+		}
+	}
+
+	// send the queries directly to the name servers of the target zone
+	if opts.Authoritative && opts.serveAddr == "" {
+		err = setupAuthoritative(term, opts, hostname)
+		if err != nil {
+			return err
+		}
+	}
+
+	// rate the results by comparing them with the answers for random names
+	if opts.rateConfidence() && opts.serveAddr == "" {
+		err = setupWildcard(ctx, term, opts, hostname, throttle)
+		if err != nil {
+			return err
+		}
+	}
+
+	// skip the names below names which do not exist
+	if opts.PruneNXDomain && opts.serveAddr == "" {
+		setupPruning(term, opts, hostname)
+	}
+
+	return nil
+}
+
+// startResultStages passes the results from in through the plugins, the
+// notifier, the bell, the HTTP prober and the port checker, as configured in
+// opts. The plugins run first, so the others see the results they hide or
+// extend. The returned notifier is nil unless notifications are configured.
+func startResultStages(ctx context.Context, g *errgroup.Group, term cli.Terminal, opts *Options, hostname string, in <-chan resolve.Result) (<-chan resolve.Result, *Notifier, error) {
+	responseCh := in
+
+	if len(opts.PluginFiles) > 0 || len(opts.Plugins) > 0 || len(opts.PluginCommands) > 0 {
+		chain, err := setupPlugins(opts, cli.NewStdioWrapper(term).Stderr())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return chain.Run(ctx, in, out)
+		})
+	}
+
+	var notifier *Notifier
+	if opts.notifySlack != "" || opts.notifyDiscord != "" || opts.notifyTelegram != "" {
+		var err error
+		notifier, err = NewNotifier(term, opts, hostname)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return notifier.Run(ctx, in, out)
+		})
+	}
+
+	if opts.Bell || opts.DesktopNotify {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		bell := NewBell(term, opts.Bell, opts.bellPatterns, opts.DesktopNotify)
+		g.Go(func() error {
+			return bell.Run(ctx, in, out)
+		})
+	}
+
+	if opts.HTTPProbe {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		prober := NewHTTPProber(opts.HTTPTimeout, opts.Threads)
+		g.Go(func() error {
+			return prober.Run(ctx, in, out)
+		})
+	}
+
+	if len(opts.CheckPorts) > 0 {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		checker := NewPortChecker(opts.CheckPorts, opts.PortTimeout, opts.Threads)
+		g.Go(func() error {
+			return checker.Run(ctx, in, out)
+		})
+	}
+
+	return responseCh, notifier, nil
+}
+
+func run(ctx context.Context, g *errgroup.Group, opts *Options, args []string) error {
+	hostname, err := opts.hostname(args)
+	if err != nil {
+		return err
+	}
+
+	err = opts.valid()
+	if err != nil {
+		return err
+	}
+
+	// setup logging and the terminal
+	logfilePrefix, err := logfilePath(opts, hostname)
+	if err != nil {
+		return err
+	}
+
+	term, cleanup, err := setupTerminal(ctx, g, logfilePrefix, logfileSuffix(opts, ".log"), opts.quiet(), opts.logFormat(hostname))
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	if len(opts.skippedPlugins) > 0 {
+		cli.Warnf(term, "the plugins of the recorded run are not loaded: %v", strings.Join(opts.skippedPlugins, ", "))
+	}
+
+	if opts.pprofAddr != "" {
+		addr, err := startPprof(opts.pprofAddr)
+		if err != nil {
+			return fmt.Errorf("unable to start pprof server: %v", err)
+		}
+
+		if !opts.quiet() {
+			term.Printf("serving pprof on http://%v/debug/pprof/", addr)
+		}
+	}
+
+	// limit the throughput (if requested), the throttle can be paused and
+	// adjusted while running. The requests of the comparer, the verifier and
+	// the wildcard detection take from the same rate, so they slow down the
+	// values sent to the resolvers.
+	throttle := producer.NewThrottle(opts.RequestsPerSecond, opts.Burst)
+
+	err = setupNameservers(ctx, term, opts, hostname, throttle)
+	if err != nil {
+		return err
+	}
+
+	// collect the filters for the responses
+	responseFilters, err := setupResultFilters(opts)
+	if err != nil {
+		return err
+	}
+
+	// stop sending new requests after the maximum duration or when enough
+	// results have been found, the requests in flight are finished and the
+	// results are processed as usual
+	producerCtx, stopProducer := context.WithCancel(ctx)
+	defer stopProducer()
+	if opts.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		producerCtx, cancel = context.WithTimeout(producerCtx, opts.MaxDuration)
+		defer cancel()
+	}
+
+	// setup the pipeline for the values
+	vch := make(chan string, opts.BufferSize)
+	var valueCh <-chan string = vch
+	cch := make(chan int, 1)
+	var countCh <-chan int = cch
+
+	// start a producer from the options
+	iterationSize := make(chan int, 1)
+	err = setupProducer(producerCtx, g, opts, vch, cch, iterationSize)
+	if err != nil {
+		return err
+	}
+
+	// filter values (skip, limit)
+	valueCh, countCh = setupValueFilters(producerCtx, opts, valueCh, countCh)
+
+	if opts.SpillDir != "" {
+		// buffer the values which passed the filters in memory and in a
+		// temporary file instead of blocking the producer
+		out := make(chan string)
+		in := valueCh
+		valueCh = out
+
+		g.Go(func() error {
+			return producer.Spill(producerCtx, opts.SpillDir, opts.BufferSize, in, out)
+		})
+	}
+
+	// read the labels from structured input
+	var labeler *Labeler
+	if opts.InputFormat != "" && opts.InputFormat != "text" {
+		labeler = NewLabeler(opts.InputFormat, hostname)
+
+		out := make(chan string)
+		in := valueCh
+		valueCh = out
+
+		g.Go(func() error {
+			return labeler.Parse(producerCtx, in, out)
+		})
+	}
+
+	// number the values, so the recorder can compute how many values from
+	// the start have been processed although the results arrive in any order
+	var tracker *producer.Tracker
+	if trackPosition(opts, logfilePrefix) {
+		tracker = producer.NewTracker()
+		valueCh = tracker.Run(producerCtx, valueCh)
+	}
+
+	// add the items for fuzzing below the host names found
+	var recurser *Recurser
+	if opts.RecurseDepth > 0 || opts.HarvestSANs {
+		recurser = NewRecurser(hostname, opts.recurseWords, opts.RecurseDepth)
+		valueCh, countCh = recurser.Feed(producerCtx, valueCh, countCh)
+	}
+
+	valueCh = throttle.Run(producerCtx, valueCh)
+
+	// pause on SIGUSR1, resume on SIGUSR2
+	handlePauseSignals(ctx, throttle)
+
+	var ctrl *Controller
+	if opts.Interactive {
+		ctrl = NewController(term, throttle, opts.ShowNotFound)
+		responseFilters.Request = append(responseFilters.Request, ctrl.FilterNotFound())
+	}
+
+	// start the resolvers, or hand out the values to workers
+	var responseCh <-chan resolve.Result
+	var pool *resolve.Pool
+	var coord *Coordinator
+	if opts.serveAddr != "" {
+		responseCh, coord, err = startCoordinator(ctx, g, term, opts, hostname, valueCh)
+	} else {
+		opts.health = newHealth(term, opts)
+		responseCh, pool, err = startResolvers(ctx, g, opts, hostname, valueCh)
+	}
+	if err != nil {
+		return err
+	}
+
+	// send the queries to the name servers to compare with
+	if len(opts.CompareWith) > 0 {
+		responseCh = startComparer(ctx, g, opts, throttle, responseCh)
+	}
+
+	// this needs to be done after the comparison, which expects the data
+	// sent by all name servers in the same format
+	if opts.TXTSegments {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return separateTXT(ctx, in, out)
+		})
+	}
+
+	if labeler != nil {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return labeler.Run(ctx, in, out)
+		})
+	}
+
+	if opts.RDAP {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		lookup := NewOwnerLookup(term, opts.RDAPServer, opts.RDAPRate)
+		g.Go(func() error {
+			return lookup.Run(ctx, in, out)
+		})
+	}
+
+	// filter the responses
+	responseCh = filter.Mark(responseCh, responseFilters)
+
+	// request the names from the certificates, this needs to be done before
+	// the recurser has seen the result
+	if opts.HarvestSANs {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		harvester := NewSANHarvester(hostname, recurser, opts.SANLimit, opts.PortTimeout, opts.Threads)
+		g.Go(func() error {
+			return harvester.Run(ctx, in, out)
+		})
+	}
+
+	if recurser != nil {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return recurser.Run(ctx, in, out)
+		})
+	}
+
+	// check that the shown results can be reproduced
+	if opts.VerifyWith != "" {
+		responseCh = startVerifier(ctx, g, opts, throttle, responseCh)
+	}
+
+	// validate the answers of shown results
+	if opts.DNSSEC {
+		server := opts.Nameserver
+		if server == "" {
+			server = opts.Resolvers[0]
+		}
+
+		checker, err := NewDNSSECChecker(server, opts.Threads)
+		if err != nil {
+			return err
+		}
+
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return checker.Run(ctx, in, out)
+		})
+	}
+
+	// the plugins, notifications and probes run only after the verifier has
+	// hidden the results which cannot be reproduced and the DNSSEC checker
+	// has validated the answers
+	responseCh, notifier, err := startResultStages(ctx, g, term, opts, hostname, responseCh)
+	if err != nil {
+		return err
+	}
+
+	if ctrl != nil {
+		restore, err := cli.SetCbreak(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("unable to read key presses: %v", err)
+		}
+		defer func() {
+			// ignore error
+			_ = restore()
+		}()
+
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return ctrl.Run(ctx, in, out)
+		})
+
+		go ctrl.ReadKeys(ctx, os.Stdin)
+	}
+
+	if opts.StopAfterFound > 0 {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return stopAfterShown(ctx, opts.StopAfterFound, stopProducer, in, out)
+		})
+	}
+
+	var rec *report.Recorder
+	if logfilePrefix != "" {
+		rec, err = report.NewRecorder(logfilePrefix+logfileSuffix(opts, ".json"), resolve.CleanHostname(hostname))
+		if err != nil {
+			return err
+		}
+
+		// fill in information for generating the request
+		rec.Data.InputFile = opts.Filename
+		rec.Data.Range = opts.Range
+		rec.Data.RangeFormat = opts.RangeFormat
+
+		rec.Data.Options, err = json.Marshal(opts)
+		if err != nil {
+			return err
+		}
+		rec.Data.Build = currentBuildInfo()
+		rec.Filters = func(position int) *report.FilterState {
+			return newFilterState(opts, position)
+		}
+
+		rec.RecordHidden = opts.RecordHidden
+
+		// the coordinator tracks the batches returned by the workers,
+		// otherwise the tracker ignores the items generated by the
+		// recurser
+		if coord != nil {
+			rec.Position = coord.Position
+		} else {
+			rec.Position = tracker.Position
+			rec.Done = tracker.Done
+		}
+
+		rec.Stopped = func() bool {
+			return producerCtx.Err() != nil && ctx.Err() == nil
+		}
+
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		outCount := make(chan int)
+		inCount := countCh
+		countCh = outCount
+
+		g.Go(func() error {
+			return rec.Run(ctx, in, out, inCount, outCount)
+		})
+	}
+
+	if opts.WriteFound != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		w := NewFoundWriter(opts.WriteFound)
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	if opts.WriteAmass != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		w := NewAmassWriter(opts.WriteAmass, hostname)
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	if opts.WriteMarkdown != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		w := NewMarkdownWriter(opts.WriteMarkdown, resolve.CleanHostname(hostname))
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	if opts.WriteGraph != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		w := NewGraphWriter(opts.WriteGraph)
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	if opts.WriteIPs != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		w := NewIPWriter(opts.WriteIPs, opts.SplitIPs)
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	// collect items for which all requests failed, next to the log file
+	// unless another file is given
+	if filename := outputFile(opts.WriteFailed, opts.NoWriteFailed, logfilePrefix, ".failed.txt"); filename != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		w := NewFailedWriter(filename)
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	// generate new items from the naming patterns of the results, they are
+	// written next to the log file unless another file is given
+	if filename := outputFile(opts.WriteSuggestions, opts.NoWriteSuggestions, logfilePrefix, ".suggestions.txt"); filename != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		sg := NewSuggester(filename)
+		g.Go(func() error {
+			return sg.Run(ctx, in, out)
+		})
+	}
+
+	if len(opts.KafkaBrokers) > 0 {
+		w, err := NewKafkaWriter(term, opts)
+		if err != nil {
+			return err
+		}
+
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	if opts.WriteTypes != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		w := NewTypeWriter(opts.WriteTypes)
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	if opts.WriteDelegations != "" {
+		out := make(chan resolve.Result)
+		in := responseCh
+		responseCh = out
+
+		w := NewDelegationWriter(opts.WriteDelegations)
+		g.Go(func() error {
+			return w.Run(ctx, in, out)
+		})
+	}
+
+	// run the reporter
+	printer, err := newResultPrinter(opts, hostname)
+	if err != nil {
+		return err
+	}
+
+	if opts.OutputFormat == "text" && !opts.quiet() {
+		term.Printf("hostname template: %v\n\n", hostname)
+	}
+
+	reporter := report.NewReporter(term, printer)
+	reporter.Quiet = opts.quiet()
+	reporter.SortBy = opts.SortResults
+	reporter.GroupSummary = opts.GroupSummary
+	reporter.Cluster = opts.Cluster
+	reporter.Verbosity = opts.Verbose
+	reporter.ExactStats = opts.ExactStats
+	reporter.Paused = throttle.Paused
+	reporter.IterationSize = iterationSize
+	if opts.health != nil {
+		reporter.Benched = opts.health.Benched
+	}
+
+	if opts.controlAddr != "" {
+		token := opts.controlToken
+		if token == "" {
+			token, err = newToken()
+			if err != nil {
+				return err
+			}
+		}
+
+		api := NewControlAPI(throttle, pool, reporter, rec, token)
+		addr, err := api.Start(opts.controlAddr)
+		if err != nil {
+			return fmt.Errorf("unable to start control API: %v", err)
+		}
+
+		if !opts.quiet() || opts.controlToken == "" {
+			term.Printf("control API listening on http://%v\n", addr)
+		}
+
+		// the API cannot be used without the generated token, it is not
+		// written to the log file
+		if opts.controlToken == "" {
+			cli.Secretf(term, "control API token is %v\n", token)
+		}
+	}
+
+	err = reporter.Display(responseCh, countCh)
+	if notifier != nil {
+		notifier.Finish(ctx.Err() != nil || err != nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the producer is only cancelled early when enough results were shown
+	switch {
+	case opts.quiet() || ctx.Err() != nil:
+	case producerCtx.Err() == context.Canceled:
+		term.Printf("\nstopped after %d shown results", opts.StopAfterFound)
+	case producerCtx.Err() == context.DeadlineExceeded:
+		term.Printf("\nstopped after the maximum duration of %v", opts.MaxDuration)
+	}
+
+	if opts.nxdomains != nil && opts.nxdomains.Pruned() > 0 && !opts.quiet() {
+		term.Printf("\nskipped the requests for %d host names below names which do not exist", opts.nxdomains.Pruned())
+	}
+
+	if coord != nil && !opts.quiet() {
+		term.Printf("\nresults by worker:\n%s\n", strings.Join(coord.Report(), "\n"))
+	}
+
+	return nil
+}
+
+// addRunFlags adds the flags for reading values, controlling the run and
+// writing output files.
+func addRunFlags(flags *pflag.FlagSet, opts *Options) {
+	flags.Float64Var(&opts.RequestsPerSecond, "requests-per-second", 0, "do at most `n` requests per seconds (e.g. 0.5), including the requests for --compare-with and --verify-with")
+	flags.IntVar(&opts.Burst, "burst", 1, "allow bursts of up to `n` requests while respecting --requests-per-second on average")
+	flags.StringVar(&opts.Jitter, "jitter", "", "wait a random duration in `range` (e.g. 50ms-500ms) before each query, in addition to --requests-per-second")
+	flags.StringVar(&opts.pprofAddr, "pprof-addr", "", "serve profiling data (net/http/pprof) on `addr`, e.g. localhost:6060")
+	flags.StringVar(&opts.controlAddr, "control-addr", "", "serve an HTTP API for pausing and adjusting the running program on `addr`, e.g. localhost:8053")
+	flags.StringVar(&opts.controlToken, "control-token", "", "require clients of the control API to send `token` as \"Authorization: Bearer TOKEN\" (default: generate a random token)")
+	flags.BoolVar(&opts.Interactive, "interactive", false, "control the running program with keys (pause, rate limit, filters, details)")
+	flags.IntVar(&opts.BufferSize, "buffer-size", 100000, "set number of buffered items to `n`")
+	flags.StringVar(&opts.SpillDir, "spill-dir", "", "store items exceeding --buffer-size in temporary files in `dir` instead of waiting")
+	flags.StringVar(&opts.Logfile, "logfile", "", "write copy of printed messages to `filename`.log")
+	flags.StringVar(&opts.Logdir, "logdir", os.Getenv("TAIFUN_LOG_DIR"), "automatically log all output to files in `dir`")
+	flags.BoolVar(&opts.Compress, "compress", false, "compress the log files with gzip")
+	flags.StringVar(&opts.LogFormat, "log-format", "text", "write messages to the .log file in `format` (text, json)")
+	flags.BoolVar(&opts.LogStdout, "log-stdout", false, "also print messages as JSON records on stdout (requires --log-format json)")
+
+	flags.BoolVar(&opts.RecordHidden, "record-hidden", false, "also record hidden results in the JSON log, marked as hidden")
+	flags.StringVar(&opts.WriteFound, "write-found", "", "append host names which resolved to `filename`")
+	flags.StringVar(&opts.WriteAmass, "write-amass", "", "write host names which resolved with their addresses to `filename` in the JSON format of amass")
+	flags.StringVar(&opts.WriteMarkdown, "write-markdown", "", "write a Markdown report of the findings to `filename`")
+	flags.StringVar(&opts.WriteGraph, "write-graph", "", "write a graph of CNAME chains, delegations and addresses to `filename` (DOT, or SVG if the name ends with .svg)")
+	flags.StringVar(&opts.WriteIPs, "write-ips", "", "write the unique addresses of the shown results to `filename` at the end of the run, e.g. for port scanners")
+	flags.BoolVar(&opts.SplitIPs, "split-ips", false, "write IPv4 and IPv6 addresses for --write-ips to separate files (e.g. ips-v4.txt and ips-v6.txt)")
+	flags.StringVar(&opts.WriteTypes, "write-types", "", "write responses to one file per record type (e.g. a.txt) in `dir`")
+	flags.StringVar(&opts.WriteDelegations, "write-delegations", "", "write potential delegations and their name servers to `filename`")
+	flags.StringVar(&opts.WriteFailed, "write-failed", "", "write the values for which all requests failed to `filename`, e.g. for retrying them with -f (default: <logfile>.failed.txt)")
+	flags.BoolVar(&opts.NoWriteFailed, "no-write-failed", false, "do not write the values for which all requests failed to a file")
+	flags.StringVar(&opts.WriteSuggestions, "write-suggestions", "", "write new values generated from the naming patterns of the host names found to `filename`, e.g. for a second run with -f (default: <logfile>.suggestions.txt)")
+	flags.BoolVar(&opts.NoWriteSuggestions, "no-write-suggestions", false, "do not write new values generated from the naming patterns to a file")
+
+	flags.StringSliceVar(&opts.KafkaBrokers, "kafka-brokers", nil, "publish the shown results as JSON to Kafka, connecting to `host:port,...` (key: host name)")
+	flags.StringVar(&opts.KafkaTopic, "kafka-topic", "", "publish the results for --kafka-brokers to `topic`")
+	flags.BoolVar(&opts.KafkaTLS, "kafka-tls", false, "connect to the Kafka brokers with TLS")
+	flags.StringVar(&opts.KafkaSASL, "kafka-sasl", "", "authenticate to the Kafka brokers with SASL `mechanism` ("+strings.Join(kafkaSASLMechanisms, ", ")+")")
+	flags.StringVar(&opts.KafkaUsername, "kafka-username", "", "use `name` for --kafka-sasl")
+	flags.StringVar(&opts.kafkaPassword, "kafka-password", "", "use `password` for --kafka-sasl")
+
+	flags.StringVar(&opts.notifySlack, "notify-slack", "", "post notifications to the Slack incoming webhook at `url` (usually set in a profile in the config file)")
+	flags.StringVar(&opts.notifyDiscord, "notify-discord", "", "post notifications to the Discord webhook at `url` (usually set in a profile in the config file)")
+	flags.StringVar(&opts.notifyTelegram, "notify-telegram", "", "send notifications via the Telegram bot to a chat, specified as `token:chat-id`")
+	flags.StringSliceVar(&opts.NotifyEvents, "notify-events", notifyEvents, "send notifications for these `events`: "+strings.Join(notifyEvents, ", "))
+	flags.Float64Var(&opts.NotifyErrorRate, "notify-error-rate", 0.2, "send the errors notification when all requests have failed for this `fraction` of the results (0 disables it)")
+	flags.BoolVar(&opts.Bell, "bell", false, "ring the terminal bell when a result is shown (or one matching --bell-pattern)")
+	flags.StringArrayVar(&opts.BellPatterns, "bell-pattern", nil, "only ring the bell for results with a host name or response data matching `regex` (may be specified multiple times)")
+	flags.BoolVar(&opts.DesktopNotify, "desktop-notify", false, "also show a desktop notification for the results the bell rings for (needs notify-send or osascript)")
+
+	flags.StringArrayVar(&opts.PluginFiles, "plugin-load", nil, "load the Go plugin in `file.so`")
+	flags.StringArrayVar(&opts.Plugins, "plugin", nil, "process results with the loaded plugin `name[:args]`")
+	flags.StringArrayVar(&opts.PluginCommands, "plugin-exec", nil, "process results with the external plugin started by `command` (JSON lines on stdin and stdout)")
 
 	flags.IntVar(&opts.Skip, "skip", 0, "skip the first `n` requests")
 	flags.IntVar(&opts.Limit, "limit", 0, "only run `n` requests, then exit")
+	flags.IntVar(&opts.Loop, "loop", 0, "replay the input `n` times and report the statistics for each iteration, without the response cache (e.g. to test the stability of resolvers)")
+	flags.BoolVar(&opts.Forever, "forever", false, "replay the input until interrupted, like --loop")
+	flags.DurationVar(&opts.MaxDuration, "max-duration", 0, "stop sending requests after `duration` (e.g. 2h), finish the requests in flight and exit")
+	flags.IntVar(&opts.StopAfterFound, "stop-after-found", 0, "stop sending requests after `n` results have been shown, finish the requests in flight and exit")
+	flags.IntVar(&opts.RecurseDepth, "recurse-depth", 0, "also fuzz below the host names found, up to `n` levels below the template")
+	flags.StringVar(&opts.RecurseFile, "recurse-file", "", "read the values to test below the host names found with --recurse-depth from `filename` (default: --file)")
+	flags.IntVar(&opts.CacheSize, "cache-size", 10000, "answer host names requested again from a cache of the results for the last `n` host names (0 disables the cache)")
+	flags.BoolVar(&opts.Dedup, "dedup", false, "skip duplicate items, using a fixed amount of memory (a small fraction of items may be skipped wrongly)")
+	flags.IntVar(&opts.DedupExpected, "dedup-expected", 10000000, "size the filter for --dedup for `n` distinct items")
+	flags.Float64Var(&opts.DedupFalsePositive, "dedup-false-positive", 0.0001, "skip at most this `fraction` of distinct items wrongly with --dedup")
 
 	flags.StringVarP(&opts.Filename, "file", "f", "", "read values to test from `filename`")
 	flags.StringVarP(&opts.Range, "range", "r", "", "test range `from-to`")
 	flags.StringVar(&opts.RangeFormat, "range-format", "%d", "set `format` for range")
+	flags.StringVar(&opts.InputFormat, "input-format", "text", "read the values in `format`: text, csv (value,label,...), json (one object with item and labels per line), amass or subfinder (JSON output of these tools, host names outside the template are skipped)")
 	flags.StringSliceVar(&opts.RequestTypes, "request-types", []string{"A", "AAAA"}, "request `TYPE,TYPE2` for each host")
+	flags.BoolVar(&opts.TXTSegments, "txt-segments", false, "print the strings of TXT records quoted and separated instead of joined")
+	flags.IntVar(&opts.ParallelRequests, "parallel-requests", 1, "send up to `n` requests for the same host (e.g. for different types) in parallel")
+	flags.BoolVar(&opts.FollowCNAMEs, "follow-cnames", false, fmt.Sprintf("resolve CNAME chains to the final addresses if the answer does not include them (at most %d CNAME records)", resolve.MaxCNAMEDepth))
+	flags.StringArrayVar(&opts.ClientSubnets, "ecs", nil, "send an EDNS Client Subnet option for `subnet` (CIDR) with each query, if specified multiple times, each query is sent once per subnet and host names with different answers are flagged")
+	flags.BoolVar(&opts.PruneNXDomain, "prune-nxdomain", false, "skip the requests for host names below names which returned NXDOMAIN, as nothing can exist below them (RFC 8020)")
+	flags.StringVar(&opts.ClientSubnet, "client-subnet", "", "attach an EDNS Client Subnet option for `subnet` (CIDR) to all queries (including --compare-with and --verify-with), e.g. the network of the client")
+	flags.BoolVarP(&opts.IPv4, "ipv4", "4", false, "only send queries to the name servers via IPv4")
+	flags.BoolVarP(&opts.IPv6, "ipv6", "6", false, "only send queries to the name servers via IPv6")
+	flags.StringVar(&opts.Interface, "interface", "", "send queries from the addresses of the network interface `name` (e.g. wg0)")
+	addTSIGFlags(flags, &opts.TSIGName, &opts.TSIGAlgorithm, &opts.tsigSecret)
+}
 
-	flags.StringVar(&opts.Nameserver, "nameserver", "", "send DNS queries to `server`, if empty, the system resolver is used")
-
+// addDisplayFlags adds the flags for filtering and displaying results.
+func addDisplayFlags(flags *pflag.FlagSet, opts *Options) {
+	flags.StringVar(&opts.OutputFormat, "output-format", "text", "print results in `format` (text, wide, csv, massdns, massdns-ndjson)")
+	flags.StringVar(&opts.Format, "format", "", "print each response using the Go `template`, e.g. '{{.Hostname}} {{.Type}} {{.Data}}'")
+	flags.StringVar(&opts.SortResults, "sort-results", "", "print all shown results again at the end, sorted by `order` (hostname, ip)")
+	flags.CountVarP(&opts.Verbose, "verbose", "v", "print the raw DNS messages for shown results (answer and authority, all sections for -vv)")
+	flags.BoolVar(&opts.ExactStats, "exact-stats", false, fmt.Sprintf("count unique responses exactly, by default the numbers are estimated above %d values to save memory", report.MaxExactValues))
+	flags.BoolVar(&opts.ShowRTT, "show-rtt", false, "display the round-trip time of the request for each response")
+	flags.BoolVar(&opts.ShowFlags, "show-flags", false, "display the header flags of the response (aa: authoritative, tc: truncated, ra: recursion available, ad: authenticated data)")
+	flags.BoolVar(&opts.ShowConfidence, "show-confidence", false, "display the confidence that the answers are not caused by a wildcard (low, medium, high)")
+	flags.StringVar(&opts.MinConfidence, "min-confidence", "", "hide results with answers rated below `level` (low, medium, high) compared to the wildcard")
+	flags.BoolVar(&opts.GroupSummary, "group-summary", false, "print the host names grouped by address and CNAME target at the end")
+	flags.BoolVar(&opts.Cluster, "cluster", false, "print clusters of host names connected by shared addresses or CNAME targets at the end")
+	flags.BoolVarP(&opts.Quiet, "quiet", "q", false, "only print results, no status (default if stdout is not a terminal)")
 	flags.BoolVar(&opts.ShowNotFound, "show-not-found", false, "do not hide 'not found' responses")
 	flags.StringArrayVar(&opts.HideNetworks, "hide-network", nil, "hide responses in `network` (CIDR)")
 	flags.StringArrayVar(&opts.ShowNetworks, "show-network", nil, "only show responses in `network` (CIDR)")
-	flags.StringArrayVar(&opts.HideCNAMEs, "hide-cname", nil, "hide CNAME responses matching `regex`")
-	flags.StringArrayVar(&opts.HidePTR, "hide-ptr", nil, "hide PTR responses matching `regex`")
+	flags.StringArrayVar(&opts.HideCNAMEs, "hide-cname", nil, "hide CNAME responses matching `regex` (same as --hide-data CNAME:regex)")
+	flags.StringArrayVar(&opts.HidePTR, "hide-ptr", nil, "hide PTR responses matching `regex` (same as --hide-data PTR:regex)")
+	flags.StringArrayVar(&opts.HideData, "hide-data", nil, "hide responses with data matching `[TYPE:]regex`, e.g. TXT:^v=spf1 (can be specified multiple times)")
+	flags.StringArrayVar(&opts.ShowData, "show-data", nil, "only show responses with data matching `[TYPE:]regex`, responses of other types are not affected (can be specified multiple times)")
 	flags.BoolVar(&opts.HideEmpty, "hide-empty", false, "do not show empty responses")
 	flags.BoolVar(&opts.HideDelegations, "hide-delegations", false, "do not show potential delegations")
+	flags.StringArrayVar(&opts.ShowLabels, "show-label", nil, "only show results for items with `label` from --input-format csv or json (can be specified multiple times)")
+	flags.StringArrayVar(&opts.HideLabels, "hide-label", nil, "do not show results for items with `label` (can be specified multiple times)")
+	flags.StringArrayVar(&opts.ShowOwners, "show-owner", nil, "only show addresses whose owner or netname from --rdap matches `regex` (can be specified multiple times)")
+	flags.StringArrayVar(&opts.HideOwners, "hide-owner", nil, "hide addresses whose owner or netname from --rdap matches `regex` (can be specified multiple times)")
+}
+
+func main() {
+	var opts Options
+
+	cmd := &cobra.Command{
+		Use:                   "taifun [options] HOSTNAME",
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		Args:                  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// only the options on the command line are rejected, the
+			// profile may set options which the checkpoint overrides
+			if opts.resumeCheckpoint != "" {
+				if len(args) > 0 {
+					return errors.New("the host name is read from the checkpoint, no arguments needed")
+				}
+
+				err := checkCheckpointFlags(cmd.Flags())
+				if err != nil {
+					return err
+				}
+			}
+
+			err := applyProfile(cmd, opts.configFile, opts.Profile)
+			if err != nil {
+				return err
+			}
+
+			if opts.resumeCheckpoint != "" {
+				resumed, hostname, err := resumeCheckpoint(&opts)
+				if err != nil {
+					return err
+				}
+				opts, args = *resumed, []string{hostname}
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				if opts.Watch {
+					return watch(ctx, g, &opts, args)
+				}
+				return run(ctx, g, &opts, args)
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	addProfileFlags(flags, &opts.configFile, &opts.Profile)
+	opts.Threads = 2
+	flags.VarP(threadsValue{&opts}, "threads", "t", "resolve `n` DNS queries in parallel, \"auto\" adjusts the number while running")
+	flags.StringVar(&opts.Nameserver, "nameserver", "", "send DNS queries to `server` or to the URL of a JSON API (e.g. https://dns.google/resolve), if empty, the system resolver is used")
+	flags.StringVar(&opts.resolversFile, "resolvers", "", "distribute DNS queries across the name servers read from `filename`, one per line, optionally followed by a weight to pick the server for each query at random (\"builtin\" selects a list of public resolvers)")
+	flags.BoolVar(&opts.DNSSEC, "dnssec", false, "validate the DNSSEC chain of trust for shown results via --nameserver and report the state (secure, insecure, bogus)")
+	flags.StringArrayVar(&opts.CompareWith, "compare-with", nil, "also send each query to `server` and flag host names with different answers, e.g. to detect split-horizon DNS (can be specified multiple times)")
+	flags.StringVar(&opts.VerifyWith, "verify-with", "", "send the requests for shown results again to the trusted resolver `server` and hide results it does not reproduce")
+	flags.BoolVar(&opts.HTTPProbe, "http-probe", false, "send HTTP and HTTPS requests to the host names of shown results and report the status, title and server")
+	flags.DurationVar(&opts.HTTPTimeout, "http-timeout", 10*time.Second, "wait at most `duration` for the responses of --http-probe")
+	flags.IntSliceVar(&opts.CheckPorts, "check-ports", nil, "connect to the TCP `ports` (e.g. 22,80,443) of each address in shown results and report the open ones")
+	flags.DurationVar(&opts.PortTimeout, "port-timeout", 2*time.Second, "wait at most `duration` for each connection of --check-ports and --tls-sans")
+	flags.BoolVar(&opts.HarvestSANs, "tls-sans", false, "record the host names in the certificates on port 443 of shown results and request the new ones matching the template")
+	flags.IntVar(&opts.SANLimit, "tls-sans-limit", 1000, "request at most `n` new host names found with --tls-sans")
+	flags.BoolVar(&opts.RDAP, "rdap", false, "look up the owning organization and netname of the addresses via RDAP")
+	flags.StringVar(&opts.RDAPServer, "rdap-server", rdap.DefaultServer, "send RDAP requests for --rdap to `url`")
+	flags.Float64Var(&opts.RDAPRate, "rdap-requests-per-second", 1, "send at most `n` RDAP requests per second, networks are cached")
+	flags.BoolVar(&opts.KeepUnverified, "keep-unverified", false, "only mark results not reproduced with --verify-with instead of hiding them")
+	flags.StringVar(&opts.CheckResolvers, "check-resolvers", "", "send test queries to the name servers before the run and do not use those which fail (`mode` drop), or only warn about them (warn)")
+	flags.Lookup("check-resolvers").NoOptDefVal = "drop"
+	flags.StringVar(&opts.CheckName, "check-name", "example.com", "resolve `name` and a random name below it to check the name servers with --check-resolvers")
+	flags.BoolVar(&opts.Authoritative, "authoritative", false, "send DNS queries directly to the authoritative name servers of the target zone, found via --nameserver")
+	flags.BoolVar(&opts.Search, "search", false, "append the first search domain of the system to relative host name templates (e.g. FUZZ)")
+
+	flags.StringVar(&opts.resumeCheckpoint, "resume-checkpoint", "", "continue the run recorded in the checkpoint `filename` (written next to the JSON log) where it stopped, all options except for secrets like --tsig-secret are read from the checkpoint")
+
+	flags.BoolVar(&opts.Watch, "watch", false, "repeat the enumeration every --interval and only report new, removed and changed host names")
+	flags.DurationVar(&opts.WatchInterval, "interval", 6*time.Hour, "wait `duration` between passes with --watch")
+	flags.StringVar(&opts.WatchState, "watch-state", "", "load the results to compare against from `filename` and save them after each pass with --watch")
+	flags.StringVar(&opts.OnChange, "on-change", "", "run `command` with the changes on stdin when a pass with --watch finds changes")
+
+	addRunFlags(flags, &opts)
+	addDisplayFlags(flags, &opts)
+
+	cmd.AddCommand(
+		newDelegationsCommand(),
+		newResumeCommand(),
+		newReplayCommand(),
+		newDiffCommand(),
+		newMergeCommand(),
+		newReportCommand(),
+		newClusterCommand(),
+		newPTRCommand(),
+		newCompatCommand(),
+		newAXFRCommand(),
+		newBenchCommand(),
+		newOpenResolversCommand(),
+		newUpdateResolversCommand(),
+		newEmailAuditCommand(),
+		newSelftestCommand(),
+		newServeCommand(),
+		newWorkerCommand(),
+		newGRPCServerCommand(),
+	)
 
 	err := cmd.Execute()
 	if err != nil {
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestOutputFile(t *testing.T) {
+	var tests = []struct {
+		filename string
+		disabled bool
+		prefix   string
+		want     string
+	}{
+		{prefix: "/tmp/run", want: "/tmp/run.failed.txt"},
+		{filename: "retry.txt", prefix: "/tmp/run", want: "retry.txt"},
+		{filename: "retry.txt", want: "retry.txt"},
+		{disabled: true, prefix: "/tmp/run"},
+		{filename: "retry.txt", disabled: true, prefix: "/tmp/run"},
+		{},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			got := outputFile(test.filename, test.disabled, test.prefix, ".failed.txt")
+			if got != test.want {
+				t.Errorf("wrong file name, want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackPosition(t *testing.T) {
+	var tests = []struct {
+		opts   *Options
+		prefix string
+		want   bool
+	}{
+		{opts: &Options{}, prefix: "/tmp/run", want: true},
+		// without a log, nobody marks the values as done
+		{opts: &Options{}},
+		{opts: &Options{Forever: true}},
+		// the coordinator tracks the batches itself
+		{opts: &Options{serveAddr: "localhost:8054"}, prefix: "/tmp/run"},
+	}
+
+	for _, test := range tests {
+		got := trackPosition(test.opts, test.prefix)
+		if got != test.want {
+			t.Errorf("trackPosition(%+v, %q): want %v, got %v", test.opts, test.prefix, test.want, got)
+		}
+	}
+}
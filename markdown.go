@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+)
+
+// escapeMarkdown escapes the pipe character, which separates the cells in a Markdown table.
+func escapeMarkdown(s string) string {
+	return strings.Replace(s, "|", `\|`, -1)
+}
+
+func writeMarkdownTable(wr io.Writer, title, column string, m map[string][]string, columnList string) {
+	if len(m) == 0 {
+		return
+	}
+
+	fmt.Fprintf(wr, "\n## %s\n\n", title)
+	fmt.Fprintf(wr, "| %s | %s |\n", column, columnList)
+	fmt.Fprintf(wr, "|---|---|\n")
+
+	for _, key := range resolve.SortedKeys(m) {
+		var values []string
+		for _, v := range resolve.Unique(m[key]) {
+			values = append(values, "`"+escapeMarkdown(v)+"`")
+		}
+		fmt.Fprintf(wr, "| `%s` | %s |\n", escapeMarkdown(key), strings.Join(values, ", "))
+	}
+}
+
+// WriteMarkdown renders a report about the summary for the hostname template as Markdown.
+func WriteMarkdown(wr io.Writer, hostname string, s *report.Summary) {
+	fmt.Fprintf(wr, "# DNS enumeration of `%s`\n\n", escapeMarkdown(hostname))
+	fmt.Fprintf(wr, "%d results found: %d addresses, %d CNAME targets, %d potential delegations.\n",
+		s.Results, len(s.Addresses), len(s.CNAMEs), len(s.Delegations))
+	if s.Truncated {
+		fmt.Fprintf(wr, "\nThe tables are incomplete, at most %d entries and %d host names per entry are kept.\n", report.MaxSummaryKeys, report.MaxSummaryHostnames)
+	}
+
+	writeMarkdownTable(wr, "Addresses", "Address", s.Addresses, "Host names")
+	writeMarkdownTable(wr, "CNAME targets", "Target", s.CNAMEs, "Host names")
+	writeMarkdownTable(wr, "Potential delegations", "Host name", s.Delegations, "Name servers")
+}
+
+// MarkdownWriter collects results and writes a Markdown report when done.
+type MarkdownWriter struct {
+	filename string
+	hostname string
+	*report.Summary
+}
+
+// NewMarkdownWriter returns a new writer for filename, hostname is the template.
+func NewMarkdownWriter(filename, hostname string) *MarkdownWriter {
+	return &MarkdownWriter{
+		filename: filename,
+		hostname: hostname,
+		Summary:  report.NewSummary(),
+	}
+}
+
+// Run reads results from in and forwards them to out, collecting the shown
+// results on the way. When in is closed or the context is cancelled, the
+// report is written and out is closed.
+func (w *MarkdownWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	err := forward(ctx, in, out, func(res resolve.Result) error {
+		w.Add(res)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	WriteMarkdown(buf, w.hostname, w.Summary)
+	return ioutil.WriteFile(w.filename, buf.Bytes(), 0644)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+)
+
+// mergeResults merges two results for the same host name, requests of a
+// type not recorded in res yet are added from other. The data of a hidden
+// result is not added to a shown result, it has been filtered out.
+func mergeResults(res, other report.RecordedResult) report.RecordedResult {
+	switch {
+	case res.Hidden && !other.Hidden:
+		return other
+	case !res.Hidden && other.Hidden:
+		return res
+	}
+
+	types := make(map[string]struct{})
+	for _, req := range res.Requests {
+		types[req.Type] = struct{}{}
+	}
+
+	for _, req := range other.Requests {
+		if _, ok := types[req.Type]; ok {
+			continue
+		}
+		res.Requests = append(res.Requests, req)
+	}
+
+	res.Hidden = res.Hidden && other.Hidden
+	res.Nameservers = resolve.Unique(append(res.Nameservers, other.Nameservers...))
+	res.PotentialDelegation = res.PotentialDelegation || other.PotentialDelegation
+	res.PotentialSuffix = res.PotentialSuffix || other.PotentialSuffix
+
+	return res
+}
+
+// sameOptions returns true if both recorded options are equal.
+func sameOptions(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+
+	var bufA, bufB bytes.Buffer
+	errA := json.Compact(&bufA, a)
+	errB := json.Compact(&bufB, b)
+	return errA == nil && errB == nil && bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
+
+// MergeData merges the results of several runs for the same host name
+// template into one, results for the same host name are deduplicated. The
+// statistics are combined, the numbers of shown and hidden results are
+// counted in the merged results, so hidden results are only included if they
+// have been recorded (--record-hidden).
+func MergeData(logs []*report.Data) (*report.Data, error) {
+	if len(logs) == 0 {
+		return nil, errors.New("nothing to merge")
+	}
+
+	first := logs[0]
+	merged := &report.Data{
+		SchemaVersion: report.SchemaVersion,
+		Start:         first.Start,
+		End:           first.End,
+		Hostname:      first.Hostname,
+		InputFile:     first.InputFile,
+		Range:         first.Range,
+		RangeFormat:   first.RangeFormat,
+		Options:       first.Options,
+		Build:         currentBuildInfo(),
+		Results:       []report.RecordedResult{},
+	}
+
+	index := make(map[string]int)
+
+	for _, data := range logs {
+		if data.Hostname != merged.Hostname {
+			return nil, fmt.Errorf("host name templates differ: %q and %q", merged.Hostname, data.Hostname)
+		}
+
+		if data.Start.Before(merged.Start) {
+			merged.Start = data.Start
+		}
+		if data.End.After(merged.End) {
+			merged.End = data.End
+		}
+
+		merged.TotalRequests += data.TotalRequests
+		merged.SentRequests += data.SentRequests
+		merged.Position += data.ProcessedValues()
+		merged.Cancelled = merged.Cancelled || data.Cancelled
+
+		// the input is only known if it was the same for all runs
+		if data.InputFile != merged.InputFile || data.Range != merged.Range {
+			merged.InputFile, merged.Range, merged.RangeFormat = "", "", ""
+		}
+		if !sameOptions(data.Options, merged.Options) {
+			merged.Options = nil
+		}
+
+		for _, res := range data.Results {
+			if i, ok := index[res.Hostname]; ok {
+				merged.Results[i] = mergeResults(merged.Results[i], res)
+				continue
+			}
+
+			index[res.Hostname] = len(merged.Results)
+			merged.Results = append(merged.Results, res)
+		}
+	}
+
+	for _, res := range merged.Results {
+		if res.Hidden {
+			merged.HiddenResults++
+		} else {
+			merged.ShownResults++
+		}
+	}
+
+	merged.IPs = merged.Addresses()
+	merged.Delegations = merged.PotentialDelegations()
+
+	return merged, nil
+}
+
+func newMergeCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:                   "merge [options] LOGFILE.json [LOGFILE.json...]",
+		Short:                 "Merge several JSON logs into one",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				return errors.New("no output file specified")
+			}
+
+			if len(args) == 0 {
+				return errors.New("no JSON logs specified")
+			}
+
+			var logs []*report.Data
+			for _, filename := range args {
+				data, err := report.ReadData(filename)
+				if err != nil {
+					return fmt.Errorf("reading %v failed: %v", filename, err)
+				}
+				logs = append(logs, data)
+			}
+
+			merged, err := MergeData(logs)
+			if err != nil {
+				return err
+			}
+
+			return report.WriteData(output, merged)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the merged log to `filename`")
+
+	return cmd
+}
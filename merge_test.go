@@ -0,0 +1,179 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/report"
+)
+
+// recordedResult returns a result for hostname with one request per type,
+// each answered with data.
+func recordedResult(hostname string, hidden bool, data string, types ...string) report.RecordedResult {
+	res := report.RecordedResult{Item: hostname, Hostname: hostname, Hidden: hidden}
+	for _, t := range types {
+		res.Requests = append(res.Requests, report.RecordedRequest{
+			Type:      t,
+			Status:    "NOERROR",
+			Responses: []report.RecordedResponse{{Type: t, Data: data}},
+		})
+	}
+	return res
+}
+
+// requestTypes returns the types and the first response data of the
+// requests of res.
+func requestTypes(res report.RecordedResult) (list []string) {
+	for _, req := range res.Requests {
+		list = append(list, req.Type+":"+req.Responses[0].Data)
+	}
+	return list
+}
+
+func TestMergeResults(t *testing.T) {
+	var tests = []struct {
+		name   string
+		res    report.RecordedResult
+		other  report.RecordedResult
+		hidden bool
+		want   []string
+	}{
+		{
+			name:  "new-type",
+			res:   recordedResult("www", false, "192.0.2.1", "A"),
+			other: recordedResult("www", false, "2001:db8::1", "AAAA"),
+			want:  []string{"A:192.0.2.1", "AAAA:2001:db8::1"},
+		},
+		{
+			// the request of the first result is kept
+			name:  "same-type",
+			res:   recordedResult("www", false, "192.0.2.1", "A"),
+			other: recordedResult("www", false, "192.0.2.2", "A", "AAAA"),
+			want:  []string{"A:192.0.2.1", "AAAA:192.0.2.2"},
+		},
+		{
+			name:  "other-hidden",
+			res:   recordedResult("www", false, "192.0.2.1", "A"),
+			other: recordedResult("www", true, "10.0.0.1", "A", "AAAA"),
+			want:  []string{"A:192.0.2.1"},
+		},
+		{
+			name:  "first-hidden",
+			res:   recordedResult("www", true, "10.0.0.1", "A", "AAAA"),
+			other: recordedResult("www", false, "192.0.2.1", "A"),
+			want:  []string{"A:192.0.2.1"},
+		},
+		{
+			name:   "both-hidden",
+			res:    recordedResult("www", true, "10.0.0.1", "A"),
+			other:  recordedResult("www", true, "10.0.0.2", "AAAA"),
+			hidden: true,
+			want:   []string{"A:10.0.0.1", "AAAA:10.0.0.2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := mergeResults(test.res, test.other)
+
+			if res.Hidden != test.hidden {
+				t.Errorf("wrong hidden state, want %v, got %v", test.hidden, res.Hidden)
+			}
+
+			got := requestTypes(res)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong requests, want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestMergeData(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	first := &report.Data{
+		Hostname:      "FUZZ.example.com",
+		InputFile:     "words.txt",
+		Start:         start,
+		End:           start.Add(time.Minute),
+		TotalRequests: 4,
+		SentRequests:  4,
+		ShownResults:  2,
+		HiddenResults: 2,
+		Results: []report.RecordedResult{
+			recordedResult("www.example.com", false, "192.0.2.1", "A"),
+			recordedResult("mail.example.com", false, "192.0.2.2", "A"),
+			recordedResult("ftp.example.com", true, "10.0.0.1", "A"),
+		},
+	}
+
+	second := &report.Data{
+		Hostname:      "FUZZ.example.com",
+		InputFile:     "other.txt",
+		Start:         start.Add(time.Hour),
+		End:           start.Add(2 * time.Hour),
+		TotalRequests: 3,
+		SentRequests:  3,
+		ShownResults:  3,
+		Results: []report.RecordedResult{
+			recordedResult("www.example.com", false, "2001:db8::1", "AAAA"),
+			recordedResult("ftp.example.com", false, "192.0.2.3", "A"),
+			recordedResult("dev.example.com", false, "192.0.2.4", "A"),
+		},
+	}
+
+	merged, err := MergeData([]*report.Data{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, res := range merged.Results {
+		for _, rt := range requestTypes(res) {
+			got = append(got, res.Hostname+" "+rt)
+		}
+	}
+
+	// the hidden result for ftp is replaced by the shown one
+	want := []string{
+		"www.example.com A:192.0.2.1",
+		"www.example.com AAAA:2001:db8::1",
+		"mail.example.com A:192.0.2.2",
+		"ftp.example.com A:192.0.2.3",
+		"dev.example.com A:192.0.2.4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong results, want:\n  %q\ngot:\n  %q", want, got)
+	}
+
+	if merged.ShownResults != 4 || merged.HiddenResults != 0 {
+		t.Errorf("wrong number of results, want 4 shown and 0 hidden, got %d and %d", merged.ShownResults, merged.HiddenResults)
+	}
+
+	if merged.TotalRequests != 7 || merged.SentRequests != 7 {
+		t.Errorf("wrong number of requests, want 7 and 7, got %d and %d", merged.TotalRequests, merged.SentRequests)
+	}
+
+	if !merged.Start.Equal(first.Start) || !merged.End.Equal(second.End) {
+		t.Errorf("wrong time range %v - %v", merged.Start, merged.End)
+	}
+
+	// the input files differ
+	if merged.InputFile != "" {
+		t.Errorf("input file %q kept", merged.InputFile)
+	}
+
+	if _, ok := merged.IPs["10.0.0.1"]; ok {
+		t.Errorf("address of the hidden result included: %v", merged.IPs)
+	}
+
+	if hosts := merged.IPs["192.0.2.3"]; !reflect.DeepEqual(hosts, []string{"ftp.example.com"}) {
+		t.Errorf("wrong host names for 192.0.2.3: %v", hosts)
+	}
+
+	_, err = MergeData([]*report.Data{first, {Hostname: "FUZZ.example.net"}})
+	if err == nil {
+		t.Errorf("logs for different templates merged")
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+)
+
+// Events for notifications, see --notify-events.
+const (
+	eventTakeover = "takeover" // a CNAME points to a name which does not exist
+	eventFinished = "finished" // the run has finished
+	eventErrors   = "errors"   // the fraction of failed results exceeds --notify-error-rate
+)
+
+// notifyEvents lists the valid values for --notify-events.
+var notifyEvents = []string{eventTakeover, eventFinished, eventErrors}
+
+// minErrorSamples is the number of results needed before the error rate is
+// evaluated.
+const minErrorSamples = 100
+
+// notifyTimeout is the time to wait for a webhook to accept a message.
+const notifyTimeout = 10 * time.Second
+
+// notifyTarget sends messages to a chat service.
+type notifyTarget struct {
+	name string
+	url  string
+	body func(msg string) interface{}
+}
+
+// newNotifyTargets returns the targets configured in opts. Telegram is
+// configured as "bot-token:chat-id", the bot token contains a colon itself.
+func newNotifyTargets(opts *Options) (targets []notifyTarget, err error) {
+	if opts.notifySlack != "" {
+		targets = append(targets, notifyTarget{
+			name: "Slack",
+			url:  opts.notifySlack,
+			body: func(msg string) interface{} { return map[string]string{"text": msg} },
+		})
+	}
+
+	if opts.notifyDiscord != "" {
+		targets = append(targets, notifyTarget{
+			name: "Discord",
+			url:  opts.notifyDiscord,
+			body: func(msg string) interface{} { return map[string]string{"content": msg} },
+		})
+	}
+
+	if opts.notifyTelegram != "" {
+		pos := strings.LastIndex(opts.notifyTelegram, ":")
+		if pos <= 0 || pos == len(opts.notifyTelegram)-1 {
+			return nil, errors.New("invalid value for --notify-telegram, expected bot-token:chat-id")
+		}
+		token, chat := opts.notifyTelegram[:pos], opts.notifyTelegram[pos+1:]
+
+		targets = append(targets, notifyTarget{
+			name: "Telegram",
+			url:  "https://api.telegram.org/bot" + token + "/sendMessage",
+			body: func(msg string) interface{} { return map[string]string{"chat_id": chat, "text": msg} },
+		})
+	}
+
+	return targets, nil
+}
+
+// danglingCNAME returns the CNAME target of res if it does not exist, which
+// makes the host name a candidate for a subdomain takeover.
+func danglingCNAME(res resolve.Result) (target string, ok bool) {
+	for _, req := range res.Requests {
+		var cname string
+		for _, response := range req.Responses {
+			if response.Type == "CNAME" {
+				cname = response.Data
+			}
+		}
+
+		if cname == "" {
+			continue
+		}
+
+		// the resolver followed the CNAME record, or --follow-cnames did
+		if req.NotFound || strings.HasSuffix(req.ChainError, "NXDOMAIN") {
+			return cname, true
+		}
+	}
+
+	return "", false
+}
+
+// notifyInterval is the minimal time between two batches of notifications.
+// Takeover candidates found in between are sent in a single message.
+const notifyInterval = 30 * time.Second
+
+// maxTakeoverLines is the maximal number of takeover candidates listed in one
+// message, the others are only counted.
+const maxTakeoverLines = 20
+
+// Notifier posts messages to chat services via webhooks when interesting
+// events occur: takeover candidates, a high error rate, and the end of the
+// run. Messages are collected and sent in batches by a single goroutine, so a
+// burst of takeover candidates results in one message. Errors are printed to
+// the terminal.
+type Notifier struct {
+	term      cli.Terminal
+	hostname  string
+	targets   []notifyTarget
+	events    map[string]bool
+	errorRate float64
+	client    *http.Client
+
+	start              time.Time
+	results, failed    int
+	shown              int
+	errorsSent         bool
+	takeoverCandidates int
+
+	mu        sync.Mutex
+	messages  []string // pending messages
+	takeovers []string // pending takeover candidates
+	skipped   int      // pending takeover candidates not listed
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewNotifier returns a new Notifier for the run for hostname.
+func NewNotifier(term cli.Terminal, opts *Options, hostname string) (*Notifier, error) {
+	targets, err := newNotifyTargets(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Notifier{
+		term:      term,
+		hostname:  resolve.CleanHostname(hostname),
+		targets:   targets,
+		events:    make(map[string]bool),
+		errorRate: opts.NotifyErrorRate,
+		client:    &http.Client{Timeout: notifyTimeout},
+		start:     time.Now(),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	for _, event := range opts.NotifyEvents {
+		n.events[event] = true
+	}
+
+	return n, nil
+}
+
+// post sends msg to target.
+func (n *Notifier) post(target notifyTarget, msg string) error {
+	buf, err := json.Marshal(target.body(msg))
+	if err != nil {
+		return err
+	}
+
+	res, err := n.client.Post(target.url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		// the error contains the URL, which includes secrets
+		return errors.New("request failed")
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected HTTP status %v", res.Status)
+	}
+
+	return nil
+}
+
+// notify queues msg for event, if the event is enabled.
+func (n *Notifier) notify(event, msg string) {
+	if !n.events[event] {
+		return
+	}
+
+	n.mu.Lock()
+	if event == eventTakeover {
+		if len(n.takeovers) < maxTakeoverLines {
+			n.takeovers = append(n.takeovers, msg)
+		} else {
+			n.skipped++
+		}
+	} else {
+		n.messages = append(n.messages, msg)
+	}
+	n.mu.Unlock()
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pending returns the queued messages and resets the queue. The takeover
+// candidates are joined into one message.
+func (n *Notifier) pending() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var msgs []string
+	if len(n.takeovers) > 0 {
+		msg := fmt.Sprintf("%d takeover candidates:\n%v", len(n.takeovers)+n.skipped, strings.Join(n.takeovers, "\n"))
+		if len(n.takeovers) == 1 {
+			msg = "takeover candidate " + n.takeovers[0]
+		}
+		if n.skipped > 0 {
+			msg += fmt.Sprintf("\n(and %d more)", n.skipped)
+		}
+		msgs = append(msgs, msg)
+	}
+	msgs = append(msgs, n.messages...)
+
+	n.messages, n.takeovers, n.skipped = nil, nil, 0
+	return msgs
+}
+
+// send posts all queued messages to all targets.
+func (n *Notifier) send() {
+	for _, msg := range n.pending() {
+		msg = fmt.Sprintf("taifun %v: %v", n.hostname, msg)
+		for _, target := range n.targets {
+			err := n.post(target, msg)
+			if err != nil {
+				cli.Warnf(n.term, "sending notification to %v failed: %v", target.name, err)
+			}
+		}
+	}
+}
+
+// sender sends the queued messages at most once per interval until n.done
+// is closed, then it sends the remaining messages.
+func (n *Notifier) sender(interval time.Duration) {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case <-n.wake:
+		case <-n.done:
+			n.send()
+			return
+		}
+
+		n.send()
+
+		select {
+		case <-time.After(interval):
+		case <-n.done:
+			n.send()
+			return
+		}
+	}
+}
+
+// Run reads results from in and forwards them to out, sending notifications
+// on the way. When in is closed or the context is cancelled, out is closed.
+func (n *Notifier) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	n.wg.Add(1)
+	go n.sender(notifyInterval)
+
+	return forward(ctx, in, out, func(res resolve.Result) error {
+		n.results++
+		if res.Failed() {
+			n.failed++
+		}
+
+		rate := float64(n.failed) / float64(n.results)
+		if !n.errorsSent && n.errorRate > 0 && n.results >= minErrorSamples && rate >= n.errorRate {
+			n.errorsSent = true
+			n.notify(eventErrors, fmt.Sprintf("all requests failed for %.0f%% of %d results", rate*100, n.results))
+		}
+
+		// the default filters hide the NXDOMAIN answers, so the dangling
+		// CNAME records are checked for the hidden results as well
+		if target, ok := danglingCNAME(res); ok {
+			n.takeoverCandidates++
+			n.notify(eventTakeover, fmt.Sprintf("%v, CNAME target %v does not exist", res.Hostname, resolve.CleanHostname(target)))
+		}
+
+		if !res.Hide {
+			n.shown++
+		}
+
+		return nil
+	})
+}
+
+// Finish sends the notification for the end of the run and waits until all
+// notifications have been sent.
+func (n *Notifier) Finish(cancelled bool) {
+	state := "finished"
+	if cancelled {
+		state = "was cancelled"
+	}
+
+	n.notify(eventFinished, fmt.Sprintf("run %v after %v: %d requests, %d results shown, %d takeover candidates",
+		state, time.Since(n.start).Round(time.Second), n.results, n.shown, n.takeoverCandidates))
+
+	close(n.done)
+	n.wg.Wait()
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/filter"
+	"github.com/happal/taifun/plugins"
+	"github.com/happal/taifun/resolve"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDanglingCNAME(t *testing.T) {
+	cname := resolve.Response{Type: "CNAME", Data: "foo.cloudapp.example."}
+	a := resolve.Response{Type: "A", Data: "192.0.2.1"}
+
+	var tests = []struct {
+		name   string
+		req    resolve.Request
+		target string
+	}{
+		{
+			name: "no-cname",
+			req:  resolve.Request{Type: "A", Responses: []resolve.Response{a}},
+		},
+		{
+			name: "resolved",
+			req:  resolve.Request{Type: "A", Responses: []resolve.Response{cname, a}},
+		},
+		{
+			name:   "nxdomain",
+			req:    resolve.Request{Type: "A", Status: "NXDOMAIN", NotFound: true, Responses: []resolve.Response{cname}},
+			target: "foo.cloudapp.example.",
+		},
+		{
+			name:   "chain-error",
+			req:    resolve.Request{Type: "CNAME", Responses: []resolve.Response{cname}, ChainError: "foo.cloudapp.example.: NXDOMAIN"},
+			target: "foo.cloudapp.example.",
+		},
+		{
+			name: "chain-servfail",
+			req:  resolve.Request{Type: "CNAME", Responses: []resolve.Response{cname}, ChainError: "foo.cloudapp.example.: SERVFAIL"},
+		},
+		{
+			name: "nxdomain-without-cname",
+			req:  resolve.Request{Type: "A", Status: "NXDOMAIN", NotFound: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := resolve.Result{Hostname: "www.example.com", Requests: []resolve.Request{test.req}}
+			target, ok := danglingCNAME(res)
+			if ok != (test.target != "") {
+				t.Fatalf("wrong result, want %v, got %v", test.target != "", ok)
+			}
+
+			if target != test.target {
+				t.Errorf("wrong target, want %q, got %q", test.target, target)
+			}
+		})
+	}
+}
+
+func TestNewNotifyTargets(t *testing.T) {
+	var tests = []struct {
+		opts  Options
+		names []string
+		urls  []string
+		err   bool
+	}{
+		{},
+		{
+			opts:  Options{notifySlack: "https://hooks.slack.example/x", notifyDiscord: "https://discord.example/y"},
+			names: []string{"Slack", "Discord"},
+			urls:  []string{"https://hooks.slack.example/x", "https://discord.example/y"},
+		},
+		{
+			opts:  Options{notifyTelegram: "123456:ABC-token:-1001234"},
+			names: []string{"Telegram"},
+			urls:  []string{"https://api.telegram.org/bot123456:ABC-token/sendMessage"},
+		},
+		{
+			opts: Options{notifyTelegram: "123456"},
+			err:  true,
+		},
+		{
+			opts: Options{notifyTelegram: ":123"},
+			err:  true,
+		},
+		{
+			opts: Options{notifyTelegram: "123456:ABC:"},
+			err:  true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			targets, err := newNotifyTargets(&test.opts)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error not returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(targets) != len(test.names) {
+				t.Fatalf("wrong number of targets, want %d, got %d", len(test.names), len(targets))
+			}
+
+			for i, target := range targets {
+				if target.name != test.names[i] {
+					t.Errorf("target %d: wrong name, want %v, got %v", i, test.names[i], target.name)
+				}
+				if target.url != test.urls[i] {
+					t.Errorf("target %d: wrong URL, want %v, got %v", i, test.urls[i], target.url)
+				}
+			}
+		})
+	}
+}
+
+func TestNewNotifyTargetsTelegramBody(t *testing.T) {
+	targets, err := newNotifyTargets(&Options{notifyTelegram: "123456:ABC-token:-1001234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := json.Marshal(targets[0].body("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"chat_id":"-1001234","text":"hello"}`
+	if string(buf) != want {
+		t.Errorf("wrong body, want %s, got %s", want, buf)
+	}
+}
+
+func TestNotifierBatch(t *testing.T) {
+	var mu sync.Mutex
+	var messages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		mu.Lock()
+		messages = append(messages, body["text"])
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	term := &testTerminal{}
+	opts := &Options{notifySlack: srv.URL, NotifyEvents: notifyEvents}
+	n, err := NewNotifier(term, opts, "FUZZ.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dangling := resolve.Request{
+		Type:      "A",
+		NotFound:  true,
+		Responses: []resolve.Response{{Type: "CNAME", Data: "gone.example.net."}},
+	}
+
+	in := make(chan resolve.Result)
+	out := make(chan resolve.Result)
+	go func() {
+		for i := 0; i < 50; i++ {
+			in <- resolve.Result{Hostname: fmt.Sprintf("%d.example.com", i), Requests: []resolve.Request{dangling}}
+		}
+		// hidden results without a dangling CNAME are ignored
+		in <- resolve.Result{Hostname: "hidden.example.com", Hide: true, Requests: []resolve.Request{{Type: "A", NotFound: true}}}
+		close(in)
+	}()
+	go func() {
+		for range out {
+		}
+	}()
+
+	err = n.Run(context.Background(), in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	n.Finish(false)
+	if time.Since(start) > notifyInterval/2 {
+		t.Errorf("Finish waited for the interval")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the first candidates may have been sent before the others were found
+	var candidates, finished int
+	for _, msg := range messages {
+		if !strings.HasPrefix(msg, "taifun FUZZ.example.com: ") {
+			t.Errorf("message has wrong prefix: %q", msg)
+		}
+
+		if strings.Contains(msg, "run finished") {
+			finished++
+		}
+		listed := strings.Count(msg, "CNAME target gone.example.net does not exist")
+		if listed > maxTakeoverLines {
+			t.Errorf("too many candidates listed in one message: %d", listed)
+		}
+		candidates += listed
+
+		var more int
+		if pos := strings.Index(msg, "(and "); pos >= 0 {
+			_, err := fmt.Sscanf(msg[pos:], "(and %d more)", &more)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		candidates += more
+
+		if strings.Contains(msg, "hidden.example.com") {
+			t.Errorf("notification sent for hidden result: %q", msg)
+		}
+	}
+
+	if len(messages) > 3 {
+		t.Errorf("notifications were not batched, got %d messages: %q", len(messages), messages)
+	}
+	if finished != 1 {
+		t.Errorf("wrong number of finished messages, want 1, got %d", finished)
+	}
+	if candidates != 50 {
+		t.Errorf("wrong number of candidates, want 50, got %d", candidates)
+	}
+	if len(term.lines) > 0 {
+		t.Errorf("unexpected errors: %q", term.lines)
+	}
+}
+
+func TestNotifierDefaultFilters(t *testing.T) {
+	messages := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Error(err)
+		}
+		messages <- body["text"]
+	}))
+	defer srv.Close()
+
+	opts := &Options{notifySlack: srv.URL, NotifyEvents: []string{eventTakeover}}
+	filters, err := setupResultFilters(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	term := &testTerminal{}
+	n, err := NewNotifier(term, opts, "FUZZ.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the resolver returns NXDOMAIN for the target along with the CNAME record
+	dangling := resolve.Request{
+		Type:      "A",
+		Status:    "NXDOMAIN",
+		NotFound:  true,
+		Responses: []resolve.Response{{Type: "CNAME", Data: "gone.example.net."}},
+	}
+
+	in := make(chan resolve.Result)
+	out := make(chan resolve.Result)
+	go func() {
+		in <- resolve.Result{Hostname: "www.example.com", Requests: []resolve.Request{dangling}}
+		in <- resolve.Result{Hostname: "missing.example.com", Requests: []resolve.Request{{Type: "A", Status: "NXDOMAIN", NotFound: true}}}
+		close(in)
+	}()
+
+	var hidden int
+	done := make(chan struct{})
+	go func() {
+		for res := range out {
+			if res.Hide {
+				hidden++
+			}
+		}
+		close(done)
+	}()
+
+	err = n.Run(context.Background(), filter.Mark(in, filters), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	// both results are hidden by the default filters
+	if hidden != 2 {
+		t.Errorf("wrong number of hidden results, want 2, got %d", hidden)
+	}
+
+	n.Finish(false)
+	close(messages)
+
+	var found bool
+	for msg := range messages {
+		if strings.Contains(msg, "missing.example.com") {
+			t.Errorf("notification sent for a name which does not exist: %q", msg)
+		}
+		if strings.Contains(msg, "www.example.com, CNAME target gone.example.net does not exist") {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("dangling CNAME hidden by the default filters was not reported")
+	}
+}
+
+// hideSuffixPlugin hides all results with the suffix.
+type hideSuffixPlugin struct {
+	suffix string
+}
+
+func (p hideSuffixPlugin) Process(ctx context.Context, res resolve.Result) (resolve.Result, error) {
+	if strings.HasSuffix(res.Hostname, p.suffix) {
+		res.Hide = true
+	}
+	return res, nil
+}
+
+func (p hideSuffixPlugin) Close() error { return nil }
+
+var registerHideSuffix sync.Once
+
+func TestResultStagesPluginHides(t *testing.T) {
+	registerHideSuffix.Do(func() {
+		plugins.Register("test-hide-suffix", func(args string) (plugins.Plugin, error) {
+			return hideSuffixPlugin{suffix: args}, nil
+		})
+	})
+
+	messages := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Error(err)
+		}
+		messages <- body["text"]
+	}))
+	defer srv.Close()
+
+	opts := &Options{
+		Plugins:      []string{"test-hide-suffix:.internal.example.com"},
+		notifySlack:  srv.URL,
+		NotifyEvents: notifyEvents,
+		Bell:         true,
+	}
+
+	term := &bellTerminal{}
+	g, ctx := errgroup.WithContext(context.Background())
+
+	in := make(chan resolve.Result)
+	out, notifier, err := startResultStages(ctx, g, term, opts, "FUZZ.example.com", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if notifier == nil {
+		t.Fatal("notifier not returned")
+	}
+
+	go func() {
+		in <- resolve.Result{
+			Hostname: "db.internal.example.com",
+			Requests: []resolve.Request{{Type: "A", Responses: []resolve.Response{{Type: "A", Data: "10.0.0.1"}}}},
+		}
+		close(in)
+	}()
+
+	var hidden int
+	for res := range out {
+		if res.Hide {
+			hidden++
+		}
+	}
+
+	err = g.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.Finish(false)
+	close(messages)
+
+	if hidden != 1 {
+		t.Errorf("result was not hidden by the plugin")
+	}
+
+	if len(term.errors) > 0 {
+		t.Errorf("bell rang for a result hidden by a plugin: %q", term.errors)
+	}
+
+	var found bool
+	for msg := range messages {
+		if strings.Contains(msg, "run finished") {
+			found = true
+			if !strings.Contains(msg, "0 results shown") {
+				t.Errorf("result hidden by a plugin was counted as shown: %q", msg)
+			}
+			continue
+		}
+		t.Errorf("unexpected notification: %q", msg)
+	}
+
+	if !found {
+		t.Errorf("finished notification was not sent")
+	}
+}
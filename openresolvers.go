@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/mattn/go-isatty"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// ResolverProbe is the result of sending the test query to a server.
+type ResolverProbe struct {
+	Server    string
+	Status    string
+	Recursive bool // the server answered the test query recursively
+	Answers   []string
+	RTT       time.Duration
+	Error     error
+}
+
+// probeResolver sends a recursive query for name to server. The server is an
+// open resolver if recursion is available and the answer contains records.
+func probeResolver(client *dns.Client, server, name string, qtype uint16) ResolverProbe {
+	probe := ResolverProbe{Server: server}
+
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = true
+
+	res, rtt, err := client.Exchange(m, resolve.NameserverAddress(server))
+	probe.RTT = rtt
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+
+	probe.Status = dns.RcodeToString[res.Rcode]
+	for _, rr := range res.Answer {
+		probe.Answers = append(probe.Answers, strings.TrimPrefix(rr.String(), rr.Header().String()))
+	}
+
+	probe.Recursive = res.RecursionAvailable && res.Rcode == dns.RcodeSuccess && len(res.Answer) > 0
+	return probe
+}
+
+// formatProbe returns a line describing the probe.
+func formatProbe(probe ResolverProbe) string {
+	if probe.Error != nil {
+		return fmt.Sprintf("%-39s  %-6s  %v", probe.Server, "error", probe.Error)
+	}
+
+	state := "closed"
+	if probe.Recursive {
+		state = "open"
+	}
+
+	return fmt.Sprintf("%-39s  %-6s  %-8s  %8s  %s", probe.Server, state, probe.Status, report.FormatLatency(probe.RTT), strings.Join(probe.Answers, ", "))
+}
+
+func runOpenResolvers(ctx context.Context, g *errgroup.Group, args []string, filename, name, requestType string, threads int, timeout time.Duration, requestsPerSecond float64, burst int, showAll bool, output string) error {
+	if len(args) == 0 && filename == "" {
+		return errors.New("neither networks nor a file with addresses specified, nothing to do")
+	}
+
+	if len(args) > 0 && filename != "" {
+		return errors.New("only one source allowed but both networks and filename specified")
+	}
+
+	if threads <= 0 {
+		return errors.New("invalid number of threads")
+	}
+
+	if burst < 0 {
+		return errors.New("burst must not be negative")
+	}
+
+	qtype, ok := dns.StringToType[requestType]
+	if !ok {
+		return fmt.Errorf("invalid request type %q", requestType)
+	}
+
+	networks, err := parseNetworks(args)
+	if err != nil {
+		return err
+	}
+
+	quiet := !isatty.IsTerminal(os.Stdout.Fd())
+	term, cleanup, err := setupTerminal(ctx, g, "", "", quiet, logFormat{})
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	vch := make(chan string, threads)
+	countCh := make(chan int, 1)
+
+	if filename != "" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+
+		g.Go(func() error {
+			return producer.Reader(ctx, f, vch, countCh)
+		})
+	} else {
+		g.Go(func() error {
+			return producer.Addresses(ctx, networks, vch, countCh)
+		})
+	}
+
+	var valueCh <-chan string = vch
+	if requestsPerSecond > 0 {
+		valueCh = producer.Limit(ctx, requestsPerSecond, burst, valueCh)
+	}
+
+	client := &dns.Client{Timeout: timeout}
+
+	var (
+		mu          sync.Mutex
+		total, sent int
+		open        []string
+	)
+
+	status := func() {
+		term.SetStatus([]string{"", fmt.Sprintf("%d of %d servers tested, %d open resolvers found", sent, total, len(open))})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for server := range valueCh {
+				server = strings.TrimSpace(server)
+				if server == "" || strings.HasPrefix(server, "#") {
+					continue
+				}
+
+				probe := probeResolver(client, server, name, qtype)
+
+				mu.Lock()
+				sent++
+				if probe.Recursive {
+					open = append(open, server)
+				}
+				if probe.Recursive || showAll {
+					term.Printf("%s", formatProbe(probe))
+				}
+				status()
+				mu.Unlock()
+
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	g.Go(func() error {
+		select {
+		case n := <-countCh:
+			mu.Lock()
+			total = n
+			mu.Unlock()
+		case <-ctx.Done():
+		}
+		return nil
+	})
+
+	wg.Wait()
+	term.SetStatus(nil)
+
+	if !quiet {
+		term.Printf("\n%d of %d servers answered recursively", len(open), sent)
+	}
+
+	if output != "" {
+		return writeLines(output, resolve.Unique(open))
+	}
+
+	return nil
+}
+
+func newOpenResolversCommand() *cobra.Command {
+	var (
+		filename          string
+		name              string
+		requestType       string
+		threads           int
+		timeout           time.Duration
+		requestsPerSecond float64
+		burst             int
+		showAll           bool
+		output            string
+	)
+
+	cmd := &cobra.Command{
+		Use:                   "open-resolvers [options] [NETWORK...]",
+		Short:                 "Find servers in the networks (CIDR) which answer queries recursively",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				return runOpenResolvers(ctx, g, args, filename, name, requestType, threads, timeout, requestsPerSecond, burst, showAll, output)
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&filename, "file", "f", "", "read the addresses of the servers to test from `filename`, one per line")
+	flags.StringVar(&name, "query-name", "example.com", "send the test query for `name`")
+	flags.StringVar(&requestType, "request-type", "A", "send the test query for records of `type`")
+	flags.IntVarP(&threads, "threads", "t", 20, "test `n` servers in parallel")
+	flags.DurationVar(&timeout, "timeout", 2*time.Second, "wait at most `duration` for an answer")
+	flags.Float64Var(&requestsPerSecond, "requests-per-second", 0, "do at most `n` requests per seconds (e.g. 0.5)")
+	flags.IntVar(&burst, "burst", 1, "allow bursts of up to `n` requests while respecting --requests-per-second on average")
+	flags.BoolVar(&showAll, "show-all", false, "also print servers which did not answer recursively")
+	flags.StringVarP(&output, "output", "o", "", "write the addresses of the open resolvers to `filename`, usable with --resolvers")
+
+	return cmd
+}
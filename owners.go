@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/rdap"
+	"github.com/happal/taifun/resolve"
+)
+
+// ownerWorkers is the number of results processed in parallel, so results
+// with cached networks are not held up by lookups waiting for the rate limit.
+const ownerWorkers = 4
+
+// OwnerLookup looks up the networks of the addresses in the results via RDAP
+// and records the owning organization and the netname in the responses.
+type OwnerLookup struct {
+	term   cli.Terminal
+	client *rdap.Client
+
+	reportOnce sync.Once
+}
+
+// NewOwnerLookup returns a new OwnerLookup which sends at most perSecond
+// requests per second to the RDAP server.
+func NewOwnerLookup(term cli.Terminal, server string, perSecond float64) *OwnerLookup {
+	return &OwnerLookup{
+		term:   term,
+		client: rdap.NewClient(server, perSecond),
+	}
+}
+
+// annotate adds the owners to the address responses in res.
+func (o *OwnerLookup) annotate(ctx context.Context, res resolve.Result) {
+	for _, request := range res.Requests {
+		for i, response := range request.Responses {
+			if response.Type != "A" && response.Type != "AAAA" {
+				continue
+			}
+
+			ip := net.ParseIP(response.Data)
+			if ip == nil {
+				continue
+			}
+
+			network, err := o.client.Lookup(ctx, ip)
+			if err != nil {
+				if ctx.Err() == nil {
+					o.reportOnce.Do(func() {
+						cli.Warnf(o.term, "RDAP lookup failed: %v (further errors are not reported)", err)
+					})
+				}
+				continue
+			}
+
+			// the result has not been passed on yet, so it can be modified in place
+			request.Responses[i].Owner = network.Organization
+			request.Responses[i].Netname = network.Name
+		}
+	}
+}
+
+// Run reads results from in and forwards them to out, looking up the owners
+// of the addresses on the way. When in is closed or the context is
+// cancelled, out is closed.
+func (o *OwnerLookup) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	defer close(out)
+
+	var wg sync.WaitGroup
+	for i := 0; i < ownerWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for res := range in {
+				o.annotate(ctx, res)
+
+				select {
+				case <-ctx.Done():
+					return
+				case out <- res:
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return nil
+}
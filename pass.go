@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/happal/taifun/filter"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/resolve"
+	"golang.org/x/sync/errgroup"
+)
+
+// resolveAll sends the requests for all values through the throttle and
+// marks the results with the filters from the options, the shown results are
+// checked with --verify-with if requested. If values is empty, the values are
+// read from the source configured in the options. For each result onResult
+// is called, onCount is called with the number of values when it is known.
+// It returns when all values have been processed or the context is
+// cancelled.
+func resolveAll(ctx context.Context, opts *Options, hostname string, values []string, throttle *producer.Throttle, onCount func(int), onResult func(resolve.Result)) error {
+	responseFilters, err := setupResultFilters(opts)
+	if err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	vch := make(chan string, opts.BufferSize)
+	cch := make(chan int, 1)
+
+	if len(values) > 0 {
+		rd := ioutil.NopCloser(strings.NewReader(strings.Join(values, "\n")))
+		g.Go(func() error {
+			return producer.Reader(ctx, rd, vch, cch)
+		})
+	} else {
+		err := setupProducer(ctx, g, opts, vch, cch, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	valueCh, countCh := setupValueFilters(ctx, opts, vch, cch)
+	valueCh = throttle.Run(ctx, valueCh)
+
+	responseCh, _, err := startResolvers(ctx, g, opts, hostname, valueCh)
+	if err != nil {
+		return err
+	}
+
+	responseCh = filter.Mark(responseCh, responseFilters)
+
+	if opts.VerifyWith != "" {
+		responseCh = startVerifier(ctx, g, opts, throttle, responseCh)
+	}
+
+	g.Go(func() error {
+		for {
+			select {
+			case total, ok := <-countCh:
+				if !ok {
+					countCh = nil
+					continue
+				}
+				onCount(total)
+
+			case res, ok := <-responseCh:
+				if !ok {
+					return nil
+				}
+				onResult(res)
+
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+
+	return g.Wait()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/happal/taifun/plugins"
+	"github.com/happal/taifun/shell"
+)
+
+// setupPlugins loads and starts the plugins from the options. Messages from
+// external plugins are written to stderr.
+func setupPlugins(opts *Options, stderr io.Writer) (chain plugins.Chain, err error) {
+	// stop the plugins started so far if one fails
+	defer func() {
+		if err != nil {
+			for _, p := range chain {
+				_ = p.Close()
+			}
+		}
+	}()
+
+	for _, filename := range opts.PluginFiles {
+		err := plugins.Load(filename)
+		if err != nil {
+			return chain, err
+		}
+	}
+
+	for _, spec := range opts.Plugins {
+		p, err := plugins.New(spec)
+		if err != nil {
+			return chain, err
+		}
+		chain = append(chain, p)
+	}
+
+	for _, command := range opts.PluginCommands {
+		args, err := shell.Split(command)
+		if err != nil {
+			return chain, fmt.Errorf("invalid command %q for plugin: %v", command, err)
+		}
+
+		p, err := plugins.NewExec(args, stderr)
+		if err != nil {
+			return chain, err
+		}
+		chain = append(chain, p)
+	}
+
+	return chain, nil
+}
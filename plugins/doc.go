@@ -0,0 +1,15 @@
+// Package plugins allows extending taifun with custom filters, enrichers and
+// output sinks.
+//
+// Go plugins (built with -buildmode=plugin) call Register from their init
+// function and are loaded with Load. Instances are created with New.
+//
+// External plugins run as separate processes (see NewExec) and exchange one
+// JSON object per line: for each result, a Message is written to the plugin's
+// stdin, and the plugin answers with a Reply on stdout, e.g.:
+//
+//	> {"item":"www","hostname":"www.example.com","requests":[...]}
+//	< {"hidden":true}
+//	> {"item":"mail","hostname":"mail.example.com","requests":[...]}
+//	< {"add":[{"type":"TXT","data":"AS64496"}]}
+package plugins
@@ -0,0 +1,265 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+)
+
+// Message is sent to an external plugin for each result, encoded as JSON on a
+// single line.
+type Message struct {
+	Item     string           `json:"item"`
+	Hostname string           `json:"hostname"`
+	Hidden   bool             `json:"hidden,omitempty"`
+	Requests []MessageRequest `json:"requests"`
+}
+
+// MessageRequest is a request in a Message.
+type MessageRequest struct {
+	Type      string            `json:"type"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Server    string            `json:"server,omitempty"`
+	RTT       float64           `json:"rtt_ms,omitempty"`
+	Hidden    bool              `json:"hidden,omitempty"`
+	Responses []MessageResponse `json:"responses,omitempty"`
+}
+
+// MessageResponse is a response in a MessageRequest.
+type MessageResponse struct {
+	Type   string `json:"type"`
+	Data   string `json:"data"`
+	TTL    uint   `json:"ttl,omitempty"`
+	Hidden bool   `json:"hidden,omitempty"`
+}
+
+// Reply is expected from an external plugin for each Message, encoded as JSON
+// on a single line. An empty object leaves the result unchanged.
+type Reply struct {
+	// Hidden hides (true) or shows (false) the result, if set.
+	Hidden *bool `json:"hidden,omitempty"`
+
+	// Add contains responses to add to the result. The types must be DNS
+	// types (e.g. TXT), the responses are added as new requests for their
+	// types with the plugin as the server.
+	Add []MessageResponse `json:"add,omitempty"`
+
+	// Error stops processing.
+	Error string `json:"error,omitempty"`
+}
+
+// newMessage returns the message for res.
+func newMessage(res resolve.Result) Message {
+	msg := Message{
+		Item:     res.Item,
+		Hostname: res.Hostname,
+		Hidden:   res.Hide,
+		Requests: []MessageRequest{},
+	}
+
+	for _, req := range res.Requests {
+		r := MessageRequest{
+			Type:   req.Type,
+			Status: req.Status,
+			Server: req.Server,
+			RTT:    req.RTT.Seconds() * 1000,
+			Hidden: req.Hide,
+		}
+		if req.Error != nil {
+			r.Error = req.Error.Error()
+		}
+
+		for _, response := range req.Responses {
+			r.Responses = append(r.Responses, MessageResponse{
+				Type:   response.Type,
+				Data:   response.Data,
+				TTL:    response.TTL,
+				Hidden: response.Hide,
+			})
+		}
+
+		msg.Requests = append(msg.Requests, r)
+	}
+
+	return msg
+}
+
+// execTimeout is the time an external plugin has to exit after its input has
+// been closed.
+const execTimeout = 10 * time.Second
+
+// Exec is a plugin running as a separate process. For each result, a Message
+// is written to the process' stdin and a Reply is read from its stdout.
+type Exec struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *json.Encoder
+	dec   *json.Decoder
+
+	// killed is set when the process was killed because the context was
+	// cancelled while it processed a result.
+	killed bool
+}
+
+// NewExec starts the external plugin. The first element of args is the
+// program to run. Messages the plugin writes to stderr are passed to stderr.
+func NewExec(args []string, stderr io.Writer) (*Exec, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no command for plugin specified")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Exec{
+		name:  filepath.Base(args[0]),
+		cmd:   cmd,
+		stdin: stdin,
+		enc:   json.NewEncoder(stdin),
+		dec:   json.NewDecoder(bufio.NewReader(stdout)),
+	}, nil
+}
+
+// exchange sends msg to the plugin and reads the reply.
+func (p *Exec) exchange(msg Message) (reply Reply, err error) {
+	err = p.enc.Encode(msg)
+	if err != nil {
+		return reply, fmt.Errorf("plugin %v: %v", p.name, err)
+	}
+
+	err = p.dec.Decode(&reply)
+	if err == io.EOF {
+		return reply, fmt.Errorf("plugin %v exited unexpectedly", p.name)
+	}
+	if err != nil {
+		return reply, fmt.Errorf("plugin %v: invalid reply: %v", p.name, err)
+	}
+
+	return reply, nil
+}
+
+// Process sends res to the plugin and applies the reply. If the context is
+// cancelled before the reply is received, the plugin is killed since it
+// cannot be used for further results.
+func (p *Exec) Process(ctx context.Context, res resolve.Result) (resolve.Result, error) {
+	if p.killed {
+		return res, fmt.Errorf("plugin %v was stopped", p.name)
+	}
+
+	type result struct {
+		reply Reply
+		err   error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		reply, err := p.exchange(newMessage(res))
+		ch <- result{reply, err}
+	}()
+
+	var reply Reply
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return res, r.err
+		}
+		reply = r.reply
+	case <-ctx.Done():
+		p.killed = true
+		_ = p.cmd.Process.Kill()
+		<-ch
+		return res, ctx.Err()
+	}
+
+	if reply.Error != "" {
+		return res, fmt.Errorf("plugin %v: %v", p.name, reply.Error)
+	}
+
+	if reply.Hidden != nil {
+		res.Hide = *reply.Hidden
+	}
+
+	if len(reply.Add) > 0 {
+		// copy the list so the original result is not modified
+		requests := append([]resolve.Request{}, res.Requests...)
+
+		// add a request for each type, in the order of the responses
+		index := make(map[string]int)
+		for _, r := range reply.Add {
+			typ := strings.ToUpper(r.Type)
+			if _, ok := dns.StringToType[typ]; !ok {
+				return res, fmt.Errorf("plugin %v: invalid type %q for added response", p.name, r.Type)
+			}
+
+			i, ok := index[typ]
+			if !ok {
+				i = len(requests)
+				index[typ] = i
+				requests = append(requests, resolve.Request{
+					Type:   typ,
+					Status: "NOERROR",
+					Server: "plugin " + p.name,
+				})
+			}
+
+			requests[i].Responses = append(requests[i].Responses, resolve.NewResponse(typ, uint32(r.TTL), r.Data))
+		}
+
+		res.Requests = requests
+	}
+
+	return res, nil
+}
+
+// Close closes the plugin's stdin and waits for it to exit. If it does not
+// exit in time, it is killed.
+func (p *Exec) Close() error {
+	err := p.stdin.Close()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.cmd.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(execTimeout):
+		_ = p.cmd.Process.Kill()
+		err = <-done
+	}
+
+	if err != nil && !p.killed {
+		return fmt.Errorf("plugin %v: %v", p.name, err)
+	}
+	return nil
+}
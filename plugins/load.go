@@ -0,0 +1,19 @@
+//go:build (linux && cgo) || (darwin && cgo) || (freebsd && cgo)
+// +build linux,cgo darwin,cgo freebsd,cgo
+
+package plugins
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// Load opens the Go plugin in filename. The plugin registers itself with
+// Register when it is loaded.
+func Load(filename string) error {
+	_, err := plugin.Open(filename)
+	if err != nil {
+		return fmt.Errorf("loading plugin %v failed: %v", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build (!linux && !darwin && !freebsd) || !cgo
+// +build !linux,!darwin,!freebsd !cgo
+
+package plugins
+
+import "errors"
+
+// Load opens the Go plugin in filename. Go plugins are not supported on this
+// platform.
+func Load(filename string) error {
+	return errors.New("Go plugins are not supported on this platform")
+}
@@ -0,0 +1,123 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// Plugin processes results before they are displayed and recorded.
+type Plugin interface {
+	// Process is called for each result, including hidden ones. The returned
+	// result replaces res, so a plugin can hide or show results (filters),
+	// add responses (enrichers) or just write them somewhere (output sinks).
+	Process(ctx context.Context, res resolve.Result) (resolve.Result, error)
+
+	// Close is called after the last result has been processed.
+	Close() error
+}
+
+// Factory returns a new instance of a plugin. The string args is passed in
+// from the command line and can be used for configuration.
+type Factory func(args string) (Plugin, error)
+
+var registry = struct {
+	sync.Mutex
+	factories map[string]Factory
+}{
+	factories: make(map[string]Factory),
+}
+
+// Register makes a plugin available under name. It is usually called from
+// the init function of the package implementing the plugin. Register panics
+// if a plugin with the same name has already been registered.
+func Register(name string, f Factory) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.factories[name]; ok {
+		panic(fmt.Sprintf("plugin %q registered twice", name))
+	}
+
+	registry.factories[name] = f
+}
+
+// Names returns the sorted names of all registered plugins.
+func Names() []string {
+	registry.Lock()
+	defer registry.Unlock()
+
+	var names []string
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new instance of a registered plugin. The string spec has the
+// form "name" or "name:args".
+func New(spec string) (Plugin, error) {
+	name, args := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, args = spec[:i], spec[i+1:]
+	}
+
+	registry.Lock()
+	f, ok := registry.factories[name]
+	registry.Unlock()
+
+	if !ok {
+		names := Names()
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown plugin %q, no plugins loaded", name)
+		}
+		return nil, fmt.Errorf("unknown plugin %q, available plugins: %s", name, strings.Join(names, ", "))
+	}
+
+	return f(args)
+}
+
+// Chain runs results through several plugins, in order.
+type Chain []Plugin
+
+// Run passes all results from in through the plugins and sends them to out.
+// When in is closed or the context is cancelled, the plugins are closed and
+// out is closed.
+func (c Chain) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) (err error) {
+	defer close(out)
+
+	defer func() {
+		for _, p := range c {
+			cerr := p.Close()
+			if err == nil {
+				err = cerr
+			}
+		}
+	}()
+
+	for res := range in {
+		for _, p := range c {
+			res, err = p.Process(ctx, res)
+			if err != nil && ctx.Err() != nil {
+				// the plugin was interrupted
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- res:
+		}
+	}
+
+	return nil
+}
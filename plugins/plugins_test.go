@@ -0,0 +1,165 @@
+package plugins
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+type hidePlugin struct {
+	suffix string
+	closed bool
+}
+
+func (p *hidePlugin) Process(ctx context.Context, res resolve.Result) (resolve.Result, error) {
+	if strings.HasSuffix(res.Hostname, p.suffix) {
+		res.Hide = true
+	}
+	return res, nil
+}
+
+func (p *hidePlugin) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	var instance *hidePlugin
+	Register("test-hide", func(args string) (Plugin, error) {
+		instance = &hidePlugin{suffix: args}
+		return instance, nil
+	})
+
+	p, err := New("test-hide:.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New("unknown")
+	if err == nil {
+		t.Fatal("expected error for unknown plugin not returned")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan resolve.Result, 2)
+	in <- resolve.Result{Hostname: "www.example.com"}
+	in <- resolve.Result{Hostname: "db.internal"}
+	close(in)
+
+	out := make(chan resolve.Result)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Chain{p}.Run(ctx, in, out)
+	}()
+
+	var hidden []string
+	for res := range out {
+		if res.Hide {
+			hidden = append(hidden, res.Hostname)
+		}
+	}
+
+	err = <-errCh
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hidden) != 1 || hidden[0] != "db.internal" {
+		t.Fatalf("wrong results hidden: %v", hidden)
+	}
+
+	if !instance.closed {
+		t.Fatal("plugin was not closed")
+	}
+}
+
+func TestExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// hide www, add a response for all other results
+	script := `while read -r line; do
+	case "$line" in
+		*'"hostname":"www.example.com"'*) echo '{"hidden":true}' ;;
+		*'"hostname":"bad.example.com"'*) echo '{"add":[{"type":"ASN","data":"AS64496"}]}' ;;
+		*) echo '{"add":[{"type":"txt","data":"AS64496"},{"type":"A","data":"192.0.2.1"},{"type":"TXT","data":"Example Net"}]}' ;;
+	esac
+done`
+
+	p, err := NewExec([]string{"sh", "-c", script}, os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	res, err := p.Process(ctx, resolve.Result{Hostname: "www.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Hide {
+		t.Errorf("result was not hidden")
+	}
+
+	res, err = p.Process(ctx, resolve.Result{Hostname: "mail.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Hide {
+		t.Errorf("result was hidden")
+	}
+	// a request is added for each type
+	if len(res.Requests) != 2 || res.Requests[0].Type != "TXT" || res.Requests[0].Server != "plugin sh" ||
+		len(res.Requests[0].Responses) != 2 || res.Requests[0].Responses[1].Data != "Example Net" ||
+		res.Requests[1].Type != "A" || len(res.Requests[1].Responses) != 1 {
+		t.Errorf("responses were not added: %+v", res.Requests)
+	}
+
+	_, err = p.Process(ctx, resolve.Result{Hostname: "bad.example.com"})
+	if err == nil {
+		t.Errorf("expected error for invalid type not returned")
+	}
+
+	err = p.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecCancel(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// the plugin never replies
+	p, err := NewExec([]string{"sh", "-c", "cat > /dev/null"}, os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = p.Process(ctx, resolve.Result{Hostname: "www.example.com"})
+	if err != context.DeadlineExceeded {
+		t.Errorf("wrong error, want %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	_, err = p.Process(context.Background(), resolve.Result{Hostname: "mail.example.com"})
+	if err == nil {
+		t.Errorf("expected error for stopped plugin not returned")
+	}
+
+	err = p.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
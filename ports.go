@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// maxPortChecks is the number of TCP connections attempted at the same time.
+const maxPortChecks = 64
+
+// portResult is the result of the check for an address, done is closed when
+// it is available.
+type portResult struct {
+	done chan struct{}
+	open []int
+}
+
+// PortChecker connects to the ports of each unique address in the shown
+// results and records the ports which accept connections. Each address is
+// only checked once.
+type PortChecker struct {
+	ports   []int
+	timeout time.Duration
+	threads int
+
+	sem chan struct{} // limits the number of connections in progress
+
+	mu      sync.Mutex
+	results map[string]*portResult
+}
+
+// NewPortChecker returns a new PortChecker for the ports, waiting at most
+// timeout for each connection and processing threads results in parallel.
+func NewPortChecker(ports []int, timeout time.Duration, threads int) *PortChecker {
+	if threads < 1 {
+		threads = 1
+	}
+
+	return &PortChecker{
+		ports:   ports,
+		timeout: timeout,
+		threads: threads,
+		sem:     make(chan struct{}, maxPortChecks),
+		results: make(map[string]*portResult),
+	}
+}
+
+// connect returns true if port on addr accepts connections.
+func (c *PortChecker) connect(ctx context.Context, addr string, port int) bool {
+	select {
+	case c.sem <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	defer func() { <-c.sem }()
+
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// check returns the open ports of addr, the ports are checked in parallel
+// the first time addr is seen. If the context is cancelled while another
+// call checks addr, nil is returned.
+func (c *PortChecker) check(ctx context.Context, addr string) []int {
+	c.mu.Lock()
+	res, ok := c.results[addr]
+	if !ok {
+		res = &portResult{done: make(chan struct{})}
+		c.results[addr] = res
+	}
+	c.mu.Unlock()
+
+	if ok {
+		// res.open may only be read after done has been closed
+		select {
+		case <-res.done:
+			return res.open
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	open := make([]bool, len(c.ports))
+	var wg sync.WaitGroup
+	for i, port := range c.ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			open[i] = c.connect(ctx, addr, port)
+		}(i, port)
+	}
+	wg.Wait()
+
+	for i, port := range c.ports {
+		if open[i] {
+			res.open = append(res.open, port)
+		}
+	}
+	close(res.done)
+
+	return res.open
+}
+
+// annotate records the open ports in the address responses of res.
+func (c *PortChecker) annotate(ctx context.Context, res resolve.Result) {
+	if res.Hide {
+		return
+	}
+
+	for _, request := range res.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for i, response := range request.Responses {
+			if response.Hide || (response.Type != "A" && response.Type != "AAAA") {
+				continue
+			}
+
+			// the result has not been passed on yet, so it can be modified in place
+			request.Responses[i].OpenPorts = c.check(ctx, response.Data)
+		}
+	}
+}
+
+// Run checks the ports for all results from in and sends them to out.
+func (c *PortChecker) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forwardParallel(ctx, c.threads, in, out, func(res resolve.Result) resolve.Result {
+		c.annotate(ctx, res)
+		return res
+	})
+}
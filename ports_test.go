@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// testPorts returns a port which accepts connections on 127.0.0.1 and one
+// which does not. The listener is closed by the returned function.
+func testPorts(t testing.TB) (open, closed int, cleanup func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed = ln2.Addr().(*net.TCPAddr).Port
+	_ = ln2.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port, closed, func() { _ = ln.Close() }
+}
+
+func TestPortCheckerCheck(t *testing.T) {
+	open, closed, cleanup := testPorts(t)
+	defer cleanup()
+
+	c := NewPortChecker([]int{closed, open}, time.Second, 4)
+
+	ports := c.check(context.Background(), "127.0.0.1")
+	if len(ports) != 1 || ports[0] != open {
+		t.Errorf("wrong open ports, want [%d], got %v", open, ports)
+	}
+
+	// the address is only checked once
+	cleanup()
+	ports = c.check(context.Background(), "127.0.0.1")
+	if len(ports) != 1 || ports[0] != open {
+		t.Errorf("wrong open ports for second check, want [%d], got %v", open, ports)
+	}
+}
+
+func TestPortCheckerCancelWait(t *testing.T) {
+	c := NewPortChecker([]int{80}, time.Second, 2)
+
+	// another call is checking the address
+	res := &portResult{done: make(chan struct{})}
+	c.results["192.0.2.1"] = res
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ports := c.check(ctx, "192.0.2.1")
+	if ports != nil {
+		t.Errorf("wrong ports for cancelled check, want nil, got %v", ports)
+	}
+
+	res.open = []int{80}
+	close(res.done)
+
+	ports = c.check(context.Background(), "192.0.2.1")
+	if len(ports) != 1 || ports[0] != 80 {
+		t.Errorf("wrong ports after check, want [80], got %v", ports)
+	}
+}
+
+func TestPortCheckerRun(t *testing.T) {
+	open, closed, cleanup := testPorts(t)
+	defer cleanup()
+
+	c := NewPortChecker([]int{open, closed}, time.Second, 4)
+
+	address := func(data string, hide bool) resolve.Response {
+		return resolve.Response{Type: "A", Data: data, Hide: hide}
+	}
+
+	var results []resolve.Result
+	for i := 0; i < 10; i++ {
+		results = append(results, resolve.Result{
+			Hostname: "host" + strconv.Itoa(i) + ".example.com",
+			Requests: []resolve.Request{{Type: "A", Responses: []resolve.Response{
+				address("127.0.0.1", false),
+				address("127.0.0.2", true),
+				{Type: "CNAME", Data: "127.0.0.1"},
+			}}},
+		})
+	}
+	results = append(results, resolve.Result{
+		Hostname: "hidden.example.com",
+		Hide:     true,
+		Requests: []resolve.Request{{Type: "A", Responses: []resolve.Response{address("127.0.0.1", false)}}},
+	})
+
+	in := make(chan resolve.Result)
+	out := make(chan resolve.Result)
+	go func() {
+		for _, res := range results {
+			in <- res
+		}
+		close(in)
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Run(context.Background(), in, out)
+	}()
+
+	var n int
+	for res := range out {
+		n++
+		responses := res.Requests[0].Responses
+
+		if res.Hide {
+			if responses[0].OpenPorts != nil {
+				t.Errorf("%v: ports checked for hidden result", res.Hostname)
+			}
+			continue
+		}
+
+		if len(responses[0].OpenPorts) != 1 || responses[0].OpenPorts[0] != open {
+			t.Errorf("%v: wrong open ports, want [%d], got %v", res.Hostname, open, responses[0].OpenPorts)
+		}
+
+		if responses[1].OpenPorts != nil || responses[2].OpenPorts != nil {
+			t.Errorf("%v: ports checked for hidden or other responses", res.Hostname)
+		}
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(results) {
+		t.Errorf("wrong number of results, want %d, got %d", len(results), n)
+	}
+
+	if len(c.results) != 1 {
+		t.Errorf("wrong number of addresses checked, want 1, got %d", len(c.results))
+	}
+}
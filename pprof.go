@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+const (
+	// pprofBlockRate is the average time in nanoseconds spent blocked per
+	// event recorded in the blocking profile.
+	pprofBlockRate = 10000
+
+	// pprofMutexFraction configures recording one in this many mutex
+	// contention events in the mutex profile.
+	pprofMutexFraction = 100
+)
+
+// startPprof serves the profiling endpoints from net/http/pprof on addr (at
+// /debug/pprof/) and enables collecting the blocking and mutex profiles. The
+// profiles are sampled, so the overhead is small enough for large runs.
+func startPprof(addr string) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime.SetBlockProfileRate(pprofBlockRate)
+	runtime.SetMutexProfileFraction(pprofMutexFraction)
+
+	// use a separate mux so no other handlers registered with the default
+	// mux are exposed, the command line is left out because it may contain
+	// secrets
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	go func() {
+		// the server runs until the program exits
+		_ = http.Serve(ln, mux)
+	}()
+
+	return ln.Addr(), nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestPprofCmdline(t *testing.T) {
+	addr, err := startPprof("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		path   string
+		status int
+	}{
+		{"/debug/pprof/", http.StatusOK},
+		{"/debug/pprof/goroutine", http.StatusOK},
+		{"/debug/pprof/cmdline", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("http://%v%v", addr, test.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+
+			if res.StatusCode != test.status {
+				t.Errorf("wrong status, want %v, got %v", test.status, res.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+)
+
+// checkModes lists the valid values for --check-resolvers.
+var checkModes = []string{"drop", "warn"}
+
+// checkResolver sends test queries to server and returns the reasons why
+// it should not be used: name must resolve to at least one address, the
+// request types must be supported, and a random name below name must not
+// exist (some resolvers return addresses for all names).
+func checkResolver(server, name string, requestTypes []string) (problems []string) {
+	fqdn := dns.Fqdn(name)
+
+	req := resolve.Query(fqdn, "A", server)
+	switch {
+	case req.Error != nil:
+		return []string{req.Error.Error()}
+	case len(req.Responses) == 0:
+		problems = append(problems, fmt.Sprintf("no addresses for %v (%v)", name, req.Status))
+	}
+
+	for _, requestType := range requestTypes {
+		req := resolve.Query(fqdn, requestType, server)
+		switch {
+		case req.Error != nil:
+			problems = append(problems, fmt.Sprintf("%v request failed: %v", requestType, req.Error))
+		case req.Failure && !req.NotFound:
+			problems = append(problems, fmt.Sprintf("%v request failed: %v", requestType, req.Status))
+		}
+	}
+
+	random := fmt.Sprintf("taifun-%08x.%s", rand.Uint32(), fqdn)
+	req = resolve.Query(random, "A", server)
+	if req.Error == nil && !req.NotFound {
+		problems = append(problems, fmt.Sprintf("returned %v instead of NXDOMAIN for a random name", req.Status))
+	}
+
+	return problems
+}
+
+// checkResolvers runs the checks for all servers, using threads requests in
+// parallel, and returns one line per server with problems. Unless mode is
+// "warn", these servers are removed from the returned list.
+func checkResolvers(servers []string, name string, requestTypes []string, threads int, mode string) (usable, report []string) {
+	if threads < 1 {
+		threads = 1
+	}
+
+	problems := make([][]string, len(servers))
+
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range ch {
+				problems[n] = checkResolver(servers[n], name, requestTypes)
+			}
+		}()
+	}
+
+	for n := range servers {
+		ch <- n
+	}
+	close(ch)
+	wg.Wait()
+
+	for n, server := range servers {
+		if len(problems[n]) == 0 {
+			usable = append(usable, server)
+			continue
+		}
+
+		action := "not used"
+		if mode == "warn" {
+			action = "used anyway"
+			usable = append(usable, server)
+		}
+		report = append(report, fmt.Sprintf("resolver %v failed the checks (%s): %s", server, action, strings.Join(problems[n], ", ")))
+	}
+
+	return usable, report
+}
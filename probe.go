@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"html"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// maxProbeBody is the number of bytes read from the body to find the title.
+const maxProbeBody = 512 * 1024
+
+// titlePattern extracts the title from an HTML document.
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// probeSchemes are requested for each host name.
+var probeSchemes = []string{"http", "https"}
+
+// extractTitle returns the title of the HTML document in body with the
+// whitespace collapsed, or the empty string if there is none.
+func extractTitle(body []byte) string {
+	m := titlePattern.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+
+	return strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+}
+
+// HTTPProber sends HTTP and HTTPS requests to the host names of the shown
+// results which resolved and records the status code, the title and the
+// server header of the responses. The connections are made to the addresses
+// in the results, so proxies configured in the environment are not used.
+// Redirects are not followed and certificates are not checked.
+type HTTPProber struct {
+	Threads int
+	Timeout time.Duration
+}
+
+// NewHTTPProber returns a new HTTPProber which waits at most timeout for each
+// response, using threads requests in parallel.
+func NewHTTPProber(timeout time.Duration, threads int) *HTTPProber {
+	if threads < 1 {
+		threads = 1
+	}
+
+	return &HTTPProber{
+		Threads: threads,
+		Timeout: timeout,
+	}
+}
+
+// newClient returns an HTTP client which connects to addrs instead of
+// resolving the host name again. If addrs is empty, the system resolver is
+// used (e.g. for results with only a CNAME record).
+func (p *HTTPProber) newClient(addrs []string) *http.Client {
+	dialer := &net.Dialer{Timeout: p.Timeout}
+
+	transport := &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil || len(addrs) == 0 {
+				return dialer.DialContext(ctx, network, addr)
+			}
+
+			for _, ip := range addrs {
+				var conn net.Conn
+				conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+				if err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		},
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   p.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// request sends a GET request to target.
+func (p *HTTPProber) request(ctx context.Context, client *http.Client, target string) resolve.HTTPProbe {
+	probe := resolve.HTTPProbe{URL: target}
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	req = req.WithContext(ctx)
+
+	res, err := client.Do(req)
+	if err != nil {
+		// strip the method and URL from the error
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		probe.Error = err.Error()
+		return probe
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxProbeBody))
+	_ = res.Body.Close()
+
+	probe.Status = res.StatusCode
+	probe.Server = res.Header.Get("Server")
+	probe.Location = res.Header.Get("Location")
+	probe.Title = extractTitle(body)
+
+	return probe
+}
+
+// probe sends the requests for res.
+func (p *HTTPProber) probe(ctx context.Context, res resolve.Result) resolve.Result {
+	if res.Hide || !res.Resolved() {
+		return res
+	}
+
+	var addrs []string
+	for _, request := range res.Requests {
+		for _, response := range request.Responses {
+			if !response.Hide && (response.Type == "A" || response.Type == "AAAA") {
+				addrs = append(addrs, response.Data)
+			}
+		}
+	}
+
+	client := p.newClient(addrs)
+	for _, scheme := range probeSchemes {
+		res.HTTP = append(res.HTTP, p.request(ctx, client, scheme+"://"+res.Hostname+"/"))
+	}
+
+	return res
+}
+
+// Run probes all results from in and sends them to out.
+func (p *HTTPProber) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forwardParallel(ctx, p.Threads, in, out, func(res resolve.Result) resolve.Result {
+		return p.probe(ctx, res)
+	})
+}
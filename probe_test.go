@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestExtractTitle(t *testing.T) {
+	var tests = []struct {
+		body string
+		want string
+	}{
+		{"<html><head><title>Example</title></head></html>", "Example"},
+		{"<TITLE>Upper Case</TITLE>", "Upper Case"},
+		{`<title lang="en">With Attributes</title>`, "With Attributes"},
+		{"<title>\n  Login\n\t Portal  \n</title>", "Login Portal"},
+		{"<title>Tom &amp; Jerry &#39;s</title>", "Tom & Jerry 's"},
+		{"<title>First</title><title>Second</title>", "First"},
+		{"<title></title>", ""},
+		{"<title>Unclosed", ""},
+		{"<html><body>no title</body></html>", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		got := extractTitle([]byte(test.body))
+		if got != test.want {
+			t.Errorf("extractTitle(%q): want %q, got %q", test.body, test.want, got)
+		}
+	}
+}
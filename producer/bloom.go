@@ -0,0 +1,79 @@
+package producer
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter is a probabilistic set. Testing for a value never returns a
+// false negative, but may return a false positive.
+type bloomFilter struct {
+	bits   []uint64
+	m      uint64 // number of bits
+	hashes uint64 // number of hash functions
+}
+
+// newBloomFilter returns a filter sized for n values with a false positive
+// rate of p.
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomFilter{
+		bits:   make([]uint64, (m+63)/64),
+		m:      m,
+		hashes: k,
+	}
+}
+
+// locations returns the two base hashes for s, the bit positions are derived
+// from them (double hashing).
+func locations(s string) (h1, h2 uint64) {
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(s))
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// Contains returns true if s is (probably) present.
+func (f *bloomFilter) Contains(s string) bool {
+	h1, h2 := locations(s)
+
+	for i := uint64(0); i < f.hashes; i++ {
+		pos := (h1 + i*h2) % f.m
+		if f.bits[pos/64]&(uint64(1)<<(pos%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add inserts s and returns true if it was (probably) already present.
+func (f *bloomFilter) Add(s string) (present bool) {
+	h1, h2 := locations(s)
+
+	present = true
+	for i := uint64(0); i < f.hashes; i++ {
+		pos := (h1 + i*h2) % f.m
+		word, bit := pos/64, uint64(1)<<(pos%64)
+		if f.bits[word]&bit == 0 {
+			present = false
+			f.bits[word] |= bit
+		}
+	}
+
+	return present
+}
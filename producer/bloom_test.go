@@ -0,0 +1,116 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilterFalsePositives(t *testing.T) {
+	const n = 100000
+	f := newBloomFilter(n, 0.01)
+
+	for i := 0; i < n; i++ {
+		if f.Add(fmt.Sprintf("value%d", i)) && i < 10 {
+			t.Errorf("value%d reported as present", i)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if !f.Add(fmt.Sprintf("value%d", i)) {
+			t.Fatalf("value%d not reported as present", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if f.Contains(fmt.Sprintf("other%d", i)) {
+			falsePositives++
+		}
+	}
+
+	if rate := float64(falsePositives) / n; rate > 0.015 {
+		t.Fatalf("false positive rate too high: %v", rate)
+	}
+}
+
+func TestFilterDuplicates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	values := []string{"foo", "bar", "foo", "baz", "bar", "foo"}
+	in := make(chan string, len(values))
+	for _, v := range values {
+		in <- v
+	}
+	close(in)
+
+	f := &FilterDuplicates{Expected: 100, FalsePositiveRate: 0.001}
+
+	var result []string
+	for v := range f.Select(ctx, in) {
+		result = append(result, v)
+	}
+
+	want := []string{"foo", "bar", "baz"}
+	if len(result) != len(want) {
+		t.Fatalf("wrong result, want %v, got %v", want, result)
+	}
+
+	for i := range want {
+		if result[i] != want[i] {
+			t.Fatalf("wrong result, want %v, got %v", want, result)
+		}
+	}
+}
+
+func TestFilterDuplicatesCount(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	values := []string{"foo", "bar", "foo", "baz", "bar", "foo"}
+	in := make(chan string, len(values))
+	for _, v := range values {
+		in <- v
+	}
+	close(in)
+
+	inCount := make(chan int, 1)
+	inCount <- len(values)
+
+	f := &FilterDuplicates{Expected: 100, FalsePositiveRate: 0.001}
+	countCh := f.Count(ctx, inCount)
+
+	// the total from the producer is passed through first
+	if total := <-countCh; total != len(values) {
+		t.Fatalf("wrong total, want %d, got %d", len(values), total)
+	}
+
+	for range f.Select(ctx, in) {
+	}
+
+	// then the number of values passed through
+	if total := <-countCh; total != 3 {
+		t.Fatalf("wrong corrected total, want 3, got %d", total)
+	}
+
+	if _, ok := <-countCh; ok {
+		t.Fatalf("count channel was not closed")
+	}
+
+	// the filters after it correct the updated total
+	skip := &FilterSkip{Skip: 2}
+	inCount = make(chan int, 2)
+	inCount <- 6
+	inCount <- 3
+	close(inCount)
+
+	var totals []int
+	for total := range skip.Count(ctx, inCount) {
+		totals = append(totals, total)
+	}
+
+	if len(totals) != 2 || totals[0] != 4 || totals[1] != 1 {
+		t.Fatalf("wrong totals from skip filter, want [4 1], got %v", totals)
+	}
+}
@@ -1,6 +1,9 @@
 package producer
 
-import "context"
+import (
+	"context"
+	"sync"
+)
 
 // Filter selects/rejects items received from a producer.
 type Filter interface {
@@ -22,22 +25,31 @@ func (f *FilterSkip) Count(ctx context.Context, in <-chan int) <-chan int {
 
 	go func() {
 		defer close(out)
-		var total int
-		select {
-		case total = <-in:
-		case <-ctx.Done():
-		}
+		// the total may be updated (e.g. by FilterDuplicates)
+		for {
+			var total int
+			var ok bool
+			select {
+			case total, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
 
-		// calculate the correct total count
-		if total < f.Skip {
-			total = 0
-		} else {
-			total -= f.Skip
-		}
+			// calculate the correct total count
+			if total < f.Skip {
+				total = 0
+			} else {
+				total -= f.Skip
+			}
 
-		select {
-		case out <- total:
-		case <-ctx.Done():
+			select {
+			case out <- total:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
@@ -92,20 +104,29 @@ func (f *FilterLimit) Count(ctx context.Context, in <-chan int) <-chan int {
 
 	go func() {
 		defer close(out)
-		var total int
-		select {
-		case total = <-in:
-		case <-ctx.Done():
-		}
+		// the total may be updated (e.g. by FilterDuplicates)
+		for {
+			var total int
+			var ok bool
+			select {
+			case total, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
 
-		// calculate the correct total count
-		if total > f.Max {
-			total = f.Max
-		}
+			// calculate the correct total count
+			if total > f.Max {
+				total = f.Max
+			}
 
-		select {
-		case out <- total:
-		case <-ctx.Done():
+			select {
+			case out <- total:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
@@ -148,3 +169,105 @@ func (f *FilterLimit) Select(ctx context.Context, in <-chan string) <-chan strin
 
 	return out
 }
+
+// FilterDuplicates drops values which have been seen before. It uses a bloom
+// filter, so the memory needed is fixed, but a small fraction of values may be
+// dropped although they were not seen before.
+type FilterDuplicates struct {
+	// Expected is the number of distinct values the filter is sized for.
+	Expected int
+
+	// FalsePositiveRate is the fraction of new values which are dropped
+	// wrongly, as long as at most Expected distinct values are seen.
+	FalsePositiveRate float64
+
+	once   sync.Once
+	passed chan int // receives the number of values passed through by Select
+}
+
+func (f *FilterDuplicates) init() {
+	f.once.Do(func() {
+		f.passed = make(chan int, 1)
+	})
+}
+
+// Count passes through the number of values, it is not known in advance how
+// many duplicates will be dropped. When all values have been filtered, the
+// number of values passed through is sent as the corrected total.
+func (f *FilterDuplicates) Count(ctx context.Context, in <-chan int) <-chan int {
+	f.init()
+	out := make(chan int, 1)
+
+	go func() {
+		defer close(out)
+		for {
+			var total int
+			final := false
+
+			select {
+			case n, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				total = n
+			case total = <-f.passed:
+				final = true
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case out <- total:
+			case <-ctx.Done():
+				return
+			}
+
+			if final {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Select filters values sent over ch.
+func (f *FilterDuplicates) Select(ctx context.Context, in <-chan string) <-chan string {
+	f.init()
+	out := make(chan string)
+
+	go func() {
+		defer close(out)
+		seen := newBloomFilter(f.Expected, f.FalsePositiveRate)
+		passed := 0
+		for {
+			var v string
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok = <-in:
+				// when the input channel is closed we're done
+				if !ok {
+					f.passed <- passed
+					return
+				}
+			}
+
+			if seen.Add(v) {
+				// drop value, receive next
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- v:
+				passed++
+			}
+		}
+	}()
+
+	return out
+}
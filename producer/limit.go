@@ -7,12 +7,12 @@ import (
 	"github.com/juju/ratelimit"
 )
 
-// Limit limits the number of values per second to the value perSecond. A new
-// goroutine is started, which terminates when in is closed or the context is
-// cancelled.
-func Limit(ctx context.Context, perSecond float64, in <-chan string) <-chan string {
-	fillInterval := time.Duration(float64(time.Second) / float64(perSecond))
-	bucket := ratelimit.NewBucket(fillInterval, 1)
+// Limit limits the number of values per second to the value perSecond. Up to
+// burst values are passed through at once as long as the average rate is
+// respected, values smaller than one are treated as one. A new goroutine is
+// started, which terminates when in is closed or the context is cancelled.
+func Limit(ctx context.Context, perSecond float64, burst int, in <-chan string) <-chan string {
+	bucket := newBucket(perSecond, burst)
 
 	out := make(chan string)
 
@@ -36,3 +36,14 @@ func Limit(ctx context.Context, perSecond float64, in <-chan string) <-chan stri
 
 	return out
 }
+
+// newBucket returns a token bucket which is refilled with perSecond tokens
+// per second and holds at most burst tokens.
+func newBucket(perSecond float64, burst int) *ratelimit.Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	fillInterval := time.Duration(float64(time.Second) / perSecond)
+	return ratelimit.NewBucket(fillInterval, int64(burst))
+}
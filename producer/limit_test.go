@@ -0,0 +1,36 @@
+package producer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimitBurst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan string, 4)
+	for i := 0; i < 4; i++ {
+		in <- "foo"
+	}
+	close(in)
+
+	out := Limit(ctx, 10, 3, in)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		<-out
+	}
+
+	// the first three values are passed through at once
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Fatalf("burst took too long: %v", d)
+	}
+
+	// the fourth value needs to wait for the bucket to be refilled
+	<-out
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatalf("value after the burst was passed through too fast: %v", d)
+	}
+}
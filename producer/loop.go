@@ -0,0 +1,115 @@
+package producer
+
+import (
+	"context"
+)
+
+// Source sends values to ch and the number of values to count, and closes ch
+// when done. Reader and Range are sources.
+type Source func(ctx context.Context, ch chan<- string, count chan<- int) error
+
+// Loop runs a source repeatedly, e.g. to measure the stability of name
+// servers over time.
+type Loop struct {
+	// Iterations is the number of times the source is run, zero means until
+	// the context is cancelled.
+	Iterations int
+
+	// Size is called with the number of values per iteration as soon as the
+	// source has sent it, it may be nil.
+	Size func(int)
+}
+
+// Run starts source for each iteration and sends all values to ch. For a
+// fixed number of iterations, the total number of values is sent to count.
+// When the loop is done or the context is cancelled, ch is closed.
+func (l *Loop) Run(ctx context.Context, start func() (Source, error), ch chan<- string, count chan<- int) error {
+	defer close(ch)
+
+	counted := false
+	setCount := func(n int) {
+		counted = true
+		if l.Size != nil {
+			l.Size(n)
+		}
+		if l.Iterations > 0 {
+			count <- n * l.Iterations
+		}
+	}
+
+	for i := 0; l.Iterations == 0 || i < l.Iterations; i++ {
+		source, err := start()
+		if err != nil {
+			return err
+		}
+
+		in := make(chan string)
+		inCount := make(chan int, 1)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- source(ctx, in, inCount)
+		}()
+
+	values:
+		for {
+			select {
+			case n := <-inCount:
+				if !counted {
+					setCount(n)
+				}
+
+			case v, ok := <-in:
+				if !ok {
+					break values
+				}
+
+				select {
+				case ch <- v:
+				case <-ctx.Done():
+					// let the source terminate
+					for range in {
+					}
+					return nil
+				}
+			}
+		}
+
+		err = <-errCh
+		if err != nil {
+			return err
+		}
+
+		// the count may have been sent right before the channel was closed
+		if !counted {
+			select {
+			case n := <-inCount:
+				setCount(n)
+			default:
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Values returns a source which sends the values.
+func Values(values []string) Source {
+	return func(ctx context.Context, ch chan<- string, count chan<- int) error {
+		defer close(ch)
+
+		count <- len(values)
+		for _, v := range values {
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		return nil
+	}
+}
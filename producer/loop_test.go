@@ -0,0 +1,97 @@
+package producer
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	starts := 0
+	start := func() (Source, error) {
+		starts++
+		rd := ioutil.NopCloser(strings.NewReader("a\nb\nc\n"))
+		return func(ctx context.Context, ch chan<- string, count chan<- int) error {
+			return Reader(ctx, rd, ch, count)
+		}, nil
+	}
+
+	var size int
+	loop := &Loop{
+		Iterations: 3,
+		Size:       func(n int) { size = n },
+	}
+
+	ch := make(chan string)
+	count := make(chan int, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- loop.Run(ctx, start, ch, count)
+	}()
+
+	var values []string
+	for v := range ch {
+		values = append(values, v)
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	want := "a b c a b c a b c"
+	if got := strings.Join(values, " "); got != want {
+		t.Fatalf("wrong values, want %q, got %q", want, got)
+	}
+
+	if starts != 3 {
+		t.Fatalf("source started %d times, want 3", starts)
+	}
+
+	if size != 3 {
+		t.Fatalf("wrong size, want 3, got %d", size)
+	}
+
+	if total := <-count; total != 9 {
+		t.Fatalf("wrong count, want 9, got %d", total)
+	}
+}
+
+func TestLoopForever(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := func() (Source, error) {
+		return Values([]string{"a", "b"}), nil
+	}
+
+	loop := &Loop{}
+	ch := make(chan string)
+	count := make(chan int, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- loop.Run(ctx, start, ch, count)
+	}()
+
+	// read more than a few iterations, then stop the loop
+	for i := 0; i < 11; i++ {
+		<-ch
+	}
+	cancel()
+
+	for range ch {
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case total := <-count:
+		t.Fatalf("count %d sent for an endless loop", total)
+	default:
+	}
+}
@@ -0,0 +1,145 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxHostBits is the maximum number of host bits for a network passed to
+// Networks, larger networks are rejected.
+const maxHostBits = 32
+
+// ReverseName returns the name for a reverse lookup of ip in the
+// in-addr.arpa (IPv4) or ip6.arpa (IPv6) zone, without a trailing dot.
+func ReverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0])
+	}
+
+	ip = ip.To16()
+	var labels []string
+	for i := len(ip) - 1; i >= 0; i-- {
+		labels = append(labels, strconv.FormatUint(uint64(ip[i]&0xf), 16), strconv.FormatUint(uint64(ip[i]>>4), 16))
+	}
+	return strings.Join(labels, ".") + ".ip6.arpa"
+}
+
+// ParseReverseName returns the IP address for a name in the in-addr.arpa or
+// ip6.arpa zone. If name is not a valid reverse name, nil is returned.
+func ParseReverseName(name string) net.IP {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa"):
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+		if len(labels) != 4 {
+			return nil
+		}
+
+		ip := make(net.IP, net.IPv4len)
+		for i, label := range labels {
+			v, err := strconv.ParseUint(label, 10, 8)
+			if err != nil {
+				return nil
+			}
+			ip[3-i] = byte(v)
+		}
+		return net.IPv4(ip[0], ip[1], ip[2], ip[3])
+
+	case strings.HasSuffix(name, ".ip6.arpa"):
+		labels := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+		if len(labels) != 2*net.IPv6len {
+			return nil
+		}
+
+		ip := make(net.IP, net.IPv6len)
+		for i, label := range labels {
+			v, err := strconv.ParseUint(label, 16, 4)
+			if err != nil || len(label) != 1 {
+				return nil
+			}
+
+			pos := len(ip) - 1 - i/2
+			if i%2 == 0 {
+				ip[pos] |= byte(v)
+			} else {
+				ip[pos] |= byte(v) << 4
+			}
+		}
+		return ip
+	}
+
+	return nil
+}
+
+// networkSize returns the number of addresses in network.
+func networkSize(network *net.IPNet) (int, error) {
+	ones, bits := network.Mask.Size()
+	if bits-ones > maxHostBits {
+		return 0, fmt.Errorf("network %v is too large", network)
+	}
+	return 1 << uint(bits-ones), nil
+}
+
+// Networks sends the names for reverse lookups of all addresses in the
+// networks to the channel ch, and the number of items to the channel count.
+// Sending stops and ch is closed when an error occurs or the context is
+// cancelled.
+func Networks(ctx context.Context, networks []*net.IPNet, ch chan<- string, count chan<- int) error {
+	return addresses(ctx, networks, ch, count, ReverseName)
+}
+
+// Addresses sends all addresses in the networks to the channel ch, like
+// Networks.
+func Addresses(ctx context.Context, networks []*net.IPNet, ch chan<- string, count chan<- int) error {
+	return addresses(ctx, networks, ch, count, net.IP.String)
+}
+
+// addresses sends the string returned by format for all addresses in the
+// networks to ch.
+func addresses(ctx context.Context, networks []*net.IPNet, ch chan<- string, count chan<- int, format func(net.IP) string) error {
+	defer close(ch)
+
+	if len(networks) == 0 {
+		return errors.New("no networks specified")
+	}
+
+	total := 0
+	for _, network := range networks {
+		size, err := networkSize(network)
+		if err != nil {
+			return err
+		}
+		total += size
+	}
+
+	count <- total
+
+	for _, network := range networks {
+		size, _ := networkSize(network)
+
+		base := new(big.Int).SetBytes(network.IP)
+		length := len(network.IP)
+
+		for i := 0; i < size; i++ {
+			addr := new(big.Int).Add(base, big.NewInt(int64(i))).Bytes()
+
+			// pad the address to the original length
+			ip := make(net.IP, length)
+			copy(ip[length-len(addr):], addr)
+
+			select {
+			case ch <- format(ip):
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
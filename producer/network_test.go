@@ -0,0 +1,107 @@
+package producer
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestReverseName(t *testing.T) {
+	var tests = []struct {
+		ip   string
+		name string
+	}{
+		{"192.0.2.1", "1.2.0.192.in-addr.arpa"},
+		{"10.0.0.255", "255.0.0.10.in-addr.arpa"},
+		{"2001:db8::567:89ab", "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			ip := net.ParseIP(test.ip)
+
+			name := ReverseName(ip)
+			if name != test.name {
+				t.Fatalf("wrong reverse name for %v, want %q, got %q", test.ip, test.name, name)
+			}
+
+			parsed := ParseReverseName(name + ".")
+			if !parsed.Equal(ip) {
+				t.Fatalf("wrong address for %v, want %v, got %v", name, ip, parsed)
+			}
+		})
+	}
+}
+
+func TestParseReverseNameInvalid(t *testing.T) {
+	var tests = []string{
+		"example.com",
+		"1.2.3.in-addr.arpa",
+		"256.2.0.192.in-addr.arpa",
+		"1.2.ip6.arpa",
+	}
+
+	for _, name := range tests {
+		if ip := ParseReverseName(name); ip != nil {
+			t.Errorf("expected nil for %q, got %v", name, ip)
+		}
+	}
+}
+
+func TestNetworks(t *testing.T) {
+	_, network, err := net.ParseCIDR("192.0.2.254/31")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan string)
+	count := make(chan int, 1)
+
+	go func() {
+		err := Networks(context.Background(), []*net.IPNet{network}, ch, count)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var names []string
+	for name := range ch {
+		names = append(names, name)
+	}
+
+	if c := <-count; c != 2 {
+		t.Errorf("wrong count, want 2, got %v", c)
+	}
+
+	want := []string{"254.2.0.192.in-addr.arpa", "255.2.0.192.in-addr.arpa"}
+	if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] {
+		t.Errorf("wrong names, want %v, got %v", want, names)
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	_, network, err := net.ParseCIDR("2001:db8::/127")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan string)
+	count := make(chan int, 1)
+
+	go func() {
+		err := Addresses(context.Background(), []*net.IPNet{network}, ch, count)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var addrs []string
+	for addr := range ch {
+		addrs = append(addrs, addr)
+	}
+
+	want := []string{"2001:db8::", "2001:db8::1"}
+	if len(addrs) != len(want) || addrs[0] != want[0] || addrs[1] != want[1] {
+		t.Errorf("wrong addresses, want %v, got %v", want, addrs)
+	}
+}
@@ -0,0 +1,273 @@
+package producer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+)
+
+// spillSegmentSize is the number of values stored in one file of a
+// spillQueue.
+const spillSegmentSize = 1 << 20
+
+// spillSegment is a temporary file holding a part of the values of a
+// spillQueue.
+type spillSegment struct {
+	file    *os.File
+	wr      *bufio.Writer
+	rd      *bufio.Reader // reads with ReadAt, so the offset is independent of wr
+	written int
+	read    int
+}
+
+func newSpillSegment(dir string) (*spillSegment, error) {
+	f, err := ioutil.TempFile(dir, "taifun-queue-")
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &spillSegment{
+		file: f,
+		wr:   bufio.NewWriter(f),
+		rd:   bufio.NewReader(io.NewSectionReader(f, 0, math.MaxInt64)),
+	}
+
+	return seg, nil
+}
+
+// reset truncates the file so it can be used again.
+func (seg *spillSegment) reset() error {
+	err := seg.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = seg.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	seg.wr.Reset(seg.file)
+	seg.rd.Reset(io.NewSectionReader(seg.file, 0, math.MaxInt64))
+	seg.written, seg.read = 0, 0
+	return nil
+}
+
+// next reads the next value. The buffer of the writer is only flushed when
+// the value has not been written to the file yet.
+func (seg *spillSegment) next() (string, error) {
+	line, err := seg.rd.ReadString('\n')
+	if err == io.EOF {
+		err = seg.wr.Flush()
+		if err != nil {
+			return "", err
+		}
+
+		var rest string
+		rest, err = seg.rd.ReadString('\n')
+		line += rest
+	}
+	if err != nil {
+		return "", err
+	}
+
+	seg.read++
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// close closes and removes the file.
+func (seg *spillSegment) close() error {
+	err := seg.file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(seg.file.Name())
+}
+
+// spillQueue is a FIFO queue which keeps up to max values in memory and
+// appends all further values to temporary files. Values are written to the
+// files as long as they are not empty, so the order is preserved. The files
+// hold up to segmentSize values each, when all values have been read from a
+// file it is truncated and reused, so the space on disk is bounded by the
+// number of values in the queue.
+type spillQueue struct {
+	dir         string
+	max         int
+	segmentSize int
+	memory      []string
+
+	segments []*spillSegment // oldest first, values are appended to the last one
+	free     *spillSegment   // empty segment for reuse
+	onDisk   int
+}
+
+func newSpillQueue(dir string, max int) (*spillQueue, error) {
+	// create the first file now so that errors are reported early
+	seg, err := newSpillSegment(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &spillQueue{
+		dir:         dir,
+		max:         max,
+		segmentSize: spillSegmentSize,
+		free:        seg,
+	}
+
+	return q, nil
+}
+
+// Len returns the number of values in the queue.
+func (q *spillQueue) Len() int {
+	return len(q.memory) + q.onDisk
+}
+
+// Push appends a value to the queue.
+func (q *spillQueue) Push(s string) error {
+	if q.onDisk == 0 && len(q.memory) < q.max {
+		q.memory = append(q.memory, s)
+		return nil
+	}
+
+	if len(q.segments) == 0 || q.segments[len(q.segments)-1].written >= q.segmentSize {
+		seg := q.free
+		q.free = nil
+		if seg == nil {
+			var err error
+			seg, err = newSpillSegment(q.dir)
+			if err != nil {
+				return err
+			}
+		}
+		q.segments = append(q.segments, seg)
+	}
+
+	seg := q.segments[len(q.segments)-1]
+	_, err := seg.wr.WriteString(s + "\n")
+	if err != nil {
+		return err
+	}
+	seg.written++
+	q.onDisk++
+	return nil
+}
+
+// Pop removes the first value from the queue and returns it. Pop must not be
+// called on an empty queue.
+func (q *spillQueue) Pop() (string, error) {
+	if len(q.memory) > 0 {
+		s := q.memory[0]
+		q.memory[0] = ""
+		q.memory = q.memory[1:]
+		return s, nil
+	}
+
+	seg := q.segments[0]
+	s, err := seg.next()
+	if err != nil {
+		return "", err
+	}
+	q.onDisk--
+
+	if seg.read == seg.written {
+		// all values have been read, keep one file for reuse
+		q.segments[0] = nil
+		q.segments = q.segments[1:]
+
+		if q.free == nil {
+			q.free = seg
+			err = seg.reset()
+		} else {
+			err = seg.close()
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return s, nil
+}
+
+// Close closes and removes the files.
+func (q *spillQueue) Close() (err error) {
+	segments := q.segments
+	if q.free != nil {
+		segments = append(segments, q.free)
+	}
+
+	for _, seg := range segments {
+		cerr := seg.close()
+		if err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// Spill forwards all values from in to out. Up to memory values are buffered
+// in memory, further values are stored in temporary files in dir (or the
+// default directory for temporary files if dir is empty), so a fast producer
+// is never blocked by slow consumers. The files are removed and out is closed
+// when all values have been sent or the context is cancelled.
+func Spill(ctx context.Context, dir string, memory int, in <-chan string, out chan<- string) (err error) {
+	defer close(out)
+
+	q, err := newSpillQueue(dir, memory)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		cerr := q.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+
+	var next string
+	var haveNext bool
+
+	for in != nil || haveNext || q.Len() > 0 {
+		if !haveNext && q.Len() > 0 {
+			next, err = q.Pop()
+			if err != nil {
+				return err
+			}
+			haveNext = true
+		}
+
+		// only send if there is a value
+		var sendCh chan<- string
+		if haveNext {
+			sendCh = out
+		}
+
+		select {
+		case s, ok := <-in:
+			if !ok {
+				// disable receiving
+				in = nil
+				continue
+			}
+
+			err = q.Push(s)
+			if err != nil {
+				return err
+			}
+
+		case sendCh <- next:
+			haveNext = false
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
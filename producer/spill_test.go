@@ -0,0 +1,148 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the input channel is buffered so that all values are available
+	// before the first is read from the output channel, which forces
+	// most values to be written to the file
+	const n = 1000
+	in := make(chan string, n)
+	for i := 0; i < n; i++ {
+		in <- fmt.Sprintf("value %d", i)
+	}
+	close(in)
+
+	out := make(chan string)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Spill(ctx, dir, 10, in, out)
+	}()
+
+	i := 0
+	for s := range out {
+		want := fmt.Sprintf("value %d", i)
+		if s != want {
+			t.Fatalf("wrong value %d, want %q, got %q", i, want, s)
+		}
+		i++
+	}
+
+	if i != n {
+		t.Fatalf("wrong number of values, want %d, got %d", n, i)
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Fatalf("temporary file was not removed: %v", entries[0].Name())
+	}
+}
+
+func TestSpillQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newSpillQueue(dir, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.segmentSize = 10
+
+	files := func() int {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+
+	var pushed, popped int
+	push := func(n int) {
+		for i := 0; i < n; i++ {
+			err := q.Push(fmt.Sprintf("value %d", pushed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pushed++
+		}
+	}
+	pop := func(n int) {
+		for i := 0; i < n; i++ {
+			s, err := q.Pop()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := fmt.Sprintf("value %d", popped); s != want {
+				t.Fatalf("wrong value, want %q, got %q", want, s)
+			}
+			popped++
+		}
+	}
+
+	// values are read from a file while more are written to it
+	push(12)
+	pop(6)
+	push(3)
+	pop(4)
+
+	if q.Len() != pushed-popped {
+		t.Fatalf("wrong length, want %d, got %d", pushed-popped, q.Len())
+	}
+
+	// the values are spread over several files
+	push(35)
+	if n := files(); n != 5 {
+		t.Fatalf("wrong number of files, want 5, got %d", n)
+	}
+
+	// the files which have been read are removed, except one for reuse
+	pop(30)
+	if n := files(); n != 3 {
+		t.Fatalf("wrong number of files, want 3, got %d", n)
+	}
+
+	push(50)
+	pop(pushed - popped)
+	if q.Len() != 0 {
+		t.Fatalf("queue is not empty, %d values left", q.Len())
+	}
+
+	if n := files(); n != 1 {
+		t.Fatalf("wrong number of files, want 1, got %d", n)
+	}
+
+	err = q.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := files(); n != 0 {
+		t.Fatalf("temporary files were not removed, %d left", n)
+	}
+}
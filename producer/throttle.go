@@ -0,0 +1,170 @@
+package producer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/juju/ratelimit"
+)
+
+// Throttle passes values through and can be paused and rate limited while
+// it is running. Other stages can share the limit by calling Wait, then the
+// values passed through and the calls to Wait take tokens from the same
+// bucket, so together they stay below the rate.
+type Throttle struct {
+	mu        sync.Mutex
+	resume    chan struct{} // closed when the throttle is resumed, nil if not paused
+	perSecond float64
+	burst     int
+	bucket    *ratelimit.Bucket
+
+	start time.Time
+	count int
+}
+
+// NewThrottle returns a new Throttle which passes through at most perSecond
+// values per second, with bursts of up to burst values (see Limit). If
+// perSecond is zero, the number of values is not limited.
+func NewThrottle(perSecond float64, burst int) *Throttle {
+	t := &Throttle{burst: burst}
+	t.SetRate(perSecond)
+	return t
+}
+
+// Pause stops passing through values until Resume is called.
+func (t *Throttle) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.resume == nil {
+		t.resume = make(chan struct{})
+	}
+}
+
+// Resume continues passing through values.
+func (t *Throttle) Resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.resume != nil {
+		close(t.resume)
+		t.resume = nil
+	}
+}
+
+// Paused returns true if the throttle is paused.
+func (t *Throttle) Paused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.resume != nil
+}
+
+// SetRate changes the number of values passed through per second. If
+// perSecond is zero, the number of values is not limited.
+func (t *Throttle) SetRate(perSecond float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.perSecond = perSecond
+	t.bucket = nil
+	if perSecond > 0 {
+		t.bucket = newBucket(perSecond, t.burst)
+	}
+}
+
+// Rate returns the current limit for the values per second, zero means
+// unlimited.
+func (t *Throttle) Rate() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.perSecond
+}
+
+// Throughput returns the average number of values per second passed through
+// since Run was called.
+func (t *Throttle) Throughput() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.count == 0 {
+		return 0
+	}
+
+	return float64(t.count) / time.Since(t.start).Seconds()
+}
+
+// wait returns the channel to wait on while paused (or nil) and the time to
+// wait for the rate limit.
+func (t *Throttle) wait() (resume <-chan struct{}, timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.resume != nil {
+		return t.resume, 0
+	}
+
+	if t.bucket != nil {
+		timeout = t.bucket.Take(1)
+	}
+
+	return nil, timeout
+}
+
+// Wait blocks until the throttle is not paused and the rate limit allows
+// another value. It returns the error of the context if it is cancelled
+// before. Other stages sending requests for the values use it to respect
+// the limit.
+func (t *Throttle) Wait(ctx context.Context) error {
+	for {
+		resume, timeout := t.wait()
+		if resume == nil {
+			select {
+			case <-time.After(timeout):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Run passes through the values from in to the returned channel. A new
+// goroutine is started, which terminates when in is closed or the context is
+// cancelled.
+func (t *Throttle) Run(ctx context.Context, in <-chan string) <-chan string {
+	out := make(chan string)
+
+	t.mu.Lock()
+	t.start = time.Now()
+	t.mu.Unlock()
+
+	go func() {
+		defer close(out)
+		for s := range in {
+			if t.Wait(ctx) != nil {
+				return
+			}
+
+			select {
+			case out <- s:
+			case <-ctx.Done():
+				return
+			}
+
+			t.mu.Lock()
+			t.count++
+			t.mu.Unlock()
+		}
+	}()
+
+	return out
+}
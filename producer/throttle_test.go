@@ -0,0 +1,94 @@
+package producer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestThrottlePause(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan string, 2)
+	in <- "foo"
+	in <- "bar"
+	close(in)
+
+	throttle := NewThrottle(0, 1)
+	throttle.Pause()
+	if !throttle.Paused() {
+		t.Fatal("throttle is not paused")
+	}
+
+	out := throttle.Run(ctx, in)
+
+	select {
+	case s := <-out:
+		t.Fatalf("received value %q while paused", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	throttle.Resume()
+
+	var values []string
+	for s := range out {
+		values = append(values, s)
+	}
+
+	if len(values) != 2 || values[0] != "foo" || values[1] != "bar" {
+		t.Fatalf("wrong values returned, want [foo bar], got %v", values)
+	}
+}
+
+func TestThrottleRate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		in <- "foo"
+	}
+	close(in)
+
+	throttle := NewThrottle(1000, 1)
+	throttle.SetRate(20)
+	if throttle.Rate() != 20 {
+		t.Fatalf("wrong rate, want 20, got %v", throttle.Rate())
+	}
+
+	start := time.Now()
+	for range throttle.Run(ctx, in) {
+	}
+
+	// the first value is passed through immediately, then one every 50ms
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Fatalf("values were passed through too fast: %v", d)
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	throttle := NewThrottle(20, 1)
+
+	// the values passed through and the other requests share the limit
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := throttle.Wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Errorf("rate limit was not respected, three requests took %v", d)
+	}
+
+	throttle.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := throttle.Wait(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("wrong error while paused, want %v, got %v", context.DeadlineExceeded, err)
+	}
+}
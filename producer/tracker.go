@@ -0,0 +1,89 @@
+package producer
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracker numbers the values passed through it and computes how many values
+// from the start have been completed. Results may arrive in any order when
+// several resolvers run in parallel, so the position only moves past values
+// without a gap before them.
+type Tracker struct {
+	mu       sync.Mutex
+	next     int              // index of the next value
+	pending  map[string][]int // indexes of the values not completed yet
+	done     map[int]struct{} // indexes after position which have been completed
+	position int              // number of values from the start which have been completed
+}
+
+// NewTracker returns a new tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		pending: make(map[string][]int),
+		done:    make(map[int]struct{}),
+	}
+}
+
+// Run passes the values from in through and records them in order. A new
+// goroutine is started, which terminates when in is closed or the context is
+// cancelled.
+func (t *Tracker) Run(ctx context.Context, in <-chan string) <-chan string {
+	out := make(chan string)
+
+	go func() {
+		defer close(out)
+		for s := range in {
+			t.mu.Lock()
+			t.pending[s] = append(t.pending[s], t.next)
+			t.next++
+			t.mu.Unlock()
+
+			select {
+			case out <- s:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Done records that the value s has been completed. If s was passed through
+// more than once, the earliest occurrence not completed yet is used. Values
+// which have not been passed through the tracker (e.g. generated items) are
+// ignored.
+func (t *Tracker) Done(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	indexes := t.pending[s]
+	if len(indexes) == 0 {
+		return
+	}
+
+	if len(indexes) == 1 {
+		delete(t.pending, s)
+	} else {
+		t.pending[s] = indexes[1:]
+	}
+
+	t.done[indexes[0]] = struct{}{}
+	for {
+		if _, ok := t.done[t.position]; !ok {
+			return
+		}
+		delete(t.done, t.position)
+		t.position++
+	}
+}
+
+// Position returns the number of values from the start which have been
+// completed.
+func (t *Tracker) Position() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.position
+}
@@ -0,0 +1,101 @@
+package producer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTracker(t *testing.T) {
+	var tests = []struct {
+		name     string
+		values   []string
+		done     []string
+		position int
+	}{
+		{
+			name:     "in-order",
+			values:   []string{"a", "b", "c"},
+			done:     []string{"a", "b"},
+			position: 2,
+		},
+		{
+			name:     "gap",
+			values:   []string{"a", "b", "c", "d"},
+			done:     []string{"b", "c", "d"},
+			position: 0,
+		},
+		{
+			name:     "gap-closed",
+			values:   []string{"a", "b", "c", "d"},
+			done:     []string{"b", "c", "a"},
+			position: 3,
+		},
+		{
+			name:     "duplicates",
+			values:   []string{"a", "b", "a", "c"},
+			done:     []string{"a", "b", "c"},
+			position: 2,
+		},
+		{
+			name:     "unknown",
+			values:   []string{"a", "b"},
+			done:     []string{"x.a", "a"},
+			position: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			in := make(chan string, len(test.values))
+			for _, v := range test.values {
+				in <- v
+			}
+			close(in)
+
+			tr := NewTracker()
+			for range tr.Run(ctx, in) {
+			}
+
+			for _, v := range test.done {
+				tr.Done(v)
+			}
+
+			if pos := tr.Position(); pos != test.position {
+				t.Errorf("wrong position, want %d, got %d", test.position, pos)
+			}
+		})
+	}
+}
+
+func TestTrackerDrained(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	values := []string{"a", "b", "a", "c", "d"}
+	in := make(chan string, len(values))
+	for _, v := range values {
+		in <- v
+	}
+	close(in)
+
+	tr := NewTracker()
+	for range tr.Run(ctx, in) {
+	}
+
+	// complete the values out of order
+	for _, v := range []string{"c", "a", "d", "b", "a"} {
+		tr.Done(v)
+	}
+
+	if pos := tr.Position(); pos != len(values) {
+		t.Errorf("wrong position, want %d, got %d", len(values), pos)
+	}
+
+	// nothing may be kept for completed values
+	if len(tr.pending) != 0 || len(tr.done) != 0 {
+		t.Errorf("values kept after completion: pending %v, done %v", tr.pending, tr.done)
+	}
+}
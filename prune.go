@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+)
+
+// parentDomain returns the name above the label containing FUZZ in the
+// template, e.g. "dev.example.com" for "FUZZ.dev.example.com", or the empty
+// string if there is none.
+func parentDomain(template string) string {
+	pos := strings.Index(template, "FUZZ")
+	if pos < 0 {
+		return ""
+	}
+
+	rest := template[pos+len("FUZZ"):]
+	dot := strings.Index(rest, ".")
+	if dot < 0 {
+		return ""
+	}
+
+	return resolve.CleanHostname(rest[dot+1:])
+}
+
+// maxNXDomains is the number of names which do not exist kept for pruning.
+// Most of them are leaves produced by the template, so only the most recent
+// ones are kept to bound the memory used in long runs.
+const maxNXDomains = 100000
+
+// setupPruning creates the cache for names which do not exist, so requests
+// for names below them are skipped. The parent domain of the template is
+// requested first, if it does not exist, all requests are skipped.
+func setupPruning(term cli.Terminal, opts *Options, hostname string) {
+	opts.nxdomains = resolve.NewNXDomainCache(maxNXDomains)
+
+	domain := parentDomain(hostname)
+	if domain == "" {
+		return
+	}
+
+	server := opts.Nameserver
+	if server == "" {
+		server = opts.Resolvers[0]
+	}
+
+	req := resolve.Query(dns.Fqdn(domain), "A", server)
+	if req.NonExistent() {
+		opts.nxdomains.Add(domain)
+		if !opts.quiet() {
+			term.Printf("%v does not exist (NXDOMAIN), no requests are sent for the names below it", domain)
+		}
+	}
+}
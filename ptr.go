@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/filter"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// PTRPrinter prints the results of reverse lookups as address and name.
+type PTRPrinter struct {
+	Width    int  // width of the address column
+	NoHeader bool // do not print the table header
+}
+
+// addressWidth returns the width needed to display the addresses in the networks.
+func addressWidth(networks []*net.IPNet) int {
+	for _, network := range networks {
+		if network.IP.To4() == nil {
+			return len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+		}
+	}
+	return len("255.255.255.255")
+}
+
+// PrintHeader prints the table header.
+func (p *PTRPrinter) PrintHeader(term report.Printer) {
+	if p.NoHeader {
+		return
+	}
+
+	term.Printf("%s  %6s  %s", rjust("address", p.Width), "TTL", "name")
+}
+
+// PrintResult prints one line per PTR response.
+func (p *PTRPrinter) PrintResult(term report.Printer, result resolve.Result) {
+	ip := producer.ParseReverseName(result.Hostname)
+	for _, request := range result.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for _, response := range request.Responses {
+			if response.Hide || response.Type != "PTR" {
+				continue
+			}
+			term.Printf("%s  %6v  %s", rjust(ip.String(), p.Width), response.TTL, response.Data)
+		}
+	}
+}
+
+// ptrEntry is a PTR response for an address.
+type ptrEntry struct {
+	ip   net.IP
+	name string
+}
+
+// PTRCollector collects the shown PTR responses grouped by network.
+type PTRCollector struct {
+	networks []*net.IPNet
+	entries  map[*net.IPNet][]ptrEntry
+}
+
+// NewPTRCollector returns a new collector for the networks.
+func NewPTRCollector(networks []*net.IPNet) *PTRCollector {
+	return &PTRCollector{
+		networks: networks,
+		entries:  make(map[*net.IPNet][]ptrEntry),
+	}
+}
+
+func (c *PTRCollector) add(res resolve.Result) error {
+	if res.Hide {
+		return nil
+	}
+
+	ip := producer.ParseReverseName(res.Hostname)
+	if ip == nil {
+		return nil
+	}
+
+	var network *net.IPNet
+	for _, n := range c.networks {
+		if n.Contains(ip) {
+			network = n
+			break
+		}
+	}
+
+	if network == nil {
+		return nil
+	}
+
+	for _, request := range res.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for _, response := range request.Responses {
+			if response.Hide || response.Type != "PTR" {
+				continue
+			}
+			c.entries[network] = append(c.entries[network], ptrEntry{ip: ip, name: response.Data})
+		}
+	}
+
+	return nil
+}
+
+// Run reads results from in and forwards them to out, collecting PTR
+// responses on the way.
+func (c *PTRCollector) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forward(ctx, in, out, c.add)
+}
+
+// Print prints the collected PTR responses grouped by network and sorted by
+// address.
+func (c *PTRCollector) Print(term report.Printer) {
+	for _, network := range c.networks {
+		entries := c.entries[network]
+		if len(entries) == 0 {
+			continue
+		}
+
+		sort.SliceStable(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].ip.To16(), entries[j].ip.To16()) < 0
+		})
+
+		term.Printf("\n%v (%d names)\n", network, len(entries))
+		for _, e := range entries {
+			term.Printf("  %s  %s", rjust(e.ip.String(), addressWidth(c.networks)), e.name)
+		}
+	}
+}
+
+func runPTR(ctx context.Context, g *errgroup.Group, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return errors.New("no networks specified")
+	}
+
+	if opts.Threads <= 0 {
+		return errors.New("invalid number of threads")
+	}
+
+	if opts.Burst < 0 {
+		return errors.New("burst must not be negative")
+	}
+
+	networks, err := parseNetworks(args)
+	if err != nil {
+		return err
+	}
+
+	// the results are always printed by the PTRPrinter
+	opts.OutputFormat = "text"
+	err = opts.validDisplay()
+	if err != nil {
+		return err
+	}
+
+	term, cleanup, err := setupTerminal(ctx, g, "", "", opts.quiet(), logFormat{})
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	if opts.Nameserver == "" {
+		opts.Nameserver, err = resolve.FindSystemNameserver()
+		if err != nil {
+			return err
+		}
+
+		if !opts.quiet() {
+			term.Printf("found system nameserver %v", opts.Nameserver)
+		}
+	}
+
+	filters, err := setupResultFilters(opts)
+	if err != nil {
+		return err
+	}
+
+	vch := make(chan string, opts.BufferSize)
+	var valueCh <-chan string = vch
+	countCh := make(chan int, 1)
+
+	g.Go(func() error {
+		return producer.Networks(ctx, networks, vch, countCh)
+	})
+
+	if opts.RequestsPerSecond > 0 {
+		valueCh = producer.Limit(ctx, opts.RequestsPerSecond, opts.Burst, valueCh)
+	}
+
+	opts.RequestTypes = []string{"PTR"}
+	responseCh, _, err := startResolvers(ctx, g, opts, "FUZZ.", valueCh)
+	if err != nil {
+		return err
+	}
+
+	responseCh = filter.Mark(responseCh, filters)
+
+	collector := NewPTRCollector(networks)
+	out := make(chan resolve.Result)
+	in := responseCh
+	responseCh = out
+	g.Go(func() error {
+		return collector.Run(ctx, in, out)
+	})
+
+	reporter := report.NewReporter(term, &PTRPrinter{Width: addressWidth(networks), NoHeader: opts.quiet()})
+	reporter.Quiet = opts.quiet()
+	err = reporter.Display(responseCh, countCh)
+	if err != nil {
+		return err
+	}
+
+	collector.Print(term)
+	return nil
+}
+
+func newPTRCommand() *cobra.Command {
+	var opts Options
+
+	cmd := &cobra.Command{
+		Use:                   "ptr [options] NETWORK [NETWORK...]",
+		Short:                 "Resolve the PTR records for all addresses in the networks (CIDR)",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := applyProfile(cmd, opts.configFile, opts.Profile)
+			if err != nil {
+				return err
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				return runPTR(ctx, g, &opts, args)
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVarP(&opts.Threads, "threads", "t", 2, "resolve `n` DNS queries in parallel")
+	flags.Float64Var(&opts.RequestsPerSecond, "requests-per-second", 0, "do at most `n` requests per seconds (e.g. 0.5)")
+	flags.IntVar(&opts.Burst, "burst", 1, "allow bursts of up to `n` requests while respecting --requests-per-second on average")
+	flags.IntVar(&opts.BufferSize, "buffer-size", 100000, "set number of buffered items to `n`")
+	flags.StringVar(&opts.Nameserver, "nameserver", "", "send DNS queries to `server`, if empty, the system resolver is used")
+	flags.BoolVar(&opts.ShowNotFound, "show-not-found", false, "do not hide 'not found' responses")
+	flags.StringArrayVar(&opts.HidePTR, "hide-ptr", nil, "hide PTR responses matching `regex`")
+	flags.BoolVarP(&opts.Quiet, "quiet", "q", false, "only print results, no status (default if stdout is not a terminal)")
+	addProfileFlags(flags, &opts.configFile, &opts.Profile)
+
+	return cmd
+}
+
+// rjust returns s right-justified to width, padded with spaces on the left.
+func rjust(s string, width int) string {
+	if len(s) < width {
+		return strings.Repeat(" ", width-len(s)) + s
+	}
+	return s
+}
@@ -0,0 +1,216 @@
+package rdap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/ratelimit"
+)
+
+// DefaultServer redirects requests to the registry responsible for the
+// address.
+const DefaultServer = "https://rdap.org"
+
+// Network is the network containing an address, as registered with a regional
+// internet registry.
+type Network struct {
+	Handle       string
+	Name         string // the netname, e.g. "GOGL"
+	Organization string // the name of the registrant, e.g. "Google LLC"
+
+	Start, End net.IP // first and last address of the network
+}
+
+// Contains returns true if ip is part of the network.
+func (n Network) Contains(ip net.IP) bool {
+	ip = ip.To16()
+	return bytes.Compare(ip, n.Start.To16()) >= 0 && bytes.Compare(ip, n.End.To16()) <= 0
+}
+
+// ipNetwork is the response for an IP network lookup.
+type ipNetwork struct {
+	Handle       string   `json:"handle"`
+	Name         string   `json:"name"`
+	StartAddress string   `json:"startAddress"`
+	EndAddress   string   `json:"endAddress"`
+	Entities     []entity `json:"entities"`
+}
+
+// entity is a contact of a network.
+type entity struct {
+	Roles      []string      `json:"roles"`
+	VCardArray []interface{} `json:"vcardArray"`
+	Entities   []entity      `json:"entities"`
+}
+
+// name returns the formatted name (fn) of the entity from the jCard.
+func (e entity) name() string {
+	if len(e.VCardArray) < 2 {
+		return ""
+	}
+
+	properties, ok := e.VCardArray[1].([]interface{})
+	if !ok {
+		return ""
+	}
+
+	for _, p := range properties {
+		property, ok := p.([]interface{})
+		if !ok || len(property) < 4 || property[0] != "fn" {
+			continue
+		}
+
+		if s, ok := property[3].(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// organization returns the name of the registrant, or of the first entity
+// with a name if there is no registrant.
+func organization(entities []entity) string {
+	var first string
+	for _, e := range entities {
+		name := e.name()
+		if name == "" {
+			name = organization(e.Entities)
+		}
+
+		for _, role := range e.Roles {
+			if role == "registrant" && name != "" {
+				return name
+			}
+		}
+
+		if first == "" {
+			first = name
+		}
+	}
+
+	return first
+}
+
+// Client looks up addresses via RDAP. Networks are cached, so addresses in
+// networks seen before do not cause new requests, and requests are rate
+// limited. It is safe for concurrent use.
+type Client struct {
+	server string
+	client *http.Client
+	bucket *ratelimit.Bucket
+
+	mu       sync.Mutex
+	networks []Network
+	failed   map[string]error
+}
+
+// NewClient returns a client which sends at most perSecond requests per
+// second to server.
+func NewClient(server string, perSecond float64) *Client {
+	return &Client{
+		server: strings.TrimSuffix(server, "/"),
+		client: &http.Client{Timeout: 20 * time.Second},
+		bucket: ratelimit.NewBucket(time.Duration(float64(time.Second)/perSecond), 1),
+		failed: make(map[string]error),
+	}
+}
+
+// cached returns the network for ip if it has been looked up before.
+func (c *Client) cached(ip net.IP) (Network, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err, ok := c.failed[ip.String()]; ok {
+		return Network{}, true, err
+	}
+
+	for _, network := range c.networks {
+		if network.Contains(ip) {
+			return network, true, nil
+		}
+	}
+
+	return Network{}, false, nil
+}
+
+// Lookup returns the network containing ip. Failed lookups are not retried,
+// the error is returned again for the same address.
+func (c *Client) Lookup(ctx context.Context, ip net.IP) (Network, error) {
+	if network, ok, err := c.cached(ip); ok {
+		return network, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return Network{}, ctx.Err()
+	case <-time.After(c.bucket.Take(1)):
+	}
+
+	// another lookup may have found the network in the meantime
+	if network, ok, err := c.cached(ip); ok {
+		return network, err
+	}
+
+	network, err := c.request(ctx, ip)
+
+	c.mu.Lock()
+	if err != nil {
+		if ctx.Err() == nil {
+			c.failed[ip.String()] = err
+		}
+	} else {
+		c.networks = append(c.networks, network)
+	}
+	c.mu.Unlock()
+
+	return network, err
+}
+
+// request sends the request for ip to the server.
+func (c *Client) request(ctx context.Context, ip net.IP) (Network, error) {
+	req, err := http.NewRequest(http.MethodGet, c.server+"/ip/"+ip.String(), nil)
+	if err != nil {
+		return Network{}, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/rdap+json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return Network{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Network{}, fmt.Errorf("unexpected HTTP status %v for %v", res.Status, ip)
+	}
+
+	var response ipNetwork
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return Network{}, fmt.Errorf("invalid response for %v: %v", ip, err)
+	}
+
+	network := Network{
+		Handle:       response.Handle,
+		Name:         response.Name,
+		Organization: organization(response.Entities),
+		Start:        net.ParseIP(response.StartAddress),
+		End:          net.ParseIP(response.EndAddress),
+	}
+
+	// only cache the address itself if the range is missing
+	if network.Start == nil || network.End == nil || !network.Contains(ip) {
+		network.Start, network.End = ip, ip
+	}
+
+	return network, nil
+}
@@ -0,0 +1,81 @@
+package rdap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+const testResponse = `{
+  "objectClassName": "ip network",
+  "handle": "NET-192-0-2-0-1",
+  "startAddress": "192.0.2.0",
+  "endAddress": "192.0.2.255",
+  "name": "TEST-NET-1",
+  "entities": [
+    {
+      "roles": ["technical"],
+      "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example NOC"]]]
+    },
+    {
+      "roles": ["registrant"],
+      "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Org"], ["kind", {}, "text", "org"]]]
+    }
+  ]
+}`
+
+func TestLookup(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if r.URL.Path == "/ip/192.0.2.1" {
+			fmt.Fprint(w, testResponse)
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL+"/", 1000)
+
+	network, err := client.Lookup(context.Background(), net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if network.Name != "TEST-NET-1" || network.Organization != "Example Org" || network.Handle != "NET-192-0-2-0-1" {
+		t.Errorf("wrong network returned: %+v", network)
+	}
+
+	// the second address is in the same network, so it is taken from the cache
+	network, err = client.Lookup(context.Background(), net.ParseIP("192.0.2.200"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if network.Name != "TEST-NET-1" {
+		t.Errorf("wrong network returned: %+v", network)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("wrong number of requests, want 1, got %d", n)
+	}
+
+	// failed lookups are not retried
+	for i := 0; i < 2; i++ {
+		_, err = client.Lookup(context.Background(), net.ParseIP("198.51.100.1"))
+		if err == nil {
+			t.Fatal("expected error not returned")
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("wrong number of requests, want 2, got %d", n)
+	}
+}
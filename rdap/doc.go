@@ -0,0 +1,4 @@
+// Package rdap looks up the networks containing IP addresses via the
+// Registration Data Access Protocol (RDAP) and returns their names and owning
+// organizations.
+package rdap
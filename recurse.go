@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// Wordlist is a file with the words for the recursion, one per line. Empty
+// lines are ignored, all others are used as they are. The words are read
+// from the file for each host name found, so they are not kept in memory.
+type Wordlist struct {
+	filename string
+	count    int
+}
+
+// NewWordlist checks that filename can be read and counts the words.
+func NewWordlist(filename string) (*Wordlist, error) {
+	list := &Wordlist{filename: filename}
+
+	err := list.each(func(string) bool {
+		list.count++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// each calls fn for each word in the file until it returns false.
+func (list *Wordlist) each(fn func(word string) bool) error {
+	f, err := os.Open(list.filename)
+	if err != nil {
+		return err
+	}
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		word := strings.TrimSpace(sc.Text())
+		if word == "" {
+			continue
+		}
+
+		if !fn(word) {
+			break
+		}
+	}
+
+	if sc.Err() != nil {
+		_ = f.Close()
+		return sc.Err()
+	}
+
+	return f.Close()
+}
+
+// hit is a host name found which the words are prepended to.
+type hit struct {
+	hostname string
+	depth    int // depth of the items generated for the host name
+}
+
+// Recurser fuzzes below the host names found: for each shown result which
+// resolved or returned an empty response (a potential suffix), the words are
+// prepended to the host name (e.g. "www.example.com" becomes
+// "word.www.example.com") and the items for the new names are requested in
+// the same run, up to maxDepth levels below the original items. Results with
+// a low confidence (the answers of a wildcard) are not used. Other stages can
+// request more items with Add.
+type Recurser struct {
+	template string
+	words    *Wordlist
+	maxDepth int
+
+	mu          sync.Mutex
+	depth       map[string]int // depth of the items generated, the others have depth zero
+	generated   map[string]int // number of generated items (including Add) without a result yet
+	hits        []hit          // host names found, the words for them are sent by Feed
+	queue       []string       // items requested with Add waiting to be sent
+	outstanding int            // items sent without a result yet
+	total       int            // number of items from the producer
+	added       int            // number of items generated
+	wake        chan struct{}
+}
+
+// NewRecurser returns a new Recurser for the host name template. The words
+// may be nil if items are only requested with Add.
+func NewRecurser(template string, words *Wordlist, maxDepth int) *Recurser {
+	return &Recurser{
+		template:  template,
+		words:     words,
+		maxDepth:  maxDepth,
+		depth:     make(map[string]int),
+		generated: make(map[string]int),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// generate reads the words for h and sends the items to ch until the
+// context is cancelled. Words which do not result in a valid item for the
+// template are skipped.
+func (r *Recurser) generate(ctx context.Context, h hit, ch chan<- string) {
+	// the file was read before, if this fails now the remaining words are
+	// skipped
+	sent := 0
+	_ = r.words.each(func(word string) bool {
+		item, ok := templateItem(r.template, word+"."+h.hostname)
+		if !ok {
+			return true
+		}
+
+		r.mu.Lock()
+		r.depth[item] = h.depth
+		r.generated[item]++
+		r.outstanding++
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			r.mu.Lock()
+			r.outstanding--
+			r.mu.Unlock()
+			return false
+		case ch <- item:
+			sent++
+			return true
+		}
+	})
+
+	// correct the total for the words which were not sent
+	if sent < r.words.count {
+		r.mu.Lock()
+		r.added -= r.words.count - sent
+		r.mu.Unlock()
+	}
+}
+
+// Feed passes the values from in to the returned channel, followed by the
+// items generated for the results seen by Run. The returned channel is closed
+// when in is closed and all results have been seen, or the context is
+// cancelled. The returned count channel receives the total number of items,
+// which grows while new items are generated.
+func (r *Recurser) Feed(ctx context.Context, in <-chan string, inCount <-chan int) (<-chan string, <-chan int) {
+	out := make(chan string)
+	outCount := make(chan int, 1)
+
+	// the items for the hits are sent by a separate goroutine, one hit at a
+	// time, so the words are streamed from the file
+	generated := make(chan string)
+	var generating chan struct{}
+
+	go func() {
+		defer close(out)
+
+		// the total is only sent after the producer has sent its count
+		counted := false
+
+		for {
+			r.mu.Lock()
+			if in == nil && len(r.queue) == 0 && len(r.hits) == 0 && generating == nil && r.outstanding == 0 {
+				r.mu.Unlock()
+				return
+			}
+
+			// start generating the items for the next hit
+			if generating == nil && len(r.hits) > 0 {
+				h := r.hits[0]
+				r.hits = r.hits[1:]
+
+				done := make(chan struct{})
+				generating = done
+				go func() {
+					r.generate(ctx, h, generated)
+					close(done)
+				}()
+			}
+
+			// send the next item requested with Add, if any
+			var next string
+			var sendCh chan<- string
+			if len(r.queue) > 0 {
+				next = r.queue[0]
+				sendCh = out
+			}
+			r.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-r.wake:
+				if counted {
+					r.updateCount(outCount)
+				}
+
+			case <-generating:
+				generating = nil
+				if counted {
+					r.updateCount(outCount)
+				}
+
+			case item := <-generated:
+				select {
+				case <-ctx.Done():
+					return
+				case out <- item:
+				}
+
+			case total, ok := <-inCount:
+				if !ok {
+					inCount = nil
+					continue
+				}
+
+				r.mu.Lock()
+				r.total = total
+				r.mu.Unlock()
+				counted = true
+				r.updateCount(outCount)
+
+			case v, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+
+				r.mu.Lock()
+				r.outstanding++
+				r.mu.Unlock()
+
+				select {
+				case <-ctx.Done():
+					return
+				case out <- v:
+				}
+
+			case sendCh <- next:
+				r.mu.Lock()
+				r.queue = r.queue[1:]
+				r.outstanding++
+				r.mu.Unlock()
+			}
+		}
+	}()
+
+	return out, outCount
+}
+
+// updateCount replaces the value in ch (if any) with the current total.
+func (r *Recurser) updateCount(ch chan int) {
+	r.mu.Lock()
+	total := r.total + r.added
+	r.mu.Unlock()
+
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- total
+}
+
+// Add requests the items in the same run. It must be called before the
+// result the items were found for is passed to Run, so Feed does not finish
+// in between.
+func (r *Recurser) Add(items []string) {
+	if len(items) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	r.queue = append(r.queue, items...)
+	r.added += len(items)
+	for _, item := range items {
+		r.generated[item]++
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run reads results from in and forwards them to out, generating new items
+// for the results on the way. When in is closed or the context is cancelled,
+// out is closed.
+func (r *Recurser) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forward(ctx, in, out, func(res resolve.Result) error {
+		r.mu.Lock()
+		r.outstanding--
+		depth := r.depth[res.Item]
+		delete(r.depth, res.Item)
+
+		if r.generated[res.Item] > 0 {
+			r.generated[res.Item]--
+			if r.generated[res.Item] == 0 {
+				delete(r.generated, res.Item)
+			}
+		}
+
+		if r.words != nil && depth < r.maxDepth && !res.Hide && (res.Resolved() || res.Empty()) && res.Confidence != resolve.ConfidenceLow {
+			r.hits = append(r.hits, hit{hostname: res.Hostname, depth: depth + 1})
+			r.added += r.words.count
+		}
+		r.mu.Unlock()
+
+		// wake up Feed so it can send the new items or finish
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// testWordlist writes the lines to a file in dir and returns a Wordlist for
+// it.
+func testWordlist(t testing.TB, dir string, lines ...string) *Wordlist {
+	filename := filepath.Join(dir, "words.txt")
+	err := ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := NewWordlist(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return words
+}
+
+func TestWordlist(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	// only empty lines are ignored, unlike in the lists of resolvers
+	words := testWordlist(t, tempdir, "www", "", "  ", "#dev", "builtin", " mail ")
+
+	var list []string
+	err = words.each(func(word string) bool {
+		list = append(list, word)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"www", "#dev", "builtin", "mail"}
+	if strings.Join(list, ",") != strings.Join(want, ",") || words.count != len(want) {
+		t.Errorf("wrong words, want %q, got %q (count %d)", want, list, words.count)
+	}
+
+	_, err = NewWordlist(filepath.Join(tempdir, "missing.txt"))
+	if err == nil {
+		t.Errorf("expected error for missing file not returned")
+	}
+}
+
+// runRecurser sends the values through r and a fake resolver, for which the
+// host names in found exist. It returns the items requested and the last
+// total sent by r (-1 if none was sent).
+func runRecurser(t testing.TB, r *Recurser, template string, values []string, found []string) (items []string, total int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan string, len(values))
+	for _, v := range values {
+		in <- v
+	}
+	close(in)
+
+	inCount := make(chan int, 1)
+	inCount <- len(values)
+	close(inCount)
+
+	valueCh, countCh := r.Feed(ctx, in, inCount)
+
+	a := []resolve.Request{{Type: "A", Responses: []resolve.Response{{Type: "A", Data: "192.0.2.1"}}}}
+	results := make(chan resolve.Result)
+	go func() {
+		defer close(results)
+		for item := range valueCh {
+			items = append(items, item)
+			// the other names do not exist, the result is hidden
+			res := resolve.Result{Item: item, Hostname: strings.Replace(template, "FUZZ", item, 1), Hide: true}
+			if contains(found, res.Hostname) {
+				res.Requests, res.Hide = a, false
+			}
+			results <- res
+		}
+	}()
+
+	out := make(chan resolve.Result)
+	go func() {
+		for range out {
+		}
+	}()
+
+	err := r.Run(ctx, results, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Feed may finish before it has seen the count from the producer
+	select {
+	case total = <-countCh:
+	default:
+		total = -1
+	}
+
+	sort.Strings(items)
+	return items, total
+}
+
+func TestRecurser(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	words := testWordlist(t, tempdir, "a", "b")
+
+	var tests = []struct {
+		template string
+		depth    int
+		found    []string
+		items    []string
+	}{
+		{
+			template: "FUZZ.example.com",
+			depth:    2,
+			found:    []string{"www.example.com", "a.www.example.com", "b.a.www.example.com"},
+			items:    []string{"a.a.www", "a.www", "b.a.www", "b.www", "mail", "www"},
+		},
+		{
+			template: "FUZZ.example.com",
+			depth:    1,
+			found:    []string{"www.example.com", "a.www.example.com"},
+			items:    []string{"a.www", "b.www", "mail", "www"},
+		},
+		{
+			// the new names are built from the host name
+			template: "FUZZ-dev.example.com",
+			depth:    1,
+			found:    []string{"www-dev.example.com"},
+			items:    []string{"a.www", "b.www", "mail", "www"},
+		},
+		{
+			// names below the host name do not match the template
+			template: "api.FUZZ.example.com",
+			depth:    1,
+			found:    []string{"api.www.example.com"},
+			items:    []string{"mail", "www"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			r := NewRecurser(test.template, words, test.depth)
+			items, total := runRecurser(t, r, test.template, []string{"www", "mail"}, test.found)
+
+			if strings.Join(items, ",") != strings.Join(test.items, ",") {
+				t.Errorf("wrong items requested, want %q, got %q", test.items, items)
+			}
+
+			if total >= 0 && total != len(test.items) {
+				t.Errorf("wrong total, want %d, got %d", len(test.items), total)
+			}
+		})
+	}
+}
+
+func TestRecurserAdd(t *testing.T) {
+	r := NewRecurser("FUZZ.example.com", nil, 0)
+	r.Add([]string{"san"})
+
+	items, total := runRecurser(t, r, "FUZZ.example.com", []string{"www", "mail"}, []string{"www.example.com"})
+
+	want := []string{"mail", "san", "www"}
+	if strings.Join(items, ",") != strings.Join(want, ",") {
+		t.Errorf("wrong items requested, want %q, got %q", want, items)
+	}
+
+	if total >= 0 && total != 3 {
+		t.Errorf("wrong total, want 3, got %d", total)
+	}
+}
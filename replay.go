@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/filter"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// responseSet returns the set of responses as strings of the form "TYPE data".
+// Hidden responses are ignored.
+func responseSet(responses []report.RecordedResponse) map[string]struct{} {
+	set := make(map[string]struct{}, len(responses))
+	for _, res := range responses {
+		if res.Hidden {
+			continue
+		}
+		set[res.Type+" "+res.Data] = struct{}{}
+	}
+	return set
+}
+
+// diffSets returns the entries only in b (added) and only in a (removed), sorted.
+func diffSets(a, b map[string]struct{}) (added, removed []string) {
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			added = append(added, k)
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	return resolve.Unique(added), resolve.Unique(removed)
+}
+
+// formatChanges returns a description of the added and removed entries.
+func formatChanges(added, removed []string) string {
+	var parts []string
+	if len(added) > 0 {
+		parts = append(parts, "added "+strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		parts = append(parts, "removed "+strings.Join(removed, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// replayQuery is a query recorded in a log.
+type replayQuery struct {
+	hostname    string
+	requestType string
+	responses   []report.RecordedResponse
+}
+
+// replayQueries returns the queries recorded in data. For results without
+// recorded requests (e.g. potential delegations), the request types from
+// the recorded options opts are used, opts may be nil.
+func replayQueries(data *report.Data, opts *Options) (queries []replayQuery) {
+	requestTypes := []string{"A", "AAAA"}
+	if opts != nil && len(opts.RequestTypes) > 0 {
+		requestTypes = opts.RequestTypes
+	}
+
+	for _, res := range data.Results {
+		if len(res.Requests) == 0 {
+			for _, t := range requestTypes {
+				queries = append(queries, replayQuery{hostname: res.Hostname, requestType: t})
+			}
+			continue
+		}
+
+		for _, req := range res.Requests {
+			queries = append(queries, replayQuery{
+				hostname:    res.Hostname,
+				requestType: req.Type,
+				responses:   req.Responses,
+			})
+		}
+	}
+
+	return queries
+}
+
+// replayFilters returns the filters of the recorded options opts for the
+// replayed requests, opts may be nil. The filters for results need all
+// requests of a result, so only the filters for requests and responses are
+// returned.
+func replayFilters(opts *Options) (filter.Set, error) {
+	if opts == nil {
+		return filter.Set{}, nil
+	}
+
+	err := opts.validDisplay()
+	if err != nil {
+		return filter.Set{}, fmt.Errorf("invalid recorded options: %v", err)
+	}
+
+	filters, err := setupResultFilters(opts)
+	if err != nil {
+		return filter.Set{}, err
+	}
+
+	return filter.Set{Request: filters.Request, Response: filters.Response}, nil
+}
+
+// shownResponses returns the responses of request which are not hidden by
+// filters, in the same way as they are recorded in the log.
+func shownResponses(hostname string, request resolve.Request, filters filter.Set) (responses []report.RecordedResponse) {
+	res := filters.Run(resolve.Result{Hostname: hostname, Requests: []resolve.Request{request}})
+	if res.Requests[0].Hide {
+		return nil
+	}
+
+	for _, response := range res.Requests[0].Responses {
+		if response.Hide {
+			continue
+		}
+		responses = append(responses, report.RecordedResponse{Type: response.Type, Data: response.Data})
+	}
+	return responses
+}
+
+func replay(ctx context.Context, term cli.Terminal, data *report.Data, opts *Options, server string, threads int) error {
+	// responses hidden by the filters of the recorded run are not in the
+	// log, so they are hidden for the replayed requests as well
+	filters, err := replayFilters(opts)
+	if err != nil {
+		return err
+	}
+
+	queries := replayQueries(data, opts)
+
+	ch := make(chan replayQuery)
+	go func() {
+		defer close(ch)
+		for _, q := range queries {
+			select {
+			case ch <- q:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var changed, sent int
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range ch {
+				request := resolve.Query(q.hostname+".", q.requestType, server)
+				current := shownResponses(q.hostname, request, filters)
+
+				added, removed := diffSets(responseSet(q.responses), responseSet(current))
+
+				mu.Lock()
+				sent++
+				if request.Error != nil {
+					cli.Logf(term, cli.LevelError, map[string]string{"host": q.hostname, "type": q.requestType}, "%s %s: error: %v", q.hostname, q.requestType, request.Error)
+				} else if len(added) > 0 || len(removed) > 0 {
+					changed++
+					term.Printf("%s %s: %s", q.hostname, q.requestType, formatChanges(added, removed))
+				}
+				term.SetStatus([]string{"", fmt.Sprintf("%d of %d queries replayed, %d changed", sent, len(queries), changed)})
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	term.SetStatus(nil)
+	term.Printf("\nreplayed %d of %d queries, %d changed\n", sent, len(queries), changed)
+	return nil
+}
+
+func newReplayCommand() *cobra.Command {
+	var nameserver string
+	var threads int
+	var configFile, profile string
+
+	cmd := &cobra.Command{
+		Use:                   "replay [options] LOGFILE.json",
+		Short:                 "Send the queries recorded in a JSON log again and report changed answers",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("exactly one JSON log needs to be specified")
+			}
+
+			err := applyProfile(cmd, configFile, profile)
+			if err != nil {
+				return err
+			}
+
+			if threads <= 0 {
+				return errors.New("invalid number of threads")
+			}
+
+			data, err := report.ReadData(args[0])
+			if err != nil {
+				return fmt.Errorf("reading %v failed: %v", args[0], err)
+			}
+
+			opts, err := recordedOptions(data)
+			if err != nil {
+				return fmt.Errorf("reading %v failed: %v", args[0], err)
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				term, cleanup, err := setupTerminal(ctx, g, "", "", false, logFormat{})
+				defer cleanup()
+				if err != nil {
+					return err
+				}
+
+				if nameserver == "" && opts != nil {
+					nameserver = opts.Nameserver
+					if nameserver == "" && len(opts.Resolvers) > 0 {
+						nameserver = opts.Resolvers[0]
+					}
+				}
+
+				if nameserver == "" {
+					nameserver, err = resolve.FindSystemNameserver()
+					if err != nil {
+						return err
+					}
+				}
+
+				term.Printf("replaying queries from %v against %v\n", args[0], nameserver)
+				return replay(ctx, term, data, opts, nameserver, threads)
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&nameserver, "nameserver", "", "send DNS queries to `server` (default: the server used for the recorded run)")
+	flags.IntVarP(&threads, "threads", "t", 2, "resolve `n` DNS queries in parallel")
+	addProfileFlags(flags, &configFile, &profile)
+
+	return cmd
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+)
+
+// recordedFiltered returns the data of a run with opts, which requested the
+// A records of www.example.com from the server at addr.
+func recordedFiltered(t testing.TB, opts *Options, addr string) *report.Data {
+	err := opts.validDisplay()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters, err := setupResultFilters(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := resolve.Result{
+		Hostname: "www.example.com",
+		Requests: []resolve.Request{resolve.Query("www.example.com.", "A", addr)},
+	}
+	res = filters.Run(res)
+
+	buf, err := json.Marshal(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &report.Data{
+		Results: []report.RecordedResult{report.NewResult(res, false)},
+		Options: buf,
+	}
+}
+
+func TestReplayFiltered(t *testing.T) {
+	srv := testWatchServer(t,
+		"www.example.com. 300 IN A 192.0.2.1",
+		"www.example.com. 300 IN A 10.0.0.1",
+	)
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	data := recordedFiltered(t, &Options{
+		RequestTypes: []string{"A"},
+		HideNetworks: []string{"10.0.0.0/8"},
+		OutputFormat: "text",
+	}, srv.Addr)
+
+	if len(data.Results) != 1 || len(data.Results[0].Requests) != 1 || len(data.Results[0].Requests[0].Responses) != 1 {
+		t.Fatalf("wrong recorded results: %+v", data.Results)
+	}
+
+	var tests = []struct {
+		name    string
+		records []string
+		want    []string
+	}{
+		{
+			name: "unchanged",
+			records: []string{
+				"www.example.com. 300 IN A 192.0.2.1",
+				"www.example.com. 300 IN A 10.0.0.1",
+			},
+			want: []string{"\nreplayed 1 of 1 queries, 0 changed\n"},
+		},
+		{
+			name: "changed",
+			records: []string{
+				"www.example.com. 300 IN A 192.0.2.2",
+				"www.example.com. 300 IN A 10.0.0.2",
+			},
+			want: []string{
+				"www.example.com A: added A 192.0.2.2; removed A 192.0.2.1",
+				"\nreplayed 1 of 1 queries, 1 changed\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := testWatchServer(t, test.records...)
+			defer func() {
+				_ = srv.Close()
+			}()
+
+			opts, err := recordedOptions(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			term := &testTerminal{}
+			err = replay(context.Background(), term, data, opts, srv.Addr, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Join(term.lines, "|") != strings.Join(test.want, "|") {
+				t.Errorf("wrong output, want:\n  %q\ngot:\n  %q", test.want, term.lines)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/filter"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// displayData runs the results recorded in data through the filters and
+// displays them with the reporter.
+func displayData(ctx context.Context, g *errgroup.Group, opts *Options, data *report.Data) error {
+	term, cleanup, err := setupTerminal(ctx, g, "", "", opts.quiet(), logFormat{})
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	filters, err := setupResultFilters(opts)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan resolve.Result)
+	countCh := make(chan int, 1)
+	countCh <- len(data.Results)
+
+	go func() {
+		defer close(ch)
+		for _, res := range data.Results {
+			select {
+			case ch <- res.ToResult():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	printer, err := newResultPrinter(opts, data.Hostname)
+	if err != nil {
+		return err
+	}
+
+	if opts.OutputFormat == "text" && !opts.quiet() {
+		term.Printf("hostname template: %v\n\n", data.Hostname)
+	}
+
+	reporter := report.NewReporter(term, printer)
+	reporter.Quiet = opts.quiet()
+	reporter.SortBy = opts.SortResults
+	reporter.GroupSummary = opts.GroupSummary
+	reporter.Cluster = opts.Cluster
+	reporter.Verbosity = opts.Verbose
+	reporter.ExactStats = opts.ExactStats
+	return reporter.Display(filter.Mark(ch, filters), countCh)
+}
+
+func newReportCommand() *cobra.Command {
+	var opts Options
+
+	cmd := &cobra.Command{
+		Use:                   "report [options] LOGFILE.json",
+		Short:                 "Display the results recorded in a JSON log, applying the filters again",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("exactly one JSON log needs to be specified")
+			}
+
+			err := applyProfile(cmd, opts.configFile, opts.Profile)
+			if err != nil {
+				return err
+			}
+
+			err = opts.validDisplay()
+			if err != nil {
+				return err
+			}
+
+			data, err := report.ReadData(args[0])
+			if err != nil {
+				return fmt.Errorf("reading %v failed: %v", args[0], err)
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				return displayData(ctx, g, &opts, data)
+			})
+		},
+	}
+
+	addDisplayFlags(cmd.Flags(), &opts)
+	addProfileFlags(cmd.Flags(), &opts.configFile, &opts.Profile)
+
+	return cmd
+}
@@ -0,0 +1,113 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// Checkpoint is written regularly during a run, it contains everything needed
+// to continue the run without reading the (potentially large) JSON log.
+type Checkpoint struct {
+	SchemaVersion int       `json:"schema_version"`
+	Written       time.Time `json:"written"`
+
+	Hostname string          `json:"hostname"`
+	Options  json.RawMessage `json:"options"`
+
+	// Position is the number of items processed, the next run skips them.
+	Position      int   `json:"position"`
+	TotalRequests int   `json:"total_requests"`
+	ShownResults  int   `json:"shown_results"`
+	HiddenResults int   `json:"hidden_results"`
+	ResponseBytes int64 `json:"response_bytes"`
+
+	Start     time.Time `json:"start"`
+	Cancelled bool      `json:"cancelled"`
+	Complete  bool      `json:"complete"`
+
+	// Stopped is set when no more values were sent because of
+	// --max-duration or --stop-after-found, the run is not complete.
+	Stopped bool `json:"stopped,omitempty"`
+
+	// Filters is the state of the value filters at Position, it is missing
+	// in checkpoints written by older versions.
+	Filters *FilterState `json:"filters,omitempty"`
+
+	// Results is the name of the file containing the results so far.
+	Results string `json:"results"`
+}
+
+// FilterState is the state of the value filters after a number of values
+// have been processed, a run continued from there uses it instead of the
+// original options.
+type FilterState struct {
+	// Skip is the number of values to skip, including the ones processed.
+	Skip int `json:"skip"`
+
+	// Limit is the number of values still to be processed for --limit,
+	// zero if no limit was set.
+	Limit int `json:"limit,omitempty"`
+}
+
+// CheckpointFilename returns the name of the checkpoint for the JSON log
+// filename.
+func CheckpointFilename(filename string) string {
+	filename = strings.TrimSuffix(filename, CompressedSuffix)
+	return strings.TrimSuffix(filename, ".json") + ".checkpoint.json"
+}
+
+// NewCheckpoint returns a checkpoint for the status in data.
+func NewCheckpoint(data Data, results string) Checkpoint {
+	return Checkpoint{
+		SchemaVersion: SchemaVersion,
+		Written:       time.Now(),
+		Hostname:      data.Hostname,
+		Options:       data.Options,
+		Position:      data.Position,
+		TotalRequests: data.TotalRequests,
+		ShownResults:  data.ShownResults,
+		HiddenResults: data.HiddenResults,
+		ResponseBytes: data.ResponseBytes,
+		Start:         data.Start,
+		Cancelled:     data.Cancelled,
+		Results:       results,
+	}
+}
+
+// WriteCheckpoint writes cp to filename. The file is replaced atomically, so
+// a crash while writing does not destroy the previous checkpoint.
+func WriteCheckpoint(filename string, cp Checkpoint) error {
+	buf, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+
+	tempfile := filename + ".tmp"
+	err = ioutil.WriteFile(tempfile, buf, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempfile, filename)
+}
+
+// ReadCheckpoint reads a checkpoint from filename.
+func ReadCheckpoint(filename string) (*Checkpoint, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	err = json.Unmarshal(buf, &cp)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %v failed: %v", filename, err)
+	}
+
+	return &cp, nil
+}
@@ -0,0 +1,133 @@
+package report
+
+import (
+	"sort"
+	"strings"
+)
+
+// Cluster is a group of host names connected by shared addresses or CNAME
+// targets, e.g. the names of a shared hosting server or a load balancer.
+type Cluster struct {
+	Hostnames []string
+	Addresses []string // addresses of the host names in the cluster
+	CNAMEs    []string // CNAME targets of the host names in the cluster
+}
+
+// FindClusters returns the clusters with at least minSize host names in the
+// summary, the largest clusters first. Host names are in the same cluster if
+// they share an address or a CNAME target, or one is the CNAME target of the
+// other, directly or via other host names.
+func FindClusters(summary *Summary, minSize int) []Cluster {
+	// union-find over the host names
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(name string) string {
+		p, ok := parent[name]
+		if !ok {
+			parent[name] = name
+			return name
+		}
+		if p == name {
+			return name
+		}
+		root := find(p)
+		parent[name] = root
+		return root
+	}
+
+	union := func(names []string) {
+		if len(names) == 0 {
+			return
+		}
+		root := find(names[0])
+		for _, name := range names[1:] {
+			if r := find(name); r != root {
+				parent[r] = root
+			}
+		}
+	}
+
+	for _, names := range summary.Addresses {
+		union(names)
+	}
+	for _, names := range summary.CNAMEs {
+		union(names)
+	}
+
+	// CNAME targets which are host names in the summary join the clusters
+	for target, names := range summary.CNAMEs {
+		if _, ok := parent[target]; ok && len(names) > 0 {
+			union([]string{target, names[0]})
+		}
+	}
+
+	byRoot := make(map[string]*Cluster)
+	cluster := func(names []string) *Cluster {
+		root := find(names[0])
+		c, ok := byRoot[root]
+		if !ok {
+			c = &Cluster{}
+			byRoot[root] = c
+		}
+		return c
+	}
+
+	for addr, names := range summary.Addresses {
+		if len(names) > 0 {
+			c := cluster(names)
+			c.Addresses = append(c.Addresses, addr)
+		}
+	}
+	for target, names := range summary.CNAMEs {
+		if len(names) > 0 {
+			c := cluster(names)
+			c.CNAMEs = append(c.CNAMEs, target)
+		}
+	}
+	for name := range parent {
+		c := byRoot[find(name)]
+		c.Hostnames = append(c.Hostnames, name)
+	}
+
+	var list []Cluster
+	for _, c := range byRoot {
+		if len(c.Hostnames) < minSize {
+			continue
+		}
+
+		sort.Strings(c.Hostnames)
+		sort.Strings(c.Addresses)
+		sort.Strings(c.CNAMEs)
+		list = append(list, *c)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].Hostnames) != len(list[j].Hostnames) {
+			return len(list[i].Hostnames) > len(list[j].Hostnames)
+		}
+		return list[i].Hostnames[0] < list[j].Hostnames[0]
+	})
+
+	return list
+}
+
+// PrintClusters prints the clusters with their host names, addresses and
+// CNAME targets.
+func PrintClusters(term Printer, clusters []Cluster) {
+	term.Printf("\nclusters of host names with shared addresses or CNAME targets:\n")
+	if len(clusters) == 0 {
+		term.Printf("  none found")
+		return
+	}
+
+	for i, c := range clusters {
+		term.Printf("  cluster %d (%d host names)", i+1, len(c.Hostnames))
+		if len(c.Addresses) > 0 {
+			term.Printf("    addresses:     %s", strings.Join(c.Addresses, ", "))
+		}
+		if len(c.CNAMEs) > 0 {
+			term.Printf("    CNAME targets: %s", strings.Join(c.CNAMEs, ", "))
+		}
+		term.Printf("    host names:    %s", strings.Join(c.Hostnames, ", "))
+	}
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// CompressedSuffix is the file name extension of files compressed with gzip.
+const CompressedSuffix = ".gz"
+
+// File is a file which may be compressed.
+type File struct {
+	f  *os.File
+	gz *gzip.Writer
+}
+
+// CreateFile creates a new file. If filename ends with ".gz", all data
+// written to the file is compressed with gzip.
+func CreateFile(filename string) (*File, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{f: f}
+	if strings.HasSuffix(filename, CompressedSuffix) {
+		file.gz = gzip.NewWriter(f)
+	}
+
+	return file, nil
+}
+
+// Write writes p to the file.
+func (f *File) Write(p []byte) (int, error) {
+	if f.gz != nil {
+		return f.gz.Write(p)
+	}
+	return f.f.Write(p)
+}
+
+// Flush writes all pending compressed data to the file.
+func (f *File) Flush() error {
+	if f.gz != nil {
+		return f.gz.Flush()
+	}
+	return nil
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	if f.gz != nil {
+		err := f.gz.Close()
+		if err != nil {
+			_ = f.f.Close()
+			return err
+		}
+	}
+	return f.f.Close()
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (rd gzipReadCloser) Close() error {
+	err := rd.Reader.Close()
+	if err != nil {
+		_ = rd.f.Close()
+		return err
+	}
+	return rd.f.Close()
+}
+
+// openFile opens a file for reading. If filename ends with ".gz", the data is
+// decompressed.
+func openFile(filename string) (io.ReadCloser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(filename, CompressedSuffix) {
+		return f, nil
+	}
+
+	rd, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return gzipReadCloser{Reader: rd, f: f}, nil
+}
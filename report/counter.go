@@ -0,0 +1,84 @@
+package report
+
+import (
+	"strconv"
+
+	"github.com/happal/taifun/hyperloglog"
+)
+
+// MaxExactValues is the number of distinct values which are counted exactly
+// before switching to an estimate.
+const MaxExactValues = 100000
+
+// sketchPrecision configures the memory used for estimating the number of
+// distinct values (2^14 bytes, standard error below 1%).
+const sketchPrecision = 14
+
+// UniqueCounter counts distinct values. The values are kept in a set until
+// MaxExactValues is reached, then the counter switches to an estimate with
+// bounded memory (unless it is configured to be exact). Computing the
+// estimate reads the whole sketch, so it is only updated by Refresh.
+type UniqueCounter struct {
+	exact    bool
+	values   map[string]struct{}
+	sketch   *hyperloglog.Sketch
+	estimate int
+}
+
+// NewUniqueCounter returns a new counter. If exact is set, all values are
+// kept in memory.
+func NewUniqueCounter(exact bool) *UniqueCounter {
+	return &UniqueCounter{
+		exact:  exact,
+		values: make(map[string]struct{}),
+	}
+}
+
+// Add adds a value.
+func (c *UniqueCounter) Add(value string) {
+	if c.sketch != nil {
+		c.sketch.Add(value)
+		return
+	}
+
+	c.values[value] = struct{}{}
+
+	if !c.exact && len(c.values) > MaxExactValues {
+		c.sketch = hyperloglog.New(sketchPrecision)
+		for v := range c.values {
+			c.sketch.Add(v)
+		}
+		c.values = nil
+		c.Refresh()
+	}
+}
+
+// Refresh updates the estimate returned by Count.
+func (c *UniqueCounter) Refresh() {
+	if c.sketch != nil {
+		c.estimate = int(c.sketch.Count())
+	}
+}
+
+// Count returns the number of distinct values. Estimates are those from the
+// last call to Refresh.
+func (c *UniqueCounter) Count() int {
+	if c.sketch != nil {
+		return c.estimate
+	}
+	return len(c.values)
+}
+
+// Estimated returns true if Count returns an estimate.
+func (c *UniqueCounter) Estimated() bool {
+	return c.sketch != nil
+}
+
+// String returns the number of distinct values, estimates are prefixed with a
+// tilde.
+func (c *UniqueCounter) String() string {
+	if c.Estimated() {
+		return "~" + strconv.Itoa(c.Count())
+	}
+	return strconv.Itoa(c.Count())
+}
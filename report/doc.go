@@ -0,0 +1,5 @@
+// Package report records the results of a run in a JSON log and displays
+// them on a terminal. A Recorder writes the results to the log while the run
+// is in progress, ReadData reads the log back. A Reporter prints the results
+// and the statistics, formatted by a ResultPrinter.
+package report
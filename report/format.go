@@ -0,0 +1,414 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+)
+
+// ResultPrinter displays Results on a terminal.
+type ResultPrinter interface {
+	PrintHeader(Printer)
+	PrintResult(Printer, resolve.Result)
+}
+
+// TextPrinter prints results in a human-readable table.
+type TextPrinter struct {
+	Width    int  // width of the first column
+	NoHeader bool // do not print the table header
+	ShowRTT  bool // print the round-trip time of the request
+
+	ShowConfidence bool // print the confidence rating of the result
+	ShowFlags      bool // print the header flags of the response
+}
+
+// PrintHeader prints the table header.
+func (p *TextPrinter) PrintHeader(term Printer) {
+	if p.NoHeader {
+		return
+	}
+
+	var extra, extraNames string
+	if p.ShowRTT {
+		extra += fmt.Sprintf(" %9s", "")
+		extraNames += fmt.Sprintf(" %9s", "RTT")
+	}
+	if p.ShowConfidence {
+		extra += fmt.Sprintf(" %6s", "")
+		extraNames += fmt.Sprintf(" %6s", "conf")
+	}
+	if p.ShowFlags {
+		extra += fmt.Sprintf(" %11s", "")
+		extraNames += fmt.Sprintf(" %11s", "flags")
+	}
+
+	term.Printf("%s %8s %8s %6s%s  %s", ljust("", p.Width), "request", "response", "", extra, "")
+	term.Printf("%s %8s %8s %6s%s  %s", ljust("name  ", p.Width), "type", "type", "TTL", extraNames, "response")
+}
+
+// PrintResult prints one line per response.
+func (p *TextPrinter) PrintResult(term Printer, result resolve.Result) {
+	printResult(term, p, result)
+}
+
+// CSVPrinter prints results as comma separated values.
+type CSVPrinter struct{}
+
+var csvHeader = []string{"hostname", "item", "request_type", "response_type", "ttl", "data", "status", "nameserver", "confidence", "flags", "labels"}
+
+// csvLine returns the fields encoded as a CSV line without the trailing line break.
+func csvLine(fields []string) string {
+	buf := bytes.NewBuffer(nil)
+	wr := csv.NewWriter(buf)
+	// errors can only occur when writing to buf, which does not fail
+	_ = wr.Write(fields)
+	wr.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// PrintHeader prints the names of the columns.
+func (p *CSVPrinter) PrintHeader(term Printer) {
+	term.Printf("%s", csvLine(csvHeader))
+}
+
+// PrintResult prints one line per response, see responseLines.
+func (p *CSVPrinter) PrintResult(term Printer, result resolve.Result) {
+	for _, line := range responseLines(result) {
+		var ttl string
+		if line.RequestType != "" && line.Type != "" {
+			ttl = strconv.FormatUint(uint64(line.TTL), 10)
+		}
+
+		cli.Logf(term, cli.LevelInfo, resultFields(line.Hostname, line.RequestType), "%s", csvLine([]string{
+			line.Hostname,
+			line.Item,
+			line.RequestType,
+			line.Type,
+			ttl,
+			line.Data,
+			line.Status,
+			line.Server,
+			line.Confidence,
+			line.Flags,
+			line.Labels,
+		}))
+	}
+}
+
+// WidePrinter prints one line per result with the host name and the type and
+// data of all responses, e.g. "www.example.com CNAME:example.com A:192.0.2.1".
+type WidePrinter struct{}
+
+// PrintHeader does nothing, there is no header.
+func (p *WidePrinter) PrintHeader(term Printer) {}
+
+// PrintResult prints a single line for the result. Shown requests without
+// responses are printed with the status, e.g. "AAAA:NOERROR", also if other
+// requests of the result were answered.
+func (p *WidePrinter) PrintResult(term Printer, result resolve.Result) {
+	lines := responseLines(result)
+	if len(lines) == 0 {
+		return
+	}
+
+	fields := []string{result.Hostname}
+	seen := make(map[string]struct{})
+	add := func(field string) {
+		// CNAMEs are returned for each request type
+		if _, ok := seen[field]; ok {
+			return
+		}
+		seen[field] = struct{}{}
+
+		fields = append(fields, field)
+	}
+
+	for _, line := range lines {
+		if line.Type == "" {
+			add(line.RequestType + ":" + line.Status)
+			continue
+		}
+		add(line.Type + ":" + line.Data)
+	}
+
+	// responseLines only returns the status if no request was answered
+	if !result.Delegation() {
+		for _, request := range result.Requests {
+			if !request.Hide && len(request.Responses) == 0 {
+				add(request.Type + ":" + request.Status)
+			}
+		}
+	}
+
+	cli.Logf(term, cli.LevelInfo, resultFields(result.Hostname, ""), "%s", strings.Join(fields, " "))
+}
+
+// massdnsRecords parses the raw records of a section, records which cannot
+// be parsed and OPT pseudo-records are skipped.
+func massdnsRecords(raw []string) (records []dns.RR) {
+	for _, s := range raw {
+		rr, err := dns.NewRR(s)
+		if err != nil || rr == nil || rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		records = append(records, rr)
+	}
+	return records
+}
+
+// ShownAnswers returns the records of the answer section of request without
+// the records for responses which were hidden (e.g. by a filter), so the
+// massdns output and the graph contain the same answers as the other
+// formats. Records for other names, e.g. the target of a CNAME, are kept.
+func ShownAnswers(hostname string, request resolve.Request) (records []dns.RR) {
+	hidden := make(map[string]struct{})
+	for _, response := range request.Responses {
+		if response.Hide {
+			hidden[massdnsKey(response)] = struct{}{}
+		}
+	}
+
+	for _, rr := range massdnsRecords(request.Raw.Answer) {
+		if strings.EqualFold(rr.Header().Name, dns.Fqdn(hostname)) {
+			if response, ok := resolve.NewRecordResponse(rr); ok {
+				if _, ok := hidden[massdnsKey(response)]; ok {
+					continue
+				}
+			}
+		}
+		records = append(records, rr)
+	}
+	return records
+}
+
+// massdnsKey returns the type and data of response for comparing it with the
+// raw records.
+func massdnsKey(response resolve.Response) string {
+	return response.Type + " " + response.Data
+}
+
+// massdnsData returns the data of rr without the header, as massdns prints it.
+func massdnsData(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// MassdnsPrinter prints results in the "simple text" format of massdns (-o S):
+// one line per record in the answer section with the name, type and data,
+// e.g. "www.example.com. A 192.0.2.1". Tools processing massdns output can
+// read it unchanged.
+type MassdnsPrinter struct{}
+
+// PrintHeader does nothing, there is no header.
+func (p *MassdnsPrinter) PrintHeader(term Printer) {}
+
+// PrintResult prints the answer records of all requests.
+func (p *MassdnsPrinter) PrintResult(term Printer, result resolve.Result) {
+	for _, request := range result.Requests {
+		if request.Hide || request.Error != nil {
+			continue
+		}
+
+		for _, rr := range ShownAnswers(result.Hostname, request) {
+			hdr := rr.Header()
+			cli.Logf(term, cli.LevelInfo, resultFields(result.Hostname, request.Type), "%s %s %s", hdr.Name, dns.TypeToString[hdr.Rrtype], massdnsData(rr))
+		}
+	}
+}
+
+// massdnsRecord is a record in the ndjson format of massdns.
+type massdnsRecord struct {
+	TTL   uint32 `json:"ttl"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+	Name  string `json:"name"`
+	Data  string `json:"data"`
+}
+
+// massdnsLine is a response in the ndjson format of massdns.
+type massdnsLine struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Class     string `json:"class"`
+	Status    string `json:"status"`
+	Timestamp int64  `json:"rx_ts"`
+	Data      struct {
+		Answers     []massdnsRecord `json:"answers,omitempty"`
+		Authorities []massdnsRecord `json:"authorities,omitempty"`
+		Additionals []massdnsRecord `json:"additionals,omitempty"`
+	} `json:"data"`
+	Flags    []string `json:"flags"`
+	Resolver string   `json:"resolver"`
+}
+
+// newMassdnsRecords converts the records of a section.
+func newMassdnsRecords(rrs []dns.RR) (records []massdnsRecord) {
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		records = append(records, massdnsRecord{
+			TTL:   hdr.Ttl,
+			Type:  dns.TypeToString[hdr.Rrtype],
+			Class: dns.ClassToString[hdr.Class],
+			Name:  hdr.Name,
+			Data:  massdnsData(rr),
+		})
+	}
+	return records
+}
+
+// MassdnsJSONPrinter prints results in the ndjson format of massdns (-o J):
+// one JSON object per response.
+type MassdnsJSONPrinter struct {
+	now func() time.Time // returns the time of the responses, time.Now if nil
+}
+
+// PrintHeader does nothing, there is no header.
+func (p *MassdnsJSONPrinter) PrintHeader(term Printer) {}
+
+// PrintResult prints one line per request which received a response.
+func (p *MassdnsJSONPrinter) PrintResult(term Printer, result resolve.Result) {
+	now := time.Now
+	if p.now != nil {
+		now = p.now
+	}
+
+	for _, request := range result.Requests {
+		if request.Hide || request.Error != nil {
+			continue
+		}
+
+		line := massdnsLine{
+			Name:      dns.Fqdn(result.Hostname),
+			Type:      request.Type,
+			Class:     "IN",
+			Status:    request.Status,
+			Timestamp: now().UnixNano(),
+			Flags:     request.Flags.List(),
+			Resolver:  request.Server,
+		}
+		if line.Flags == nil {
+			line.Flags = []string{}
+		}
+
+		line.Data.Answers = newMassdnsRecords(ShownAnswers(result.Hostname, request))
+		line.Data.Authorities = newMassdnsRecords(massdnsRecords(request.Raw.Nameserver))
+		line.Data.Additionals = newMassdnsRecords(massdnsRecords(request.Raw.Extra))
+
+		buf, err := json.Marshal(line)
+		if err != nil {
+			cli.Logf(term, cli.LevelError, nil, "encoding result failed: %v", err)
+			continue
+		}
+
+		cli.Logf(term, cli.LevelInfo, resultFields(result.Hostname, request.Type), "%s", buf)
+	}
+}
+
+// ResponseLine contains the data for a single response.
+type ResponseLine struct {
+	Hostname    string
+	Item        string
+	RequestType string
+	Status      string
+	Server      string
+	RTT         time.Duration
+	Size        int // size of the response in bytes
+	Confidence  string
+	Flags       string // header flags of the response, e.g. "aa ra"
+	Labels      string // labels of the item from the input, separated by spaces
+
+	Type string
+	Data string
+	TTL  uint
+}
+
+// responseLines returns one line per response which is not hidden.
+// Potential delegations are returned as one NS line per name server, empty
+// results as one line per request (without response type and data).
+func responseLines(result resolve.Result) (lines []ResponseLine) {
+	if result.Delegation() {
+		var nameserver string
+		if len(result.Requests) > 0 {
+			nameserver = result.Requests[0].Server
+		}
+
+		for _, server := range result.Nameservers() {
+			lines = append(lines, ResponseLine{
+				Hostname: result.Hostname,
+				Item:     result.Item,
+				Labels:   strings.Join(result.Labels, " "),
+				Server:   nameserver,
+				Type:     "NS",
+				Data:     server,
+			})
+		}
+		return lines
+	}
+
+	for _, request := range result.Requests {
+		if request.Hide {
+			continue
+		}
+
+		line := ResponseLine{
+			Hostname:    result.Hostname,
+			Item:        result.Item,
+			RequestType: request.Type,
+			Status:      request.Status,
+			Server:      request.Server,
+			RTT:         request.RTT,
+			Size:        request.Size,
+			Confidence:  result.Confidence,
+			Flags:       request.Flags.String(),
+			Labels:      strings.Join(result.Labels, " "),
+		}
+
+		if result.Empty() {
+			lines = append(lines, line)
+			continue
+		}
+
+		for _, response := range request.Responses {
+			if response.Hide {
+				continue
+			}
+
+			line.Type = response.Type
+			line.Data = response.Data
+			line.TTL = response.TTL
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// TemplatePrinter prints one line per response by executing a template with
+// a ResponseLine.
+type TemplatePrinter struct {
+	Template *template.Template
+}
+
+// PrintHeader does nothing, there is no header.
+func (p *TemplatePrinter) PrintHeader(term Printer) {}
+
+// PrintResult prints one line per response.
+func (p *TemplatePrinter) PrintResult(term Printer, result resolve.Result) {
+	for _, line := range responseLines(result) {
+		buf := bytes.NewBuffer(nil)
+		err := p.Template.Execute(buf, line)
+		if err != nil {
+			cli.Logf(term, cli.LevelError, resultFields(line.Hostname, line.RequestType), "template error: %v", err)
+			return
+		}
+		cli.Logf(term, cli.LevelInfo, resultFields(line.Hostname, line.RequestType), "%s", buf.String())
+	}
+}
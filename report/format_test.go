@@ -0,0 +1,230 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// linePrinter collects the lines printed.
+type linePrinter struct {
+	lines []string
+}
+
+func (p *linePrinter) Printf(msg string, data ...interface{}) {
+	p.lines = append(p.lines, fmt.Sprintf(msg, data...))
+}
+
+func TestWidePrinter(t *testing.T) {
+	cname := resolve.Response{Type: "CNAME", Data: "example.com."}
+
+	var tests = []struct {
+		name   string
+		result resolve.Result
+		want   []string
+	}{
+		{
+			name: "addresses",
+			result: resolve.Result{
+				Hostname: "www.example.com",
+				Requests: []resolve.Request{
+					{Type: "A", Status: "NOERROR", Responses: []resolve.Response{
+						{Type: "A", Data: "192.0.2.1"},
+						{Type: "A", Data: "192.0.2.2"},
+					}},
+					{Type: "AAAA", Status: "NOERROR", Responses: []resolve.Response{
+						{Type: "AAAA", Data: "2001:db8::1"},
+					}},
+				},
+			},
+			want: []string{"www.example.com A:192.0.2.1 A:192.0.2.2 AAAA:2001:db8::1"},
+		},
+		{
+			// the CNAME is returned for both requests, but printed once
+			name: "cname",
+			result: resolve.Result{
+				Hostname: "www.example.com",
+				Requests: []resolve.Request{
+					{Type: "A", Status: "NOERROR", Responses: []resolve.Response{
+						cname,
+						{Type: "A", Data: "192.0.2.1"},
+					}},
+					{Type: "AAAA", Status: "NOERROR", Responses: []resolve.Response{
+						cname,
+						{Type: "AAAA", Data: "2001:db8::1"},
+					}},
+				},
+			},
+			want: []string{"www.example.com CNAME:example.com. A:192.0.2.1 AAAA:2001:db8::1"},
+		},
+		{
+			name: "empty",
+			result: resolve.Result{
+				Hostname: "www.example.com",
+				Requests: []resolve.Request{
+					{Type: "A", Status: "NOERROR"},
+					{Type: "AAAA", Status: "NOERROR"},
+				},
+			},
+			want: []string{"www.example.com A:NOERROR AAAA:NOERROR"},
+		},
+		{
+			// the status of the empty request is printed with the answers
+			name: "mixed",
+			result: resolve.Result{
+				Hostname: "www.example.com",
+				Requests: []resolve.Request{
+					{Type: "A", Status: "NOERROR", Responses: []resolve.Response{
+						{Type: "A", Data: "192.0.2.1"},
+					}},
+					{Type: "AAAA", Status: "NOERROR"},
+				},
+			},
+			want: []string{"www.example.com A:192.0.2.1 AAAA:NOERROR"},
+		},
+		{
+			name: "hidden",
+			result: resolve.Result{
+				Hostname: "www.example.com",
+				Requests: []resolve.Request{
+					{Type: "A", Status: "NOERROR", Responses: []resolve.Response{
+						{Type: "A", Data: "192.0.2.1", Hide: true},
+						{Type: "A", Data: "192.0.2.2"},
+					}},
+					{Type: "AAAA", Status: "NOERROR", Hide: true, Responses: []resolve.Response{
+						{Type: "AAAA", Data: "2001:db8::1"},
+					}},
+				},
+			},
+			want: []string{"www.example.com A:192.0.2.2"},
+		},
+		{
+			name: "delegation",
+			result: resolve.Result{
+				Hostname: "sub.example.com",
+				Requests: []resolve.Request{
+					{Type: "A", Status: "NOERROR", Nameserver: []resolve.Response{
+						{Type: "NS", Data: "ns2.example.net."},
+						{Type: "NS", Data: "ns1.example.net."},
+					}},
+				},
+			},
+			want: []string{"sub.example.com NS:ns1.example.net. NS:ns2.example.net."},
+		},
+		{
+			name: "not-found",
+			result: resolve.Result{
+				Hostname: "www.example.com",
+				Requests: []resolve.Request{
+					{Type: "A", Status: "NXDOMAIN", Failure: true, NotFound: true},
+				},
+			},
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			term := &linePrinter{}
+			p := &WidePrinter{}
+			p.PrintHeader(term)
+			p.PrintResult(term, test.result)
+
+			if !reflect.DeepEqual(term.lines, test.want) {
+				t.Errorf("wrong output, want:\n%q\ngot:\n%q", test.want, term.lines)
+			}
+		})
+	}
+}
+
+// massdnsResults returns the results for the massdns printers: a CNAME with
+// the address of the target, a hidden response, a hidden request and a
+// request which failed.
+func massdnsResults() []resolve.Result {
+	www := resolve.Request{Type: "A", Status: "NOERROR", Server: "192.0.2.53:53",
+		Flags: resolve.Flags{RecursionAvailable: true},
+		Responses: []resolve.Response{
+			{Type: "CNAME", Data: "web.example.com."},
+		},
+	}
+	www.Raw.Answer = []string{
+		"www.example.com. 300 IN CNAME web.example.com.",
+		"web.example.com. 60 IN A 192.0.2.1",
+	}
+
+	mail := resolve.Request{Type: "A", Status: "NOERROR", Server: "192.0.2.53:53",
+		Flags: resolve.Flags{Authoritative: true},
+		Responses: []resolve.Response{
+			{Type: "A", Data: "10.0.0.1", Hide: true},
+			{Type: "A", Data: "192.0.2.2"},
+		},
+	}
+	mail.Raw.Answer = []string{
+		"mail.example.com. 300 IN A 10.0.0.1",
+		"mail.example.com. 300 IN A 192.0.2.2",
+	}
+	mail.Raw.Nameserver = []string{"example.com. 3600 IN NS ns1.example.net."}
+	mail.Raw.Extra = []string{"ns1.example.net. 3600 IN A 198.51.100.1"}
+
+	hidden := resolve.Request{Type: "AAAA", Status: "NOERROR", Hide: true}
+	hidden.Raw.Answer = []string{"mail.example.com. 300 IN AAAA 2001:db8::1"}
+
+	failed := resolve.Request{Type: "MX", Error: errors.New("timeout")}
+
+	return []resolve.Result{
+		{Hostname: "www.example.com", Requests: []resolve.Request{www}},
+		{Hostname: "mail.example.com", Requests: []resolve.Request{mail, hidden, failed}},
+	}
+}
+
+func TestMassdnsPrinter(t *testing.T) {
+	want := []string{
+		"www.example.com. CNAME web.example.com.",
+		"web.example.com. A 192.0.2.1",
+		"mail.example.com. A 192.0.2.2",
+	}
+
+	term := &linePrinter{}
+	p := &MassdnsPrinter{}
+	p.PrintHeader(term)
+	for _, result := range massdnsResults() {
+		p.PrintResult(term, result)
+	}
+
+	if !reflect.DeepEqual(term.lines, want) {
+		t.Errorf("wrong output, want:\n%q\ngot:\n%q", want, term.lines)
+	}
+}
+
+func TestMassdnsJSONPrinter(t *testing.T) {
+	want := []string{
+		`{"name":"www.example.com.","type":"A","class":"IN","status":"NOERROR","rx_ts":1577880000000000000,` +
+			`"data":{"answers":[` +
+			`{"ttl":300,"type":"CNAME","class":"IN","name":"www.example.com.","data":"web.example.com."},` +
+			`{"ttl":60,"type":"A","class":"IN","name":"web.example.com.","data":"192.0.2.1"}]},` +
+			`"flags":["ra"],"resolver":"192.0.2.53:53"}`,
+		`{"name":"mail.example.com.","type":"A","class":"IN","status":"NOERROR","rx_ts":1577880000000000000,` +
+			`"data":{"answers":[` +
+			`{"ttl":300,"type":"A","class":"IN","name":"mail.example.com.","data":"192.0.2.2"}],` +
+			`"authorities":[{"ttl":3600,"type":"NS","class":"IN","name":"example.com.","data":"ns1.example.net."}],` +
+			`"additionals":[{"ttl":3600,"type":"A","class":"IN","name":"ns1.example.net.","data":"198.51.100.1"}]},` +
+			`"flags":["aa"],"resolver":"192.0.2.53:53"}`,
+	}
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	term := &linePrinter{}
+	p := &MassdnsJSONPrinter{now: func() time.Time { return now }}
+	p.PrintHeader(term)
+	for _, result := range massdnsResults() {
+		p.PrintResult(term, result)
+	}
+
+	if !reflect.DeepEqual(term.lines, want) {
+		t.Errorf("wrong output, want:\n%s\ngot:\n%s", want, term.lines)
+	}
+}
@@ -0,0 +1,74 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// histogramBase is the ratio between the upper bounds of two consecutive
+// buckets of a LatencyHistogram, so percentiles are accurate to 5%.
+const histogramBase = 1.05
+
+// histogramMin is the upper bound of the first bucket, shorter latencies are
+// counted there.
+const histogramMin = 100 * time.Microsecond
+
+// LatencyHistogram counts latencies in buckets which grow exponentially, so
+// the memory used does not depend on the number of values.
+type LatencyHistogram struct {
+	buckets []int
+	count   int
+}
+
+// histogramBucket returns the index of the bucket for d.
+func histogramBucket(d time.Duration) int {
+	if d <= histogramMin {
+		return 0
+	}
+	return int(math.Ceil(math.Log(float64(d)/float64(histogramMin)) / math.Log(histogramBase)))
+}
+
+// Add records the latency d.
+func (h *LatencyHistogram) Add(d time.Duration) {
+	i := histogramBucket(d)
+	if i >= len(h.buckets) {
+		h.buckets = append(h.buckets, make([]int, i+1-len(h.buckets))...)
+	}
+	h.buckets[i]++
+	h.count++
+}
+
+// Count returns the number of latencies recorded.
+func (h *LatencyHistogram) Count() int {
+	return h.count
+}
+
+// Percentile returns the p-th percentile (0 <= p <= 100), which is the upper
+// bound of the bucket it falls into.
+func (h *LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(float64(h.count) * p / 100))
+	if rank < 1 {
+		rank = 1
+	}
+
+	seen := 0
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			return time.Duration(float64(histogramMin) * math.Pow(histogramBase, float64(i)))
+		}
+	}
+
+	// not reached
+	return 0
+}
+
+// FormatLatency returns d in milliseconds.
+func FormatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", d.Seconds()*1000)
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// bucketBound returns the upper bound of bucket i.
+func bucketBound(i int) time.Duration {
+	h := LatencyHistogram{buckets: make([]int, i+1), count: 1}
+	h.buckets[i] = 1
+	return h.Percentile(100)
+}
+
+func TestHistogramBucket(t *testing.T) {
+	var tests = []struct {
+		d    time.Duration
+		want int
+	}{
+		{0, 0},
+		{time.Microsecond, 0},
+		{histogramMin, 0},
+		{101 * time.Microsecond, 1},
+		{104 * time.Microsecond, 1},
+		{106 * time.Microsecond, 2},
+		{time.Millisecond, 48},
+		{time.Second, 189},
+	}
+
+	for _, test := range tests {
+		t.Run(test.d.String(), func(t *testing.T) {
+			got := histogramBucket(test.d)
+			if got != test.want {
+				t.Errorf("wrong bucket, want %d, got %d", test.want, got)
+			}
+		})
+	}
+}
+
+func TestHistogramBucketBounds(t *testing.T) {
+	// the upper bound of the bucket is at most 5% above the latency
+	for _, d := range []time.Duration{
+		150 * time.Microsecond,
+		time.Millisecond,
+		12345 * time.Microsecond,
+		time.Second,
+		30 * time.Second,
+	} {
+		bound := bucketBound(histogramBucket(d))
+		if bound < d || float64(bound) > float64(d)*histogramBase {
+			t.Errorf("%v: wrong upper bound %v", d, bound)
+		}
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	// 100 latencies from 1ms to 100ms
+	var h LatencyHistogram
+	for i := 1; i <= 100; i++ {
+		h.Add(time.Duration(i) * time.Millisecond)
+	}
+
+	if h.Count() != 100 {
+		t.Fatalf("wrong count, want 100, got %d", h.Count())
+	}
+
+	var tests = []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{1, time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("p%v", test.p), func(t *testing.T) {
+			got := h.Percentile(test.p)
+			want := bucketBound(histogramBucket(test.want))
+			if got != want {
+				t.Errorf("wrong percentile, want %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	var h LatencyHistogram
+	if p := h.Percentile(50); p != 0 {
+		t.Errorf("wrong percentile for empty histogram, want 0, got %v", p)
+	}
+}
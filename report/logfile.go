@@ -0,0 +1,170 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// ReadData reads a JSON log written by a Recorder. Compressed files are
+// decompressed if the file name ends with ".gz".
+func ReadData(filename string) (*Data, error) {
+	rd, err := openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var data Data
+	err = json.NewDecoder(rd).Decode(&data)
+	if err != nil {
+		_ = rd.Close()
+		return nil, err
+	}
+
+	// logs written before the schema version was introduced
+	if data.SchemaVersion == 0 {
+		data.SchemaVersion = 1
+	}
+
+	return &data, rd.Close()
+}
+
+// ProcessedValues returns the number of values from the input which have
+// been processed, a resumed run skips them. Logs written before the position
+// was recorded only contain the number of results.
+func (data *Data) ProcessedValues() int {
+	if data.SchemaVersion < 3 {
+		return data.SentRequests
+	}
+	return data.Position
+}
+
+// Addresses returns the index of all addresses recorded in data which are
+// not hidden, mapping each address to the host names.
+func (data *Data) Addresses() map[string][]string {
+	addresses := make(map[string][]string)
+	for _, res := range data.Results {
+		if res.Hidden {
+			continue
+		}
+
+		for _, req := range res.Requests {
+			if req.Hidden {
+				continue
+			}
+
+			for _, response := range req.Responses {
+				if response.Hidden || (response.Type != "A" && response.Type != "AAAA") {
+					continue
+				}
+
+				addresses[response.Data] = resolve.Unique(append(addresses[response.Data], res.Hostname))
+			}
+		}
+	}
+	return addresses
+}
+
+// PotentialDelegations returns the potential delegations recorded in data, mapping
+// the host name to the name servers.
+func (data *Data) PotentialDelegations() map[string][]string {
+	delegations := make(map[string][]string)
+	for _, res := range data.Results {
+		if res.Hidden || !res.PotentialDelegation {
+			continue
+		}
+		delegations[res.Hostname] = resolve.Unique(append(delegations[res.Hostname], res.Nameservers...))
+	}
+	return delegations
+}
+
+// WriteData writes data as JSON to a file, which is compressed if the name
+// ends with ".gz".
+func WriteData(filename string, data *Data) error {
+	buf, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+
+	f, err := CreateFile(filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(buf)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ToResult converts a recorded result back into a Result. Markers for hidden
+// results are not restored, so filters can be applied again.
+func (r RecordedResult) ToResult() resolve.Result {
+	res := resolve.Result{
+		Item:     r.Item,
+		Hostname: r.Hostname,
+		Labels:   r.Labels,
+		HTTP:     r.HTTP,
+		SANs:     r.SANs,
+	}
+
+	if r.PotentialDelegation {
+		request := resolve.Request{}
+		for _, ns := range r.Nameservers {
+			request.Nameserver = append(request.Nameserver, resolve.Response{Type: "NS", Data: ns})
+		}
+		for i := range r.SOA {
+			request.SOA = append(request.SOA, resolve.Response{Type: "SOA", Data: r.SOA[i].Mname, SOA: &r.SOA[i]})
+		}
+		res.Requests = append(res.Requests, request)
+		return res
+	}
+
+	for _, req := range r.Requests {
+		request := resolve.Request{
+			Type:     req.Type,
+			Status:   req.Status,
+			Failure:  req.Status != "" && req.Status != "NOERROR",
+			NotFound: req.Status == "NXDOMAIN",
+			Server:   req.Server,
+			RTT:      time.Duration(req.RTT * float64(time.Millisecond)),
+		}
+
+		if req.Error != "" {
+			request.Error = errors.New(req.Error)
+		}
+
+		for _, response := range req.Responses {
+			segments := response.Segments
+			if response.Type == "TXT" && len(segments) == 0 {
+				// only TXT records with several strings are recorded
+				segments = []string{response.Data}
+			}
+
+			request.Responses = append(request.Responses, resolve.Response{
+				Type:      response.Type,
+				Data:      response.Data,
+				TTL:       response.TTL,
+				Owner:     response.Owner,
+				Netname:   response.Netname,
+				OpenPorts: response.OpenPorts,
+				Segments:  segments,
+			})
+		}
+
+		request.Raw.Question = req.Raw.Question
+		request.Raw.Answer = req.Raw.Answer
+		request.Raw.Nameserver = req.Raw.Nameserver
+		request.Raw.Extra = req.Raw.Extra
+
+		res.Requests = append(res.Requests, request)
+	}
+
+	return res
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"fmt"
+	"time"
+)
+
+// IterationStats contains the statistics for one iteration of the input with
+// --loop or --forever.
+type IterationStats struct {
+	Iteration         int            `json:"iteration"`
+	Start             time.Time      `json:"start"`
+	Duration          float64        `json:"duration_seconds"`
+	Results           int            `json:"results"`
+	ShownResults      int            `json:"shown_results"`
+	Errors            int            `json:"errors"`
+	Mismatches        int            `json:"mismatches"`
+	Empty             int            `json:"empty"`
+	RequestsPerSecond float64        `json:"requests_per_second"`
+	NewUnique         map[string]int `json:"new_unique"`
+}
+
+// iterationBase contains the totals at the start of an iteration.
+type iterationBase struct {
+	start              time.Time
+	results, shown     int
+	errors, mismatches int
+	empty              int
+	a, aaaa, mx, cname int
+	ptr                int
+}
+
+// iterationDone returns true if the last result added to stats completed an
+// iteration. r.mu must be held.
+func (r *Reporter) iterationDone(stats *Stats) bool {
+	return r.iterationSize > 0 && stats.Results-r.iterationBase.results >= r.iterationSize
+}
+
+// endIteration records the statistics since the last iteration ended and
+// starts the next one. r.mu must be held.
+func (r *Reporter) endIteration(stats *Stats) IterationStats {
+	base := r.iterationBase
+	if base.start.IsZero() {
+		base.start = stats.Start
+	}
+
+	it := IterationStats{
+		Iteration:    len(r.iterations) + 1,
+		Start:        base.start,
+		Duration:     time.Since(base.start).Seconds(),
+		Results:      stats.Results - base.results,
+		ShownResults: stats.ShownResults - base.shown,
+		Errors:       stats.Errors - base.errors,
+		Mismatches:   stats.Mismatches - base.mismatches,
+		Empty:        stats.Empty - base.empty,
+		NewUnique: map[string]int{
+			"A":     stats.A.Count() - base.a,
+			"AAAA":  stats.AAAA.Count() - base.aaaa,
+			"MX":    stats.MX.Count() - base.mx,
+			"CNAME": stats.CNAME.Count() - base.cname,
+			"PTR":   stats.PTR.Count() - base.ptr,
+		},
+	}
+	if it.Duration > 0 {
+		it.RequestsPerSecond = float64(it.Results) / it.Duration
+	}
+
+	r.iterations = append(r.iterations, it)
+	r.iterationBase = iterationBase{
+		start:      time.Now(),
+		results:    stats.Results,
+		shown:      stats.ShownResults,
+		errors:     stats.Errors,
+		mismatches: stats.Mismatches,
+		empty:      stats.Empty,
+		a:          stats.A.Count(),
+		aaaa:       stats.AAAA.Count(),
+		mx:         stats.MX.Count(),
+		cname:      stats.CNAME.Count(),
+		ptr:        stats.PTR.Count(),
+	}
+
+	return it
+}
+
+// pendingIteration returns true if results were added since the last
+// iteration ended. r.mu must be held.
+func (r *Reporter) pendingIteration(stats *Stats) bool {
+	return r.iterationSize > 0 && stats.Results > r.iterationBase.results
+}
+
+// String returns a summary of the iteration.
+func (it IterationStats) String() string {
+	s := fmt.Sprintf("iteration %d: %d requests in %s, %.0f req/s, %d shown, %d errors",
+		it.Iteration, it.Results, formatSeconds(it.Duration), it.RequestsPerSecond, it.ShownResults, it.Errors)
+
+	if it.Mismatches > 0 {
+		s += fmt.Sprintf(", %d mismatches", it.Mismatches)
+	}
+	if it.Empty > 0 {
+		s += fmt.Sprintf(", %d empty", it.Empty)
+	}
+
+	for _, t := range []string{"A", "AAAA", "PTR", "MX", "CNAME"} {
+		if n := it.NewUnique[t]; n != 0 {
+			s += fmt.Sprintf(", %+d unique %s", n, t)
+		}
+	}
+
+	return s
+}
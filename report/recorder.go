@@ -0,0 +1,655 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// Recorder records information about received responses in a file encoded as
+// JSON. While running, the results are appended to a separate file (one JSON
+// document per line) and only the statistics are written to the JSON file.
+// When done, the results are merged into the JSON file and the separate
+// file is removed. If the file name ends with ".gz", all files are compressed.
+type Recorder struct {
+	filename        string
+	resultsFilename string
+
+	// RecordHidden configures the recorder to also record results hidden by
+	// filters, they are marked as hidden.
+	RecordHidden bool
+
+	// Position returns the number of values from the start of the input
+	// which have been processed, if set. Otherwise the position is not
+	// recorded and a resumed run starts at the beginning.
+	Position func() int
+
+	// Done is called with the item of each result after it has been
+	// recorded, if set. This allows Position to only include the values
+	// whose results are in the file, even if they arrive out of order.
+	Done func(item string)
+
+	// Stopped reports whether the producer was stopped early (e.g. by
+	// --max-duration), the run is then not complete even if it was not
+	// cancelled.
+	Stopped func() bool
+
+	// Filters returns the state of the value filters after position values
+	// for the checkpoint, if set.
+	Filters func(position int) *FilterState
+
+	dumpNow chan struct{}
+
+	Data
+}
+
+// SchemaVersion is the version of the data structure written by the
+// Recorder. Files without a version were written before versioning was
+// introduced, they are version 1. Version 3 introduced Position.
+const SchemaVersion = 3
+
+// BuildInfo describes the binary which wrote the data.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// Data is the data structure written to the file by a Recorder.
+type Data struct {
+	SchemaVersion int `json:"schema_version"`
+
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	TotalRequests int       `json:"total_requests"`
+	SentRequests  int       `json:"sent_requests"`
+	HiddenResults int       `json:"hidden_results"`
+	ShownResults  int       `json:"shown_results"`
+	Cancelled     bool      `json:"cancelled"`
+
+	// Position is the number of values from the start of the input which
+	// have been processed, a resumed run skips them.
+	Position int `json:"position"`
+
+	// ResponseBytes is the total size of all responses received.
+	ResponseBytes int64 `json:"response_bytes"`
+
+	Hostname    string `json:"hostname"`
+	InputFile   string `json:"input_file,omitempty"`
+	Range       string `json:"range,omitempty"`
+	RangeFormat string `json:"range_format,omitempty"`
+
+	// Options contains the options of the run encoded as JSON, they are
+	// not interpreted by the Recorder.
+	Options json.RawMessage `json:"options,omitempty"`
+	Build   BuildInfo       `json:"build"`
+
+	// IPs maps each address to the host names which resolved to it. It is
+	// only included in the final file.
+	IPs map[string][]string `json:"ips,omitempty"`
+
+	// Delegations maps the host names of potential delegations to their name
+	// servers. It is only included in the final file.
+	Delegations map[string][]string `json:"delegations,omitempty"`
+
+	// SummaryTruncated is set if IPs and Delegations are incomplete because
+	// the limits for the number of entries were reached.
+	SummaryTruncated bool `json:"summary_truncated,omitempty"`
+
+	// Results is empty while the Recorder is running, the results are
+	// written to a separate file and appended when the final file is written.
+	Results []RecordedResult `json:"responses,omitempty"`
+}
+
+// RecordedResult is the result of a request sent to the target.
+type RecordedResult struct {
+	Item     string `json:"item"`
+	Hostname string `json:"hostname"`
+	Hidden   bool   `json:"hidden,omitempty"`
+
+	PotentialSuffix     bool          `json:"potential_prefix,omitempty"`
+	PotentialDelegation bool          `json:"potential_delegation,omitempty"`
+	Nameservers         []string      `json:"nameservers,omitempty"`
+	SOA                 []resolve.SOA `json:"soa,omitempty"`
+	DifferingServers    []string      `json:"differing_servers,omitempty"`
+	DifferingSubnets    bool          `json:"differing_subnets,omitempty"`
+	DNSSEC              string        `json:"dnssec,omitempty"`
+	DNSSECReason        string        `json:"dnssec_reason,omitempty"`
+	Confidence          string        `json:"confidence,omitempty"`
+	Unverified          bool          `json:"unverified,omitempty"`
+	Labels              []string      `json:"labels,omitempty"`
+	Cached              bool          `json:"cached,omitempty"`
+
+	HTTP []resolve.HTTPProbe `json:"http,omitempty"`
+	SANs []string            `json:"sans,omitempty"`
+
+	Requests []RecordedRequest `json:"requests"`
+}
+
+// RecordedRequest captures one particular request.
+type RecordedRequest struct {
+	Error  string `json:"error,omitempty"`
+	Hidden bool   `json:"hidden,omitempty"`
+
+	Type      string              `json:"type"`
+	Status    string              `json:"status"`
+	Server    string              `json:"server,omitempty"`
+	RTT       float64             `json:"rtt_ms,omitempty"`
+	Size      int                 `json:"size,omitempty"`
+	Responses []RecordedResponse  `json:"responses,omitempty"`
+	Raw       RawRecordedResponse `json:"raw"`
+
+	ClientSubnet      string `json:"client_subnet,omitempty"`
+	ClientSubnetScope *int   `json:"client_subnet_scope,omitempty"`
+
+	Mismatches []string `json:"mismatches,omitempty"`
+	Flags      []string `json:"flags,omitempty"`
+
+	CNAMEChain      []RecordedResponse `json:"cname_chain,omitempty"`
+	CNAMEChainError string             `json:"cname_chain_error,omitempty"`
+
+	PrunedBy string `json:"pruned_by,omitempty"`
+}
+
+// RecordedResponse is a serialized response.
+type RecordedResponse struct {
+	Type   string `json:"type"`
+	Data   string `json:"data"`
+	Hidden bool   `json:"hidden,omitempty"`
+
+	TTL uint `json:"ttl"`
+
+	Owner   string `json:"owner,omitempty"`
+	Netname string `json:"netname,omitempty"`
+
+	OpenPorts []int `json:"open_ports,omitempty"`
+
+	// Segments contains the strings of TXT records with more than one
+	// string.
+	Segments []string `json:"segments,omitempty"`
+}
+
+// RawRecordedResponse contains the (string versions of) the raw DNS response.
+type RawRecordedResponse struct {
+	Question   []string `json:"question,omitempty"`
+	Answer     []string `json:"answer,omitempty"`
+	Nameserver []string `json:"nameserver,omitempty"`
+	Extra      []string `json:"extra,omitempty"`
+
+	// EDNS contains the OPT pseudo-record, if the response had one.
+	EDNS *resolve.EDNS `json:"edns,omitempty"`
+}
+
+// NewRecorder creates a new  recorder. The results are written to a file
+// with the extension ".ndjson" while running.
+func NewRecorder(filename string, hostname string) (*Recorder, error) {
+	rec := &Recorder{
+		filename:        filename,
+		resultsFilename: resultsFilename(filename),
+		dumpNow:         make(chan struct{}, 1),
+		Data: Data{
+			SchemaVersion: SchemaVersion,
+			Hostname:      hostname,
+			Results:       []RecordedResult{},
+		},
+	}
+	return rec, nil
+}
+
+// resultsFilename returns the name of the file the results are written to
+// while running.
+func resultsFilename(filename string) string {
+	compressed := strings.HasSuffix(filename, CompressedSuffix)
+	filename = strings.TrimSuffix(filename, CompressedSuffix)
+	filename = strings.TrimSuffix(filename, ".json") + ".ndjson"
+	if compressed {
+		filename += CompressedSuffix
+	}
+	return filename
+}
+
+const statusInterval = time.Second
+
+// Dump requests writing the current status to the file immediately instead
+// of waiting for the next regular update.
+func (r *Recorder) Dump() {
+	select {
+	case r.dumpNow <- struct{}{}:
+	default:
+		// a dump is already pending
+	}
+}
+
+// Run reads responses from ch and forwards them to the returned channel,
+// recording statistics on the way. When ch is closed or the context is
+// cancelled, the output file is closed, processing stops, and the output
+// channel is closed.
+func (r *Recorder) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result, inCount <-chan int, outCount chan<- int) error {
+	defer close(out)
+
+	resultsFile, err := CreateFile(r.resultsFilename)
+	if err != nil {
+		return err
+	}
+	results := bufio.NewWriter(resultsFile)
+	enc := json.NewEncoder(results)
+
+	data := r.Data
+	data.Start = time.Now()
+	data.End = time.Now()
+
+	// omit range_format if range is unset
+	if data.Range == "" {
+		data.RangeFormat = ""
+	}
+
+	lastStatus := time.Now()
+
+	// collect the addresses of all shown results
+	summary := NewSummary()
+
+	var countCh chan<- int // countCh is nil initially to disable sending
+
+	// writeStatus flushes the results file and writes the current status
+	writeStatus := func() error {
+		lastStatus = time.Now()
+		if r.Position != nil {
+			data.Position = r.Position()
+		}
+
+		err := results.Flush()
+		if err == nil {
+			err = resultsFile.Flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		err = r.dump(data)
+		if err != nil {
+			return err
+		}
+
+		return WriteCheckpoint(CheckpointFilename(r.filename), r.checkpoint(data, r.resultsFilename))
+	}
+
+loop:
+	for {
+		var res resolve.Result
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			data.Cancelled = true
+			break loop
+
+		case res, ok = <-in:
+			if !ok {
+				// we're done, exit
+				break loop
+			}
+
+		case total, ok := <-inCount:
+			if !ok {
+				// disable receiving on the closed in count channel
+				inCount = nil
+				continue loop
+			}
+
+			// the total may be updated while running (e.g. with
+			// --recurse-depth)
+			data.TotalRequests = total
+			// enable sending by setting countCh to outCount (which is not nil)
+			countCh = outCount
+			continue loop
+
+		case countCh <- data.TotalRequests:
+			// disable sending again by setting countCh to nil
+			countCh = nil
+			continue loop
+
+		case <-r.dumpNow:
+			err := writeStatus()
+			if err != nil {
+				_ = resultsFile.Close()
+				return err
+			}
+			continue loop
+		}
+
+		data.SentRequests++
+
+		for _, req := range res.Requests {
+			data.ResponseBytes += int64(req.Size)
+		}
+
+		if !res.Hide {
+			data.ShownResults++
+			summary.Add(res)
+		} else {
+			data.HiddenResults++
+		}
+
+		if !res.Hide || r.RecordHidden {
+			rres := NewResult(res, r.RecordHidden)
+			if !rres.Empty() {
+				err := enc.Encode(rres)
+				if err != nil {
+					_ = resultsFile.Close()
+					return err
+				}
+			}
+		}
+
+		if r.Done != nil {
+			r.Done(res.Item)
+		}
+
+		data.End = time.Now()
+
+		if time.Since(lastStatus) > statusInterval {
+			err := writeStatus()
+			if err != nil {
+				_ = resultsFile.Close()
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			data.Cancelled = true
+			break loop
+		case out <- res:
+		}
+	}
+
+	data.End = time.Now()
+	if r.Position != nil {
+		data.Position = r.Position()
+	}
+
+	err = results.Flush()
+	if err != nil {
+		_ = resultsFile.Close()
+		return err
+	}
+
+	err = resultsFile.Close()
+	if err != nil {
+		return err
+	}
+
+	data.IPs = make(map[string][]string, len(summary.Addresses))
+	for addr, hostnames := range summary.Addresses {
+		data.IPs[addr] = resolve.Unique(hostnames)
+	}
+
+	data.Delegations = summary.Delegations
+	data.SummaryTruncated = summary.Truncated
+
+	cp := r.checkpoint(data, r.filename)
+	cp.Stopped = r.Stopped != nil && r.Stopped()
+	cp.Complete = !data.Cancelled && !cp.Stopped
+	err = WriteCheckpoint(CheckpointFilename(r.filename), cp)
+	if err != nil {
+		return err
+	}
+
+	return r.finish(data)
+}
+
+// checkpoint returns the checkpoint for the status in data, with the state
+// of the value filters if r.Filters is set.
+func (r *Recorder) checkpoint(data Data, results string) Checkpoint {
+	cp := NewCheckpoint(data, results)
+	if r.Filters != nil {
+		cp.Filters = r.Filters(data.Position)
+	}
+	return cp
+}
+
+// dump writes the current status without any results to the file.
+func (r *Recorder) dump(data Data) error {
+	buf, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+
+	f, err := CreateFile(r.filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(buf)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// finish writes the final JSON file, which contains the status and all
+// results from the results file. The results file is removed afterwards.
+func (r *Recorder) finish(data Data) error {
+	data.Results = nil
+	header, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// the results are appended as the last field, so the closing brace is
+	// written after them
+	if !bytes.HasSuffix(header, []byte("\n}")) {
+		return errors.New("invalid JSON encoding of the status")
+	}
+	header = header[:len(header)-len("\n}")]
+
+	rd, err := openFile(r.resultsFilename)
+	if err != nil {
+		return err
+	}
+
+	// keep the extension so the temporary file is compressed if requested
+	ext := filepath.Ext(r.filename)
+	tempfile := strings.TrimSuffix(r.filename, ext) + ".tmp" + ext
+	f, err := CreateFile(tempfile)
+	if err != nil {
+		_ = rd.Close()
+		return err
+	}
+
+	wr := bufio.NewWriter(f)
+	_, _ = wr.Write(header)
+	_, _ = wr.WriteString(",\n  \"responses\": [")
+
+	first := true
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(nil, 64*1024*1024)
+	for sc.Scan() {
+		if first {
+			_, _ = wr.WriteString("\n    ")
+			first = false
+		} else {
+			_, _ = wr.WriteString(",\n    ")
+		}
+
+		buf := bytes.NewBuffer(nil)
+		err = json.Indent(buf, sc.Bytes(), "    ", "  ")
+		if err != nil {
+			break
+		}
+		_, _ = wr.Write(buf.Bytes())
+	}
+
+	if err == nil {
+		err = sc.Err()
+	}
+
+	// close the list of results and the object
+	if first {
+		_, _ = wr.WriteString("]\n}\n")
+	} else {
+		_, _ = wr.WriteString("\n  ]\n}\n")
+	}
+
+	if err == nil {
+		err = wr.Flush()
+	}
+
+	_ = rd.Close()
+
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempfile, r.filename)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(r.resultsFilename)
+}
+
+// txtSegments returns the strings of TXT responses with more than one string,
+// otherwise nil.
+func txtSegments(response resolve.Response) []string {
+	if response.Type != "TXT" || len(response.Segments) < 2 {
+		return nil
+	}
+	return response.Segments
+}
+
+// NewResult builds a Result struct for serialization with JSON. Hidden
+// requests and responses are only included (and marked as hidden) if
+// includeHidden is set.
+func NewResult(r resolve.Result, includeHidden bool) (res RecordedResult) {
+	res = RecordedResult{
+		Item:     r.Item,
+		Hostname: r.Hostname,
+		Hidden:   r.Hide,
+		Requests: []RecordedRequest{},
+
+		DifferingServers: r.Differing,
+		DifferingSubnets: r.DifferingSubnets,
+		DNSSEC:           r.DNSSEC,
+		DNSSECReason:     r.DNSSECReason,
+		Confidence:       r.Confidence,
+		Unverified:       r.Unverified,
+		Labels:           r.Labels,
+		Cached:           r.Cached,
+		HTTP:             r.HTTP,
+		SANs:             r.SANs,
+	}
+
+	if r.Delegation() {
+		res.PotentialDelegation = true
+		res.Nameservers = r.Nameservers()
+		res.SOA = r.SOAs()
+		return res
+	}
+
+	if r.Empty() {
+		res.PotentialSuffix = true
+		return res
+	}
+
+	for _, request := range r.Requests {
+		// do not record hidden requests
+		if (request.Hide && !includeHidden) || request.Empty() {
+			continue
+		}
+		req := RecordedRequest{
+			Hidden: request.Hide,
+			Status: request.Status,
+			Type:   request.Type,
+			Server: request.Server,
+			RTT:    request.RTT.Seconds() * 1000,
+			Size:   request.Size,
+			Raw:    RawRecordedResponse(request.Raw),
+
+			Mismatches: request.Mismatches,
+			Flags:      request.Flags.List(),
+
+			CNAMEChainError: request.ChainError,
+
+			PrunedBy: request.PrunedBy,
+		}
+		if request.Error != nil {
+			req.Error = request.Error.Error()
+		}
+		if request.ClientSubnet != "" {
+			req.ClientSubnet = request.ClientSubnet
+			if request.ClientSubnetScope >= 0 {
+				scope := request.ClientSubnetScope
+				req.ClientSubnetScope = &scope
+			}
+		}
+
+		for _, response := range request.Chain {
+			req.CNAMEChain = append(req.CNAMEChain, RecordedResponse{
+				Type:     response.Type,
+				Data:     response.Data,
+				TTL:      response.TTL,
+				Segments: txtSegments(response),
+			})
+		}
+
+		for _, response := range request.Responses {
+			// do not record hidden responses
+			if response.Hide && !includeHidden {
+				continue
+			}
+
+			req.Responses = append(req.Responses, RecordedResponse{
+				Type:      response.Type,
+				Data:      response.Data,
+				Hidden:    response.Hide,
+				TTL:       response.TTL,
+				Owner:     response.Owner,
+				Netname:   response.Netname,
+				OpenPorts: response.OpenPorts,
+				Segments:  txtSegments(response),
+			})
+		}
+
+		// keep requests which failed the sanity checks, even if all
+		// records have been discarded
+		if len(req.Responses) == 0 && len(req.Mismatches) == 0 {
+			continue
+		}
+
+		res.Requests = append(res.Requests, req)
+	}
+
+	return res
+}
+
+// Empty returns true if the responses are all hidden or empty.
+func (r RecordedResult) Empty() bool {
+	if len(r.Requests) > 0 {
+		return false
+	}
+
+	if r.PotentialSuffix || r.PotentialDelegation {
+		return false
+	}
+
+	return true
+}
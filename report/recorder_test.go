@@ -0,0 +1,262 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/resolve"
+)
+
+// recordCheckpoint runs a recorder for results and returns the checkpoint
+// written at the end.
+func recordCheckpoint(t testing.TB, dir string, results []resolve.Result, setup func(*Recorder)) *Checkpoint {
+	filename := filepath.Join(dir, "log.json")
+	rec, err := NewRecorder(filename, "FUZZ.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Filters = func(position int) *FilterState {
+		return &FilterState{Skip: position}
+	}
+	setup(rec)
+
+	in := make(chan resolve.Result, len(results))
+	for _, res := range results {
+		in <- res
+	}
+	close(in)
+
+	out := make(chan resolve.Result, len(results))
+	inCount := make(chan int, 1)
+	inCount <- len(results)
+	close(inCount)
+
+	err = rec.Run(context.Background(), in, out, inCount, make(chan int, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := ReadCheckpoint(CheckpointFilename(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cp
+}
+
+// trackValues configures r to compute the position with a tracker, which
+// has seen the values in order.
+func trackValues(r *Recorder, values ...string) {
+	in := make(chan string, len(values))
+	for _, v := range values {
+		in <- v
+	}
+	close(in)
+
+	tracker := producer.NewTracker()
+	for range tracker.Run(context.Background(), in) {
+	}
+
+	r.Position = tracker.Position
+	r.Done = tracker.Done
+}
+
+func TestRecorderCheckpoint(t *testing.T) {
+	results := []resolve.Result{
+		{Item: "www", Hostname: "www.example.com"},
+		{Item: "a.www", Hostname: "a.www.example.com"},
+		{Item: "mail", Hostname: "mail.example.com", Hide: true},
+	}
+
+	var tests = []struct {
+		name     string
+		setup    func(*Recorder)
+		complete bool
+		stopped  bool
+		position int
+	}{
+		{
+			name: "complete",
+			setup: func(r *Recorder) {
+				trackValues(r, "www", "a.www", "mail")
+			},
+			complete: true,
+			position: 3,
+		},
+		{
+			name: "stopped",
+			setup: func(r *Recorder) {
+				trackValues(r, "www", "a.www", "mail")
+				r.Stopped = func() bool { return true }
+			},
+			stopped:  true,
+			position: 3,
+		},
+		{
+			// the result for the first value is missing, so none of the
+			// others are included in the position
+			name: "gap",
+			setup: func(r *Recorder) {
+				trackValues(r, "ftp", "www", "a.www", "mail")
+			},
+			complete: true,
+			position: 0,
+		},
+		{
+			name:     "untracked",
+			setup:    func(*Recorder) {},
+			complete: true,
+			position: 0,
+		},
+		{
+			name: "position",
+			setup: func(r *Recorder) {
+				r.Position = func() int { return 2 }
+			},
+			complete: true,
+			position: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempdir, err := ioutil.TempDir("", "taifun-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = os.RemoveAll(tempdir)
+			}()
+
+			cp := recordCheckpoint(t, tempdir, results, test.setup)
+
+			if cp.Complete != test.complete || cp.Stopped != test.stopped {
+				t.Errorf("wrong state, want complete %v and stopped %v, got %v and %v", test.complete, test.stopped, cp.Complete, cp.Stopped)
+			}
+
+			if cp.Position != test.position {
+				t.Errorf("wrong position, want %d, got %d", test.position, cp.Position)
+			}
+
+			if cp.Filters == nil || cp.Filters.Skip != test.position {
+				t.Errorf("wrong filter state %+v", cp.Filters)
+			}
+
+			if cp.ShownResults != 2 || cp.HiddenResults != 1 {
+				t.Errorf("wrong number of results, want 2 shown and 1 hidden, got %d and %d", cp.ShownResults, cp.HiddenResults)
+			}
+		})
+	}
+}
+
+func TestRecorderFinish(t *testing.T) {
+	results := []resolve.Result{
+		{Item: "www", Hostname: "www.example.com"},
+		{Item: "mail", Hostname: "mail.example.com"},
+	}
+
+	var tests = []struct {
+		name      string
+		results   []resolve.Result
+		interrupt bool
+	}{
+		{name: "empty"},
+		{name: "complete", results: results},
+		{name: "interrupted", results: results, interrupt: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempdir, err := ioutil.TempDir("", "taifun-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempdir)
+
+			filename := filepath.Join(tempdir, "log.json")
+			rec, err := NewRecorder(filename, "FUZZ.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			in := make(chan resolve.Result)
+			out := make(chan resolve.Result, len(test.results))
+			inCount := make(chan int, 1)
+			inCount <- len(test.results)
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- rec.Run(ctx, in, out, inCount, make(chan int, 1))
+			}()
+
+			for _, res := range test.results {
+				in <- res
+			}
+
+			// the recorder is interrupted while waiting for more results
+			if test.interrupt {
+				cancel()
+			} else {
+				close(in)
+			}
+
+			err = <-errCh
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !json.Valid(buf) {
+				t.Fatalf("invalid JSON written:\n%s", buf)
+			}
+
+			// the list of results is merged into the file before the closing brace
+			if !bytes.HasSuffix(buf, []byte("]\n}\n")) {
+				t.Errorf("list of results and closing brace missing at the end:\n%s", buf)
+			}
+
+			if len(test.results) == 0 && !bytes.Contains(buf, []byte(`"responses": []`)) {
+				t.Errorf("empty list of results is missing:\n%s", buf)
+			}
+
+			var data Data
+			err = json.Unmarshal(buf, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if data.Cancelled != test.interrupt {
+				t.Errorf("wrong cancelled state, want %v, got %v", test.interrupt, data.Cancelled)
+			}
+
+			if len(data.Results) != len(test.results) {
+				t.Fatalf("wrong number of results, want %d, got %d", len(test.results), len(data.Results))
+			}
+
+			for i, res := range data.Results {
+				if res.Hostname != test.results[i].Hostname {
+					t.Errorf("result %d: wrong hostname, want %v, got %v", i, test.results[i].Hostname, res.Hostname)
+				}
+			}
+
+			// the separate file for the results is removed
+			_, err = os.Stat(filepath.Join(tempdir, "log.ndjson"))
+			if !os.IsNotExist(err) {
+				t.Errorf("results file not removed: %v", err)
+			}
+		})
+	}
+}
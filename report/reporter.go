@@ -0,0 +1,991 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+)
+
+// Reporter prints the Results to a terminal.
+type Reporter struct {
+	term    cli.Terminal
+	printer ResultPrinter
+
+	// Quiet disables printing the summary at the end.
+	Quiet bool
+
+	// SortBy configures the reporter to print all shown results again at
+	// the end, sorted by "hostname" or "ip".
+	SortBy string
+
+	// GroupSummary configures the reporter to print the host names grouped
+	// by address and CNAME target at the end.
+	GroupSummary bool
+
+	// Cluster configures the reporter to print the clusters of host names
+	// with shared addresses or CNAME targets at the end.
+	Cluster bool
+
+	// Verbosity configures the reporter to print the raw DNS messages for
+	// shown results: the answer and authority sections for 1, all sections
+	// for 2 and above.
+	Verbosity int
+
+	// ExactStats configures the reporter to count the unique values exactly
+	// instead of estimating them for large numbers of values.
+	ExactStats bool
+
+	// Paused is called to find out whether sending requests is paused, it
+	// may be nil.
+	Paused func() bool
+
+	// Benched is called to find out which name servers are benched because
+	// they throttle requests, it may be nil.
+	Benched func() []string
+
+	// IterationSize receives the number of results per iteration of the
+	// input with --loop, the statistics are then reported for each
+	// iteration. It may be nil.
+	IterationSize <-chan int
+
+	mu    sync.Mutex
+	stats *Stats // set by Display
+
+	iterationSize int
+	iterationBase iterationBase
+	iterations    []IterationStats
+}
+
+// maxStatusServers is the number of name servers listed in the status lines.
+const maxStatusServers = 10
+
+// NewReporter returns a new reporter which uses printer to display the results.
+func NewReporter(term cli.Terminal, printer ResultPrinter) *Reporter {
+	return &Reporter{term: term, printer: printer}
+}
+
+// Stats collects statistics about several responses.
+type Stats struct {
+	Start                   time.Time
+	Errors, Results         int
+	Mismatches              int
+	CacheHits               int
+	Empty, Delegated        int
+	A, AAAA, MX, CNAME, PTR *UniqueCounter
+
+	ShownResults int
+	Count        int
+	Paused       bool
+	Benched      []string
+
+	// Bytes is the total size of all responses, Largest is the size of the
+	// largest response, which was received for LargestName.
+	Bytes       int64
+	Largest     int
+	LargestName string
+
+	Servers map[string]*ServerStats
+
+	// Latency contains the round-trip times of answered requests by
+	// request type.
+	Latency map[string]*LatencyHistogram
+
+	// rps is the rate of results per second, smoothed with an
+	// exponentially weighted moving average, updated by updateRate
+	rps         float64
+	lastRPS     time.Time
+	lastResults int
+}
+
+// add records the result in the statistics.
+func (h *Stats) add(result resolve.Result) {
+	h.Results++
+	if !result.Hide {
+		h.ShownResults++
+	}
+
+	if result.Delegation() {
+		h.Delegated++
+	} else if result.Empty() {
+		h.Empty++
+	}
+
+	// the requests were not sent again, so only the answers are counted
+	if result.Cached {
+		h.CacheHits++
+	}
+
+	for _, request := range result.Requests {
+		h.addAnswers(request)
+		if result.Cached {
+			continue
+		}
+
+		if request.Error != nil {
+			h.Errors++
+		}
+		if len(request.Mismatches) > 0 {
+			h.Mismatches++
+		}
+		h.addServer(request)
+
+		if request.Error == nil {
+			hist, ok := h.Latency[request.Type]
+			if !ok {
+				hist = &LatencyHistogram{}
+				h.Latency[request.Type] = hist
+			}
+			hist.Add(request.RTT)
+		}
+
+		h.Bytes += int64(request.Size)
+		if request.Size > h.Largest {
+			h.Largest = request.Size
+			h.LargestName = request.Type + " " + result.Hostname
+		}
+	}
+}
+
+// refreshUnique updates the estimated numbers of unique answers.
+func (h *Stats) refreshUnique() {
+	for _, c := range []*UniqueCounter{h.A, h.AAAA, h.MX, h.CNAME, h.PTR} {
+		c.Refresh()
+	}
+}
+
+// addAnswers records the unique answers of request.
+func (h *Stats) addAnswers(request resolve.Request) {
+	for _, response := range request.Responses {
+		switch response.Type {
+		case "A":
+			h.A.Add(response.Data)
+		case "AAAA":
+			h.AAAA.Add(response.Data)
+		case "MX":
+			h.MX.Add(response.Data)
+		case "CNAME":
+			h.CNAME.Add(response.Data)
+		case "PTR":
+			h.PTR.Add(response.Data)
+		}
+	}
+}
+
+// StatsSnapshot contains the current statistics of a Reporter.
+type StatsSnapshot struct {
+	Start             time.Time      `json:"start"`
+	Results           int            `json:"results"`
+	ShownResults      int            `json:"shown_results"`
+	Total             int            `json:"total"`
+	Errors            int            `json:"errors"`
+	Mismatches        int            `json:"mismatches"`
+	CacheHits         int            `json:"cache_hits"`
+	Empty             int            `json:"empty"`
+	Delegated         int            `json:"delegated"`
+	ResponseBytes     int64          `json:"response_bytes"`
+	RequestsPerSecond float64        `json:"requests_per_second"`
+	Unique            map[string]int `json:"unique"`
+
+	Iterations []IterationStats `json:"iterations,omitempty"`
+}
+
+// Snapshot returns the current statistics, or nil if Display has not been
+// called yet.
+func (r *Reporter) Snapshot() *StatsSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := r.stats
+	if h == nil {
+		return nil
+	}
+
+	s := &StatsSnapshot{
+		Start:         h.Start,
+		Results:       h.Results,
+		ShownResults:  h.ShownResults,
+		Total:         h.Count,
+		Errors:        h.Errors,
+		Mismatches:    h.Mismatches,
+		CacheHits:     h.CacheHits,
+		Empty:         h.Empty,
+		Delegated:     h.Delegated,
+		ResponseBytes: h.Bytes,
+		Unique: map[string]int{
+			"A":     h.A.Count(),
+			"AAAA":  h.AAAA.Count(),
+			"MX":    h.MX.Count(),
+			"CNAME": h.CNAME.Count(),
+			"PTR":   h.PTR.Count(),
+		},
+		Iterations: append([]IterationStats(nil), r.iterations...),
+	}
+
+	if dur := time.Since(h.Start).Seconds(); dur > 0 {
+		s.RequestsPerSecond = float64(h.Results) / dur
+	}
+
+	return s
+}
+
+// ServerStats collects statistics about the requests sent to a name server.
+type ServerStats struct {
+	Requests int
+	Errors   int
+	RTT      time.Duration // sum of the round-trip times of all answered requests
+	Latency  LatencyHistogram
+}
+
+// addServer records the request in the statistics for its server.
+func (h *Stats) addServer(request resolve.Request) {
+	if request.Server == "" {
+		return
+	}
+
+	s, ok := h.Servers[request.Server]
+	if !ok {
+		s = &ServerStats{}
+		h.Servers[request.Server] = s
+	}
+
+	s.Requests++
+	if request.Error != nil {
+		s.Errors++
+		return
+	}
+	s.RTT += request.RTT
+	s.Latency.Add(request.RTT)
+}
+
+// LatencyReport returns the percentiles of the round-trip times by request
+// type and by name server.
+func (h *Stats) LatencyReport() (res []string) {
+	line := func(name string, width int, hist *LatencyHistogram) string {
+		return fmt.Sprintf("  %-*s %8d answers, p50 %9s, p90 %9s, p99 %9s", width, name, hist.Count(),
+			FormatLatency(hist.Percentile(50)), FormatLatency(hist.Percentile(90)), FormatLatency(hist.Percentile(99)))
+	}
+
+	if len(h.Latency) == 0 {
+		return nil
+	}
+
+	types := make([]string, 0, len(h.Latency))
+	for requestType := range h.Latency {
+		types = append(types, requestType)
+	}
+	sort.Strings(types)
+
+	res = append(res, "latency by request type:")
+	for _, requestType := range types {
+		res = append(res, line(requestType, 5, h.Latency[requestType]))
+	}
+
+	servers := make([]string, 0, len(h.Servers))
+	width := 0
+	for server, s := range h.Servers {
+		if s.Latency.Count() == 0 {
+			continue
+		}
+		servers = append(servers, server)
+		if len(server) > width {
+			width = len(server)
+		}
+	}
+	sort.Strings(servers)
+
+	if len(servers) > 0 {
+		res = append(res, "latency by name server:")
+	}
+	for _, server := range servers {
+		res = append(res, line(server, width, &h.Servers[server].Latency))
+	}
+
+	return res
+}
+
+// ServerReport returns one line per name server with the number of requests,
+// the error rate and the average latency. Nothing is returned unless more
+// than one server was used. If max is larger than zero, at most max servers
+// are listed.
+func (h *Stats) ServerReport(max int) (res []string) {
+	if len(h.Servers) < 2 {
+		return nil
+	}
+
+	servers := make([]string, 0, len(h.Servers))
+	width := 0
+	for server := range h.Servers {
+		servers = append(servers, server)
+		if len(server) > width {
+			width = len(server)
+		}
+	}
+	sort.Strings(servers)
+
+	res = append(res, "")
+	for i, server := range servers {
+		if max > 0 && i == max {
+			res = append(res, fmt.Sprintf("  ... and %d more servers", len(servers)-max))
+			break
+		}
+
+		s := h.Servers[server]
+		var latency string
+		if answered := s.Requests - s.Errors; answered > 0 {
+			latency = FormatLatency(s.RTT / time.Duration(answered))
+		}
+
+		res = append(res, fmt.Sprintf("  %-*s %8d requests, %5.1f%% errors, %9s avg latency",
+			width, server, s.Requests, float64(s.Errors)*100/float64(s.Requests), latency))
+	}
+
+	return res
+}
+
+// rateHalfLife is the time after which the rate measured in an interval only
+// has half of its weight in the smoothed rate.
+const rateHalfLife = 10 * time.Second
+
+// updateRate measures the rate of results since the last update, at most once
+// per second, and adds it to the smoothed rate. Recent intervals have a higher
+// weight, so the rate (and the remaining time derived from it) reacts to
+// slowdowns instead of showing the average of the whole run.
+func (h *Stats) updateRate(now time.Time) {
+	first := h.lastRPS.IsZero()
+	last := h.lastRPS
+	if first {
+		last = h.Start
+	}
+
+	dt := now.Sub(last)
+	if dt < time.Second {
+		return
+	}
+
+	rate := float64(h.Results-h.lastResults) / dt.Seconds()
+	if first {
+		h.rps = rate
+	} else {
+		weight := 1 - math.Pow(0.5, dt.Seconds()/rateHalfLife.Seconds())
+		h.rps += weight * (rate - h.rps)
+	}
+
+	h.lastRPS = now
+	h.lastResults = h.Results
+}
+
+func formatSeconds(secs float64) string {
+	sec := int(secs)
+	hours := sec / 3600
+	sec -= hours * 3600
+	min := sec / 60
+	sec -= min * 60
+
+	if hours > 0 {
+		return fmt.Sprintf("%dh%02dm%02ds", hours, min, sec)
+	}
+
+	return fmt.Sprintf("%dm%02ds", min, sec)
+}
+
+// progressBarWidth is the number of characters in the progress bar.
+const progressBarWidth = 40
+
+// Progress returns a progress bar with the percentage of requests done and
+// the remaining time, or the empty string if the total is unknown.
+func (h *Stats) Progress() string {
+	if h.Count <= 0 || h.Results > h.Count {
+		return ""
+	}
+
+	done := float64(h.Results) / float64(h.Count)
+	filled := int(done * progressBarWidth)
+
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+
+	res := fmt.Sprintf("[%s] %5.1f%%", bar, done*100)
+
+	todo := h.Count - h.Results
+	if todo > 0 && h.rps > 0 {
+		rem := float64(todo) / h.rps
+		res += fmt.Sprintf(", %s remaining", formatSeconds(rem))
+	}
+
+	return res
+}
+
+// Report returns a report about the received response codes.
+func (h *Stats) Report(current string) (res []string) {
+	res = append(res, "")
+	status := fmt.Sprintf("%v of %v requests shown", h.ShownResults, h.Results)
+	h.updateRate(time.Now())
+
+	if h.rps > 0 {
+		status += fmt.Sprintf(", %.0f req/s", h.rps)
+	}
+
+	todo := h.Count - h.Results
+	if todo > 0 {
+		status += fmt.Sprintf(", %d todo", todo)
+	}
+
+	if current != "" {
+		status += fmt.Sprintf(", current: %v", current)
+	}
+
+	if h.Paused {
+		status += ", paused"
+	}
+
+	res = append(res, status)
+
+	if len(h.Benched) > 0 {
+		res = append(res, fmt.Sprintf("benched:      %v", strings.Join(h.Benched, ", ")))
+	}
+
+	if h.Errors > 0 {
+		res = append(res, fmt.Sprintf("errors:       %v", h.Errors))
+	}
+
+	if h.Mismatches > 0 {
+		res = append(res, fmt.Sprintf("mismatches:   %v", h.Mismatches))
+	}
+
+	if h.CacheHits > 0 {
+		res = append(res, fmt.Sprintf("cache hits:   %v", h.CacheHits))
+	}
+
+	if h.A.Count() > 0 {
+		res = append(res, fmt.Sprintf("unique A:     %v", h.A))
+	}
+	if h.AAAA.Count() > 0 {
+		res = append(res, fmt.Sprintf("unique AAAA:  %v", h.AAAA))
+	}
+	if h.PTR.Count() > 0 {
+		res = append(res, fmt.Sprintf("unique PTR:   %v", h.PTR))
+	}
+	if h.MX.Count() > 0 {
+		res = append(res, fmt.Sprintf("unique MX:    %v", h.MX))
+	}
+	if h.CNAME.Count() > 0 {
+		res = append(res, fmt.Sprintf("unique CNAME: %v", h.CNAME))
+	}
+	if h.Empty > 0 {
+		res = append(res, fmt.Sprintf("empty:        %v", h.Empty))
+	}
+	if h.Delegated > 0 {
+		res = append(res, fmt.Sprintf("delegated:    %v", h.Delegated))
+	}
+	if h.Bytes > 0 {
+		res = append(res, fmt.Sprintf("received:     %s, largest response %d bytes (%s)", formatBytes(h.Bytes), h.Largest, h.LargestName))
+	}
+
+	return res
+}
+
+// formatBytes returns n with a binary unit, e.g. "1.5 KiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func ljust(s string, width int) string {
+	if len(s) < width {
+		return strings.Repeat(" ", width-len(s)) + s
+	}
+	return s
+}
+
+// Printer prints lines, e.g. to a terminal.
+type Printer interface {
+	Printf(string, ...interface{})
+}
+
+// resultFields returns the fields for log records of the output for a
+// result, the request type is only included if set.
+func resultFields(hostname, requestType string) map[string]string {
+	fields := map[string]string{"host": hostname}
+	if requestType != "" {
+		fields["type"] = requestType
+	}
+	return fields
+}
+
+func printResult(term Printer, p *TextPrinter, result resolve.Result) {
+	width := p.Width
+
+	// printLine prints a line for the result, the host name and the request
+	// type (if set) are added as fields to log records
+	printLine := func(requestType, msg string, data ...interface{}) {
+		cli.Logf(term, cli.LevelInfo, resultFields(result.Hostname, requestType), msg, data...)
+	}
+
+	// extraColumns returns the optional columns with the round-trip time,
+	// the confidence and the header flags, which are empty unless enabled
+	// in p
+	extraColumns := func(rtt, confidence, flags string) (s string) {
+		if p.ShowRTT {
+			s += fmt.Sprintf(" %9s", rtt)
+		}
+		if p.ShowConfidence {
+			s += fmt.Sprintf(" %6s", confidence)
+		}
+		if p.ShowFlags {
+			s += fmt.Sprintf(" %11s", flags)
+		}
+		return s
+	}
+
+	if result.Delegation() {
+		text := fmt.Sprintf("potential delegation, servers: %s", strings.Join(result.Nameservers(), ", "))
+		printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), text)
+
+		for _, soa := range result.SOAs() {
+			text := fmt.Sprintf("SOA: primary %s, admin %s, serial %d", soa.Mname, soa.Rname, soa.Serial)
+			printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), text)
+		}
+		return
+	}
+
+	if result.Empty() {
+		printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), "empty response, potential suffix")
+		return
+	}
+
+	lastCNAME := ""
+request_loop:
+	for _, request := range result.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for _, response := range request.Responses {
+			if response.Hide {
+				continue
+			}
+
+			if response.Type == "CNAME" {
+				// only display the first CNAME response unless the CNAME has changed
+				if response.Data == lastCNAME {
+					continue request_loop
+				}
+
+				lastCNAME = response.Data
+			}
+
+			data := response.Data
+			if owner := formatOwner(response); owner != "" {
+				data += " [" + owner + "]"
+			}
+			if len(response.OpenPorts) > 0 {
+				data += " [open ports: " + formatPorts(response.OpenPorts) + "]"
+			}
+			if len(result.Differing) > 0 {
+				// answers from several servers are displayed
+				data += " (" + request.Server + ")"
+			}
+			if result.DifferingSubnets {
+				data += " (" + formatClientSubnet(request) + ")"
+			}
+
+			printLine(request.Type, "%s %8v %8v %6v%s  %v\n",
+				ljust(result.Hostname, width),
+				request.Type,
+				response.Type,
+				response.TTL,
+				extraColumns(FormatLatency(request.RTT), result.Confidence, request.Flags.String()),
+				data,
+			)
+		}
+	}
+
+	for _, request := range result.Requests {
+		if request.Hide || (len(request.Chain) == 0 && request.ChainError == "") {
+			continue
+		}
+
+		text := "chain: " + formatChain(request.Chain)
+		if request.ChainError != "" {
+			text += " (" + request.ChainError + ")"
+		}
+		printLine(request.Type, "%s %8v %8s %6s%s  %s", ljust(result.Hostname, width), request.Type, "", "", extraColumns("", "", ""), text)
+	}
+
+	if len(result.Differing) > 0 {
+		text := fmt.Sprintf("answers differ between name servers: %s", strings.Join(result.Differing, ", "))
+		printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), text)
+	}
+
+	if result.Unverified {
+		printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), "answers not reproduced by the trusted resolver")
+	}
+
+	for _, probe := range result.HTTP {
+		if text := formatHTTPProbe(probe); text != "" {
+			printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), text)
+		}
+	}
+
+	if len(result.SANs) > 0 {
+		printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), "certificate names: "+strings.Join(result.SANs, ", "))
+	}
+
+	if len(result.Labels) > 0 {
+		printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), "labels: "+strings.Join(result.Labels, ", "))
+	}
+
+	if result.DifferingSubnets {
+		printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), "answers differ between client subnets")
+	}
+
+	if result.DNSSEC != "" {
+		text := "DNSSEC " + result.DNSSEC
+		if result.DNSSEC == "bogus" {
+			// make validation failures stand out
+			text = "DNSSEC BOGUS"
+		}
+		if result.DNSSECReason != "" {
+			text += ": " + result.DNSSECReason
+		}
+		printLine("", "%s %8s %8s %6s%s  %s", ljust(result.Hostname, width), "", "", "", extraColumns("", "", ""), text)
+	}
+}
+
+// formatHTTPProbe returns a description of the response of a web server, e.g.
+// `https://www.example.com/: 200 "Example" (nginx)`. Failed requests are not
+// described.
+func formatHTTPProbe(probe resolve.HTTPProbe) string {
+	if probe.Error != "" {
+		return ""
+	}
+
+	text := fmt.Sprintf("%s: %d", probe.URL, probe.Status)
+	if probe.Title != "" {
+		text += fmt.Sprintf(" %q", probe.Title)
+	}
+	if probe.Location != "" {
+		text += " -> " + probe.Location
+	}
+	if probe.Server != "" {
+		text += " (" + probe.Server + ")"
+	}
+	return text
+}
+
+// formatOwner returns the owner and the netname of the address in response,
+// e.g. "Google LLC, GOGL", or the empty string if they are unknown.
+func formatOwner(response resolve.Response) string {
+	switch {
+	case response.Owner != "" && response.Netname != "":
+		return response.Owner + ", " + response.Netname
+	case response.Owner != "":
+		return response.Owner
+	default:
+		return response.Netname
+	}
+}
+
+// formatPorts returns the list of ports, e.g. "22, 443".
+func formatPorts(ports []int) string {
+	list := make([]string, 0, len(ports))
+	for _, port := range ports {
+		list = append(list, strconv.Itoa(port))
+	}
+	return strings.Join(list, ", ")
+}
+
+// formatChain returns the targets of the CNAME records in chain followed by
+// the terminal records.
+func formatChain(chain []resolve.Response) string {
+	var hops, records []string
+	for _, response := range chain {
+		if response.Type == "CNAME" {
+			hops = append(hops, response.Data)
+			continue
+		}
+		records = append(records, response.Data)
+	}
+
+	if len(records) == 0 {
+		records = []string{"no records"}
+	}
+
+	return strings.Join(append(hops, strings.Join(records, ", ")), " -> ")
+}
+
+// formatClientSubnet returns the client subnet sent with the request and the
+// scope returned by the server.
+func formatClientSubnet(request resolve.Request) string {
+	if request.ClientSubnetScope < 0 {
+		return request.ClientSubnet + ", no scope"
+	}
+	return fmt.Sprintf("%v, scope /%d", request.ClientSubnet, request.ClientSubnetScope)
+}
+
+// Display shows incoming Results.
+func (r *Reporter) Display(ch <-chan resolve.Result, countChannel <-chan int) error {
+	r.printer.PrintHeader(r.term)
+
+	stats := &Stats{
+		Start: time.Now(),
+		A:     NewUniqueCounter(r.ExactStats),
+		AAAA:  NewUniqueCounter(r.ExactStats),
+		MX:    NewUniqueCounter(r.ExactStats),
+		CNAME: NewUniqueCounter(r.ExactStats),
+		PTR:   NewUniqueCounter(r.ExactStats),
+
+		Servers: make(map[string]*ServerStats),
+		Latency: make(map[string]*LatencyHistogram),
+	}
+
+	r.mu.Lock()
+	r.stats = stats
+	r.mu.Unlock()
+
+	var shown []resolve.Result
+	summary := NewSummary()
+
+	// update the status regularly, even if no new results arrive (e.g. when
+	// paused)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var current string
+	updateStatus := func() {
+		if r.Paused != nil {
+			stats.Paused = r.Paused()
+		}
+		if r.Benched != nil {
+			stats.Benched = r.Benched()
+		}
+
+		status := stats.Report(current)
+
+		// show the progress bar below the line with the number of requests
+		if bar := stats.Progress(); bar != "" {
+			status = append(status[:2], append([]string{bar}, status[2:]...)...)
+		}
+
+		r.term.SetStatus(append(status, stats.ServerReport(maxStatusServers)...))
+	}
+
+loop:
+	for {
+		var result resolve.Result
+		select {
+		case res, ok := <-ch:
+			if !ok {
+				break loop
+			}
+			result = res
+		case <-ticker.C:
+			r.mu.Lock()
+			stats.refreshUnique()
+			r.mu.Unlock()
+			updateStatus()
+			continue loop
+		}
+
+		r.mu.Lock()
+		select {
+		case c, ok := <-countChannel:
+			if ok {
+				stats.Count = c
+			} else {
+				// disable receiving on the closed channel
+				countChannel = nil
+			}
+		default:
+		}
+
+		select {
+		case n := <-r.IterationSize:
+			r.iterationSize = n
+		default:
+		}
+
+		stats.add(result)
+
+		var iteration *IterationStats
+		if r.iterationDone(stats) {
+			it := r.endIteration(stats)
+			iteration = &it
+		}
+		r.mu.Unlock()
+
+		if !result.Hide {
+			r.printer.PrintResult(r.term, result)
+
+			if r.Verbosity > 0 {
+				printRaw(r.term, r.Verbosity, result)
+			}
+
+			if r.SortBy != "" {
+				shown = append(shown, result)
+			}
+
+			if r.GroupSummary || r.Cluster {
+				summary.Add(result)
+			}
+		}
+
+		if iteration != nil && !r.Quiet {
+			r.term.Print(iteration.String())
+		}
+
+		current = result.Item
+		updateStatus()
+	}
+
+	// the last iteration may be incomplete when the run was stopped
+	r.mu.Lock()
+	stats.refreshUnique()
+	var iteration *IterationStats
+	if r.pendingIteration(stats) {
+		it := r.endIteration(stats)
+		iteration = &it
+	}
+	r.mu.Unlock()
+
+	if iteration != nil && !r.Quiet {
+		r.term.Print(iteration.String())
+	}
+
+	if r.SortBy != "" {
+		r.printSorted(shown)
+	}
+
+	if r.GroupSummary {
+		r.printGroups("host names by address", summary.Addresses)
+		r.printGroups("host names by CNAME target", summary.CNAMEs)
+		if len(summary.Labels) > 0 {
+			r.printGroups("host names by label", summary.Labels)
+		}
+		if len(summary.Owners) > 0 {
+			r.printGroups("host names by owner", summary.Owners)
+		}
+		if summary.Truncated {
+			r.term.Printf("\nthe groups are incomplete, at most %d keys and %d host names per key are kept", MaxSummaryKeys, MaxSummaryHostnames)
+		}
+	}
+
+	if r.Cluster {
+		PrintClusters(r.term, FindClusters(summary, 2))
+	}
+
+	if r.Quiet {
+		return nil
+	}
+
+	r.term.Print("\n")
+	r.term.Printf("resolved %d DNS requests in %v\n", stats.Results, formatSeconds(time.Since(stats.Start).Seconds()))
+
+	for _, line := range stats.Report("")[1:] {
+		r.term.Print(line)
+	}
+
+	if lines := stats.ServerReport(0); len(lines) > 0 {
+		r.term.Print("\n")
+		for _, line := range lines[1:] {
+			r.term.Print(line)
+		}
+	}
+
+	if lines := stats.LatencyReport(); len(lines) > 0 {
+		r.term.Print("\n")
+		for _, line := range lines {
+			r.term.Print(line)
+		}
+	}
+
+	return nil
+}
+
+// printSorted prints the results again, sorted by r.SortBy.
+func (r *Reporter) printSorted(results []resolve.Result) {
+	sortResults(results, r.SortBy)
+
+	if !r.Quiet {
+		r.term.Printf("\nshown results sorted by %v:\n\n", r.SortBy)
+		r.printer.PrintHeader(r.term)
+	}
+
+	for _, result := range results {
+		r.printer.PrintResult(r.term, result)
+	}
+}
+
+// printGroups prints the host names for each key, groups with the most host
+// names first.
+func (r *Reporter) printGroups(title string, groups map[string][]string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	r.term.Printf("\n%s:\n", title)
+	for _, key := range keysBySize(groups) {
+		hostnames := resolve.Unique(groups[key])
+		r.term.Printf("  %s (%d): %s", key, len(hostnames), strings.Join(hostnames, ", "))
+	}
+}
+
+// printRaw prints the raw sections of the DNS messages for all requests which
+// are not hidden.
+func printRaw(term Printer, verbosity int, result resolve.Result) {
+	var lines []string
+	section := func(name string, records []string) {
+		for _, record := range records {
+			lines = append(lines, fmt.Sprintf("    %-10s %s", name, record))
+		}
+	}
+
+	for _, request := range result.Requests {
+		if request.Hide {
+			continue
+		}
+
+		header := fmt.Sprintf("  %s %s", request.Type, request.Status)
+		if request.Server != "" {
+			header += " from " + request.Server
+		}
+		if request.Size > 0 {
+			header += fmt.Sprintf(", %d bytes", request.Size)
+		}
+		if flags := request.Flags.String(); flags != "" {
+			header += ", flags: " + flags
+		}
+		lines = append(lines, header+":")
+		if verbosity > 1 {
+			section("question", request.Raw.Question)
+		}
+		section("answer", request.Raw.Answer)
+		section("authority", request.Raw.Nameserver)
+		if verbosity > 1 {
+			section("extra", request.Raw.Extra)
+		}
+	}
+
+	if len(lines) > 0 {
+		cli.Logf(term, cli.LevelInfo, resultFields(result.Hostname, ""), "%s\n", strings.Join(lines, "\n"))
+	}
+}
@@ -0,0 +1,68 @@
+package report
+
+import (
+	"bytes"
+	"net"
+	"sort"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// SortOrders lists the valid values for --sort-results.
+var SortOrders = []string{"hostname", "ip"}
+
+// firstAddress returns the first address in the shown responses of the
+// result, or nil if there is none.
+func firstAddress(result resolve.Result) net.IP {
+	for _, request := range result.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for _, response := range request.Responses {
+			if response.Hide || (response.Type != "A" && response.Type != "AAAA") {
+				continue
+			}
+
+			if ip := net.ParseIP(response.Data); ip != nil {
+				return ip.To16()
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortResults sorts the results by host name or by the first address.
+// Results without an address are sorted after all others.
+func sortResults(results []resolve.Result, order string) {
+	switch order {
+	case "ip":
+		addrs := make(map[string]net.IP, len(results))
+		for _, res := range results {
+			addrs[res.Hostname] = firstAddress(res)
+		}
+
+		sort.SliceStable(results, func(i, j int) bool {
+			a, b := addrs[results[i].Hostname], addrs[results[j].Hostname]
+			switch {
+			case a == nil && b == nil:
+				return results[i].Hostname < results[j].Hostname
+			case a == nil:
+				return false
+			case b == nil:
+				return true
+			}
+
+			if c := bytes.Compare(a, b); c != 0 {
+				return c < 0
+			}
+			return results[i].Hostname < results[j].Hostname
+		})
+
+	default:
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Hostname < results[j].Hostname
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestStats returns statistics for a run which started at start.
+func newTestStats(start time.Time) *Stats {
+	return &Stats{
+		Start: start,
+		A:     NewUniqueCounter(true),
+		AAAA:  NewUniqueCounter(true),
+		MX:    NewUniqueCounter(true),
+		CNAME: NewUniqueCounter(true),
+		PTR:   NewUniqueCounter(true),
+
+		Servers: make(map[string]*ServerStats),
+		Latency: make(map[string]*LatencyHistogram),
+	}
+}
+
+func TestStatsUpdateRate(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	stats := newTestStats(start)
+
+	var tests = []struct {
+		elapsed time.Duration
+		results int
+		want    float64
+	}{
+		// not measured before the first second has passed
+		{500 * time.Millisecond, 50, 0},
+		// the first interval is used as it is
+		{2 * time.Second, 200, 100},
+		// after the half-life, the new rate has half of the weight
+		{12 * time.Second, 400, 60},
+		// at most one update per second
+		{12500 * time.Millisecond, 1000, 60},
+		{22 * time.Second, 1400, 80},
+		// stalled
+		{32 * time.Second, 1400, 40},
+		{42 * time.Second, 1400, 20},
+	}
+
+	for _, test := range tests {
+		stats.Results = test.results
+		stats.updateRate(start.Add(test.elapsed))
+
+		if math.Abs(stats.rps-test.want) > 0.001 {
+			t.Errorf("after %v: wrong rate, want %v, got %v", test.elapsed, test.want, stats.rps)
+		}
+	}
+}
+
+func TestStatsReport(t *testing.T) {
+	stats := newTestStats(time.Now().Add(-2 * time.Second))
+	stats.Count = 1000
+	stats.Results = 200
+	stats.ShownResults = 20
+	stats.Errors = 3
+	stats.Paused = true
+
+	want := []string{
+		"",
+		"20 of 200 requests shown, 100 req/s, 800 todo, current: www, paused",
+		"errors:       3",
+	}
+
+	got := stats.Report("www")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong report, want:\n%q\ngot:\n%q", want, got)
+	}
+}
+
+func TestStatsProgress(t *testing.T) {
+	var tests = []struct {
+		count, results int
+		rps            float64
+		want           string
+	}{
+		// unknown total
+		{0, 10, 0, ""},
+		// the total was too low (e.g. --loop)
+		{10, 20, 0, ""},
+		{100, 0, 0, "[>                                       ]   0.0%"},
+		{100, 50, 10, "[====================>                   ]  50.0%, 0m05s remaining"},
+		{80000, 20000, 5, "[==========>                             ]  25.0%, 3h20m00s remaining"},
+		{3, 1, 0, "[=============>                          ]  33.3%"},
+		{100, 100, 10, "[========================================] 100.0%"},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			stats := newTestStats(time.Now())
+			stats.Count = test.count
+			stats.Results = test.results
+			stats.rps = test.rps
+
+			got := stats.Progress()
+			if got != test.want {
+				t.Errorf("wrong progress bar, want:\n%q\ngot:\n%q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatSeconds(t *testing.T) {
+	var tests = []struct {
+		secs float64
+		want string
+	}{
+		{0, "0m00s"},
+		{59.9, "0m59s"},
+		{61, "1m01s"},
+		{3600, "1h00m00s"},
+		{100000, "27h46m40s"},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			got := formatSeconds(test.secs)
+			if got != test.want {
+				t.Errorf("wrong duration, want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
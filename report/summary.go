@@ -0,0 +1,128 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// Limits for the groups in a Summary, so that the memory used for large runs
+// (e.g. reverse sweeps) is bounded.
+const (
+	// MaxSummaryKeys is the number of keys (e.g. addresses) in each group.
+	MaxSummaryKeys = 100000
+
+	// MaxSummaryHostnames is the number of host names recorded per key.
+	MaxSummaryHostnames = 1000
+)
+
+// Summary groups shown results by the data they resolved to. The number of
+// keys and host names in the groups is limited, Truncated is set when data
+// was dropped because of this.
+type Summary struct {
+	Results   int
+	Truncated bool
+
+	Addresses   map[string][]string // address -> host names
+	CNAMEs      map[string][]string // CNAME target -> host names
+	Delegations map[string][]string // host name -> name servers
+	Labels      map[string][]string // label -> host names
+	Owners      map[string][]string // owner of the address (RDAP) -> host names
+}
+
+// NewSummary returns a new, empty summary.
+func NewSummary() *Summary {
+	return &Summary{
+		Addresses:   make(map[string][]string),
+		CNAMEs:      make(map[string][]string),
+		Delegations: make(map[string][]string),
+		Labels:      make(map[string][]string),
+		Owners:      make(map[string][]string),
+	}
+}
+
+// Add records the responses of res which are not hidden.
+func (s *Summary) Add(res resolve.Result) {
+	if res.Hide {
+		return
+	}
+
+	if res.Delegation() {
+		s.Results++
+		s.addLabels(res)
+		if _, ok := s.Delegations[res.Hostname]; !ok && len(s.Delegations) >= MaxSummaryKeys {
+			s.Truncated = true
+			return
+		}
+		s.Delegations[res.Hostname] = res.Nameservers()
+		return
+	}
+
+	if !res.Resolved() {
+		return
+	}
+
+	s.Results++
+	s.addLabels(res)
+
+	for _, request := range res.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for _, response := range request.Responses {
+			if response.Hide {
+				continue
+			}
+
+			switch response.Type {
+			case "A", "AAAA":
+				s.add(s.Addresses, response.Data, res.Hostname)
+				if owner := formatOwner(response); owner != "" {
+					s.add(s.Owners, owner, res.Hostname)
+				}
+			case "CNAME":
+				s.add(s.CNAMEs, response.Data, res.Hostname)
+			}
+		}
+	}
+}
+
+// addLabels records the host name of res for its labels.
+func (s *Summary) addLabels(res resolve.Result) {
+	for _, label := range res.Labels {
+		s.add(s.Labels, label, res.Hostname)
+	}
+}
+
+// add records hostname for key in the group m, within the limits.
+func (s *Summary) add(m map[string][]string, key, hostname string) {
+	list, ok := m[key]
+	if !ok && len(m) >= MaxSummaryKeys {
+		s.Truncated = true
+		return
+	}
+
+	for _, entry := range list {
+		if entry == hostname {
+			return
+		}
+	}
+
+	if len(list) >= MaxSummaryHostnames {
+		s.Truncated = true
+		return
+	}
+
+	m[key] = append(list, hostname)
+}
+
+// keysBySize returns the keys of m sorted by the number of values
+// (descending), then by key.
+func keysBySize(m map[string][]string) []string {
+	keys := resolve.SortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return len(m[keys[i]]) > len(m[keys[j]])
+	})
+	return keys
+}
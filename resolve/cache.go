@@ -0,0 +1,120 @@
+package resolve
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// cacheEntry contains the result for a host name, done is closed when it is
+// available.
+type cacheEntry struct {
+	done   chan struct{}
+	result Result
+	ok     bool          // false if the lookup was cancelled
+	elem   *list.Element // position of the key in the order of the cache
+}
+
+// ResponseCache keeps the results for the host names requested in a run, so
+// host names which are produced more than once (e.g. by overlapping
+// wordlists) are not requested again. At most size host names are kept, the
+// oldest are removed first.
+type ResponseCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	order   *list.List // keys of the entries, oldest first
+	hits    int
+}
+
+// NewResponseCache returns a new cache for size host names.
+func NewResponseCache(size int) *ResponseCache {
+	return &ResponseCache{
+		size:    size,
+		entries: make(map[string]*cacheEntry),
+		order:   list.New(),
+	}
+}
+
+// Hits returns the number of results served from the cache.
+func (c *ResponseCache) Hits() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits
+}
+
+// lookup returns the result for name by calling fn, unless the result is in
+// the cache. If another lookup for name is in progress, it waits for the
+// result.
+func (c *ResponseCache) lookup(name string, fn func() (Result, bool)) (Result, bool) {
+	key := strings.ToLower(name)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{done: make(chan struct{})}
+		entry.elem = c.order.PushBack(key)
+		c.entries[key] = entry
+		c.evict()
+	}
+	c.mu.Unlock()
+
+	if ok {
+		<-entry.done
+		if entry.ok {
+			c.mu.Lock()
+			c.hits++
+			c.mu.Unlock()
+
+			res := entry.result.clone()
+			res.Cached = true
+			return res, true
+		}
+
+		// the lookup was cancelled, so the context is done, too
+		return fn()
+	}
+
+	res, sent := fn()
+	if sent {
+		entry.result = res.clone()
+		entry.ok = true
+	} else {
+		// do not keep the incomplete result
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+			c.order.Remove(entry.elem)
+		}
+		c.mu.Unlock()
+	}
+	close(entry.done)
+
+	return res, sent
+}
+
+// evict removes the oldest entries until at most size are left.
+func (c *ResponseCache) evict() {
+	for c.order.Len() > c.size {
+		key := c.order.Remove(c.order.Front()).(string)
+		delete(c.entries, key)
+	}
+}
+
+// clone returns a copy of r which does not share the lists of requests and
+// responses, which may be modified by filters.
+func (r Result) clone() Result {
+	requests := make([]Request, 0, len(r.Requests))
+	for _, req := range r.Requests {
+		req.Responses = append([]Response(nil), req.Responses...)
+		req.Nameserver = append([]Response(nil), req.Nameserver...)
+		req.SOA = append([]Response(nil), req.SOA...)
+		req.Chain = append([]Response(nil), req.Chain...)
+		requests = append(requests, req)
+	}
+	r.Requests = requests
+
+	return r
+}
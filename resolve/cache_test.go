@@ -0,0 +1,39 @@
+package resolve
+
+import "testing"
+
+func TestResponseCacheReAdd(t *testing.T) {
+	c := NewResponseCache(2)
+
+	lookup := func(name string, sent bool) bool {
+		var called bool
+		_, _ = c.lookup(name, func() (Result, bool) {
+			called = true
+			return Result{Hostname: name}, sent
+		})
+		return called
+	}
+
+	// the first lookup for www is cancelled, so the entry is removed again
+	lookup("www.example.com", false)
+	lookup("www.example.com", true)
+	lookup("mail.example.com", true)
+
+	if lookup("www.example.com", true) {
+		t.Errorf("result for www.example.com was removed from the cache")
+	}
+
+	if lookup("mail.example.com", true) {
+		t.Errorf("result for mail.example.com was removed from the cache")
+	}
+
+	// the oldest entry is removed when a third name is added
+	lookup("ftp.example.com", true)
+	if !lookup("www.example.com", true) {
+		t.Errorf("result for www.example.com is still in the cache")
+	}
+
+	if c.order.Len() != len(c.entries) {
+		t.Errorf("order has %d keys for %d entries", c.order.Len(), len(c.entries))
+	}
+}
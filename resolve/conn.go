@@ -0,0 +1,79 @@
+package resolve
+
+import (
+	"net"
+)
+
+// countingConn records the size of the last DNS message read from the
+// connection.
+type countingConn struct {
+	net.Conn
+
+	// read is the number of bytes read for the last message, for TCP this
+	// includes the two bytes of the length prefix
+	read int
+}
+
+// Read reads from the connection. For TCP, the message is read in several
+// calls, so the sizes are added up.
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read += n
+	return n, err
+}
+
+// size returns the size of the last message read.
+func (c *countingConn) size() int {
+	// the length prefix is not part of the message
+	if c.read < 2 {
+		return 0
+	}
+	return c.read - 2
+}
+
+// countingPacketConn is a countingConn for UDP, each read returns a complete
+// message. It implements net.PacketConn, so the dns package treats it as a
+// packet connection.
+type countingPacketConn struct {
+	countingConn
+	pc net.PacketConn
+}
+
+// Read reads one message, responses with a mismatched ID are ignored by the
+// dns package, so only the size of the last message is kept.
+func (c *countingPacketConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read = n
+	return n, err
+}
+
+// ReadFrom reads one message like Read.
+func (c *countingPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.pc.ReadFrom(p)
+	c.read = n
+	return n, addr, err
+}
+
+// WriteTo writes p to addr.
+func (c *countingPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	return c.pc.WriteTo(p, addr)
+}
+
+// size returns the size of the last message read.
+func (c *countingPacketConn) size() int {
+	return c.read
+}
+
+// sizeConn is a connection which records the size of the last message read.
+type sizeConn interface {
+	net.Conn
+	size() int
+}
+
+// newSizeConn wraps conn so the size of the messages read is recorded.
+func newSizeConn(conn net.Conn) sizeConn {
+	if pc, ok := conn.(net.PacketConn); ok {
+		return &countingPacketConn{countingConn: countingConn{Conn: conn}, pc: pc}
+	}
+	return &countingConn{Conn: conn}
+}
@@ -0,0 +1,4 @@
+// Package resolve sends DNS requests for host names built from a template and
+// collects the responses as Results. Resolvers read values from a channel and
+// send the results to another channel, a Pool runs many of them in parallel.
+package resolve
@@ -0,0 +1,180 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// IsJSONServer returns true if server is the URL of a resolver with a JSON API
+// (application/dns-json), e.g. https://dns.google/resolve or
+// https://cloudflare-dns.com/dns-query.
+func IsJSONServer(server string) bool {
+	return strings.HasPrefix(server, "https://")
+}
+
+// jsonTimeout is the time to wait for a response from a JSON API, it is longer
+// than for plain DNS because the request may pass a proxy.
+const jsonTimeout = 10 * time.Second
+
+// jsonClient sends the requests to the JSON APIs, the proxy is taken from
+// the environment.
+var jsonClient = &http.Client{Timeout: jsonTimeout}
+
+// jsonResponse is the response of a JSON API.
+type jsonResponse struct {
+	Status           int
+	TC, RD, RA, AD   bool
+	CD               bool
+	Answer           []jsonRecord
+	Authority        []jsonRecord
+	Additional       []jsonRecord
+	EDNSClientSubnet string `json:"edns_client_subnet"`
+}
+
+// jsonRecord is a resource record in a response of a JSON API.
+type jsonRecord struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+// toRR converts the record to a dns.RR.
+func (rec jsonRecord) toRR() (dns.RR, error) {
+	data := rec.Data
+	if rec.Type == dns.TypeTXT && !strings.HasPrefix(data, `"`) {
+		data = strconv.Quote(data)
+	}
+
+	return dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(rec.Name), rec.TTL, dns.TypeToString[rec.Type], data))
+}
+
+// toRRs converts the records to a list of dns.RR.
+func toRRs(records []jsonRecord) (list []dns.RR, err error) {
+	for _, rec := range records {
+		// skip types the dns package does not know
+		if _, ok := dns.TypeToString[rec.Type]; !ok {
+			continue
+		}
+
+		rr, err := rec.toRR()
+		if err != nil {
+			return nil, fmt.Errorf("invalid record %q: %v", rec.Data, err)
+		}
+		list = append(list, rr)
+	}
+	return list, nil
+}
+
+// exchangeJSON sends the question in m to the JSON API at server and returns
+// the answer converted to a DNS message.
+func exchangeJSON(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q := u.Query()
+	q.Set("name", m.Question[0].Name)
+	q.Set("type", dns.TypeToString[m.Question[0].Qtype])
+	if edns := m.IsEdns0(); edns != nil {
+		for _, opt := range edns.Option {
+			if subnet, ok := opt.(*dns.EDNS0_SUBNET); ok {
+				q.Set("edns_client_subnet", fmt.Sprintf("%v/%d", subnet.Address, subnet.SourceNetmask))
+			}
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/dns-json")
+
+	start := time.Now()
+	res, err := jsonClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, time.Since(start), fmt.Errorf("unexpected HTTP status %v", res.Status)
+	}
+
+	var response jsonResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, fmt.Errorf("invalid response: %v", err)
+	}
+
+	msg := &dns.Msg{}
+	msg.SetRcode(m, response.Status)
+	msg.Truncated = response.TC
+	msg.RecursionDesired = response.RD
+	msg.RecursionAvailable = response.RA
+	msg.AuthenticatedData = response.AD
+	msg.CheckingDisabled = response.CD
+
+	for _, section := range []struct {
+		records []jsonRecord
+		target  *[]dns.RR
+	}{
+		{response.Answer, &msg.Answer},
+		{response.Authority, &msg.Ns},
+		{response.Additional, &msg.Extra},
+	} {
+		*section.target, err = toRRs(section.records)
+		if err != nil {
+			return nil, rtt, err
+		}
+	}
+
+	if subnet := jsonClientSubnet(response.EDNSClientSubnet); subnet != nil {
+		msg.SetEdns0(dns.DefaultMsgSize, false)
+		edns := msg.IsEdns0()
+		edns.Option = append(edns.Option, subnet)
+	}
+
+	return msg, rtt, nil
+}
+
+// jsonClientSubnet parses the client subnet in a response of a JSON API
+// ("address/scope"), it returns nil if s is invalid.
+func jsonClientSubnet(s string) *dns.EDNS0_SUBNET {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return nil
+	}
+
+	ip := net.ParseIP(parts[0])
+	scope, err := strconv.Atoi(parts[1])
+	if ip == nil || err != nil {
+		return nil
+	}
+
+	subnet := &dns.EDNS0_SUBNET{
+		Code:        dns.EDNS0SUBNET,
+		SourceScope: uint8(scope),
+		Family:      2,
+		Address:     ip,
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		subnet.Family = 1
+		subnet.Address = ip4
+	}
+
+	return subnet
+}
@@ -0,0 +1,64 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryJSON(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/dns-json" {
+			http.Error(w, "wrong accept header", http.StatusBadRequest)
+			return
+		}
+
+		name, qtype := r.URL.Query().Get("name"), r.URL.Query().Get("type")
+		if name != "www.example.com." || qtype != "A" {
+			fmt.Fprint(w, `{"Status": 3, "RA": true}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"Status": 0, "RD": true, "RA": true, "AD": false,
+			"Question": [{"name": "www.example.com.", "type": 1}],
+			"Answer": [{"name": "www.example.com.", "type": 1, "TTL": 300, "data": "192.0.2.1"}],
+			"edns_client_subnet": "198.51.100.0/24"}`)
+	}))
+	defer srv.Close()
+
+	client := jsonClient
+	jsonClient = srv.Client()
+	defer func() { jsonClient = client }()
+
+	req := QueryWith(context.Background(), "www.example.com.", "A", srv.URL+"/resolve", QueryOptions{})
+	if req.Error != nil {
+		t.Fatal(req.Error)
+	}
+
+	if len(req.Responses) != 1 || req.Responses[0].Data != "192.0.2.1" || req.Responses[0].TTL != 300 {
+		t.Errorf("wrong responses: %v", req.Responses)
+	}
+
+	if !req.Flags.RecursionAvailable {
+		t.Errorf("flags are missing: %v", req.Flags)
+	}
+
+	if req.ClientSubnetScope != 24 {
+		t.Errorf("wrong client subnet scope, want 24, got %v", req.ClientSubnetScope)
+	}
+
+	if len(req.Mismatches) > 0 {
+		t.Errorf("unexpected mismatches: %v", req.Mismatches)
+	}
+
+	req = QueryWith(context.Background(), "foo.example.com.", "A", srv.URL+"/resolve", QueryOptions{})
+	if req.Error != nil {
+		t.Fatal(req.Error)
+	}
+
+	if !req.NotFound {
+		t.Errorf("wrong status, want NXDOMAIN, got %v", req.Status)
+	}
+}
@@ -0,0 +1,205 @@
+package resolve
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Parameters for detecting name servers which throttle requests.
+const (
+	healthWindow    = 20  // number of recent requests considered
+	healthThreshold = 0.5 // bench a server if this fraction of requests is refused
+	minBenchTime    = 10 * time.Second
+	maxBenchTime    = 5 * time.Minute
+)
+
+// Health tracks the responses of the name servers and benches servers which
+// start to refuse requests, which is typical for rate limiting. While a
+// server is benched, requests are sent to the other servers. A server which
+// is benched again right after it returned is benched for twice as long.
+type Health struct {
+	// OnChange is called when a server is benched or returns, it may be nil.
+	OnChange func(server string, benched bool, reason string)
+
+	// benched is the number of servers benched at the moment, it is
+	// accessed atomically so Pick does not need the lock while all servers
+	// are fine
+	benched int32
+
+	mu         sync.Mutex
+	servers    []string
+	state      map[string]*serverHealth
+	next       int
+	nextReturn time.Time // the earliest time a benched server returns
+}
+
+// serverHealth contains the recent requests for a server.
+type serverHealth struct {
+	recent   [healthWindow]bool // set for failed requests
+	pos, n   int
+	failures int
+
+	benched bool
+	until   time.Time
+	penalty time.Duration
+}
+
+// NewHealth returns a new Health for the servers.
+func NewHealth(servers []string) *Health {
+	h := &Health{
+		servers: servers,
+		state:   make(map[string]*serverHealth, len(servers)),
+	}
+
+	for _, server := range servers {
+		h.state[server] = &serverHealth{penalty: minBenchTime}
+	}
+
+	return h
+}
+
+// throttled returns true if the response to req indicates that the server
+// throttles requests. SERVFAIL is not counted: it is mostly caused by the
+// name servers of the target zone (e.g. broken delegations), so it would
+// bench all servers at once.
+func throttled(req Request) bool {
+	return req.Error == nil && req.Status == "REFUSED"
+}
+
+// Record records the response to req, which was sent to server.
+func (h *Health) Record(server string, req Request) {
+	h.mu.Lock()
+
+	s, ok := h.state[server]
+	if !ok || s.benched {
+		h.mu.Unlock()
+		return
+	}
+
+	failed := throttled(req)
+	if s.n == healthWindow && s.recent[s.pos] {
+		s.failures--
+	}
+	s.recent[s.pos] = failed
+	if failed {
+		s.failures++
+	}
+	s.pos = (s.pos + 1) % healthWindow
+	if s.n < healthWindow {
+		s.n++
+	}
+
+	// a full window without failures resets the penalty
+	if s.n == healthWindow && s.failures == 0 {
+		s.penalty = minBenchTime
+	}
+
+	if s.n < healthWindow || float64(s.failures) < healthThreshold*healthWindow {
+		h.mu.Unlock()
+		return
+	}
+
+	reason := fmt.Sprintf("%d of the last %d requests were refused, benched for %v", s.failures, s.n, s.penalty)
+	s.benched = true
+	s.until = time.Now().Add(s.penalty)
+	if atomic.AddInt32(&h.benched, 1) == 1 || s.until.Before(h.nextReturn) {
+		h.nextReturn = s.until
+	}
+	s.penalty *= 2
+	if s.penalty > maxBenchTime {
+		s.penalty = maxBenchTime
+	}
+	s.recent = [healthWindow]bool{}
+	s.pos, s.n, s.failures = 0, 0, 0
+	h.mu.Unlock()
+
+	if h.OnChange != nil {
+		h.OnChange(server, true, reason)
+	}
+}
+
+// release marks the benched servers as available again whose time is up and
+// returns them. It must be called with the lock held.
+func (h *Health) release(now time.Time) (returned []string) {
+	var next time.Time
+	for _, name := range h.servers {
+		s := h.state[name]
+		if !s.benched {
+			continue
+		}
+
+		if now.After(s.until) {
+			s.benched = false
+			atomic.AddInt32(&h.benched, -1)
+			returned = append(returned, name)
+			continue
+		}
+
+		if next.IsZero() || s.until.Before(next) {
+			next = s.until
+		}
+	}
+	h.nextReturn = next
+
+	return returned
+}
+
+// Pick returns the server to send a request to instead of server: server
+// itself unless it is benched, otherwise the next server which is not. If all
+// servers are benched, server is returned with the duration to wait until it
+// returns.
+func (h *Health) Pick(server string) (string, time.Duration) {
+	if atomic.LoadInt32(&h.benched) == 0 {
+		return server, 0
+	}
+
+	h.mu.Lock()
+
+	var returned []string
+	now := time.Now()
+	if now.After(h.nextReturn) {
+		returned = h.release(now)
+	}
+
+	pick, wait := server, time.Duration(0)
+	if s, ok := h.state[server]; ok && s.benched {
+		wait = s.until.Sub(now)
+		for i := range h.servers {
+			name := h.servers[(h.next+i)%len(h.servers)]
+			if !h.state[name].benched {
+				pick, wait = name, 0
+				h.next = (h.next + i + 1) % len(h.servers)
+				break
+			}
+		}
+	}
+
+	h.mu.Unlock()
+
+	if h.OnChange != nil {
+		for _, name := range returned {
+			h.OnChange(name, false, "")
+		}
+	}
+
+	return pick, wait
+}
+
+// Benched returns the sorted list of servers which are benched at the moment.
+func (h *Health) Benched() (servers []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for name, s := range h.state {
+		if s.benched && now.Before(s.until) {
+			servers = append(servers, name)
+		}
+	}
+	sort.Strings(servers)
+
+	return servers
+}
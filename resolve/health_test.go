@@ -0,0 +1,97 @@
+package resolve
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	h := NewHealth([]string{"a", "b"})
+
+	var events []string
+	h.OnChange = func(server string, benched bool, reason string) {
+		if benched {
+			events = append(events, server)
+		}
+	}
+
+	// occasional failures are fine
+	for i := 0; i < 3*healthWindow; i++ {
+		status := "NOERROR"
+		if i%4 == 0 {
+			status = "REFUSED"
+		}
+		h.Record("a", Request{Status: status})
+	}
+
+	// SERVFAIL is caused by the target zone, not by the server
+	for i := 0; i < 2*healthWindow; i++ {
+		h.Record("a", Request{Status: "SERVFAIL"})
+	}
+
+	if benched := h.Benched(); len(benched) != 0 {
+		t.Fatalf("servers benched unexpectedly: %v", benched)
+	}
+
+	for i := 0; i < healthWindow; i++ {
+		h.Record("a", Request{Status: "REFUSED"})
+	}
+
+	if benched := h.Benched(); !equal(benched, []string{"a"}) || !equal(events, []string{"a"}) {
+		t.Fatalf("server not benched, benched %v, events %v", benched, events)
+	}
+
+	if server, wait := h.Pick("a"); server != "b" || wait != 0 {
+		t.Errorf("wrong server picked, want b, got %v (wait %v)", server, wait)
+	}
+
+	if server, wait := h.Pick("b"); server != "b" || wait != 0 {
+		t.Errorf("wrong server picked, want b, got %v (wait %v)", server, wait)
+	}
+
+	for i := 0; i < healthWindow; i++ {
+		h.Record("b", Request{Status: "REFUSED"})
+	}
+
+	// all servers are benched, so the request needs to wait
+	if server, wait := h.Pick("a"); server != "a" || wait <= 0 {
+		t.Errorf("wrong server picked, want a with a wait time, got %v (wait %v)", server, wait)
+	}
+}
+
+func TestHealthReturn(t *testing.T) {
+	h := NewHealth([]string{"a", "b"})
+
+	var events []string
+	h.OnChange = func(server string, benched bool, reason string) {
+		events = append(events, fmt.Sprintf("%v:%v", server, benched))
+	}
+
+	for i := 0; i < healthWindow; i++ {
+		h.Record("a", Request{Status: "REFUSED"})
+	}
+
+	if server, _ := h.Pick("a"); server != "b" {
+		t.Fatalf("wrong server picked, want b, got %v", server)
+	}
+
+	// let the server return
+	h.mu.Lock()
+	h.state["a"].until = time.Now().Add(-time.Second)
+	h.nextReturn = h.state["a"].until
+	h.mu.Unlock()
+
+	if server, wait := h.Pick("a"); server != "a" || wait != 0 {
+		t.Errorf("wrong server picked, want a, got %v (wait %v)", server, wait)
+	}
+
+	if h.benched != 0 {
+		t.Errorf("wrong number of benched servers, want 0, got %d", h.benched)
+	}
+
+	want := []string{"a:true", "a:false"}
+	if !equal(events, want) {
+		t.Errorf("wrong events, want %v, got %v", want, events)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build !windows
+// +build !windows
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+// resolvConf is the file containing the resolver configuration.
+const resolvConf = "/etc/resolv.conf"
+
+// ReadSystemConfig returns the name servers and search domains configured in
+// /etc/resolv.conf. If the file does not list any name servers, the one the
+// Go resolver uses is returned.
+func ReadSystemConfig() (*SystemConfig, error) {
+	cfg := &SystemConfig{Ndots: 1}
+
+	f, err := os.Open(resolvConf)
+	if err == nil {
+		cfg, err = ParseResolvConf(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parsing %v failed: %v", resolvConf, err)
+		}
+	}
+
+	if len(cfg.Nameservers) == 0 {
+		server, err := FindSystemNameserver()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Nameservers = []string{server}
+	}
+
+	return cfg, nil
+}
+
+// FindSystemNameserver returns a name server configured for the system.
+func FindSystemNameserver() (string, error) {
+	var nameserver string
+	var once sync.Once
+	wantError := errors.New("findSystemResolver")
+
+	resolver := &net.Resolver{
+		// do not use the cgo resolver so we can get the IP address of the default nameserver
+		PreferGo: true,
+
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, fmt.Errorf("unable to find system nameserver, split failed: %v", err)
+			}
+			once.Do(func() {
+				nameserver = host
+			})
+			return nil, wantError
+		},
+	}
+
+	_, err := resolver.LookupHost(context.Background(), "example.com")
+	if dnsError, ok := err.(*net.DNSError); ok {
+		if dnsError.Err == wantError.Error() {
+			return nameserver, nil
+		}
+	}
+
+	return "", errors.New("unable to find system nameserver, please specify a server manually")
+}
@@ -0,0 +1,122 @@
+//go:build windows
+// +build windows
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// adapters returns the network adapters which are up, except for loopback
+// adapters.
+func adapters() ([]*windows.IpAdapterAddresses, error) {
+	size := uint32(15000)
+	var buf []byte
+	for {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, 0, 0,
+			(*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+		if err == nil {
+			break
+		}
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			return nil, err
+		}
+	}
+
+	var list []*windows.IpAdapterAddresses
+	for adapter := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); adapter != nil; adapter = adapter.Next {
+		if adapter.OperStatus != windows.IfOperStatusUp || adapter.IfType == windows.IF_TYPE_SOFTWARE_LOOPBACK {
+			continue
+		}
+		list = append(list, adapter)
+	}
+
+	return list, nil
+}
+
+// ReadSystemConfig returns the name servers and DNS suffixes configured for
+// the network adapters, which are queried via GetAdaptersAddresses because
+// the Go resolver does not use the system configuration on Windows.
+func ReadSystemConfig() (*SystemConfig, error) {
+	list, err := adapters()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &SystemConfig{Ndots: 1}
+	for _, adapter := range list {
+		for server := adapter.FirstDnsServerAddress; server != nil; server = server.Next {
+			ip := server.Address.IP()
+			if ip == nil || siteLocalDefault(ip) {
+				continue
+			}
+			cfg.Nameservers = append(cfg.Nameservers, serverAddress(ip, &server.Address))
+		}
+
+		if adapter.DnsSuffix != nil {
+			if suffix := windows.UTF16PtrToString(adapter.DnsSuffix); suffix != "" {
+				cfg.Search = append(cfg.Search, suffix)
+			}
+		}
+	}
+
+	cfg.Nameservers = uniqueInOrder(cfg.Nameservers)
+	cfg.Search = uniqueInOrder(cfg.Search)
+
+	if len(cfg.Nameservers) == 0 {
+		return nil, errors.New("unable to find system nameserver, please specify a server manually")
+	}
+
+	return cfg, nil
+}
+
+// FindSystemNameserver returns a name server configured for the system.
+func FindSystemNameserver() (string, error) {
+	cfg, err := ReadSystemConfig()
+	if err != nil {
+		return "", err
+	}
+	return cfg.Nameservers[0], nil
+}
+
+// siteLocalDefault returns true for the deprecated site-local addresses
+// (fec0:0:0:ffff::1-3) Windows lists for adapters without IPv6 name servers.
+func siteLocalDefault(ip net.IP) bool {
+	_, network, _ := net.ParseCIDR("fec0:0:0:ffff::/64")
+	return ip.To4() == nil && network.Contains(ip)
+}
+
+// serverAddress returns the name server address ip read from addr. Link-local
+// IPv6 addresses keep the zone index (e.g. fe80::1%12), without it the
+// server cannot be reached.
+func serverAddress(ip net.IP, addr *windows.SocketAddress) string {
+	if ip.To4() != nil || !ip.IsLinkLocalUnicast() {
+		return ip.String()
+	}
+
+	sa := (*windows.RawSockaddrInet6)(unsafe.Pointer(addr.Sockaddr))
+	if sa.Scope_id == 0 {
+		return ip.String()
+	}
+
+	return ip.String() + "%" + strconv.FormatUint(uint64(sa.Scope_id), 10)
+}
+
+// uniqueInOrder removes duplicate entries from list, keeping the order.
+func uniqueInOrder(list []string) (cleaned []string) {
+	known := make(map[string]struct{})
+	for _, entry := range list {
+		if _, ok := known[entry]; ok {
+			continue
+		}
+		known[entry] = struct{}{}
+		cleaned = append(cleaned, entry)
+	}
+	return cleaned
+}
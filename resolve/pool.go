@@ -0,0 +1,223 @@
+package resolve
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Parameters for adjusting the number of threads automatically.
+const (
+	minAutoThreads   = 2
+	maxAutoThreads   = 512
+	autoInterval     = time.Second
+	busyUtilization  = 0.8 // add threads if they are busy more than this fraction of the time
+	idleUtilization  = 0.3 // remove threads if they are busy less than this fraction of the time
+	latencyThreshold = 3   // remove threads if the latency is this many times the best latency seen
+)
+
+// Pool runs resolvers in a pool of workers. The number of workers is
+// either fixed or adjusted automatically based on how busy the workers are and
+// on the latency of the responses: Workers are added while all of them are
+// busy resolving names (so the producer is faster than the resolvers) and
+// removed when they are mostly idle waiting for new values or when the
+// latency increases, which indicates that the name servers are overloaded.
+type Pool struct {
+	newResolver func(n int) *Resolver
+
+	mu      sync.Mutex
+	ctx     context.Context // set by Run
+	wg      sync.WaitGroup
+	stops   []chan struct{}
+	done    bool
+	threads int           // fixed number of workers, zero means automatic
+	busy    time.Duration // time spent in lookups since the last adjustment
+	lookups int
+	rtt     time.Duration // sum of the round-trip times since the last adjustment
+	best    time.Duration // lowest average latency seen
+}
+
+// NewPool returns a new pool with the given number of workers, zero
+// means the number is adjusted automatically. The function newResolver is
+// called to create the resolver for the nth worker.
+func NewPool(newResolver func(n int) *Resolver, threads int) *Pool {
+	return &Pool{newResolver: newResolver, threads: threads}
+}
+
+// Threads returns the current number of workers.
+func (p *Pool) Threads() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.stops)
+}
+
+// SetThreads changes the number of workers to n and stops adjusting the
+// number automatically.
+func (p *Pool) SetThreads(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.threads = n
+	if p.ctx == nil || p.done {
+		return
+	}
+
+	if n > len(p.stops) {
+		p.add(n - len(p.stops))
+	} else {
+		p.remove(len(p.stops)-n, n)
+	}
+}
+
+// add starts n new workers. The caller must hold the lock.
+func (p *Pool) add(n int) {
+	for i := 0; i < n; i++ {
+		resolver := p.newResolver(len(p.stops))
+
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.work(p.ctx, resolver, stop)
+		}()
+	}
+}
+
+// remove stops n workers, at least min workers are kept. The caller must hold
+// the lock.
+func (p *Pool) remove(n, min int) {
+	for i := 0; i < n && len(p.stops) > min; i++ {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+}
+
+// work processes values until the stop channel is closed, the input channel
+// is closed or the context is cancelled.
+func (p *Pool) work(ctx context.Context, r *Resolver, stop <-chan struct{}) {
+	finish := func() {
+		p.mu.Lock()
+		p.done = true
+		p.mu.Unlock()
+	}
+
+	for {
+		var item string
+		var ok bool
+
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			finish()
+			return
+		case item, ok = <-r.input:
+			if !ok {
+				finish()
+				return
+			}
+		}
+
+		start := time.Now()
+		res := r.lookup(ctx, item)
+		p.record(time.Since(start), res)
+
+		select {
+		case <-ctx.Done():
+			finish()
+			return
+		case r.output <- res:
+		}
+	}
+}
+
+// record adds the statistics for a result.
+func (p *Pool) record(busy time.Duration, res Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.busy += busy
+	for _, request := range res.Requests {
+		if request.Error != nil {
+			continue
+		}
+		p.lookups++
+		p.rtt += request.RTT
+	}
+}
+
+// adjust changes the number of workers based on the statistics collected
+// during the last interval, unless the number of workers is fixed.
+func (p *Pool) adjust(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	utilization := float64(p.busy) / float64(interval*time.Duration(len(p.stops)))
+
+	var latency time.Duration
+	if p.lookups > 0 {
+		latency = p.rtt / time.Duration(p.lookups)
+		if p.best == 0 || latency < p.best {
+			p.best = latency
+		}
+	}
+
+	p.busy, p.rtt, p.lookups = 0, 0, 0
+
+	if p.done || p.threads > 0 {
+		return
+	}
+
+	switch {
+	case latency > 0 && latency > latencyThreshold*p.best:
+		// the name servers are slowing down, back off by a quarter
+		p.remove(len(p.stops)/4+1, minAutoThreads)
+	case utilization > busyUtilization && len(p.stops) < maxAutoThreads:
+		// all workers are busy, double the number
+		n := len(p.stops)
+		if n*2 > maxAutoThreads {
+			n = maxAutoThreads - len(p.stops)
+		}
+		p.add(n)
+	case utilization < idleUtilization:
+		p.remove(1, minAutoThreads)
+	}
+}
+
+// Run starts the workers and adjusts their number until all values have been
+// processed or the context is cancelled. The output channel is closed when
+// Run returns.
+func (p *Pool) Run(ctx context.Context, out chan<- Result) error {
+	defer close(out)
+
+	p.mu.Lock()
+	p.ctx = ctx
+	if p.threads > 0 {
+		p.add(p.threads)
+	} else {
+		p.add(minAutoThreads)
+	}
+	p.mu.Unlock()
+
+	workersDone := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(workersDone)
+	}()
+
+	ticker := time.NewTicker(autoInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-workersDone:
+			return nil
+		case <-ticker.C:
+			p.adjust(autoInterval)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestPool returns a pool running n workers, which wait for values that
+// are never sent. The workers are stopped when the returned function is
+// called.
+func newTestPool(fixed, n int) (*Pool, func()) {
+	in := make(chan string)
+	p := NewPool(func(int) *Resolver {
+		return &Resolver{input: in}
+	}, fixed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p.mu.Lock()
+	p.ctx = ctx
+	p.add(n)
+	p.mu.Unlock()
+
+	return p, func() {
+		cancel()
+		p.wg.Wait()
+	}
+}
+
+func TestPoolAdjust(t *testing.T) {
+	var tests = []struct {
+		name        string
+		fixed       int
+		threads     int
+		utilization float64
+		best        time.Duration
+		latency     time.Duration
+		want        int
+	}{
+		{
+			name:        "busy",
+			threads:     2,
+			utilization: 1,
+			want:        4,
+		},
+		{
+			name:        "busy-limit",
+			threads:     300,
+			utilization: 1,
+			want:        maxAutoThreads,
+		},
+		{
+			name:        "busy-max",
+			threads:     maxAutoThreads,
+			utilization: 1,
+			want:        maxAutoThreads,
+		},
+		{
+			name:        "normal",
+			threads:     8,
+			utilization: 0.5,
+			want:        8,
+		},
+		{
+			name:        "idle",
+			threads:     8,
+			utilization: 0.1,
+			want:        7,
+		},
+		{
+			name:        "idle-min",
+			threads:     minAutoThreads,
+			utilization: 0,
+			want:        minAutoThreads,
+		},
+		{
+			name:        "slow",
+			threads:     8,
+			utilization: 1,
+			best:        10 * time.Millisecond,
+			latency:     50 * time.Millisecond,
+			want:        5,
+		},
+		{
+			name:        "slow-min",
+			threads:     3,
+			utilization: 1,
+			best:        10 * time.Millisecond,
+			latency:     50 * time.Millisecond,
+			want:        minAutoThreads,
+		},
+		{
+			name:        "slightly-slower",
+			threads:     8,
+			utilization: 1,
+			best:        10 * time.Millisecond,
+			latency:     20 * time.Millisecond,
+			want:        16,
+		},
+		{
+			name:        "fixed",
+			fixed:       4,
+			threads:     4,
+			utilization: 1,
+			want:        4,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, stop := newTestPool(test.fixed, test.threads)
+			defer stop()
+
+			interval := time.Second
+			p.mu.Lock()
+			p.busy = time.Duration(test.utilization * float64(interval) * float64(test.threads))
+			p.best = test.best
+			if test.latency > 0 {
+				p.lookups = 10
+				p.rtt = 10 * test.latency
+			}
+			p.mu.Unlock()
+
+			p.adjust(interval)
+
+			if n := p.Threads(); n != test.want {
+				t.Errorf("wrong number of threads, want %d, got %d", test.want, n)
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.busy != 0 || p.rtt != 0 || p.lookups != 0 {
+				t.Errorf("statistics not reset: busy %v, rtt %v, lookups %d", p.busy, p.rtt, p.lookups)
+			}
+		})
+	}
+}
+
+func TestPoolSetThreads(t *testing.T) {
+	p, stop := newTestPool(0, minAutoThreads)
+	defer stop()
+
+	p.SetThreads(10)
+	if n := p.Threads(); n != 10 {
+		t.Fatalf("wrong number of threads, want 10, got %d", n)
+	}
+
+	// the number is fixed now and not adjusted any more
+	p.adjust(time.Second)
+	if n := p.Threads(); n != 10 {
+		t.Fatalf("number of threads adjusted, want 10, got %d", n)
+	}
+
+	p.SetThreads(3)
+	if n := p.Threads(); n != 3 {
+		t.Fatalf("wrong number of threads, want 3, got %d", n)
+	}
+}
@@ -0,0 +1,100 @@
+package resolve
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// NXDomainCache records the names for which NXDOMAIN was returned. According
+// to RFC 8020, no names exist below such a name, so requests for them can be
+// skipped. At most size names are kept, the oldest are removed first.
+type NXDomainCache struct {
+	size int
+
+	mu     sync.RWMutex
+	names  map[string]struct{}
+	order  []string // names in the cache, oldest first
+	pruned int
+}
+
+// NewNXDomainCache returns a new, empty cache for size names.
+func NewNXDomainCache(size int) *NXDomainCache {
+	return &NXDomainCache{
+		size:  size,
+		names: make(map[string]struct{}),
+	}
+}
+
+// Add records that name does not exist.
+func (c *NXDomainCache) Add(name string) {
+	name = strings.ToLower(dns.Fqdn(name))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.names[name]; ok {
+		return
+	}
+
+	c.names[name] = struct{}{}
+	c.order = append(c.order, name)
+
+	// remove the oldest names until at most size are left
+	for len(c.order) > c.size {
+		delete(c.names, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// Parent returns the closest name above name which does not exist, if any.
+func (c *NXDomainCache) Parent(name string) (parent string, ok bool) {
+	name = strings.ToLower(dns.Fqdn(name))
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		if _, ok := c.names[name[off:]]; ok {
+			return name[off:], true
+		}
+	}
+
+	return "", false
+}
+
+// countPruned records that the requests for a name were skipped.
+func (c *NXDomainCache) countPruned() {
+	c.mu.Lock()
+	c.pruned++
+	c.mu.Unlock()
+}
+
+// Pruned returns the number of names for which the requests were skipped.
+func (c *NXDomainCache) Pruned() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.pruned
+}
+
+// NonExistent returns true if the response shows that the name in the
+// question does not exist. For responses with CNAME records, the status
+// refers to the last target, so false is returned.
+func (r Request) NonExistent() bool {
+	return r.Error == nil && r.NotFound && r.PrunedBy == "" && len(r.Raw.Answer) == 0
+}
+
+// prunedRequest returns the request as if the name below parent had been
+// requested, which returns NXDOMAIN.
+func prunedRequest(requestType, parent string) Request {
+	return Request{
+		Type:              requestType,
+		Status:            dns.RcodeToString[dns.RcodeNameError],
+		Failure:           true,
+		NotFound:          true,
+		ClientSubnetScope: -1,
+		PrunedBy:          CleanHostname(parent),
+	}
+}
@@ -0,0 +1,755 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Resolver executes DNS requests.
+type Resolver struct {
+	input        <-chan string
+	output       chan<- Result
+	requestTypes []string
+
+	template string
+	server   string
+
+	// ClientSubnets are attached to the requests as EDNS Client Subnet
+	// options. If more than one subnet is set, each request is sent once per
+	// subnet, and results with different answers are flagged.
+	ClientSubnets []*net.IPNet
+
+	// ClientSubnet is attached to all requests as an EDNS Client Subnet
+	// option if no ClientSubnets are set, so the answers reflect the view
+	// of a specific network.
+	ClientSubnet *net.IPNet
+
+	// FollowCNAMEs configures the resolver to resolve CNAME chains to their
+	// terminal records, see QueryOptions.
+	FollowCNAMEs bool
+
+	// Wildcard is used to rate the confidence of the results, it may be nil.
+	Wildcard *Wildcard
+
+	// JitterMin and JitterMax configure a random delay between both values
+	// before each query, in addition to any rate limit.
+	JitterMin, JitterMax time.Duration
+
+	// Concurrency is the maximum number of requests for an item sent in
+	// parallel, e.g. for different request types. For values below two, the
+	// requests are sent one after another.
+	Concurrency int
+
+	// TSIG signs the queries if set, see QueryOptions.
+	TSIG *TSIG
+
+	// Health is used to detect name servers which throttle requests, while
+	// the server is benched, requests are sent to other servers. It may be
+	// nil.
+	Health *Health
+
+	// NXDomains records the names which do not exist, requests for names
+	// below them are skipped. It may be nil.
+	NXDomains *NXDomainCache
+
+	// Cache returns the results for host names requested before, it may be
+	// nil.
+	Cache *ResponseCache
+
+	// Servers picks the name server for each query at random if set,
+	// instead of sending all queries to the server of the resolver.
+	Servers *WeightedServers
+
+	// Network is used to send the queries, see QueryOptions.
+	Network string
+
+	// LocalAddrs are the addresses the queries are sent from, see
+	// QueryOptions.
+	LocalAddrs []net.IP
+}
+
+// NewResolver returns a new resolver with the given input and output channels.
+func NewResolver(in <-chan string, out chan<- Result, template string, server string, requestTypes []string) (*Resolver, error) {
+	if server == "" {
+		return nil, errors.New("nameserver not specified")
+	}
+
+	res := &Resolver{
+		input:        in,
+		output:       out,
+		template:     template,
+		server:       server,
+		requestTypes: requestTypes,
+	}
+	return res, nil
+}
+
+// NameserverAddress returns the address for sending queries to server. If
+// server does not contain a port, the default port 53 is used.
+func NameserverAddress(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "53")
+}
+
+// CleanHostname removes a trailing dot if present.
+func CleanHostname(h string) string {
+	if h == "" {
+		return h
+	}
+	last := len(h) - 1
+	if h[last] == '.' {
+		return h[:last]
+	}
+	return h
+}
+
+// RawValues returns the records formatted as strings, with tabs replaced by
+// spaces.
+func RawValues(list []dns.RR) (records []string) {
+	for _, item := range list {
+		records = append(records, strings.Replace(item.String(), "\t", " ", -1))
+	}
+	return records
+}
+
+// QueryOptions configures the requests sent by QueryWith.
+type QueryOptions struct {
+	// ClientSubnet is attached as an EDNS Client Subnet option, if set.
+	ClientSubnet *net.IPNet
+
+	// FollowCNAMEs configures QueryWith to resolve CNAME chains to the
+	// records of the requested type. Targets missing from the answer are
+	// requested from the server, at most MaxCNAMEDepth records are followed.
+	FollowCNAMEs bool
+
+	// TSIG signs the queries with the key if set, responses with an invalid
+	// signature are returned as errors.
+	TSIG *TSIG
+
+	// Network is used to send the queries, e.g. "udp4" to only use IPv4. If
+	// it is empty, "udp" is used.
+	Network string
+
+	// LocalAddrs are the addresses the queries are sent from, e.g. the
+	// addresses of a network interface. The first address of the same
+	// family as the server is used. If none is set, the system chooses.
+	LocalAddrs []net.IP
+}
+
+// dialTimeout is the timeout for connecting to a server, like the default of
+// dns.Client.
+const dialTimeout = 2 * time.Second
+
+// client returns a client for sending the queries to server.
+func (opts QueryOptions) client(server string) *dns.Client {
+	c := &dns.Client{Net: opts.Network}
+
+	if len(opts.LocalAddrs) == 0 {
+		return c
+	}
+
+	// use IPv6 for IPv6 servers and if it was requested, otherwise IPv4
+	host := NameserverAddress(server)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	v6 := opts.Network == "udp6" || (ip != nil && ip.To4() == nil)
+
+	for _, addr := range opts.LocalAddrs {
+		if (addr.To4() == nil) == v6 {
+			c.Dialer = &net.Dialer{
+				Timeout:   dialTimeout,
+				LocalAddr: &net.UDPAddr{IP: addr},
+			}
+			break
+		}
+	}
+
+	return c
+}
+
+// MaxCNAMEDepth is the maximum number of CNAME records followed when
+// resolving a chain.
+const MaxCNAMEDepth = 8
+
+// clientSubnetOption returns the EDNS Client Subnet option for subnet.
+func clientSubnetOption(subnet *net.IPNet) *dns.EDNS0_SUBNET {
+	ones, _ := subnet.Mask.Size()
+	opt := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		SourceNetmask: uint8(ones),
+	}
+
+	if ip := subnet.IP.To4(); ip != nil {
+		opt.Family = 1
+		opt.Address = ip
+	} else {
+		opt.Family = 2
+		opt.Address = subnet.IP
+	}
+
+	return opt
+}
+
+// isSubdomain returns true if name is equal to or below zone.
+func isSubdomain(name, zone string) bool {
+	return dns.IsSubDomain(strings.ToLower(zone), strings.ToLower(name))
+}
+
+// checkResponse verifies that res answers the query in req: the question
+// must be the same (including the case of the name) and all records in the
+// answer and authority sections must belong to the name in the question or
+// the targets of CNAME and DNAME records.
+func checkResponse(req, res *dns.Msg) (mismatches []string) {
+	if len(res.Question) != 1 {
+		return []string{fmt.Sprintf("response contains %d questions", len(res.Question))}
+	}
+
+	q, rq := req.Question[0], res.Question[0]
+	switch {
+	case q.Name == rq.Name:
+	case strings.EqualFold(q.Name, rq.Name):
+		mismatches = append(mismatches, fmt.Sprintf("case of the question name changed to %v", rq.Name))
+	default:
+		mismatches = append(mismatches, fmt.Sprintf("question name %v does not match", rq.Name))
+	}
+
+	if q.Qtype != rq.Qtype || q.Qclass != rq.Qclass {
+		mismatches = append(mismatches, fmt.Sprintf("question type %v %v does not match", dns.ClassToString[rq.Qclass], dns.TypeToString[rq.Qtype]))
+	}
+
+	// collect the names which may appear in the answer
+	names := map[string]struct{}{strings.ToLower(q.Name): {}}
+	for changed := true; changed; {
+		changed = false
+		for _, rr := range res.Answer {
+			var target string
+			switch rr := rr.(type) {
+			case *dns.CNAME:
+				if _, ok := names[strings.ToLower(rr.Hdr.Name)]; ok {
+					target = rr.Target
+				}
+			case *dns.DNAME:
+				if isSubdomain(q.Name, rr.Hdr.Name) {
+					target = strings.TrimSuffix(strings.ToLower(q.Name), strings.ToLower(rr.Hdr.Name)) + strings.ToLower(rr.Target)
+				}
+			}
+
+			if _, ok := names[strings.ToLower(target)]; target != "" && !ok {
+				names[strings.ToLower(target)] = struct{}{}
+				changed = true
+			}
+		}
+	}
+
+	for _, rr := range res.Answer {
+		name := strings.ToLower(rr.Header().Name)
+		if _, ok := names[name]; ok {
+			continue
+		}
+		if _, ok := rr.(*dns.DNAME); ok && isSubdomain(q.Name, name) {
+			continue
+		}
+		mismatches = append(mismatches, fmt.Sprintf("answer contains unrelated record for %v", rr.Header().Name))
+	}
+
+	// name servers and SOA records must be responsible for one of the names
+authority:
+	for _, rr := range res.Ns {
+		for name := range names {
+			if isSubdomain(name, rr.Header().Name) {
+				continue authority
+			}
+		}
+		mismatches = append(mismatches, fmt.Sprintf("authority section contains record for %v outside of the bailiwick", rr.Header().Name))
+	}
+
+	return mismatches
+}
+
+// NewRecordResponse returns the response for rr. For unsupported record
+// types, ok is false.
+func NewRecordResponse(rr dns.RR) (response Response, ok bool) {
+	switch rec := rr.(type) {
+	case *dns.A:
+		return NewResponse("A", rec.Hdr.Ttl, rec.A.String()), true
+	case *dns.AAAA:
+		return NewResponse("AAAA", rec.Hdr.Ttl, rec.AAAA.String()), true
+	case *dns.CNAME:
+		return NewResponse("CNAME", rec.Hdr.Ttl, CleanHostname(rec.Target)), true
+	case *dns.MX:
+		return NewResponse("MX", rec.Hdr.Ttl, CleanHostname(rec.Mx)), true
+	case *dns.PTR:
+		return NewResponse("PTR", rec.Hdr.Ttl, CleanHostname(rec.Ptr)), true
+	case *dns.TXT:
+		response := NewResponse("TXT", rec.Hdr.Ttl, TXTData(rec.Txt, false))
+		response.Segments = rec.Txt
+		return response, true
+	}
+	return Response{}, false
+}
+
+// followCNAMEs returns the chain of CNAME records starting at name, followed
+// by the records of type qtype for the last target. Records are taken from
+// answer if possible, otherwise the target is requested from server. An
+// error is returned for loops and chains longer than MaxCNAMEDepth, the
+// chain contains the records found so far. The queries are sent with the
+// network and the TSIG key in opts.
+func followCNAMEs(ctx context.Context, name string, qtype uint16, answer []dns.RR, server string, opts QueryOptions) (chain []Response, err error) {
+	seen := map[string]struct{}{strings.ToLower(name): {}}
+	c := opts.client(server)
+
+	for depth := 0; ; depth++ {
+		var cname *dns.CNAME
+		var records []Response
+		for _, rr := range answer {
+			if !strings.EqualFold(rr.Header().Name, name) {
+				continue
+			}
+
+			if rec, ok := rr.(*dns.CNAME); ok {
+				cname = rec
+				continue
+			}
+
+			if response, ok := NewRecordResponse(rr); ok && rr.Header().Rrtype == qtype {
+				records = append(records, response)
+			}
+		}
+
+		// names without a CNAME record do not have a chain
+		if depth == 0 && cname == nil {
+			return nil, nil
+		}
+
+		if len(records) > 0 {
+			return append(chain, records...), nil
+		}
+
+		// the chain ends without records of the requested type
+		if cname == nil {
+			return chain, nil
+		}
+
+		if len(chain) == MaxCNAMEDepth {
+			return chain, fmt.Errorf("chain is longer than %d CNAME records", MaxCNAMEDepth)
+		}
+
+		response, _ := NewRecordResponse(cname)
+		chain = append(chain, response)
+
+		name = cname.Target
+		if _, ok := seen[strings.ToLower(name)]; ok {
+			return chain, fmt.Errorf("CNAME loop at %v", CleanHostname(name))
+		}
+		seen[strings.ToLower(name)] = struct{}{}
+
+		if containsName(answer, name) {
+			continue
+		}
+
+		// the server did not include the target in the answer, ask again
+		m := dns.Msg{}
+		m.SetQuestion(name, qtype)
+		if opts.TSIG != nil {
+			c.TsigSecret = opts.TSIG.Sign(&m)
+		}
+		res, _, _, err := exchange(ctx, c, &m, server)
+		if err != nil {
+			return chain, fmt.Errorf("resolving %v failed: %v", CleanHostname(name), err)
+		}
+		if res.Rcode != dns.RcodeSuccess {
+			return chain, fmt.Errorf("resolving %v failed: %v", CleanHostname(name), dns.RcodeToString[res.Rcode])
+		}
+		answer = res.Answer
+	}
+}
+
+// containsName returns true if one of the records belongs to name.
+func containsName(records []dns.RR, name string) bool {
+	for _, rr := range records {
+		if strings.EqualFold(rr.Header().Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// exchange sends m to server like dns.Client.ExchangeContext and returns the
+// response and its size as received, when the request fails because the
+// context is done the error of the context is returned. Servers with a JSON
+// API (see IsJSONServer) are queried via HTTPS, the size is then the size of
+// the response in wire format.
+func exchange(ctx context.Context, c *dns.Client, m *dns.Msg, server string) (*dns.Msg, int, time.Duration, error) {
+	if IsJSONServer(server) {
+		res, rtt, err := exchangeJSON(ctx, m, server)
+		if err != nil {
+			return nil, 0, rtt, err
+		}
+		res.Compress = true
+		return res, res.Len(), rtt, nil
+	}
+
+	conn, err := c.DialContext(ctx, NameserverAddress(server))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, 0, ctx.Err()
+		}
+		return nil, 0, 0, err
+	}
+
+	counter := newSizeConn(conn.Conn)
+	conn.Conn = counter
+
+	// the dns package does not export exchanging a message with a context
+	// via an existing connection, so the connection is closed when the
+	// context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	res, rtt, err := c.ExchangeWithConn(m, conn)
+	_ = conn.Close()
+	if err != nil && ctx.Err() != nil {
+		return nil, 0, 0, ctx.Err()
+	}
+	if err != nil {
+		return res, 0, rtt, err
+	}
+
+	return res, counter.size(), rtt, nil
+}
+
+// Query sends a request of the given type for name to server and returns the
+// parsed response.
+func Query(name, requestType, server string) (request Request) {
+	return QueryWith(context.Background(), name, requestType, server, QueryOptions{})
+}
+
+// QueryWith sends a request like Query, configured by opts. The deadline of
+// the context limits the time to wait for the response.
+func QueryWith(ctx context.Context, name, requestType, server string, opts QueryOptions) (request Request) {
+	request = Request{
+		Type:              requestType,
+		Server:            server,
+		ClientSubnetScope: -1,
+	}
+
+	c := opts.client(server)
+	m := dns.Msg{}
+	reqType := dns.StringToType[requestType]
+
+	m.SetQuestion(name, reqType)
+
+	if opts.ClientSubnet != nil {
+		request.ClientSubnet = opts.ClientSubnet.String()
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		edns := m.IsEdns0()
+		edns.Option = append(edns.Option, clientSubnetOption(opts.ClientSubnet))
+	}
+
+	// the signature needs to be the last record
+	if opts.TSIG != nil {
+		c.TsigSecret = opts.TSIG.Sign(&m)
+	}
+
+	res, size, rtt, err := exchange(ctx, c, &m, server)
+	request.RTT = rtt
+	if err == dns.ErrId {
+		request.Mismatches = append(request.Mismatches, "response ID does not match the query")
+	}
+	if err != nil {
+		request.Error = err
+		return request
+	}
+
+	request.Mismatches = checkResponse(&m, res)
+
+	request.Size = size
+	request.Flags = Flags{
+		Authoritative:      res.Authoritative,
+		Truncated:          res.Truncated,
+		RecursionAvailable: res.RecursionAvailable,
+		AuthenticatedData:  res.AuthenticatedData,
+	}
+
+	// record the scope the answer is valid for
+	if edns := res.IsEdns0(); edns != nil {
+		for _, opt := range edns.Option {
+			if subnet, ok := opt.(*dns.EDNS0_SUBNET); ok {
+				request.ClientSubnetScope = int(subnet.SourceScope)
+			}
+		}
+	}
+
+	request.Status = dns.RcodeToString[res.MsgHdr.Rcode]
+	if res.MsgHdr.Rcode != dns.RcodeSuccess {
+		request.Failure = true
+	}
+
+	if request.Status == "NXDOMAIN" {
+		request.NotFound = true
+	}
+
+	for _, ans := range res.Answer {
+		// disregard additional data we did not ask for
+		if !strings.EqualFold(ans.Header().Name, name) {
+			continue
+		}
+
+		if response, ok := NewRecordResponse(ans); ok {
+			request.Responses = append(request.Responses, response)
+		}
+	}
+
+	if opts.FollowCNAMEs && reqType != dns.TypeCNAME {
+		var err error
+		request.Chain, err = followCNAMEs(ctx, name, reqType, res.Answer, server, opts)
+		if err != nil {
+			request.ChainError = err.Error()
+		}
+	}
+
+	// collect nameservers in case of delegated sub domains
+	for _, ans := range res.Ns {
+		if rec, ok := ans.(*dns.SOA); ok {
+			if rec.Hdr.Name == name {
+				response := NewResponse("SOA", rec.Header().Ttl, CleanHostname(rec.Ns))
+				response.SOA = NewSOA(rec)
+				request.SOA = append(request.SOA, response)
+			}
+		}
+		if rec, ok := ans.(*dns.NS); ok {
+			if rec.Hdr.Name == name {
+				request.Nameserver = append(request.Nameserver, NewResponse("NS", rec.Header().Ttl, CleanHostname(rec.Ns)))
+			}
+		}
+	}
+
+	// collect the raw responses
+	for _, q := range res.Question {
+		request.Raw.Question = append(request.Raw.Question, strings.Replace(q.String()[1:], "\t", " ", -1))
+	}
+	request.Raw.Answer = RawValues(res.Answer)
+	request.Raw.Extra = RawValues(res.Extra)
+	request.Raw.Nameserver = RawValues(res.Ns)
+	if opt := res.IsEdns0(); opt != nil {
+		request.Raw.EDNS = NewEDNS(opt)
+	}
+
+	return request
+}
+
+// jitter waits for a random duration between r.JitterMin and r.JitterMax. It
+// returns false if the context has been cancelled.
+func (r *Resolver) jitter(ctx context.Context) bool {
+	if r.JitterMax <= 0 {
+		return true
+	}
+
+	d := r.JitterMin
+	if r.JitterMax > r.JitterMin {
+		d += time.Duration(rand.Int63n(int64(r.JitterMax - r.JitterMin)))
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// query sends a request for name after waiting for the jitter. If the
+// server is benched, the request is sent to another server (or delayed until
+// the server returns). It returns false if the context has been cancelled
+// before the response was received.
+func (r *Resolver) query(ctx context.Context, name, requestType string, opts QueryOptions) (Request, bool) {
+	if !r.jitter(ctx) {
+		return Request{}, false
+	}
+
+	server := r.server
+	if r.Servers != nil {
+		server = r.Servers.Pick()
+	}
+
+	if r.Health != nil {
+		var wait time.Duration
+		server, wait = r.Health.Pick(server)
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return Request{}, false
+			}
+		}
+	}
+
+	req := QueryWith(ctx, name, requestType, server, opts)
+	if ctx.Err() != nil {
+		return Request{}, false
+	}
+
+	if r.Health != nil {
+		r.Health.Record(server, req)
+	}
+
+	return req, true
+}
+
+// lookup sends the requests for item, or returns the result from the cache
+// if the host name was requested before.
+func (r *Resolver) lookup(ctx context.Context, item string) Result {
+	name := strings.Replace(r.template, "FUZZ", item, -1)
+
+	if r.Cache == nil {
+		result, _ := r.lookupName(ctx, name, item)
+		return result
+	}
+
+	result, _ := r.Cache.lookup(name, func() (Result, bool) {
+		return r.lookupName(ctx, name, item)
+	})
+	result.Hostname = CleanHostname(name)
+	result.Item = item
+	return result
+}
+
+// lookupName sends the requests for name. It returns false if the context
+// was cancelled before all responses were received.
+func (r *Resolver) lookupName(ctx context.Context, name, item string) (Result, bool) {
+	result := Result{
+		Hostname: CleanHostname(name),
+		Item:     item,
+	}
+
+	// nothing exists below names which returned NXDOMAIN
+	if r.NXDomains != nil {
+		if parent, ok := r.NXDomains.Parent(name); ok {
+			r.NXDomains.countPruned()
+			for _, requestType := range r.requestTypes {
+				result.Requests = append(result.Requests, prunedRequest(requestType, parent))
+			}
+			return result, true
+		}
+	}
+
+	// one request per type, or per type and client subnet
+	var queries []QueryOptions
+	var types []string
+	for _, requestType := range r.requestTypes {
+		if len(r.ClientSubnets) == 0 {
+			queries = append(queries, QueryOptions{FollowCNAMEs: r.FollowCNAMEs, ClientSubnet: r.ClientSubnet, TSIG: r.TSIG, Network: r.Network, LocalAddrs: r.LocalAddrs})
+			types = append(types, requestType)
+			continue
+		}
+
+		for _, subnet := range r.ClientSubnets {
+			queries = append(queries, QueryOptions{FollowCNAMEs: r.FollowCNAMEs, ClientSubnet: subnet, TSIG: r.TSIG, Network: r.Network, LocalAddrs: r.LocalAddrs})
+			types = append(types, requestType)
+		}
+	}
+
+	requests := make([]Request, len(queries))
+	sent := make([]bool, len(queries))
+
+	if r.Concurrency < 2 {
+		for i := range queries {
+			requests[i], sent[i] = r.query(ctx, name, types[i], queries[i])
+			if !sent[i] {
+				break
+			}
+		}
+	} else {
+		sem := make(chan struct{}, r.Concurrency)
+		var wg sync.WaitGroup
+		for i := range queries {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				requests[i], sent[i] = r.query(ctx, name, types[i], queries[i])
+				<-sem
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	// keep the order of the requests, so the results do not depend on the
+	// timing
+	first := make(map[string]Request)
+	for i, req := range requests {
+		if !sent[i] {
+			return result, false
+		}
+		result.Requests = append(result.Requests, req)
+
+		if r.NXDomains != nil && req.NonExistent() {
+			r.NXDomains.Add(name)
+		}
+
+		if f, ok := first[req.Type]; !ok {
+			first[req.Type] = req
+		} else if f.Differs(req) {
+			result.DifferingSubnets = true
+		}
+	}
+
+	// the answers do not depend on the client subnet, so only the first
+	// request per type is kept and the duplicates are not displayed,
+	// recorded or counted
+	if len(r.ClientSubnets) > 1 && !result.DifferingSubnets {
+		kept := result.Requests[:0]
+		seen := make(map[string]struct{})
+		for _, req := range result.Requests {
+			if _, ok := seen[req.Type]; ok {
+				continue
+			}
+			seen[req.Type] = struct{}{}
+			kept = append(kept, req)
+		}
+		result.Requests = kept
+	}
+
+	if r.Wildcard != nil {
+		result.Confidence = r.Wildcard.Confidence(result)
+	}
+
+	return result, true
+}
+
+// Run runs a resolver, processing requests from the input channel.
+func (r *Resolver) Run(ctx context.Context) {
+	for item := range r.input {
+		res := r.lookup(ctx, item)
+
+		select {
+		case <-ctx.Done():
+			return
+		case r.output <- res:
+		}
+	}
+}
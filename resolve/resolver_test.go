@@ -0,0 +1,965 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/dnstest"
+	"github.com/miekg/dns"
+)
+
+func testServer(t testing.TB) *dnstest.Server {
+	zone, err := dnstest.NewZone("example.com",
+		"www.example.com. 300 IN A 192.0.2.1",
+		"cdn.example.com. 300 IN CNAME edge.example.com.",
+		"edge.example.com. 300 IN A 192.0.2.2",
+		"dev.example.com. 300 IN NS ns1.example.net.",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv
+}
+
+func TestQuery(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	var tests = []struct {
+		name      string
+		notFound  bool
+		responses []string
+		ns        []string
+	}{
+		{"www.example.com.", false, []string{"192.0.2.1"}, nil},
+		{"cdn.example.com.", false, []string{"edge.example.com"}, nil},
+		{"dev.example.com.", false, nil, []string{"ns1.example.net"}},
+		{"nope.example.com.", true, nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := Query(test.name, "A", srv.Addr)
+			if req.Error != nil {
+				t.Fatal(req.Error)
+			}
+
+			if req.Size == 0 {
+				t.Errorf("size of the response not recorded")
+			}
+
+			if req.NotFound != test.notFound {
+				t.Errorf("wrong NotFound, want %v, got %v", test.notFound, req.NotFound)
+			}
+
+			var responses []string
+			for _, res := range req.Responses {
+				responses = append(responses, res.Data)
+			}
+			if !equal(responses, test.responses) {
+				t.Errorf("wrong responses, want %v, got %v", test.responses, responses)
+			}
+
+			var ns []string
+			for _, res := range req.Nameserver {
+				ns = append(ns, res.Data)
+			}
+			if !equal(ns, test.ns) {
+				t.Errorf("wrong name servers, want %v, got %v", test.ns, ns)
+			}
+		})
+	}
+}
+
+func TestQueryTXT(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com",
+		`example.com. 300 IN TXT "v=spf1 include:_spf.example.net " "-all"`,
+		`token.example.com. 300 IN TXT "say \"hi\""`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var tests = []struct {
+		name     string
+		joined   string
+		separate string
+	}{
+		{"example.com.", `v=spf1 include:_spf.example.net -all`, `"v=spf1 include:_spf.example.net " "-all"`},
+		{"token.example.com.", `say \"hi\"`, `"say \"hi\""`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := Query(test.name, "TXT", srv.Addr)
+			if req.Error != nil {
+				t.Fatal(req.Error)
+			}
+
+			if len(req.Responses) != 1 {
+				t.Fatalf("wrong number of responses, want 1, got %d", len(req.Responses))
+			}
+
+			res := req.Responses[0]
+			if res.Data != test.joined {
+				t.Errorf("wrong data, want %q, got %q", test.joined, res.Data)
+			}
+
+			if data := TXTData(res.Segments, true); data != test.separate {
+				t.Errorf("wrong separate data, want %q, got %q", test.separate, data)
+			}
+		})
+	}
+}
+
+func TestQuerySOA(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	// there is no address for the zone itself, so the SOA is returned
+	req := Query("example.com.", "A", srv.Addr)
+	if req.Error != nil {
+		t.Fatal(req.Error)
+	}
+
+	if len(req.SOA) != 1 {
+		t.Fatalf("wrong number of SOA responses, want 1, got %d", len(req.SOA))
+	}
+
+	want := SOA{Mname: "ns.example.com", Rname: "hostmaster@example.com", Serial: 1}
+	if soa := req.SOA[0].SOA; soa == nil || *soa != want {
+		t.Fatalf("wrong SOA details, want %+v, got %+v", want, soa)
+	}
+}
+
+func TestMailbox(t *testing.T) {
+	var tests = []struct {
+		mbox, want string
+	}{
+		{"hostmaster.example.com.", "hostmaster@example.com"},
+		{`john\.doe.example.com.`, "john.doe@example.com"},
+		{"root.", "root"},
+	}
+
+	for _, test := range tests {
+		if got := mailbox(test.mbox); got != test.want {
+			t.Errorf("mailbox(%q): want %q, got %q", test.mbox, test.want, got)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	in := make(chan string, 1)
+	in <- "www"
+	close(in)
+	out := make(chan Result, 1)
+
+	r, err := NewResolver(in, out, "FUZZ.example.com.", srv.Addr, []string{"A", "AAAA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.JitterMin, r.JitterMax = 20*time.Millisecond, 30*time.Millisecond
+
+	start := time.Now()
+	r.Run(context.Background())
+	res := <-out
+
+	if len(res.Requests) != 2 {
+		t.Fatalf("wrong number of requests, want 2, got %d", len(res.Requests))
+	}
+
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("requests were not delayed, took %v", d)
+	}
+}
+
+// slowHandler answers all requests with an address after a delay.
+func slowHandler(w dns.ResponseWriter, req *dns.Msg) {
+	time.Sleep(50 * time.Millisecond)
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+	rr, _ := dns.NewRR(req.Question[0].Name + " 300 IN A 192.0.2.1")
+	m.Answer = append(m.Answer, rr)
+	_ = w.WriteMsg(m)
+}
+
+func TestConcurrency(t *testing.T) {
+	srv, err := dnstest.NewServer(dns.HandlerFunc(slowHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	types := []string{"A", "AAAA", "MX", "CNAME"}
+
+	in := make(chan string, 1)
+	in <- "www"
+	close(in)
+	out := make(chan Result, 1)
+
+	r, err := NewResolver(in, out, "FUZZ.example.com.", srv.Addr, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Concurrency = len(types)
+
+	start := time.Now()
+	r.Run(context.Background())
+	res := <-out
+
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("requests were not sent in parallel, took %v", d)
+	}
+
+	var got []string
+	for _, req := range res.Requests {
+		got = append(got, req.Type)
+	}
+	if !equal(got, types) {
+		t.Errorf("wrong requests, want %v, got %v", types, got)
+	}
+}
+
+func TestQueryCancel(t *testing.T) {
+	// the server never answers, so the request only returns at the deadline
+	srv, err := dnstest.NewServer(dns.HandlerFunc(func(dns.ResponseWriter, *dns.Msg) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	req := QueryWith(ctx, "www.example.com.", "A", srv.Addr, QueryOptions{})
+
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("request was not cancelled, took %v", d)
+	}
+
+	if req.Error != context.DeadlineExceeded {
+		t.Errorf("wrong error, want %v, got %v", context.DeadlineExceeded, req.Error)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	req := Query("www.example.com.", "A", srv.Addr)
+	if req.Error != nil {
+		t.Fatal(req.Error)
+	}
+	if s := req.Flags.String(); s != "aa" {
+		t.Errorf("wrong flags for answer, want %q, got %q", "aa", s)
+	}
+
+	// referrals to delegated sub domains are not authoritative
+	req = Query("dev.example.com.", "A", srv.Addr)
+	if req.Error != nil {
+		t.Fatal(req.Error)
+	}
+	if s := req.Flags.String(); s != "" {
+		t.Errorf("wrong flags for referral, want none, got %q", s)
+	}
+}
+
+func TestPool(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	items := []string{"www", "cdn", "dev", "nope"}
+	in := make(chan string, len(items))
+	for _, item := range items {
+		in <- item
+	}
+	close(in)
+
+	out := make(chan Result)
+
+	newResolver := func(n int) *Resolver {
+		r, err := NewResolver(in, out, "FUZZ.example.com.", srv.Addr, []string{"A", "AAAA"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	pool := NewPool(newResolver, 2)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pool.Run(ctx, out)
+	}()
+
+	var hosts []string
+	for res := range out {
+		if len(res.Requests) != 2 {
+			t.Errorf("%v: wrong number of requests, want 2, got %d", res.Hostname, len(res.Requests))
+		}
+		hosts = append(hosts, res.Hostname)
+	}
+
+	err := <-errCh
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(hosts)
+	want := []string{"cdn.example.com", "dev.example.com", "nope.example.com", "www.example.com"}
+	if !equal(hosts, want) {
+		t.Fatalf("wrong hosts, want %v, got %v", want, hosts)
+	}
+}
+
+// subnetHandler answers A requests with an address depending on the client
+// subnet sent in the request.
+func subnetHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	addr := "192.0.2.1"
+	if opt := req.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			subnet, ok := o.(*dns.EDNS0_SUBNET)
+			if !ok {
+				continue
+			}
+
+			if subnet.Address.Equal(net.ParseIP("198.51.100.0")) {
+				addr = "192.0.2.2"
+			}
+
+			subnet.SourceScope = 24
+			m.SetEdns0(dns.DefaultMsgSize, false)
+			m.IsEdns0().Option = append(m.IsEdns0().Option, subnet)
+		}
+	}
+
+	rr, _ := dns.NewRR(req.Question[0].Name + " 300 IN A " + addr)
+	m.Answer = append(m.Answer, rr)
+	_ = w.WriteMsg(m)
+}
+
+func TestClientSubnets(t *testing.T) {
+	srv, err := dnstest.NewServer(dns.HandlerFunc(subnetHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	req := Query("www.example.com.", "A", srv.Addr)
+	if req.ClientSubnet != "" || req.ClientSubnetScope != -1 {
+		t.Errorf("unexpected client subnet %q, scope %d", req.ClientSubnet, req.ClientSubnetScope)
+	}
+	if req.Raw.EDNS != nil {
+		t.Errorf("unexpected EDNS data %+v", req.Raw.EDNS)
+	}
+
+	in := make(chan string, 1)
+	in <- "www"
+	close(in)
+	out := make(chan Result, 1)
+
+	r, err := NewResolver(in, out, "FUZZ.example.com.", srv.Addr, []string{"A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"192.0.2.0/24", "198.51.100.0/24"} {
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.ClientSubnets = append(r.ClientSubnets, subnet)
+	}
+
+	r.Run(context.Background())
+	res := <-out
+
+	if len(res.Requests) != 2 {
+		t.Fatalf("wrong number of requests, want 2, got %d", len(res.Requests))
+	}
+
+	for i, want := range []string{"192.0.2.0/24", "198.51.100.0/24"} {
+		req := res.Requests[i]
+		if req.ClientSubnet != want || req.ClientSubnetScope != 24 {
+			t.Errorf("request %d: wrong client subnet %q, scope %d", i, req.ClientSubnet, req.ClientSubnetScope)
+		}
+
+		edns := req.Raw.EDNS
+		if edns == nil {
+			t.Errorf("request %d: OPT record not recorded", i)
+			continue
+		}
+		if edns.UDPSize != dns.DefaultMsgSize || edns.ClientSubnet != want || edns.ClientSubnetScope == nil || *edns.ClientSubnetScope != 24 {
+			t.Errorf("request %d: wrong EDNS data %+v", i, edns)
+		}
+	}
+
+	if !res.DifferingSubnets {
+		t.Errorf("different answers for the subnets not detected")
+	}
+}
+
+func TestNewEDNSClientSubnetScope(t *testing.T) {
+	opt := &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+	opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        1,
+		SourceNetmask: 24,
+		SourceScope:   0,
+		Address:       net.ParseIP("192.0.2.0").To4(),
+	})
+
+	buf, err := json.Marshal(NewEDNS(opt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a scope of zero is valid and must be recorded
+	if !strings.Contains(string(buf), `"client_subnet_scope":0`) {
+		t.Errorf("scope zero not recorded: %s", buf)
+	}
+
+	buf, err = json.Marshal(NewEDNS(&dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(buf), "client_subnet_scope") {
+		t.Errorf("scope recorded without client subnet option: %s", buf)
+	}
+}
+
+func TestClientSubnetsSameAnswers(t *testing.T) {
+	srv, err := dnstest.NewServer(dns.HandlerFunc(subnetHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	in := make(chan string, 1)
+	in <- "www"
+	close(in)
+	out := make(chan Result, 1)
+
+	r, err := NewResolver(in, out, "FUZZ.example.com.", srv.Addr, []string{"A", "AAAA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"192.0.2.0/24", "203.0.113.0/24"} {
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.ClientSubnets = append(r.ClientSubnets, subnet)
+	}
+
+	r.Run(context.Background())
+	res := <-out
+
+	if res.DifferingSubnets {
+		t.Errorf("answers for the subnets wrongly flagged as different")
+	}
+
+	// only the request for the first subnet is kept per type
+	if len(res.Requests) != 2 {
+		t.Fatalf("wrong number of requests, want 2, got %d", len(res.Requests))
+	}
+
+	for i, want := range []string{"A", "AAAA"} {
+		req := res.Requests[i]
+		if req.Type != want || req.ClientSubnet != "192.0.2.0/24" {
+			t.Errorf("request %d: want type %v for 192.0.2.0/24, got %v for %v", i, want, req.Type, req.ClientSubnet)
+		}
+	}
+}
+
+// tamperingHandler answers with records which do not belong to the question.
+func tamperingHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	switch req.Question[0].Name {
+	case "case.example.com.":
+		m.Question[0].Name = "CASE.example.com."
+		rr, _ := dns.NewRR("case.example.com. 300 IN A 192.0.2.1")
+		m.Answer = append(m.Answer, rr)
+	case "extra.example.com.":
+		for _, s := range []string{
+			"extra.example.com. 300 IN CNAME www.example.net.",
+			"www.example.net. 300 IN A 192.0.2.1",
+			"bank.example.org. 300 IN A 192.0.2.2",
+			"example.org. 300 IN NS ns.example.org.",
+		} {
+			rr, _ := dns.NewRR(s)
+			if rr.Header().Rrtype == dns.TypeNS {
+				m.Ns = append(m.Ns, rr)
+				continue
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+	default:
+		rr, _ := dns.NewRR("example.com. 300 IN SOA ns.example.com. admin.example.com. 1 3600 600 86400 300")
+		m.Ns = append(m.Ns, rr)
+		m.Rcode = dns.RcodeNameError
+	}
+
+	_ = w.WriteMsg(m)
+}
+
+func TestMismatches(t *testing.T) {
+	srv, err := dnstest.NewServer(dns.HandlerFunc(tamperingHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var tests = []struct {
+		name       string
+		mismatches []string
+		responses  []string
+	}{
+		{"nope.example.com.", nil, nil},
+		{"case.example.com.", []string{"case of the question name changed to CASE.example.com."}, []string{"192.0.2.1"}},
+		{"extra.example.com.", []string{
+			"answer contains unrelated record for bank.example.org.",
+			"authority section contains record for example.org. outside of the bailiwick",
+		}, []string{"www.example.net"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := Query(test.name, "A", srv.Addr)
+			if req.Error != nil {
+				t.Fatal(req.Error)
+			}
+
+			if !equal(req.Mismatches, test.mismatches) {
+				t.Errorf("wrong mismatches, want %q, got %q", test.mismatches, req.Mismatches)
+			}
+
+			var responses []string
+			for _, res := range req.Responses {
+				responses = append(responses, res.Data)
+			}
+			if !equal(responses, test.responses) {
+				t.Errorf("wrong responses, want %v, got %v", test.responses, responses)
+			}
+		})
+	}
+}
+
+// chainHandler answers each request with only the records for the name in
+// the question, without following CNAME records.
+func chainHandler(w dns.ResponseWriter, req *dns.Msg) {
+	records := map[string]string{
+		"www.example.com.":   "www.example.com. 300 IN CNAME cdn.example.net.",
+		"cdn.example.net.":   "cdn.example.net. 300 IN CNAME edge.example.org.",
+		"edge.example.org.":  "edge.example.org. 300 IN A 192.0.2.1",
+		"loop.example.com.":  "loop.example.com. 300 IN CNAME loop2.example.com.",
+		"loop2.example.com.": "loop2.example.com. 300 IN CNAME loop.example.com.",
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	rec, ok := records[req.Question[0].Name]
+	if !ok {
+		m.Rcode = dns.RcodeNameError
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	rr, _ := dns.NewRR(rec)
+	if rr.Header().Rrtype == req.Question[0].Qtype || rr.Header().Rrtype == dns.TypeCNAME {
+		m.Answer = append(m.Answer, rr)
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestFollowCNAMEs(t *testing.T) {
+	srv, err := dnstest.NewServer(dns.HandlerFunc(chainHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var tests = []struct {
+		name  string
+		chain []string
+		err   string
+	}{
+		{"www.example.com.", []string{"cdn.example.net", "edge.example.org", "192.0.2.1"}, ""},
+		{"loop.example.com.", []string{"loop2.example.com", "loop.example.com"}, "CNAME loop at loop.example.com"},
+		{"edge.example.org.", nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := QueryWith(context.Background(), test.name, "A", srv.Addr, QueryOptions{FollowCNAMEs: true})
+			if req.Error != nil {
+				t.Fatal(req.Error)
+			}
+
+			var chain []string
+			for _, res := range req.Chain {
+				chain = append(chain, res.Data)
+			}
+			if !equal(chain, test.chain) {
+				t.Errorf("wrong chain, want %v, got %v", test.chain, chain)
+			}
+
+			if req.ChainError != test.err {
+				t.Errorf("wrong error, want %q, got %q", test.err, req.ChainError)
+			}
+		})
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTSIG(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com",
+		"www.example.com. 300 IN A 192.0.2.1",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone.RequireTSIG = true
+
+	const secret = "c2VjcmV0IGtleSBmb3IgdGVzdGluZw=="
+	srv, err := dnstest.NewTSIGServer(zone, map[string]string{"test-key.": secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// unsigned queries are refused
+	req := QueryWith(context.Background(), "www.example.com.", "A", srv.Addr, QueryOptions{})
+	if req.Status != "REFUSED" {
+		t.Errorf("wrong status for unsigned query, want REFUSED, got %q (error %v)", req.Status, req.Error)
+	}
+
+	key, err := NewTSIG("test-key", "hmac-sha256", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = QueryWith(context.Background(), "www.example.com.", "A", srv.Addr, QueryOptions{TSIG: key})
+	if req.Error != nil {
+		t.Fatal(req.Error)
+	}
+	if len(req.Responses) != 1 || req.Responses[0].Data != "192.0.2.1" {
+		t.Errorf("wrong responses for signed query: %v", req.Responses)
+	}
+
+	// queries signed with the wrong secret are not authorized
+	wrong, err := NewTSIG("test-key", "hmac-sha256", "d3Jvbmc=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = QueryWith(context.Background(), "www.example.com.", "A", srv.Addr, QueryOptions{TSIG: wrong})
+	if req.Status != "NOTAUTH" {
+		t.Errorf("wrong status for query with wrong secret, want NOTAUTH, got %q (error %v)", req.Status, req.Error)
+	}
+}
+
+func TestNewTSIG(t *testing.T) {
+	var tests = []struct {
+		name, algorithm, secret string
+		valid                   bool
+	}{
+		{"key", "hmac-sha256", "c2VjcmV0", true},
+		{"key.", "HMAC-SHA512", "c2VjcmV0", true},
+		{"", "hmac-sha256", "c2VjcmV0", false},
+		{"key", "hmac-sha384", "c2VjcmV0", false},
+		{"key", "hmac-sha256", "not base64!", false},
+		{"key", "hmac-sha256", "", false},
+	}
+
+	for _, test := range tests {
+		key, err := NewTSIG(test.name, test.algorithm, test.secret)
+		if test.valid && err != nil {
+			t.Errorf("NewTSIG(%q, %q, %q) returned error: %v", test.name, test.algorithm, test.secret, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("NewTSIG(%q, %q, %q) did not return an error", test.name, test.algorithm, test.secret)
+		}
+		if err == nil && key.Name != "key." {
+			t.Errorf("wrong key name, want %q, got %q", "key.", key.Name)
+		}
+	}
+}
+
+func TestPruneNXDomain(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com",
+		"www.example.com. 300 IN A 192.0.2.1",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	items := []string{"foo", "a.foo", "b.a.foo", "x.www", "www"}
+	in := make(chan string, len(items))
+	for _, item := range items {
+		in <- item
+	}
+	close(in)
+	out := make(chan Result, len(items))
+
+	r, err := NewResolver(in, out, "FUZZ.example.com.", srv.Addr, []string{"A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.NXDomains = NewNXDomainCache(100)
+
+	r.Run(context.Background())
+	close(out)
+
+	prunedBy := make(map[string]string)
+	for res := range out {
+		if len(res.Requests) != 1 {
+			t.Fatalf("wrong number of requests for %v: %d", res.Hostname, len(res.Requests))
+		}
+		prunedBy[res.Item] = res.Requests[0].PrunedBy
+
+		if res.Item != "www" && !res.Requests[0].NotFound {
+			t.Errorf("%v: wrong status %v", res.Hostname, res.Requests[0].Status)
+		}
+	}
+
+	want := map[string]string{
+		"foo":     "",
+		"a.foo":   "foo.example.com",
+		"b.a.foo": "foo.example.com",
+		"x.www":   "",
+		"www":     "",
+	}
+
+	for item, parent := range want {
+		if prunedBy[item] != parent {
+			t.Errorf("%v: wrong parent, want %q, got %q", item, parent, prunedBy[item])
+		}
+	}
+
+	if n := r.NXDomains.Pruned(); n != 2 {
+		t.Errorf("wrong number of pruned names, want 2, got %d", n)
+	}
+
+	// for CNAME records, NXDOMAIN refers to the last target
+	req := Request{Status: "NXDOMAIN", NotFound: true}
+	req.Raw.Answer = []string{"dangling.example.com. 300 IN CNAME gone.example.com."}
+	if req.NonExistent() {
+		t.Errorf("NXDOMAIN for CNAME target is reported as nonexistent name")
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	var queries int32
+	srv, err := dnstest.NewServer(dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		atomic.AddInt32(&queries, 1)
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		rr, _ := dns.NewRR(req.Question[0].Name + " 300 IN A 192.0.2.1")
+		m.Answer = append(m.Answer, rr)
+		_ = w.WriteMsg(m)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	items := []string{"www", "mail", "WWW", "www", "ftp"}
+	in := make(chan string, len(items))
+	for _, item := range items {
+		in <- item
+	}
+	close(in)
+	out := make(chan Result, len(items))
+
+	r, err := NewResolver(in, out, "FUZZ.example.com.", srv.Addr, []string{"A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Cache = NewResponseCache(2)
+
+	r.Run(context.Background())
+	close(out)
+
+	var cached []string
+	for res := range out {
+		if len(res.Requests) != 1 || len(res.Requests[0].Responses) != 1 {
+			t.Errorf("wrong requests for %v: %v", res.Item, res.Requests)
+		}
+
+		if res.Cached {
+			cached = append(cached, res.Item)
+		}
+	}
+
+	want := []string{"WWW", "www"}
+	if !equal(cached, want) {
+		t.Errorf("wrong cached items, want %v, got %v", want, cached)
+	}
+
+	if n := atomic.LoadInt32(&queries); n != 3 {
+		t.Errorf("wrong number of queries, want 3, got %d", n)
+	}
+
+	if n := r.Cache.Hits(); n != 2 {
+		t.Errorf("wrong number of cache hits, want 2, got %d", n)
+	}
+}
+
+func TestLocalAddrs(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	opts := QueryOptions{LocalAddrs: []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1")}}
+
+	// the address of the same family as the server is used
+	req := QueryWith(context.Background(), "www.example.com.", "A", srv.Addr, opts)
+	if req.Error != nil {
+		t.Fatal(req.Error)
+	}
+	if len(req.Responses) != 1 {
+		t.Errorf("wrong responses: %v", req.Responses)
+	}
+
+	var tests = []struct {
+		server, network string
+		want            string
+	}{
+		{"192.0.2.53", "", "127.0.0.1"},
+		{"[2001:db8::53]:53", "", "::1"},
+		{"2001:db8::53", "", "::1"},
+		{"ns.example.com", "", "127.0.0.1"},
+		{"ns.example.com", "udp6", "::1"},
+	}
+
+	for _, test := range tests {
+		opts.Network = test.network
+		c := opts.client(test.server)
+		if c.Dialer == nil {
+			t.Errorf("%v: no local address set", test.server)
+			continue
+		}
+
+		if addr := c.Dialer.LocalAddr.(*net.UDPAddr).IP.String(); addr != test.want {
+			t.Errorf("%v: wrong local address, want %v, got %v", test.server, test.want, addr)
+		}
+	}
+}
+
+// uncompressedResponse returns the answer for req with several A records
+// without name compression, so it is larger than the compressed message.
+func uncompressedResponse(req *dns.Msg) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Compress = false
+
+	for _, addr := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		rr, _ := dns.NewRR(req.Question[0].Name + " 300 IN A " + addr)
+		m.Answer = append(m.Answer, rr)
+	}
+	return m
+}
+
+func TestQuerySize(t *testing.T) {
+	srv, err := dnstest.NewServer(dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		_ = w.WriteMsg(uncompressedResponse(req))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.com.", dns.TypeA)
+	buf, err := uncompressedResponse(m).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := len(buf)
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			req := QueryWith(context.Background(), "www.example.com.", "A", srv.Addr, QueryOptions{Network: network})
+			if req.Error != nil {
+				t.Fatal(req.Error)
+			}
+
+			if len(req.Responses) != 3 {
+				t.Fatalf("wrong number of responses, want 3, got %d", len(req.Responses))
+			}
+
+			if req.Size != want {
+				t.Errorf("wrong size, want %d, got %d", want, req.Size)
+			}
+		})
+	}
+}
+
+func TestNXDomainCacheSize(t *testing.T) {
+	c := NewNXDomainCache(2)
+	c.Add("a.example.com")
+	c.Add("b.example.com")
+	c.Add("A.example.com.")
+	c.Add("c.example.com")
+
+	if parent, ok := c.Parent("x.a.example.com"); ok {
+		t.Errorf("oldest name was not removed, got parent %v", parent)
+	}
+
+	for _, name := range []string{"x.b.example.com", "x.c.example.com"} {
+		if _, ok := c.Parent(name); !ok {
+			t.Errorf("no parent found for %v", name)
+		}
+	}
+}
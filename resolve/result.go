@@ -0,0 +1,440 @@
+package resolve
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Result is a response as received from a server.
+type Result struct {
+	Hide bool
+
+	Item     string // requested item
+	Hostname string // requested hostname
+
+	Requests []Request
+
+	// Differing lists the name servers which returned different answers
+	// when the requests were sent to more than one name server.
+	Differing []string
+
+	// DifferingSubnets is set if the answers depend on the client subnet
+	// sent with the requests.
+	DifferingSubnets bool
+
+	// DNSSEC is the state of the DNSSEC validation of the answers (secure,
+	// insecure, bogus or indeterminate), if requested. DNSSECReason
+	// explains why the answers are not secure.
+	DNSSEC       string
+	DNSSECReason string
+
+	// Unverified is set if the answers could not be reproduced by sending
+	// the requests to a trusted resolver.
+	Unverified bool
+
+	// Confidence rates how likely the answers belong to an existing host
+	// name instead of a wildcard (low, medium, high), if a Wildcard was
+	// configured for the resolver.
+	Confidence string
+
+	// Labels are the tags of the item from the input, e.g. the source of
+	// the candidate ("ctlog", "permutation").
+	Labels []string
+
+	// HTTP contains the responses of the web servers for the host name, if
+	// probing was requested.
+	HTTP []HTTPProbe
+
+	// Cached is set if the requests were not sent because the host name was
+	// requested before in the run, the result was copied from the cache.
+	Cached bool
+
+	// SANs are the host names in the certificate of the TLS server on port
+	// 443, if harvesting was requested.
+	SANs []string
+}
+
+// HTTPProbe is the response of a web server to a request for the host name.
+type HTTPProbe struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Server   string `json:"server,omitempty"`   // Server header
+	Location string `json:"location,omitempty"` // target of redirects
+	Error    string `json:"error,omitempty"`
+}
+
+// Request contains the data for a request.
+type Request struct {
+	Hide bool // can be set by a filter, response should not be displayed
+
+	Type     string // request type (A, AAAA, etc.)
+	Status   string // dns response status (e.g. NXDOMAIN)
+	Failure  bool   // set if status is anything else than NOERROR
+	NotFound bool   // set if status is NXDOMAIN
+
+	Error error
+
+	// PrunedBy is the name above the host name for which NXDOMAIN was
+	// returned, if the request was skipped for this reason.
+	PrunedBy string
+
+	Server string        // name server which answered the request
+	RTT    time.Duration // round-trip time of the request
+	Size   int           // size of the response as received from the server
+
+	// Mismatches lists the sanity checks the response failed, e.g. records
+	// which do not belong to the question.
+	Mismatches []string
+
+	Flags Flags // header flags of the response
+
+	ClientSubnet      string // EDNS Client Subnet sent with the request
+	ClientSubnetScope int    // scope prefix length returned by the server, -1 if none was returned
+
+	Responses       []Response
+	Nameserver, SOA []Response
+
+	// Chain contains the CNAME records followed for the request, then the
+	// records of the requested type for the last target (if any). It is
+	// only set if following CNAME chains was requested. ChainError
+	// describes why the chain could not be resolved completely.
+	Chain      []Response
+	ChainError string
+
+	Raw struct {
+		Question   []string
+		Answer     []string
+		Nameserver []string
+		Extra      []string
+		EDNS       *EDNS
+	}
+}
+
+// EDNS contains the OPT pseudo-record of a response.
+type EDNS struct {
+	Version       uint8  `json:"version"`
+	UDPSize       uint16 `json:"udp_size"`
+	ExtendedRcode int    `json:"extended_rcode,omitempty"` // upper bits of the response code
+	DNSSECOK      bool   `json:"do,omitempty"`
+
+	ClientSubnet string `json:"client_subnet,omitempty"`
+
+	// ClientSubnetScope is the scope prefix length of the client subnet
+	// option, nil if there was none. A scope of zero means the answer is
+	// valid for all subnets.
+	ClientSubnetScope *int `json:"client_subnet_scope,omitempty"`
+
+	Cookie string `json:"cookie,omitempty"` // hex encoded client and server cookie
+
+	// Options lists all other options, formatted as strings.
+	Options []string `json:"options,omitempty"`
+}
+
+// NewEDNS returns the contents of opt.
+func NewEDNS(opt *dns.OPT) *EDNS {
+	e := &EDNS{
+		Version:       opt.Version(),
+		UDPSize:       opt.UDPSize(),
+		ExtendedRcode: opt.ExtendedRcode(),
+		DNSSECOK:      opt.Do(),
+	}
+
+	for _, o := range opt.Option {
+		switch o := o.(type) {
+		case *dns.EDNS0_SUBNET:
+			scope := int(o.SourceScope)
+			e.ClientSubnet = fmt.Sprintf("%v/%d", o.Address, o.SourceNetmask)
+			e.ClientSubnetScope = &scope
+		case *dns.EDNS0_COOKIE:
+			e.Cookie = o.Cookie
+		default:
+			e.Options = append(e.Options, fmt.Sprintf("%d: %v", o.Option(), o.String()))
+		}
+	}
+
+	return e
+}
+
+// Flags contains the header flags of a response.
+type Flags struct {
+	Authoritative      bool // AA, the answer is from an authoritative server
+	Truncated          bool // TC, the response did not fit in the message
+	RecursionAvailable bool // RA, the server answers recursive queries
+	AuthenticatedData  bool // AD, the server validated the answer with DNSSEC
+}
+
+// List returns the names of the flags which are set in lower case, e.g.
+// "aa", in the order used by dig.
+func (f Flags) List() (flags []string) {
+	if f.Authoritative {
+		flags = append(flags, "aa")
+	}
+	if f.Truncated {
+		flags = append(flags, "tc")
+	}
+	if f.RecursionAvailable {
+		flags = append(flags, "ra")
+	}
+	if f.AuthenticatedData {
+		flags = append(flags, "ad")
+	}
+	return flags
+}
+
+// String returns the names of the flags which are set, separated by spaces.
+func (f Flags) String() string {
+	return strings.Join(f.List(), " ")
+}
+
+// Response contains the response to a DNS request.
+type Response struct {
+	Hide bool
+
+	Type string
+	Data string
+
+	TTL uint
+
+	// Owner and Netname describe the network containing the address of A
+	// and AAAA responses, if it was looked up via RDAP.
+	Owner   string
+	Netname string
+
+	// OpenPorts lists the TCP ports of the address which accepted
+	// connections, if they were checked.
+	OpenPorts []int
+
+	// Segments contains the character strings of TXT responses in
+	// presentation format, Data contains them joined, see TXTData.
+	Segments []string
+
+	// SOA contains the details of SOA responses, Data contains the
+	// primary name server.
+	SOA *SOA
+}
+
+// SOA contains the fields of a SOA record which often identify the provider
+// hosting a zone and its administrator.
+type SOA struct {
+	Mname  string `json:"mname"`  // primary name server
+	Rname  string `json:"rname"`  // mailbox of the administrator, e.g. hostmaster@example.com
+	Serial uint32 `json:"serial"` // version of the zone
+}
+
+// NewSOA returns the details for rec. The mailbox in the record is converted
+// to an email address.
+func NewSOA(rec *dns.SOA) *SOA {
+	return &SOA{
+		Mname:  CleanHostname(rec.Ns),
+		Rname:  mailbox(rec.Mbox),
+		Serial: rec.Serial,
+	}
+}
+
+// mailbox converts the mailbox of a SOA record to an email address, the first
+// label is the local part (RFC 1035, section 8). Escaped dots are part of the
+// local part.
+func mailbox(mbox string) string {
+	mbox = CleanHostname(mbox)
+
+	var local strings.Builder
+	for i := 0; i < len(mbox); i++ {
+		switch {
+		case mbox[i] == '\\' && i+1 < len(mbox):
+			i++
+			local.WriteByte(mbox[i])
+		case mbox[i] == '.':
+			return local.String() + "@" + mbox[i+1:]
+		default:
+			local.WriteByte(mbox[i])
+		}
+	}
+
+	return local.String()
+}
+
+// Empty returns true if no responses returned any result (and no error was received either).
+func (r Result) Empty() bool {
+	for _, request := range r.Requests {
+		if !request.Empty() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Delegation returns true if the responses indicate that this may be a degelated subdomain.
+func (r Result) Delegation() bool {
+	if !r.Empty() {
+		return false
+	}
+
+	for _, request := range r.Requests {
+		if len(request.Nameserver) > 0 || len(request.SOA) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolved returns true if at least one request returned a response which
+// is not hidden.
+func (r Result) Resolved() bool {
+	for _, request := range r.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for _, response := range request.Responses {
+			if !response.Hide {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Failed returns true if all requests returned an error, e.g. because of a
+// timeout.
+func (r Result) Failed() bool {
+	if len(r.Requests) == 0 {
+		return false
+	}
+
+	for _, request := range r.Requests {
+		if request.Error == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Unique returns the sorted list of unique entries.
+func Unique(list []string) (cleaned []string) {
+	known := make(map[string]struct{})
+	for _, entry := range list {
+		if _, ok := known[entry]; ok {
+			continue
+		}
+		known[entry] = struct{}{}
+		cleaned = append(cleaned, entry)
+	}
+	sort.Strings(cleaned)
+	return cleaned
+}
+
+// SortedKeys returns the keys of m in sorted order.
+func SortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SOAs returns the unique details of the SOA responses.
+func (r Result) SOAs() (list []SOA) {
+	seen := make(map[SOA]struct{})
+	for _, req := range r.Requests {
+		for _, res := range req.SOA {
+			if res.SOA == nil {
+				continue
+			}
+			if _, ok := seen[*res.SOA]; ok {
+				continue
+			}
+			seen[*res.SOA] = struct{}{}
+			list = append(list, *res.SOA)
+		}
+	}
+	return list
+}
+
+// Nameservers returns a list of (unique) name servers from SOA and NS records.
+func (r Result) Nameservers() []string {
+	var servers []string
+	for _, req := range r.Requests {
+		for _, res := range req.Nameserver {
+			servers = append(servers, res.Data)
+		}
+
+		for _, res := range req.SOA {
+			servers = append(servers, res.Data)
+		}
+	}
+	return Unique(servers)
+}
+
+// NewResponse returns a response.
+func NewResponse(responseType string, ttl uint32, data string) Response {
+	return Response{
+		Type: responseType,
+		TTL:  uint(ttl),
+		Data: data,
+	}
+}
+
+// TXTData returns the data for the character strings of a TXT record. The
+// strings are joined without separator, as required e.g. for SPF (RFC 7208,
+// section 3.3) and long verification tokens. If separate is true, each string
+// is quoted and the strings are separated by spaces, like in a zone file.
+// The strings are expected in presentation format, so quotes, backslashes and
+// non-printable characters are already escaped.
+func TXTData(segments []string, separate bool) string {
+	if !separate {
+		return strings.Join(segments, "")
+	}
+
+	quoted := make([]string, 0, len(segments))
+	for _, s := range segments {
+		quoted = append(quoted, `"`+s+`"`)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Empty returns true if the response does not have any results and no error was returned.
+func (r Request) Empty() bool {
+	if r.Failure {
+		return false
+	}
+
+	if len(r.Responses) > 0 {
+		return false
+	}
+
+	return true
+}
+
+// answerKey returns a string describing the answer to the request, which can
+// be compared with other answers. For requests which failed, ok is false.
+func (r Request) answerKey() (key string, ok bool) {
+	if r.Error != nil {
+		return "", false
+	}
+
+	var answers []string
+	for _, response := range r.Responses {
+		answers = append(answers, response.Type+" "+response.Data)
+	}
+	sort.Strings(answers)
+
+	return r.Status + ": " + strings.Join(answers, ", "), true
+}
+
+// Differs returns true if both requests were answered and the answers are
+// not the same.
+func (r Request) Differs(other Request) bool {
+	a, okA := r.answerKey()
+	b, okB := other.answerKey()
+	return okA && okB && a != b
+}
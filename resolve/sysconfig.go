@@ -0,0 +1,54 @@
+package resolve
+
+import (
+	"io"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SystemConfig contains the name resolution settings of the system.
+type SystemConfig struct {
+	// Nameservers lists all configured name servers, with the port if it is
+	// not the default one.
+	Nameservers []string
+
+	// Search contains the domains which are appended to relative names.
+	Search []string
+
+	// Ndots is the number of dots a name must contain to be considered
+	// absolute.
+	Ndots int
+}
+
+// ParseResolvConf parses a file in the format of /etc/resolv.conf.
+func ParseResolvConf(rd io.Reader) (*SystemConfig, error) {
+	conf, err := dns.ClientConfigFromReader(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &SystemConfig{
+		Search: conf.Search,
+		Ndots:  conf.Ndots,
+	}
+
+	for _, server := range conf.Servers {
+		if conf.Port != "" && conf.Port != "53" {
+			server = net.JoinHostPort(server, conf.Port)
+		}
+		cfg.Nameservers = append(cfg.Nameservers, server)
+	}
+
+	return cfg, nil
+}
+
+// Relative returns true if name is not fully qualified and contains fewer
+// dots than required by Ndots, so the search domains apply.
+func (cfg *SystemConfig) Relative(name string) bool {
+	if strings.HasSuffix(name, ".") {
+		return false
+	}
+	return strings.Count(name, ".") < cfg.Ndots
+}
@@ -0,0 +1,42 @@
+package resolve
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	cfg, err := ParseResolvConf(strings.NewReader(`# generated
+nameserver 192.0.2.53
+nameserver 2001:db8::53
+search corp.example.com example.com
+options ndots:2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"192.0.2.53", "2001:db8::53"}; !equal(cfg.Nameservers, want) {
+		t.Errorf("wrong name servers, want %v, got %v", want, cfg.Nameservers)
+	}
+
+	if want := []string{"corp.example.com", "example.com"}; !equal(cfg.Search, want) {
+		t.Errorf("wrong search domains, want %v, got %v", want, cfg.Search)
+	}
+
+	var tests = []struct {
+		name     string
+		relative bool
+	}{
+		{"FUZZ", true},
+		{"FUZZ.dev", true},
+		{"FUZZ.dev.example", false},
+		{"FUZZ.", false},
+	}
+
+	for _, test := range tests {
+		if got := cfg.Relative(test.name); got != test.relative {
+			t.Errorf("Relative(%q): want %v, got %v", test.name, test.relative, got)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package resolve
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TSIGAlgorithms maps the names of the supported TSIG algorithms to the
+// names used in the records.
+var TSIGAlgorithms = map[string]string{
+	"hmac-md5":    dns.HmacMD5,
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// TSIGAlgorithmNames returns the sorted names of the supported algorithms.
+func TSIGAlgorithmNames() []string {
+	var names []string
+	for name := range TSIGAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tsigFudge is the number of seconds the time of the server may differ.
+const tsigFudge = 300
+
+// TSIG is a key for signing queries with a transaction signature (RFC 8945),
+// e.g. for authoritative servers which only answer signed queries.
+type TSIG struct {
+	Name      string // name of the key as a fully qualified domain name
+	Algorithm string // algorithm as used in the records, e.g. "hmac-sha256."
+	Secret    string // base64 encoded secret
+}
+
+// NewTSIG returns a key for name with the algorithm (one of TSIGAlgorithms)
+// and the base64 encoded secret.
+func NewTSIG(name, algorithm, secret string) (*TSIG, error) {
+	if name == "" {
+		return nil, errors.New("TSIG key name is empty")
+	}
+
+	alg, ok := TSIGAlgorithms[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("unknown TSIG algorithm %q, valid: %v", algorithm, strings.Join(TSIGAlgorithmNames(), ", "))
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil || secret == "" {
+		return nil, errors.New("TSIG secret is not valid base64")
+	}
+
+	return &TSIG{
+		Name:      dns.Fqdn(strings.ToLower(name)),
+		Algorithm: alg,
+		Secret:    secret,
+	}, nil
+}
+
+// Sign adds the TSIG record to m, it must be called after all other records
+// have been added. The returned secrets need to be set as TsigSecret for the
+// dns.Client or dns.Transfer sending m, which then signs the message and
+// verifies the signature of the response.
+func (t *TSIG) Sign(m *dns.Msg) (secrets map[string]string) {
+	m.SetTsig(t.Name, t.Algorithm, tsigFudge, time.Now().Unix())
+	return map[string]string{t.Name: t.Secret}
+}
@@ -0,0 +1,61 @@
+package resolve
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WeightedServers picks name servers at random, proportionally to their
+// weights, e.g. so that self-hosted resolvers answer more queries than public
+// ones.
+type WeightedServers struct {
+	servers    []string
+	cumulative []float64 // sum of the weights up to and including each server
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewWeightedServers returns a new WeightedServers for the servers. Servers
+// without a weight or with a weight which is not positive get weight one.
+func NewWeightedServers(servers []string, weights map[string]float64) *WeightedServers {
+	w := &WeightedServers{
+		servers:    servers,
+		cumulative: make([]float64, len(servers)),
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	var sum float64
+	for i, server := range servers {
+		weight, ok := weights[server]
+		if !ok || weight <= 0 {
+			weight = 1
+		}
+		sum += weight
+		w.cumulative[i] = sum
+	}
+
+	return w
+}
+
+// Pick returns a server chosen at random.
+func (w *WeightedServers) Pick() string {
+	if len(w.servers) == 0 {
+		return ""
+	}
+
+	w.mu.Lock()
+	x := w.rnd.Float64() * w.cumulative[len(w.cumulative)-1]
+	w.mu.Unlock()
+
+	i := sort.Search(len(w.cumulative), func(i int) bool {
+		return w.cumulative[i] > x
+	})
+	if i == len(w.servers) {
+		i--
+	}
+
+	return w.servers[i]
+}
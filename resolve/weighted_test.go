@@ -0,0 +1,27 @@
+package resolve
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestWeightedServers(t *testing.T) {
+	servers := []string{"a", "b", "c"}
+	w := NewWeightedServers(servers, map[string]float64{"a": 6, "c": 3})
+	w.rnd = rand.New(rand.NewSource(23))
+
+	const n = 10000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[w.Pick()]++
+	}
+
+	// server b has the default weight one
+	want := map[string]float64{"a": 0.6, "b": 0.1, "c": 0.3}
+	for server, share := range want {
+		got := float64(counts[server]) / n
+		if got < share-0.02 || got > share+0.02 {
+			t.Errorf("server %v: wrong share of queries, want %.2f, got %.3f", server, share, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Confidence levels for results, see Wildcard.Confidence.
+const (
+	ConfidenceLow    = "low"    // the answers are the same as for the wildcard
+	ConfidenceMedium = "medium" // some answers are the same as for the wildcard
+	ConfidenceHigh   = "high"   // the answers are unrelated to the wildcard
+)
+
+// ConfidenceLevels lists the confidence levels, lowest first.
+var ConfidenceLevels = []string{ConfidenceLow, ConfidenceMedium, ConfidenceHigh}
+
+// ConfidenceRank returns the position of level in ConfidenceLevels, or -1
+// for unknown levels.
+func ConfidenceRank(level string) int {
+	for i, l := range ConfidenceLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// wildcardProbes is the number of random names requested to detect a
+// wildcard, more than one catches wildcards returning rotating answers.
+const wildcardProbes = 3
+
+// Wildcard contains the answers returned for host names which do not exist.
+type Wildcard struct {
+	// Answers maps the request type to the answers ("type data", e.g. "A
+	// 192.0.2.1") received for random names.
+	Answers map[string][]string `json:"answers,omitempty"`
+}
+
+// DetectWildcard requests random names for the template from server and
+// returns the answers received. If the zone does not have a wildcard, the
+// returned Wildcard does not contain any answers. If wait is not nil, it is
+// called before each request, e.g. to respect a rate limit.
+func DetectWildcard(ctx context.Context, template, server string, requestTypes []string, wait func(context.Context) error) (*Wildcard, error) {
+	w := &Wildcard{Answers: make(map[string][]string)}
+
+	for i := 0; i < wildcardProbes; i++ {
+		item := fmt.Sprintf("taifun-%08x", rand.Uint32())
+		name := strings.Replace(template, "FUZZ", item, -1)
+
+		for _, requestType := range requestTypes {
+			if wait != nil {
+				err := wait(ctx)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			req := QueryWith(ctx, name, requestType, server, QueryOptions{})
+			if req.Error != nil {
+				return nil, fmt.Errorf("detecting wildcard failed: %v", req.Error)
+			}
+
+			for _, response := range req.Responses {
+				w.Answers[requestType] = append(w.Answers[requestType], response.Type+" "+response.Data)
+			}
+		}
+	}
+
+	for requestType, answers := range w.Answers {
+		w.Answers[requestType] = Unique(answers)
+	}
+
+	return w, nil
+}
+
+// Found returns true if a wildcard has been detected.
+func (w *Wildcard) Found() bool {
+	return len(w.Answers) > 0
+}
+
+// String returns the answers for all request types.
+func (w *Wildcard) String() string {
+	var answers []string
+	for _, list := range w.Answers {
+		answers = append(answers, list...)
+	}
+	return strings.Join(Unique(answers), ", ")
+}
+
+// Confidence rates how likely the answers in res belong to an existing host
+// name instead of a wildcard: low if all answers are also returned for the
+// wildcard, medium if some are, and high otherwise. For results without any
+// answers, the empty string is returned.
+func (w *Wildcard) Confidence(res Result) string {
+	var answers, overlapping int
+	for _, req := range res.Requests {
+		known := make(map[string]struct{}, len(w.Answers[req.Type]))
+		for _, answer := range w.Answers[req.Type] {
+			known[answer] = struct{}{}
+		}
+
+		for _, response := range req.Responses {
+			answers++
+			if _, ok := known[response.Type+" "+response.Data]; ok {
+				overlapping++
+			}
+		}
+	}
+
+	switch {
+	case answers == 0:
+		return ""
+	case overlapping == answers:
+		return ConfidenceLow
+	case overlapping > 0:
+		return ConfidenceMedium
+	default:
+		return ConfidenceHigh
+	}
+}
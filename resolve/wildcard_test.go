@@ -0,0 +1,61 @@
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/happal/taifun/dnstest"
+)
+
+func TestWildcardConfidence(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com",
+		"*.example.com. 300 IN A 192.0.2.1",
+		"www.example.com. 300 IN A 192.0.2.2",
+		"both.example.com. 300 IN A 192.0.2.1",
+		"both.example.com. 300 IN A 192.0.2.3",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	w, err := DetectWildcard(context.Background(), "FUZZ.example.com.", srv.Addr, []string{"A", "AAAA"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.Found() {
+		t.Fatal("wildcard not detected")
+	}
+
+	var tests = []struct {
+		name       string
+		confidence string
+	}{
+		{"www.example.com.", ConfidenceHigh},
+		{"both.example.com.", ConfidenceMedium},
+		{"random.example.com.", ConfidenceLow},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := Result{Requests: []Request{
+				Query(test.name, "A", srv.Addr),
+				Query(test.name, "AAAA", srv.Addr),
+			}}
+
+			if c := w.Confidence(res); c != test.confidence {
+				t.Errorf("wrong confidence, want %q, got %q", test.confidence, c)
+			}
+		})
+	}
+
+	if c := w.Confidence(Result{}); c != "" {
+		t.Errorf("unexpected confidence %q for empty result", c)
+	}
+}
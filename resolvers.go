@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/resolve"
+)
+
+// readResolvers reads a list of name servers from a file, one per line.
+// Empty lines and lines starting with # are ignored. For the file name
+// "builtin", the built-in list of public resolvers is returned.
+func readResolvers(filename string) (resolvers []string, err error) {
+	if filename == builtinResolversName {
+		return readBuiltinResolvers()
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers, err = parseResolvers(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return resolvers, f.Close()
+}
+
+// parseResolvers reads a list of name servers from rd like readResolvers.
+func parseResolvers(rd io.Reader) (resolvers []string, err error) {
+	sc := bufio.NewScanner(rd)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		resolvers = append(resolvers, line)
+	}
+
+	return resolvers, sc.Err()
+}
+
+// readWeightedResolvers reads a list of name servers like readResolvers. Each
+// line may contain a weight after the server, separated by whitespace (e.g.
+// "192.0.2.1 5"), the weights are returned in a map. If no weights are
+// specified, the map is empty.
+func readWeightedResolvers(filename string) (resolvers []string, weights map[string]float64, err error) {
+	lines, err := readResolvers(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	weights = make(map[string]float64)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, nil, fmt.Errorf("invalid resolver %q in %v", line, filename)
+		}
+
+		resolvers = append(resolvers, fields[0])
+		if len(fields) == 2 {
+			weight, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil || weight <= 0 {
+				return nil, nil, fmt.Errorf("invalid weight %q for resolver %v in %v", fields[1], fields[0], filename)
+			}
+			weights[fields[0]] = weight
+		}
+	}
+
+	return resolvers, weights, nil
+}
+
+// serverNetwork returns "udp4" or "udp6" if server is an IP address (with an
+// optional port), or the empty string for host names and JSON APIs.
+func serverNetwork(server string) string {
+	if resolve.IsJSONServer(server) {
+		return ""
+	}
+
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "udp4"
+	default:
+		return "udp6"
+	}
+}
+
+// restrictTransport removes the resolvers from opts which cannot be reached
+// via the network selected with -4 or -6.
+func restrictTransport(term cli.Terminal, opts *Options) error {
+	if opts.network == "" {
+		return nil
+	}
+
+	if opts.Nameserver != "" && len(opts.Resolvers) == 0 {
+		if resolve.IsJSONServer(opts.Nameserver) {
+			return errors.New("-4 and -6 cannot be used with a JSON API")
+		}
+
+		if n := serverNetwork(opts.Nameserver); n != "" && n != opts.network {
+			return fmt.Errorf("name server %v cannot be reached via %v", opts.Nameserver, opts.network)
+		}
+		return nil
+	}
+
+	var usable, skipped []string
+	for _, server := range opts.Resolvers {
+		if n := serverNetwork(server); n != "" && n != opts.network {
+			skipped = append(skipped, server)
+			continue
+		}
+		usable = append(usable, server)
+	}
+
+	if len(usable) == 0 {
+		return fmt.Errorf("none of the resolvers can be reached via %v", opts.network)
+	}
+
+	if len(skipped) > 0 && !opts.quiet() {
+		cli.Warnf(term, "not using %d resolvers which cannot be reached via %v: %v", len(skipped), opts.network, strings.Join(skipped, ", "))
+	}
+
+	opts.Resolvers = usable
+	return nil
+}
+
+// interfaceAddrs returns the addresses of the network interface name which
+// can be used to send queries via network ("udp4", "udp6" or empty for
+// both). Link-local addresses are not used.
+func interfaceAddrs(name, network string) (addrs []net.IP, err error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range list {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+
+		v4 := ipnet.IP.To4() != nil
+		if (network == "udp4" && !v4) || (network == "udp6" && v4) {
+			continue
+		}
+
+		addrs = append(addrs, ipnet.IP)
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("interface %v has no usable addresses", name)
+	}
+
+	return addrs, nil
+}
+
+// useSystemNameservers configures the name servers of the system for opts: a
+// single server is used as the name server, several are used like a list
+// passed via --resolvers.
+func useSystemNameservers(term cli.Terminal, opts *Options) error {
+	cfg, err := resolve.ReadSystemConfig()
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.Nameservers) == 1 {
+		opts.Nameserver = cfg.Nameservers[0]
+		if !opts.quiet() {
+			term.Printf("found system nameserver %v", opts.Nameserver)
+		}
+		return nil
+	}
+
+	opts.Resolvers = cfg.Nameservers
+	if !opts.quiet() {
+		term.Printf("found system nameservers %v", strings.Join(opts.Resolvers, ", "))
+		if opts.Threads < len(opts.Resolvers) && !opts.AutoThreads {
+			term.Printf("only %d of %d resolvers are used, increase the number of threads to use all", opts.Threads, len(opts.Resolvers))
+		}
+	}
+
+	return nil
+}
+
+// newHealth returns a Health for the name servers in opts, which reports
+// servers which are benched or return. With a single name server there is
+// nothing to switch to, so nil is returned.
+func newHealth(term cli.Terminal, opts *Options) *resolve.Health {
+	servers := opts.Resolvers
+	if len(servers) < 2 {
+		return nil
+	}
+
+	health := resolve.NewHealth(servers)
+	if !opts.quiet() {
+		health.OnChange = func(server string, benched bool, reason string) {
+			if benched {
+				term.Printf("name server %v is throttling: %v", server, reason)
+				return
+			}
+			term.Printf("name server %v is used again", server)
+		}
+	}
+
+	return health
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/report"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// recordedOptions returns the options recorded in data, or nil if the log
+// does not contain them.
+func recordedOptions(data *report.Data) (*Options, error) {
+	if len(data.Options) == 0 || string(data.Options) == "null" {
+		return nil, nil
+	}
+
+	var opts Options
+	err := json.Unmarshal(data.Options, &opts)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the options failed: %v", err)
+	}
+
+	return &opts, nil
+}
+
+// resumeOptions returns the options for continuing the run recorded in data.
+// The recorded position only covers values from the start of the input whose
+// results have all been written, so no value is skipped that was not
+// processed.
+func resumeOptions(data *report.Data, wordlist string) (*Options, error) {
+	recorded, err := recordedOptions(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if recorded == nil {
+		return nil, fmt.Errorf("log does not contain the options (schema version %d), it was written by an older version", data.SchemaVersion)
+	}
+
+	if !data.Cancelled && data.TotalRequests > 0 && data.SentRequests >= data.TotalRequests {
+		return nil, errors.New("the run is already complete, nothing to do")
+	}
+
+	opts := *recorded
+
+	// never run code named in the log, it may have been modified. The
+	// plugins are registered by the plugin files, so they are skipped too.
+	opts.PluginFiles = nil
+	opts.PluginCommands = nil
+	opts.OnChange = ""
+	opts.skippedPlugins = opts.Plugins
+	opts.Plugins = nil
+
+	if wordlist != "" {
+		if opts.Range != "" {
+			return nil, errors.New("the recorded run used a range, no wordlist needed")
+		}
+		opts.Filename = wordlist
+	}
+
+	if opts.Filename == "-" {
+		return nil, errors.New("the recorded run read from stdin, please specify the wordlist")
+	}
+
+	if opts.Limit > 0 {
+		opts.Limit -= data.ProcessedValues()
+		if opts.Limit <= 0 {
+			return nil, errors.New("the limit has already been reached, nothing to do")
+		}
+	}
+	opts.Skip += data.ProcessedValues()
+
+	return &opts, nil
+}
+
+// resumeSecrets sets the secrets for resuming the run in opts, they are not
+// saved in the log. It returns an error if the recorded run needs a secret
+// which is not specified.
+func resumeSecrets(opts *Options, tsigSecret, kafkaPassword string) error {
+	if opts.TSIGName != "" && tsigSecret == "" {
+		return fmt.Errorf("the recorded run used the TSIG key %q, please specify the secret with --tsig-secret", opts.TSIGName)
+	}
+
+	if opts.KafkaSASL != "" && kafkaPassword == "" {
+		return fmt.Errorf("the recorded run authenticated to Kafka as %q, please specify the password with --kafka-password", opts.KafkaUsername)
+	}
+
+	opts.tsigSecret = tsigSecret
+	opts.kafkaPassword = kafkaPassword
+	return nil
+}
+
+func newResumeCommand() *cobra.Command {
+	var logfile string
+	var configFile, profile string
+	var tsigSecret, kafkaPassword string
+
+	cmd := &cobra.Command{
+		Use:                   "resume [options] LOGFILE.json [WORDLIST]",
+		Short:                 "Continue a run recorded in a JSON log where it stopped",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("no JSON log specified")
+			}
+
+			if len(args) > 2 {
+				return errors.New("too many arguments")
+			}
+
+			err := applyProfile(cmd, configFile, profile)
+			if err != nil {
+				return err
+			}
+
+			data, err := report.ReadData(args[0])
+			if err != nil {
+				return fmt.Errorf("reading %v failed: %v", args[0], err)
+			}
+
+			var wordlist string
+			if len(args) > 1 {
+				wordlist = args[1]
+			}
+
+			opts, err := resumeOptions(data, wordlist)
+			if err != nil {
+				return err
+			}
+
+			err = resumeSecrets(opts, tsigSecret, kafkaPassword)
+			if err != nil {
+				return err
+			}
+
+			// never overwrite the log of the previous run
+			opts.Logdir = ""
+			opts.Logfile = logfile
+			if opts.Logfile == "" {
+				prefix := strings.TrimSuffix(args[0], report.CompressedSuffix)
+				prefix = strings.TrimSuffix(prefix, ".json")
+				opts.Logfile = prefix + "_resumed"
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				return run(ctx, g, opts, []string{data.Hostname})
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&logfile, "logfile", "", "write the log files for the resumed run to `filename` (default: name of the log with the suffix _resumed)")
+	flags.StringVar(&tsigSecret, "tsig-secret", "", "sign queries with the base64 encoded TSIG `secret`, needed if the recorded run used --tsig-name")
+	flags.StringVar(&kafkaPassword, "kafka-password", "", "use `password` for --kafka-sasl, needed if the recorded run used it")
+	addProfileFlags(flags, &configFile, &profile)
+
+	return cmd
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/happal/taifun/report"
+	"github.com/spf13/pflag"
+)
+
+func TestResumeOptionsPlugins(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	marker := filepath.Join(tempdir, "marker")
+	command := fmt.Sprintf("touch %s", marker)
+
+	// a modified log must not be able to start any commands
+	buf, err := json.Marshal(map[string]interface{}{
+		"filename":        "words.txt",
+		"plugin_files":    []string{filepath.Join(tempdir, "plugin.so")},
+		"plugin_commands": []string{command},
+		"on_change":       command,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := &report.Data{
+		TotalRequests: 100,
+		SentRequests:  10,
+		Position:      10,
+		Cancelled:     true,
+		Options:       buf,
+	}
+
+	opts, err := resumeOptions(data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(opts.PluginFiles) != 0 || len(opts.PluginCommands) != 0 || opts.OnChange != "" {
+		t.Fatalf("commands from the log were used: %v %v %q", opts.PluginFiles, opts.PluginCommands, opts.OnChange)
+	}
+
+	chain, err := setupPlugins(opts, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chain) != 0 {
+		t.Errorf("plugins started: %v", chain)
+	}
+
+	for _, p := range chain {
+		_ = p.Close()
+	}
+
+	_, err = os.Stat(marker)
+	if !os.IsNotExist(err) {
+		t.Errorf("command from the log was run: %v", err)
+	}
+
+	// the options written to a new log do not contain the commands either
+	buf, err = json.Marshal(&Options{PluginCommands: []string{command}, OnChange: command})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recorded map[string]interface{}
+	err = json.Unmarshal(buf, &recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"plugin_files", "plugin_commands", "on_change"} {
+		if _, ok := recorded[name]; ok {
+			t.Errorf("field %v saved with the options", name)
+		}
+	}
+}
+
+// recordedData returns the data of a cancelled run with the options.
+func recordedData(t testing.TB, options map[string]interface{}) *report.Data {
+	buf, err := json.Marshal(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &report.Data{
+		TotalRequests: 100,
+		SentRequests:  10,
+		Position:      10,
+		Cancelled:     true,
+		Options:       buf,
+	}
+}
+
+func TestResumeOptionsSkipPlugins(t *testing.T) {
+	data := recordedData(t, map[string]interface{}{
+		"filename": "words.txt",
+		"plugins":  []string{"takeover", "score:10"},
+	})
+
+	opts, err := resumeOptions(data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(opts.Plugins) != 0 {
+		t.Errorf("plugins of the recorded run are used: %v", opts.Plugins)
+	}
+
+	want := []string{"takeover", "score:10"}
+	if !reflect.DeepEqual(opts.skippedPlugins, want) {
+		t.Errorf("wrong skipped plugins, want %v, got %v", want, opts.skippedPlugins)
+	}
+
+	// no plugin is registered, setting up the chain must not fail
+	chain, err := setupPlugins(opts, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chain) != 0 {
+		t.Errorf("plugins started: %v", chain)
+	}
+}
+
+// recordedRun returns the data of a cancelled run started with the flags in
+// args.
+func recordedRun(t testing.TB, args ...string) *report.Data {
+	// the number of threads is set up by the run command
+	opts := Options{Threads: 2}
+	flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	addRunFlags(flags, &opts)
+	addDisplayFlags(flags, &opts)
+
+	err := flags.Parse(append([]string{"--file", "words.txt"}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := json.Marshal(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := recordedData(t, nil)
+	data.Options = buf
+	return data
+}
+
+func TestResumeSecrets(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	tsig := []string{"--tsig-name", "key.example.com.", "--tsig-secret", secret}
+	kafka := []string{"--kafka-brokers", "localhost:9092", "--kafka-topic", "results",
+		"--kafka-sasl", "plain", "--kafka-username", "taifun", "--kafka-password", "secret"}
+
+	var tests = []struct {
+		name          string
+		args          []string
+		tsigSecret    string
+		kafkaPassword string
+		err           bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "tsig-missing",
+			args: tsig,
+			err:  true,
+		},
+		{
+			name:       "tsig",
+			args:       tsig,
+			tsigSecret: secret,
+		},
+		{
+			name: "kafka-missing",
+			args: kafka,
+			err:  true,
+		},
+		{
+			name:          "kafka",
+			args:          kafka,
+			kafkaPassword: "secret",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := resumeOptions(recordedRun(t, test.args...), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the secrets are not saved in the log
+			if opts.tsigSecret != "" || opts.kafkaPassword != "" {
+				t.Fatalf("secrets read from the log: %q and %q", opts.tsigSecret, opts.kafkaPassword)
+			}
+
+			err = resumeSecrets(opts, test.tsigSecret, test.kafkaPassword)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error not returned")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the options for the resumed run must be valid
+			err = opts.valid()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if opts.tsigSecret != test.tsigSecret || opts.kafkaPassword != test.kafkaPassword {
+				t.Errorf("secrets not set, got %q and %q", opts.tsigSecret, opts.kafkaPassword)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+// Package rpc contains the gRPC service for controlling taifun remotely: jobs
+// can be started and cancelled, and their results and progress streamed to
+// the client. The service and its messages are defined in taifun.proto, the
+// Go code is generated with protoc-gen-go.
+package rpc
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. taifun.proto
@@ -0,0 +1,126 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testServer struct{}
+
+func (testServer) StartJob(ctx context.Context, req *JobRequest) (*JobStatus, error) {
+	return &JobStatus{Id: "1", State: State_RUNNING, Error: req.Hostname}, nil
+}
+
+func (testServer) StreamResults(id *JobID, stream Taifun_StreamResultsServer) error {
+	for _, name := range []string{"www", "mail"} {
+		err := stream.Send(&Result{
+			Item:     name,
+			Hostname: name + ".example.com",
+			Requests: []*Request{
+				{Type: "A", Status: "NOERROR", RttMs: 1.5, Responses: []*Response{{Type: "A", Data: "192.0.2.1", Ttl: 300}}},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (testServer) StreamProgress(id *JobID, stream Taifun_StreamProgressServer) error {
+	return stream.Send(&Progress{Total: 10, Sent: 2, Rate: 0.5, State: State_DONE})
+}
+
+func (testServer) CancelJob(ctx context.Context, id *JobID) (*JobStatus, error) {
+	return &JobStatus{Id: id.Id, State: State_CANCELLED}, nil
+}
+
+func testClient(t testing.TB) (TaifunClient, func()) {
+	ln := bufconn.Listen(1 << 20)
+
+	srv := grpc.NewServer()
+	RegisterTaifunServer(srv, testServer{})
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+
+	dial := func(ctx context.Context, addr string) (net.Conn, error) {
+		return ln.Dial()
+	}
+
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(dial), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewTaifunClient(conn), func() {
+		_ = conn.Close()
+		srv.Stop()
+	}
+}
+
+func TestService(t *testing.T) {
+	client, cleanup := testClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	st, err := client.StartJob(ctx, &JobRequest{Hostname: "FUZZ.example.com", Values: []string{"www"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Id != "1" || st.State != State_RUNNING || st.Error != "FUZZ.example.com" {
+		t.Fatalf("wrong status returned: %v", st)
+	}
+
+	results, err := client.StreamResults(ctx, &JobID{Id: st.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hosts []string
+	for {
+		res, err := results.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Requests) != 1 || res.Requests[0].RttMs != 1.5 || res.Requests[0].Responses[0].Ttl != 300 {
+			t.Fatalf("wrong result received: %v", res)
+		}
+		hosts = append(hosts, res.Hostname)
+	}
+
+	if len(hosts) != 2 || hosts[0] != "www.example.com" || hosts[1] != "mail.example.com" {
+		t.Fatalf("wrong results received: %v", hosts)
+	}
+
+	progress, err := client.StreamProgress(ctx, &JobID{Id: st.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := progress.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Total != 10 || p.Sent != 2 || p.Rate != 0.5 || p.State != State_DONE {
+		t.Fatalf("wrong progress received: %v", p)
+	}
+
+	st, err = client.CancelJob(ctx, &JobID{Id: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != State_CANCELLED {
+		t.Fatalf("wrong state returned: %v", st.State)
+	}
+}
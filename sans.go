@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// SANHarvester connects to port 443 of the addresses of the shown results
+// which resolved and records the host names in the certificates. New names
+// matching the template are requested in the same run via the Recurser, at
+// most limit names in total.
+type SANHarvester struct {
+	template string
+	timeout  time.Duration
+	threads  int
+	recurser *Recurser
+
+	mu    sync.Mutex
+	seen  map[string]struct{} // items of the results and the names requested
+	limit int                 // number of names which can still be requested
+}
+
+// NewSANHarvester returns a new SANHarvester, waiting at most timeout for
+// each TLS handshake and processing threads results in parallel.
+func NewSANHarvester(template string, recurser *Recurser, limit int, timeout time.Duration, threads int) *SANHarvester {
+	if threads < 1 {
+		threads = 1
+	}
+
+	return &SANHarvester{
+		template: template,
+		timeout:  timeout,
+		threads:  threads,
+		recurser: recurser,
+		seen:     make(map[string]struct{}),
+		limit:    limit,
+	}
+}
+
+// certificateNames returns the host names in the certificate of the server on
+// port 443 of addr, sending hostname via SNI.
+func (h *SANHarvester) certificateNames(ctx context.Context, addr, hostname string) ([]string, error) {
+	dialer := &net.Dialer{Timeout: h.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, "443"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(h.timeout))
+
+	client := tls.Client(conn, &tls.Config{
+		ServerName:         hostname,
+		InsecureSkipVerify: true,
+	})
+	err = client.Handshake()
+	if err != nil {
+		return nil, err
+	}
+
+	certs := client.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, nil
+	}
+
+	names := certs[0].DNSNames
+	if len(names) == 0 && certs[0].Subject.CommonName != "" {
+		names = []string{certs[0].Subject.CommonName}
+	}
+	return names, nil
+}
+
+// harvest collects the names for res and requests the new items.
+func (h *SANHarvester) harvest(ctx context.Context, res resolve.Result) resolve.Result {
+	h.mu.Lock()
+	h.seen[res.Item] = struct{}{}
+	h.mu.Unlock()
+
+	if res.Hide || !res.Resolved() {
+		return res
+	}
+
+	names := make(map[string]struct{})
+	for _, request := range res.Requests {
+		for _, response := range request.Responses {
+			if response.Hide || (response.Type != "A" && response.Type != "AAAA") {
+				continue
+			}
+
+			list, err := h.certificateNames(ctx, response.Data, res.Hostname)
+			if err != nil {
+				// port 443 is not open or the server does not speak TLS
+				continue
+			}
+
+			for _, name := range list {
+				name = strings.TrimSuffix(strings.ToLower(name), ".")
+				names[name] = struct{}{}
+			}
+		}
+	}
+
+	for name := range names {
+		res.SANs = append(res.SANs, name)
+	}
+	sort.Strings(res.SANs)
+
+	var items []string
+	h.mu.Lock()
+	for _, name := range res.SANs {
+		// request the domain of a wildcard certificate
+		item, ok := templateItem(h.template, strings.TrimPrefix(name, "*."))
+		if !ok || strings.Contains(item, "*") {
+			continue
+		}
+
+		if _, ok := h.seen[item]; ok {
+			continue
+		}
+
+		if h.limit <= 0 {
+			break
+		}
+
+		h.seen[item] = struct{}{}
+		h.limit--
+		items = append(items, item)
+	}
+	h.mu.Unlock()
+
+	h.recurser.Add(items)
+
+	return res
+}
+
+// Run harvests the names for all results from in and sends them to out.
+func (h *SANHarvester) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forwardParallel(ctx, h.threads, in, out, func(res resolve.Result) resolve.Result {
+		return h.harvest(ctx, res)
+	})
+}
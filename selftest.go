@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/dnstest"
+	"github.com/happal/taifun/report"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// selftestZone is served by the DNS server used for the self test.
+var selftestZone = []string{
+	"www.example.com. 300 IN A 192.0.2.1",
+	"mail.example.com. 300 IN A 192.0.2.2",
+	"mail.example.com. 300 IN AAAA 2001:db8::2",
+	"cdn.example.com. 300 IN CNAME edge.example.com.",
+	"edge.example.com. 300 IN CNAME lb.example.com.",
+	"lb.example.com. 300 IN A 192.0.2.3",
+	"*.wild.example.com. 300 IN A 192.0.2.50",
+	"hidden.example.com. 300 IN A 192.0.2.99",
+	"dev.example.com. 300 IN NS ns1.example.net.",
+}
+
+// selftestItems are the values tested during the self test.
+var selftestItems = []string{"www", "mail", "cdn", "foo.wild", "hidden", "dev", "nope"}
+
+// selftestExpected are the answers expected in the log for the items.
+var selftestExpected = map[string][]string{
+	"www.example.com":      {"A 192.0.2.1"},
+	"mail.example.com":     {"A 192.0.2.2", "AAAA 2001:db8::2"},
+	"cdn.example.com":      {"CNAME edge.example.com"},
+	"foo.wild.example.com": {"A 192.0.2.50"},
+	"dev.example.com":      {"NS ns1.example.net"},
+}
+
+// checkSelftest compares the results recorded in data with the expected answers.
+func checkSelftest(data *report.Data) (failures []string) {
+	if data.SentRequests != len(selftestItems) {
+		failures = append(failures, fmt.Sprintf("wrong number of requests recorded, want %d, got %d", len(selftestItems), data.SentRequests))
+	}
+
+	hosts := hostAnswers(data)
+	for hostname, answers := range selftestExpected {
+		want := make(map[string]struct{})
+		for _, answer := range answers {
+			want[answer] = struct{}{}
+		}
+
+		got, ok := hosts[hostname]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%v: not recorded", hostname))
+			continue
+		}
+
+		added, removed := diffSets(want, got)
+		if len(added) > 0 || len(removed) > 0 {
+			failures = append(failures, fmt.Sprintf("%v: unexpected answers: %v", hostname, formatChanges(added, removed)))
+		}
+	}
+
+	for hostname := range hosts {
+		if _, ok := selftestExpected[hostname]; !ok {
+			failures = append(failures, fmt.Sprintf("%v: should have been hidden", hostname))
+		}
+	}
+
+	return failures
+}
+
+func runSelftest() error {
+	zone, err := dnstest.NewZone("example.com", selftestZone...)
+	if err != nil {
+		return err
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	tempdir, err := ioutil.TempDir("", "taifun-selftest-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	wordlist := filepath.Join(tempdir, "wordlist.txt")
+	err = ioutil.WriteFile(wordlist, []byte(strings.Join(selftestItems, "\n")+"\n"), 0644)
+	if err != nil {
+		return err
+	}
+
+	opts := &Options{
+		Filename:     wordlist,
+		RequestTypes: []string{"A", "AAAA"},
+		BufferSize:   len(selftestItems),
+		Threads:      2,
+		Logfile:      filepath.Join(tempdir, "selftest"),
+		Nameserver:   srv.Addr,
+		OutputFormat: "text",
+		HideNetworks: []string{"192.0.2.99/32"},
+	}
+
+	err = cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+		return run(ctx, g, opts, []string{"FUZZ.example.com"})
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := report.ReadData(opts.Logfile + ".json")
+	if err != nil {
+		return err
+	}
+
+	failures := checkSelftest(data)
+	if len(failures) > 0 {
+		fmt.Printf("\nself test failed:\n")
+		for _, f := range failures {
+			fmt.Printf("  %v\n", f)
+		}
+		return errors.New("self test failed")
+	}
+
+	fmt.Printf("\nself test passed\n")
+	return nil
+}
+
+func newSelftestCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "selftest",
+		Short:                 "Run a self test against a built-in DNS server",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSelftest()
+		},
+	}
+}
@@ -0,0 +1,37 @@
+package shell
+
+import "strings"
+
+// Redact returns a copy of args with the values of the flags in names
+// replaced by "***", names are given without the leading dashes. Both
+// "--name value" and "--name=value" are recognized.
+func Redact(args []string, names ...string) []string {
+	secret := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		secret["--"+name] = struct{}{}
+	}
+
+	res := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			res = append(res, args[i:]...)
+			break
+		}
+
+		if pos := strings.Index(arg, "="); pos > 0 {
+			if _, ok := secret[arg[:pos]]; ok {
+				res = append(res, arg[:pos+1]+"***")
+				continue
+			}
+		}
+
+		res = append(res, arg)
+		if _, ok := secret[arg]; ok && i+1 < len(args) {
+			res = append(res, "***")
+			i++
+		}
+	}
+
+	return res
+}
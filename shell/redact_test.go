@@ -0,0 +1,53 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	var tests = []struct {
+		args  []string
+		names []string
+		res   []string
+	}{
+		{
+			args: []string{"taifun", "--kafka-password", "foo", "FUZZ.example.com"},
+			res:  []string{"taifun", "--kafka-password", "foo", "FUZZ.example.com"},
+		},
+		{
+			args:  []string{"taifun", "--kafka-password", "foo", "FUZZ.example.com"},
+			names: []string{"kafka-password"},
+			res:   []string{"taifun", "--kafka-password", "***", "FUZZ.example.com"},
+		},
+		{
+			args:  []string{"taifun", "--kafka-password=foo=bar", "FUZZ.example.com"},
+			names: []string{"kafka-password"},
+			res:   []string{"taifun", "--kafka-password=***", "FUZZ.example.com"},
+		},
+		{
+			args:  []string{"taifun", "--kafka-password"},
+			names: []string{"kafka-password"},
+			res:   []string{"taifun", "--kafka-password"},
+		},
+		{
+			args:  []string{"taifun", "--kafka-username", "foo", "--kafka-password-file", "x", "FUZZ.example.com"},
+			names: []string{"kafka-password"},
+			res:   []string{"taifun", "--kafka-username", "foo", "--kafka-password-file", "x", "FUZZ.example.com"},
+		},
+		{
+			args:  []string{"taifun", "--", "--kafka-password", "foo"},
+			names: []string{"kafka-password"},
+			res:   []string{"taifun", "--", "--kafka-password", "foo"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			res := Redact(test.args, test.names...)
+			if strings.Join(res, "\n") != strings.Join(test.res, "\n") {
+				t.Fatalf("wrong result, want\n  %q\ngot:\n  %q", test.res, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import (
+	"context"
+
+	"github.com/happal/taifun/producer"
+)
+
+// handlePauseSignals does nothing, SIGUSR1 and SIGUSR2 are not available on
+// this platform.
+func handlePauseSignals(ctx context.Context, throttle *producer.Throttle) {}
@@ -0,0 +1,37 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/happal/taifun/producer"
+)
+
+// handlePauseSignals pauses the throttle when SIGUSR1 is received and resumes
+// it on SIGUSR2, until the context is cancelled.
+func handlePauseSignals(ctx context.Context, throttle *producer.Throttle) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case sig := <-ch:
+				if sig == syscall.SIGUSR1 {
+					throttle.Pause()
+				} else {
+					throttle.Resume()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
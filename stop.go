@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// stopAfterShown forwards the results from in to out and calls stop once
+// max results which are not hidden have been forwarded.
+func stopAfterShown(ctx context.Context, max int, stop func(), in <-chan resolve.Result, out chan<- resolve.Result) error {
+	defer close(out)
+
+	shown := 0
+	for res := range in {
+		if !res.Hide {
+			shown++
+			if shown == max {
+				stop()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- res:
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// Limits for the suggestions generated from one pattern.
+const (
+	suggestNumberGap    = 100 // do not fill gaps larger than this in numeric sequences
+	suggestNumberExtend = 5   // continue numeric sequences by this many numbers
+	suggestMaxCombined  = 500 // maximum number of prefix/suffix combinations
+)
+
+// environmentMarkers are common labels for deployment stages, items
+// containing one are suggested with the others.
+var environmentMarkers = []string{
+	"dev", "develop", "test", "testing", "qa", "uat", "int", "stage", "staging",
+	"preprod", "prod", "demo", "sandbox", "beta",
+}
+
+// numberPattern splits an item at the last number it contains.
+var numberPattern = regexp.MustCompile(`^(.*?)(\d+)(\D*)$`)
+
+// Suggester analyzes the items of shown results which resolved and writes
+// new items following the same naming patterns to a file: numeric sequences
+// are continued and gaps are filled, environment markers (e.g. dev, prod)
+// are swapped, and prefixes and suffixes separated by dashes are combined.
+// Items which have already been requested are not suggested.
+type Suggester struct {
+	filename string
+
+	found     []string
+	requested map[string]struct{}
+}
+
+// NewSuggester returns a new suggester writing to filename.
+func NewSuggester(filename string) *Suggester {
+	return &Suggester{
+		filename:  filename,
+		requested: make(map[string]struct{}),
+	}
+}
+
+// Run reads results from in and forwards them to out, collecting the items
+// on the way. When in is closed, the suggestions are written to the file and
+// out is closed.
+func (s *Suggester) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	err := forward(ctx, in, out, func(res resolve.Result) error {
+		s.requested[res.Item] = struct{}{}
+		if !res.Hide && res.Resolved() {
+			s.found = append(s.found, res.Item)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(s.filename)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range s.Suggestions() {
+		_, err = fmt.Fprintln(f, item)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// Suggestions returns the sorted list of new items.
+func (s *Suggester) Suggestions() []string {
+	var candidates []string
+	candidates = append(candidates, suggestNumbers(s.found)...)
+	candidates = append(candidates, suggestEnvironments(s.found)...)
+	candidates = append(candidates, suggestCombinations(s.found)...)
+
+	var list []string
+	for _, item := range resolve.Unique(candidates) {
+		if _, ok := s.requested[item]; ok || item == "" {
+			continue
+		}
+		list = append(list, item)
+	}
+
+	return list
+}
+
+// suggestNumbers groups the items by the text around the last number and
+// returns the missing numbers of each sequence, plus a few after the highest
+// one. Zero padding is kept.
+func suggestNumbers(items []string) (list []string) {
+	type sequence struct {
+		prefix, suffix string
+		width          int // zero padded to this width, 0 if not padded
+		numbers        []int
+	}
+
+	sequences := make(map[string]*sequence)
+	for _, item := range items {
+		m := numberPattern.FindStringSubmatch(item)
+		if m == nil {
+			continue
+		}
+
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+
+		width := 0
+		if strings.HasPrefix(m[2], "0") && len(m[2]) > 1 {
+			width = len(m[2])
+		}
+
+		key := fmt.Sprintf("%s\x00%s\x00%d", m[1], m[3], width)
+		seq, ok := sequences[key]
+		if !ok {
+			seq = &sequence{prefix: m[1], suffix: m[3], width: width}
+			sequences[key] = seq
+		}
+		seq.numbers = append(seq.numbers, n)
+	}
+
+	for _, seq := range sequences {
+		sort.Ints(seq.numbers)
+		first, last := seq.numbers[0], seq.numbers[len(seq.numbers)-1]
+		if last-first > suggestNumberGap {
+			first = last - suggestNumberGap
+		}
+
+		for n := first; n <= last+suggestNumberExtend; n++ {
+			list = append(list, fmt.Sprintf("%s%0*d%s", seq.prefix, seq.width, n, seq.suffix))
+		}
+	}
+
+	return list
+}
+
+// splitLabel splits an item into the parts separated by dashes or dots.
+func splitLabel(item string) []string {
+	return strings.FieldsFunc(item, func(r rune) bool {
+		return r == '-' || r == '.'
+	})
+}
+
+// suggestEnvironments returns the items with the environment markers
+// replaced by the other markers.
+func suggestEnvironments(items []string) (list []string) {
+	markers := make(map[string]struct{}, len(environmentMarkers))
+	for _, marker := range environmentMarkers {
+		markers[marker] = struct{}{}
+	}
+
+	for _, item := range items {
+		for _, part := range splitLabel(item) {
+			if _, ok := markers[part]; !ok {
+				continue
+			}
+
+			for _, marker := range environmentMarkers {
+				list = append(list, replaceLabelPart(item, part, marker))
+			}
+		}
+	}
+
+	return list
+}
+
+// replaceLabelPart replaces all occurrences of part in item which are
+// separated by dashes or dots from the rest of the item.
+func replaceLabelPart(item, part, replacement string) string {
+	re := regexp.MustCompile(`(^|[-.])` + regexp.QuoteMeta(part) + `($|[-.])`)
+	return re.ReplaceAllString(item, "${1}"+replacement+"${2}")
+}
+
+// suggestCombinations combines the prefixes and suffixes of items consisting
+// of two parts separated by a dash, if each of them occurs at least twice.
+func suggestCombinations(items []string) (list []string) {
+	prefixes := make(map[string]int)
+	suffixes := make(map[string]int)
+	for _, item := range items {
+		parts := strings.Split(item, "-")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		prefixes[parts[0]]++
+		suffixes[parts[1]]++
+	}
+
+	for prefix, n := range prefixes {
+		if n < 2 {
+			continue
+		}
+
+		for suffix, m := range suffixes {
+			if m < 2 {
+				continue
+			}
+
+			list = append(list, prefix+"-"+suffix)
+			if len(list) >= suggestMaxCombined {
+				return list
+			}
+		}
+	}
+
+	return list
+}
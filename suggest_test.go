@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/happal/taifun/resolve"
+)
+
+func TestSuggestions(t *testing.T) {
+	var tests = []struct {
+		name  string
+		found []string
+		want  []string
+	}{
+		{
+			name:  "numbers",
+			found: []string{"web01", "web03"},
+			want:  []string{"web02", "web04", "web05", "web06", "web07", "web08"},
+		},
+		{
+			name:  "environments",
+			found: []string{"api-dev"},
+			want: []string{
+				"api-beta", "api-demo", "api-develop", "api-int", "api-preprod",
+				"api-prod", "api-qa", "api-sandbox", "api-stage", "api-staging",
+				"api-test", "api-testing", "api-uat",
+			},
+		},
+		{
+			name:  "combinations",
+			found: []string{"mail-eu", "mail-us", "vpn-eu", "vpn-asia", "web-us"},
+			want:  []string{"vpn-us"},
+		},
+		{
+			name:  "nothing",
+			found: []string{"www", "mail"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewSuggester("")
+			for _, item := range test.found {
+				s.requested[item] = struct{}{}
+				s.found = append(s.found, item)
+			}
+
+			got := s.Suggestions()
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("wrong suggestions, want:\n  %s\ngot:\n  %s", strings.Join(test.want, "\n  "), strings.Join(got, "\n  "))
+			}
+		})
+	}
+}
+
+func TestSuggesterRun(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	found := resolve.Request{Type: "A", Responses: []resolve.Response{{Type: "A", Data: "192.0.2.1"}}}
+	results := []resolve.Result{
+		{Item: "web01", Requests: []resolve.Request{found}},
+		{Item: "web03", Requests: []resolve.Request{found}},
+		// requested items are not suggested again
+		{Item: "web05", Requests: []resolve.Request{{Type: "A", NotFound: true}}},
+		// hidden results are not used for the patterns
+		{Item: "db01", Hide: true, Requests: []resolve.Request{found}},
+	}
+
+	in := make(chan resolve.Result, len(results))
+	for _, res := range results {
+		in <- res
+	}
+	close(in)
+
+	out := make(chan resolve.Result, len(results))
+
+	filename := filepath.Join(tempdir, "run.suggestions.txt")
+	err = NewSuggester(filename).Run(context.Background(), in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "web02\nweb04\nweb06\nweb07\nweb08\n"
+	if string(buf) != want {
+		t.Errorf("wrong suggestions written, want:\n%s\ngot:\n%s", want, buf)
+	}
+
+	if len(out) != len(results) {
+		t.Errorf("wrong number of results forwarded, want %d, got %d", len(results), len(out))
+	}
+}
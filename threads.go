@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+)
+
+// threadsValue is a flag value which accepts either a number of threads or
+// "auto".
+type threadsValue struct {
+	opts *Options
+}
+
+func (v threadsValue) String() string {
+	if v.opts.AutoThreads {
+		return "auto"
+	}
+	return strconv.Itoa(v.opts.Threads)
+}
+
+func (v threadsValue) Set(s string) error {
+	if s == "auto" {
+		v.opts.AutoThreads = true
+		return nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New(`expected a number or "auto"`)
+	}
+
+	v.opts.Threads = n
+	v.opts.AutoThreads = false
+	return nil
+}
+
+func (v threadsValue) Type() string {
+	return "n"
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// separateTXT reads results from in and forwards them to out, with the
+// character strings of TXT responses quoted and separated instead of joined.
+// When in is closed or the context is cancelled, out is closed.
+func separateTXT(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forward(ctx, in, out, func(res resolve.Result) error {
+		for i := range res.Requests {
+			separateTXTResponses(res.Requests[i].Responses)
+			separateTXTResponses(res.Requests[i].Chain)
+		}
+		return nil
+	})
+}
+
+// separateTXTResponses sets the data of the TXT responses to the separate
+// strings.
+func separateTXTResponses(responses []resolve.Response) {
+	for i, response := range responses {
+		if response.Type == "TXT" && len(response.Segments) > 0 {
+			responses[i].Data = resolve.TXTData(response.Segments, true)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/happal/taifun/resolve"
+)
+
+// TypeWriter writes the shown responses to one file per record type in a
+// directory, e.g. a.txt, aaaa.txt and cname.txt. Each line contains the host
+// name and the response data separated by a space.
+type TypeWriter struct {
+	dir   string
+	files map[string]*os.File
+}
+
+// NewTypeWriter returns a new writer for the directory dir.
+func NewTypeWriter(dir string) *TypeWriter {
+	return &TypeWriter{
+		dir:   dir,
+		files: make(map[string]*os.File),
+	}
+}
+
+// file returns the file for the record type, it is created if necessary.
+func (w *TypeWriter) file(recordType string) (*os.File, error) {
+	if f, ok := w.files[recordType]; ok {
+		return f, nil
+	}
+
+	filename := filepath.Join(w.dir, strings.ToLower(recordType)+".txt")
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	w.files[recordType] = f
+	return f, nil
+}
+
+func (w *TypeWriter) write(res resolve.Result) error {
+	if res.Hide {
+		return nil
+	}
+
+	// CNAME responses are returned for all request types, only write them once
+	type record struct{ Type, Data string }
+	written := make(map[record]struct{})
+
+	for _, request := range res.Requests {
+		if request.Hide {
+			continue
+		}
+
+		for _, response := range request.Responses {
+			if response.Hide {
+				continue
+			}
+
+			rec := record{response.Type, response.Data}
+			if _, ok := written[rec]; ok {
+				continue
+			}
+			written[rec] = struct{}{}
+
+			f, err := w.file(response.Type)
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(f, "%s %s\n", res.Hostname, response.Data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run reads results from in and forwards them to out, writing the shown
+// responses to the files on the way. When in is closed or the context is
+// cancelled, the files are closed and out is closed.
+func (w *TypeWriter) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	err := os.MkdirAll(w.dir, 0755)
+	if err != nil {
+		close(out)
+		return err
+	}
+
+	err = forward(ctx, in, out, w.write)
+
+	for _, f := range w.files {
+		cerr := f.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/happal/taifun/dnssec"
+	"github.com/happal/taifun/resolve"
+	"github.com/miekg/dns"
+)
+
+// DNSSECChecker validates the DNSSEC chain of trust for the answers of all
+// shown results.
+type DNSSECChecker struct {
+	Validator *dnssec.Validator
+	Threads   int
+}
+
+// NewDNSSECChecker returns a checker which requests the records needed for
+// the validation from server, using threads requests in parallel.
+func NewDNSSECChecker(server string, threads int) (*DNSSECChecker, error) {
+	if resolve.IsJSONServer(server) {
+		return nil, errors.New("--dnssec cannot be used with a JSON API")
+	}
+
+	v, err := dnssec.NewValidator(resolve.NameserverAddress(server), dnssec.RootAnchors)
+	if err != nil {
+		return nil, err
+	}
+
+	if threads < 1 {
+		threads = 1
+	}
+
+	return &DNSSECChecker{Validator: v, Threads: threads}, nil
+}
+
+// check validates the answers for all request types which returned shown
+// responses. The state of the result is the worst state of all answers.
+func (c *DNSSECChecker) check(res resolve.Result) resolve.Result {
+	if res.Hide || res.Delegation() {
+		return res
+	}
+
+	// order of the states from good to bad
+	rank := map[dnssec.State]int{
+		dnssec.Secure:        0,
+		dnssec.Insecure:      1,
+		dnssec.Indeterminate: 2,
+		dnssec.Bogus:         3,
+	}
+
+	var worst *dnssec.Result
+	var reasons []string
+	for _, req := range res.Requests {
+		if req.Hide || len(req.Responses) == 0 {
+			continue
+		}
+
+		result := c.Validator.Validate(res.Hostname, dns.StringToType[req.Type])
+		if result.Reason != "" {
+			reasons = append(reasons, result.Reason)
+		}
+
+		if worst == nil || rank[result.State] > rank[worst.State] {
+			worst = &result
+		}
+	}
+
+	if worst == nil {
+		return res
+	}
+
+	res.DNSSEC = worst.State.String()
+	res.DNSSECReason = strings.Join(resolve.Unique(reasons), "; ")
+	return res
+}
+
+// Run validates the results from in and sends them to out.
+func (c *DNSSECChecker) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forwardParallel(ctx, c.Threads, in, out, c.check)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/resolve"
+)
+
+// Verifier sends the requests for each shown result again to a trusted
+// resolver and hides (or marks) results whose answers cannot be reproduced,
+// e.g. because a public resolver returned made up answers.
+type Verifier struct {
+	Server  string
+	Threads int
+
+	// Keep configures the verifier to only mark results which cannot be
+	// reproduced instead of hiding them.
+	Keep bool
+
+	// ClientSubnet is attached to the requests if set, see resolve.QueryOptions.
+	ClientSubnet *net.IPNet
+
+	// Throttle limits the requests together with the requests to the
+	// primary name servers if set: the requests for a result count as one
+	// value.
+	Throttle *producer.Throttle
+}
+
+// NewVerifier returns a new Verifier which sends the requests to server,
+// using threads requests in parallel.
+func NewVerifier(server string, threads int, keep bool) *Verifier {
+	if threads < 1 {
+		threads = 1
+	}
+
+	return &Verifier{
+		Server:  server,
+		Threads: threads,
+		Keep:    keep,
+	}
+}
+
+// reproduced returns true if the trusted answer contains, for each type of
+// record in the original answer, at least one record with the same data.
+// The other records of the type may differ, so that answers which change
+// between requests (e.g. the addresses returned by CDNs) are reproduced, too.
+func reproduced(original, trusted resolve.Request) bool {
+	data := make(map[string]map[string]struct{})
+	for _, response := range trusted.Responses {
+		if data[response.Type] == nil {
+			data[response.Type] = make(map[string]struct{})
+		}
+		data[response.Type][normalizeData(response.Data)] = struct{}{}
+	}
+
+	// types of the original answer without a record in the trusted answer
+	missing := make(map[string]bool)
+	for _, response := range original.Responses {
+		if response.Hide {
+			continue
+		}
+
+		if _, ok := data[response.Type][normalizeData(response.Data)]; ok {
+			missing[response.Type] = false
+			continue
+		}
+
+		if _, ok := missing[response.Type]; !ok {
+			missing[response.Type] = true
+		}
+	}
+
+	for _, m := range missing {
+		if m {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeData returns the data of a response for comparison, the case and
+// the trailing dot of names are ignored.
+func normalizeData(data string) string {
+	return strings.TrimSuffix(strings.ToLower(data), ".")
+}
+
+// verify sends the requests for res to the trusted resolver. Requests which
+// cannot be verified because the trusted resolver did not answer are kept.
+func (v *Verifier) verify(ctx context.Context, res resolve.Result) resolve.Result {
+	if res.Hide || !res.Resolved() {
+		return res
+	}
+
+	if v.Throttle != nil && v.Throttle.Wait(ctx) != nil {
+		return res
+	}
+
+	name := res.Hostname + "."
+	for _, req := range res.Requests {
+		if req.Hide || len(req.Responses) == 0 {
+			continue
+		}
+
+		trusted := resolve.QueryWith(ctx, name, req.Type, v.Server, resolve.QueryOptions{ClientSubnet: v.ClientSubnet})
+		if trusted.Error != nil {
+			continue
+		}
+
+		if !reproduced(req, trusted) {
+			res.Unverified = true
+			break
+		}
+	}
+
+	if res.Unverified && !v.Keep {
+		res.Hide = true
+	}
+
+	return res
+}
+
+// Run verifies all results from in and sends them to out.
+func (v *Verifier) Run(ctx context.Context, in <-chan resolve.Result, out chan<- resolve.Result) error {
+	return forwardParallel(ctx, v.Threads, in, out, func(res resolve.Result) resolve.Result {
+		return v.verify(ctx, res)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/happal/taifun/resolve"
+)
+
+func TestReproduced(t *testing.T) {
+	a := func(data ...string) []resolve.Response {
+		var responses []resolve.Response
+		for _, d := range data {
+			responses = append(responses, resolve.Response{Type: "A", Data: d})
+		}
+		return responses
+	}
+
+	var tests = []struct {
+		name     string
+		original []resolve.Response
+		trusted  []resolve.Response
+		want     bool
+	}{
+		{"same", a("192.0.2.1"), a("192.0.2.1"), true},
+		{"different", a("192.0.2.1"), a("192.0.2.2"), false},
+		{"missing", a("192.0.2.1"), nil, false},
+		{"empty", nil, a("192.0.2.1"), true},
+		// CDNs return different subsets of their addresses
+		{"overlap", a("192.0.2.1", "192.0.2.2"), a("192.0.2.2", "192.0.2.3"), true},
+		{"subset", a("192.0.2.1", "192.0.2.2"), a("192.0.2.2"), true},
+		{
+			name:     "hidden",
+			original: []resolve.Response{{Type: "A", Data: "192.0.2.1", Hide: true}, {Type: "A", Data: "192.0.2.2"}},
+			trusted:  a("192.0.2.2"),
+			want:     true,
+		},
+		{
+			name:     "hidden-only",
+			original: []resolve.Response{{Type: "A", Data: "192.0.2.1", Hide: true}},
+			trusted:  nil,
+			want:     true,
+		},
+		{
+			name:     "name-case",
+			original: []resolve.Response{{Type: "CNAME", Data: "WWW.example.com."}},
+			trusted:  []resolve.Response{{Type: "CNAME", Data: "www.example.com"}},
+			want:     true,
+		},
+		{
+			// every type must be reproduced
+			name:     "cname-and-a",
+			original: []resolve.Response{{Type: "CNAME", Data: "cdn.example.net."}, {Type: "A", Data: "192.0.2.1"}},
+			trusted:  []resolve.Response{{Type: "CNAME", Data: "cdn.example.net."}, {Type: "A", Data: "198.51.100.1"}},
+			want:     false,
+		},
+		{
+			name:     "other-type",
+			original: a("192.0.2.1"),
+			trusted:  []resolve.Response{{Type: "AAAA", Data: "192.0.2.1"}},
+			want:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := reproduced(resolve.Request{Type: "A", Responses: test.original}, resolve.Request{Type: "A", Responses: test.trusted})
+			if got != test.want {
+				t.Errorf("wrong result, want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/happal/taifun/report"
+)
+
+// version is set when building a release with
+// -ldflags "-X main.version=..."
+var version = ""
+
+// currentBuildInfo returns information about the running binary. If the
+// version has not been set at build time, the module version is used.
+func currentBuildInfo() report.BuildInfo {
+	info := report.BuildInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+
+	if info.Version == "" {
+		info.Version = "unknown"
+		if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+			info.Version = bi.Main.Version
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/report"
+	"github.com/happal/taifun/resolve"
+	"github.com/happal/taifun/shell"
+	"golang.org/x/sync/errgroup"
+)
+
+// watchIgnoredFlags returns the flags set in opts which have no effect with
+// --watch: each pass only resolves the values, marks the results with the
+// filters and reports the changes.
+func watchIgnoredFlags(opts *Options) []string {
+	flags := []struct {
+		set  bool
+		name string
+	}{
+		{opts.Logfile != "", "--logfile"},
+		{opts.RecordHidden, "--record-hidden"},
+		{opts.WriteFound != "", "--write-found"},
+		{opts.WriteAmass != "", "--write-amass"},
+		{opts.WriteMarkdown != "", "--write-markdown"},
+		{opts.WriteGraph != "", "--write-graph"},
+		{opts.WriteTypes != "", "--write-types"},
+		{opts.WriteDelegations != "", "--write-delegations"},
+		{opts.WriteIPs != "", "--write-ips"},
+		{opts.WriteFailed != "", "--write-failed"},
+		{opts.WriteSuggestions != "", "--write-suggestions"},
+		{len(opts.KafkaBrokers) > 0, "--kafka-brokers"},
+		{opts.notifySlack != "", "--notify-slack"},
+		{opts.notifyDiscord != "", "--notify-discord"},
+		{opts.notifyTelegram != "", "--notify-telegram"},
+		{opts.Bell, "--bell"},
+		{opts.DesktopNotify, "--desktop-notify"},
+		{len(opts.Plugins) > 0, "--plugin"},
+		{len(opts.PluginFiles) > 0, "--plugin-load"},
+		{len(opts.PluginCommands) > 0, "--plugin-exec"},
+		{opts.SpillDir != "", "--spill-dir"},
+		{opts.MaxDuration > 0, "--max-duration"},
+		{opts.StopAfterFound > 0, "--stop-after-found"},
+		{opts.InputFormat != "" && opts.InputFormat != "text", "--input-format"},
+		{opts.DNSSEC, "--dnssec"},
+		{len(opts.CompareWith) > 0, "--compare-with"},
+		{opts.HTTPProbe, "--http-probe"},
+		{len(opts.CheckPorts) > 0, "--check-ports"},
+		{opts.RDAP, "--rdap"},
+		{opts.TXTSegments, "--txt-segments"},
+		{opts.Verbose > 0, "--verbose"},
+		{opts.Format != "", "--format"},
+		{opts.OutputFormat != "" && opts.OutputFormat != "text", "--output-format"},
+		{opts.SortResults != "", "--sort-results"},
+		{opts.GroupSummary, "--group-summary"},
+		{opts.Cluster, "--cluster"},
+		{opts.controlAddr != "", "--control-addr"},
+		{opts.pprofAddr != "", "--pprof-addr"},
+	}
+
+	var names []string
+	for _, flag := range flags {
+		if flag.set {
+			names = append(names, flag.name)
+		}
+	}
+
+	return names
+}
+
+// watchPass resolves all values once and returns the results which are not
+// hidden. Host names for which requests failed keep their results from the
+// previous pass, so a temporary outage is not reported as a removal.
+func watchPass(ctx context.Context, term cli.Terminal, opts *Options, hostname string, throttle *producer.Throttle, prev *report.Data, pass int) (*report.Data, error) {
+	data := &report.Data{
+		SchemaVersion: report.SchemaVersion,
+		Start:         time.Now(),
+		Hostname:      resolve.CleanHostname(hostname),
+		InputFile:     opts.Filename,
+		Range:         opts.Range,
+		RangeFormat:   opts.RangeFormat,
+		Results:       []report.RecordedResult{},
+	}
+
+	var (
+		mu     sync.Mutex
+		total  int
+		sent   int
+		failed = make(map[string]struct{})
+	)
+
+	onCount := func(n int) {
+		mu.Lock()
+		total = n
+		mu.Unlock()
+	}
+
+	onResult := func(res resolve.Result) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sent++
+		if sent%100 == 0 {
+			term.SetStatus([]string{"", fmt.Sprintf("pass %d: %d of %d values resolved", pass, sent, total)})
+		}
+
+		// the results of the previous pass are used for failed requests
+		for _, req := range res.Requests {
+			if req.Error != nil {
+				failed[res.Hostname] = struct{}{}
+				return
+			}
+		}
+
+		if res.Hide {
+			return
+		}
+		data.Results = append(data.Results, report.NewResult(res, false))
+	}
+
+	err := resolveAll(ctx, opts, hostname, nil, throttle, onCount, onResult)
+	if err != nil {
+		return nil, err
+	}
+
+	if prev != nil {
+		for _, res := range prev.Results {
+			if _, ok := failed[res.Hostname]; ok {
+				data.Results = append(data.Results, res)
+			}
+		}
+	}
+
+	data.End = time.Now()
+	data.TotalRequests = total
+	data.SentRequests = sent
+	data.Position = sent
+	data.ShownResults = len(data.Results)
+	data.Cancelled = ctx.Err() != nil
+
+	return data, nil
+}
+
+// runOnChange runs command with the changes on stdin. Its output is printed
+// to the terminal.
+func runOnChange(ctx context.Context, term cli.Terminal, args []string, changes []Change) error {
+	var buf bytes.Buffer
+	for _, c := range changes {
+		fmt.Fprintln(&buf, c.String())
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = &buf
+	w := cli.NewStdioWrapper(term)
+	cmd.Stdout = w.Stdout()
+	cmd.Stderr = w.Stderr()
+	err := cmd.Run()
+
+	_ = w.Stdout().Close()
+	_ = w.Stderr().Close()
+	return err
+}
+
+// watch resolves all values repeatedly and reports the changes between
+// passes.
+func watch(ctx context.Context, g *errgroup.Group, opts *Options, args []string) error {
+	hostname, err := opts.hostname(args)
+	if err != nil {
+		return err
+	}
+
+	err = opts.valid()
+	if err != nil {
+		return err
+	}
+
+	var onChange []string
+	if opts.OnChange != "" {
+		onChange, err = shell.Split(opts.OnChange)
+		if err != nil {
+			return fmt.Errorf("invalid command %q for --on-change: %v", opts.OnChange, err)
+		}
+	}
+
+	logfilePrefix, err := logfilePath(opts, hostname)
+	if err != nil {
+		return err
+	}
+
+	term, cleanup, err := setupTerminal(ctx, g, logfilePrefix, logfileSuffix(opts, ".log"), opts.quiet(), opts.logFormat(hostname))
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	throttle := producer.NewThrottle(opts.RequestsPerSecond, opts.Burst)
+
+	err = setupNameservers(ctx, term, opts, hostname, throttle)
+	if err != nil {
+		return err
+	}
+
+	var prev *report.Data
+	if opts.WatchState != "" {
+		prev, err = report.ReadData(opts.WatchState)
+		if os.IsNotExist(err) {
+			prev, err = nil, nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %v failed: %v", opts.WatchState, err)
+		}
+
+		if prev != nil && !opts.quiet() {
+			term.Printf("comparing against %d results from %v", len(prev.Results), opts.WatchState)
+		}
+	}
+
+	handlePauseSignals(ctx, throttle)
+
+	for pass := 1; ; pass++ {
+		term.SetStatus([]string{"", fmt.Sprintf("pass %d: starting", pass)})
+
+		cur, err := watchPass(ctx, term, opts, hostname, throttle, prev, pass)
+		if err != nil {
+			return err
+		}
+
+		// do not compare or save incomplete passes
+		if cur.Cancelled {
+			term.SetStatus(nil)
+			return nil
+		}
+
+		ts := cur.End.Format("2006-01-02 15:04:05")
+		if prev == nil {
+			term.Printf("%v: pass %d found %d host names\n", ts, pass, len(hostAnswers(cur)))
+		} else {
+			changes := DiffData(prev, cur)
+			if len(changes) > 0 {
+				var lines []string
+				for _, c := range changes {
+					lines = append(lines, c.String())
+				}
+				term.Printf("%v: pass %d found %d changes:\n%s\n", ts, pass, len(changes), strings.Join(lines, "\n"))
+
+				if onChange != nil {
+					err := runOnChange(ctx, term, onChange, changes)
+					if err != nil && ctx.Err() == nil {
+						cli.Warnf(term, "running %q failed: %v\n", opts.OnChange, err)
+					}
+				}
+			} else if !opts.quiet() {
+				term.Printf("%v: pass %d found no changes\n", ts, pass)
+			}
+		}
+
+		if opts.WatchState != "" {
+			err := report.WriteData(opts.WatchState, cur)
+			if err != nil {
+				return err
+			}
+		}
+
+		prev = cur
+
+		next := time.Now().Add(opts.WatchInterval)
+		term.SetStatus([]string{"", fmt.Sprintf("pass %d done, next pass at %v", pass, next.Format("2006-01-02 15:04:05"))})
+
+		select {
+		case <-time.After(opts.WatchInterval):
+		case <-ctx.Done():
+			term.SetStatus(nil)
+			return nil
+		}
+	}
+}
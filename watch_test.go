@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/happal/taifun/dnstest"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/report"
+)
+
+func TestWatchIgnoredFlags(t *testing.T) {
+	var tests = []struct {
+		opts Options
+		want []string
+	}{
+		{Options{}, nil},
+		// the filters and the verification are applied in each pass
+		{Options{HideEmpty: true, VerifyWith: "192.0.2.1", InputFormat: "text"}, nil},
+		{Options{Logfile: "run"}, []string{"--logfile"}},
+		{Options{WriteFound: "found.txt", WriteIPs: "ips.txt"}, []string{"--write-found", "--write-ips"}},
+		{Options{Plugins: []string{"tag"}, PluginCommands: []string{"./enrich"}}, []string{"--plugin", "--plugin-exec"}},
+		{Options{SpillDir: "/tmp", controlAddr: "localhost:8053"}, []string{"--spill-dir", "--control-addr"}},
+		{Options{DNSSEC: true, CompareWith: []string{"192.0.2.1"}}, []string{"--dnssec", "--compare-with"}},
+		{Options{notifySlack: "https://hooks.slack.example/x"}, []string{"--notify-slack"}},
+		{Options{InputFormat: "csv"}, []string{"--input-format"}},
+		// the changes are printed in a fixed format
+		{Options{OutputFormat: "text"}, nil},
+		{Options{TXTSegments: true, Verbose: 1}, []string{"--txt-segments", "--verbose"}},
+		{Options{Format: "{{.Hostname}}", OutputFormat: "csv"}, []string{"--format", "--output-format"}},
+	}
+
+	for _, test := range tests {
+		got := watchIgnoredFlags(&test.opts)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("wrong flags, want %q, got %q", test.want, got)
+		}
+	}
+}
+
+// testWatchServer starts a name server for the records in example.com.
+func testWatchServer(t testing.TB, records ...string) *dnstest.Server {
+	zone, err := dnstest.NewZone("example.com", records...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv
+}
+
+func TestWatchPass(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "taifun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	filename := filepath.Join(tempdir, "words.txt")
+	err = ioutil.WriteFile(filename, []byte("www\nmail\napi\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{
+		Filename:     filename,
+		RequestTypes: []string{"A"},
+		Threads:      2,
+		BufferSize:   10,
+		OutputFormat: "text",
+	}
+
+	err = opts.valid()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	term := &testTerminal{}
+	throttle := producer.NewThrottle(0, 1)
+
+	pass := func(srv *dnstest.Server, prev *report.Data, n int) *report.Data {
+		opts.Nameserver = srv.Addr
+		data, err := watchPass(context.Background(), term, opts, "FUZZ.example.com.", throttle, prev, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	srv := testWatchServer(t,
+		"www.example.com. 300 IN A 192.0.2.1",
+		"mail.example.com. 300 IN A 192.0.2.2",
+	)
+	first := pass(srv, nil, 1)
+	_ = srv.Close()
+
+	if first.SentRequests != 3 || first.TotalRequests != 3 || len(first.Results) != 2 {
+		t.Fatalf("wrong first pass, want 2 of 3 results, got %d of %d (total %d)", len(first.Results), first.SentRequests, first.TotalRequests)
+	}
+
+	srv = testWatchServer(t,
+		"www.example.com. 300 IN A 192.0.2.3",
+		"api.example.com. 300 IN A 192.0.2.4",
+	)
+	second := pass(srv, first, 2)
+	_ = srv.Close()
+
+	var changes []string
+	for _, c := range DiffData(first, second) {
+		changes = append(changes, c.String())
+	}
+
+	want := []string{
+		"+ api.example.com: A 192.0.2.4",
+		"- mail.example.com: A 192.0.2.2",
+		"~ www.example.com: added A 192.0.2.3; removed A 192.0.2.1",
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong changes, want:\n  %s\ngot:\n  %s", strings.Join(want, "\n  "), strings.Join(changes, "\n  "))
+	}
+
+	// the server is gone, the results for the failed requests are kept
+	third := pass(srv, second, 3)
+	if changes := DiffData(second, third); len(changes) != 0 {
+		t.Errorf("failed requests were reported as changes: %v", changes)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/resolve"
+)
+
+// rateConfidence returns true if the results need to be rated by comparing
+// them with the answers for random names: the confidence is displayed,
+// filtered on or recorded in the JSON log or Kafka messages. Runs via the
+// compat command never rate the results.
+func (opts *Options) rateConfidence() bool {
+	if opts.compat {
+		return false
+	}
+
+	return opts.ShowConfidence || opts.MinConfidence != "" ||
+		opts.OutputFormat == "csv" || opts.template != nil ||
+		opts.Logfile != "" || opts.Logdir != "" || len(opts.KafkaBrokers) > 0
+}
+
+// setupWildcard requests random names for the hostname template to detect a
+// wildcard, which is used to rate the confidence of the results. The
+// requests respect the rate limit of throttle. If the detection fails, the
+// results are not rated.
+func setupWildcard(ctx context.Context, term cli.Terminal, opts *Options, hostname string, throttle *producer.Throttle) error {
+	server := opts.Nameserver
+	if server == "" {
+		server = opts.Resolvers[0]
+	}
+
+	wildcard, err := resolve.DetectWildcard(ctx, hostname, server, opts.RequestTypes, throttle.Wait)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		if !opts.quiet() {
+			cli.Warnf(term, "%v, results are not rated", err)
+		}
+		return nil
+	}
+
+	if wildcard.Found() && !opts.quiet() {
+		term.Printf("wildcard detected, answers for random names: %v", wildcard)
+	}
+
+	opts.wildcard = wildcard
+	return nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/happal/taifun/cli"
+	"github.com/happal/taifun/producer"
+	"github.com/happal/taifun/resolve"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// workerRetries is the number of attempts for contacting the coordinator
+	// before giving up.
+	workerRetries = 10
+
+	// workerRetryDelay is the time to wait between attempts.
+	workerRetryDelay = 3 * time.Second
+)
+
+// Worker requests batches of items from a coordinator, resolves them and
+// sends the results back.
+type Worker struct {
+	URL      string // base URL of the coordinator
+	Token    string // sent to the coordinator with each request
+	Name     string
+	Threads  int
+	Servers  []string
+	Throttle *producer.Throttle
+
+	client  *http.Client
+	batches int
+	results int
+}
+
+// NewWorker returns a new worker for the coordinator at baseURL.
+func NewWorker(baseURL, token, name string, threads int, servers []string, throttle *producer.Throttle) *Worker {
+	return &Worker{
+		URL:      strings.TrimRight(baseURL, "/"),
+		Token:    token,
+		Name:     name,
+		Threads:  threads,
+		Servers:  servers,
+		Throttle: throttle,
+		client: &http.Client{
+			// requests for batches are held by the coordinator until
+			// work is available
+			Timeout: pollTimeout + time.Minute,
+		},
+	}
+}
+
+// call sends a request to the coordinator and decodes the response into
+// result. Network errors are retried.
+func (w *Worker) call(ctx context.Context, method, path string, body, result interface{}) (err error) {
+	var buf []byte
+	if body != nil {
+		buf, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	target := w.URL + path + "?" + url.Values{"worker": []string{w.Name}}.Encode()
+
+	for i := 0; i < workerRetries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(workerRetryDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		var req *http.Request
+		req, err = http.NewRequest(method, target, bytes.NewReader(buf))
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+
+		var res *http.Response
+		res, err = w.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		err = decodeResponse(res, result)
+		if err == nil || res.StatusCode < http.StatusInternalServerError {
+			return err
+		}
+	}
+
+	return fmt.Errorf("contacting the coordinator failed: %v", err)
+}
+
+// decodeResponse decodes the JSON body of res into result and closes it.
+func decodeResponse(res *http.Response, result interface{}) error {
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&msg)
+		if err != nil || msg.Error == "" {
+			return fmt.Errorf("coordinator returned %v", res.Status)
+		}
+		return fmt.Errorf("coordinator returned an error: %v", msg.Error)
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// resolve sends the requests for all items and returns the results.
+func (w *Worker) resolve(ctx context.Context, job Job, items []string) ([]resolve.Result, error) {
+	in := make(chan string, len(items))
+	for _, item := range items {
+		in <- item
+	}
+	close(in)
+
+	valueCh := w.Throttle.Run(ctx, in)
+	out := make(chan resolve.Result)
+
+	// the subnets and the jitter have been checked before
+	subnets, _ := parseNetworks(job.ClientSubnets)
+	jitterMin, jitterMax, _ := parseJitter(job.Jitter)
+
+	// distribute the threads evenly across the servers
+	newResolver := func(n int) *resolve.Resolver {
+		resolver, _ := resolve.NewResolver(valueCh, out, job.Hostname, w.Servers[n%len(w.Servers)], job.RequestTypes)
+		resolver.ClientSubnets = subnets
+		resolver.FollowCNAMEs = job.FollowCNAMEs
+		resolver.JitterMin, resolver.JitterMax = jitterMin, jitterMax
+		resolver.Concurrency = job.ParallelRequests
+		return resolver
+	}
+
+	pool := resolve.NewPool(newResolver, w.Threads)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pool.Run(ctx, out)
+	}()
+
+	results := make([]resolve.Result, 0, len(items))
+	for res := range out {
+		results = append(results, res)
+	}
+
+	err := <-errCh
+	if err != nil {
+		return nil, err
+	}
+
+	return results, ctx.Err()
+}
+
+// Run processes batches until the coordinator has no more work or the
+// context is cancelled.
+func (w *Worker) Run(ctx context.Context, term cli.Terminal) error {
+	var job Job
+	err := w.call(ctx, http.MethodGet, "/job", nil, &job)
+	if err != nil {
+		return err
+	}
+
+	// creating a resolver only fails for invalid parameters, so check them
+	// once before starting
+	_, err = resolve.NewResolver(nil, nil, job.Hostname, w.Servers[0], job.RequestTypes)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseNetworks(job.ClientSubnets)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = parseJitter(job.Jitter)
+	if err != nil {
+		return err
+	}
+
+	term.Printf("resolving %v (%v) for %v", job.Hostname, strings.Join(job.RequestTypes, ", "), w.URL)
+
+	for {
+		term.SetStatus([]string{"", fmt.Sprintf("%d batches, %d results sent, waiting for work", w.batches, w.results)})
+
+		var batch Batch
+		err := w.call(ctx, http.MethodPost, "/batch", nil, &batch)
+		if err != nil {
+			return err
+		}
+
+		if batch.Done {
+			break
+		}
+
+		if len(batch.Items) == 0 {
+			continue
+		}
+
+		term.SetStatus([]string{"", fmt.Sprintf("%d batches, %d results sent, resolving batch %d (%d items)", w.batches, w.results, batch.ID, len(batch.Items))})
+
+		results, err := w.resolve(ctx, job, batch.Items)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		res := BatchResults{ID: batch.ID}
+		for _, r := range results {
+			res.Results = append(res.Results, NewWireResult(r))
+		}
+
+		err = w.call(ctx, http.MethodPost, "/results", res, &struct{}{})
+		if err != nil {
+			return err
+		}
+
+		w.batches++
+		w.results += len(results)
+	}
+
+	term.SetStatus(nil)
+	term.Printf("done, processed %d batches, sent %d results\n", w.batches, w.results)
+	return nil
+}
+
+func newWorkerCommand() *cobra.Command {
+	var (
+		name              string
+		token             string
+		threads           int
+		nameserver        string
+		resolversFile     string
+		requestsPerSecond float64
+		burst             int
+		configFile        string
+		profile           string
+	)
+
+	cmd := &cobra.Command{
+		Use:                   "worker [options] URL",
+		Short:                 "Resolve values handed out by a coordinator started with 'taifun serve'",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("exactly one coordinator URL needs to be specified")
+			}
+
+			err := applyProfile(cmd, configFile, profile)
+			if err != nil {
+				return err
+			}
+
+			baseURL := args[0]
+			if !strings.Contains(baseURL, "://") {
+				baseURL = "http://" + baseURL
+			}
+
+			if threads <= 0 {
+				return errors.New("invalid number of threads")
+			}
+
+			if burst < 0 {
+				return errors.New("burst must not be negative")
+			}
+
+			if nameserver != "" && resolversFile != "" {
+				return errors.New("only one of --nameserver and --resolvers can be specified")
+			}
+
+			if token == "" {
+				return errors.New("no token specified, it is printed by 'taifun serve'")
+			}
+
+			if name == "" {
+				hostname, err := os.Hostname()
+				if err != nil {
+					hostname = "worker"
+				}
+				name = fmt.Sprintf("%v-%d", hostname, os.Getpid())
+			}
+
+			return cli.WithContext(func(ctx context.Context, g *errgroup.Group) error {
+				term, cleanup, err := setupTerminal(ctx, g, "", "", false, logFormat{})
+				defer cleanup()
+				if err != nil {
+					return err
+				}
+
+				var servers []string
+				switch {
+				case resolversFile != "":
+					servers, _, err = readWeightedResolvers(resolversFile)
+					if err != nil {
+						return err
+					}
+
+					if len(servers) == 0 {
+						return fmt.Errorf("no resolvers found in %v", resolversFile)
+					}
+				case nameserver != "":
+					servers = []string{nameserver}
+				default:
+					server, err := resolve.FindSystemNameserver()
+					if err != nil {
+						return err
+					}
+					term.Printf("found system nameserver %v", server)
+					servers = []string{server}
+				}
+
+				throttle := producer.NewThrottle(requestsPerSecond, burst)
+				w := NewWorker(baseURL, token, name, threads, servers, throttle)
+				return w.Run(ctx, term)
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	addProfileFlags(flags, &configFile, &profile)
+	flags.StringVar(&token, "token", "", "authenticate to the coordinator with `token`")
+	flags.StringVar(&name, "name", "", "identify the worker as `name` to the coordinator (default: host name and process ID)")
+	flags.IntVarP(&threads, "threads", "t", 2, "resolve `n` DNS queries in parallel")
+	flags.StringVar(&nameserver, "nameserver", "", "send DNS queries to `server`, if empty, the system resolver is used")
+	flags.StringVar(&resolversFile, "resolvers", "", "distribute DNS queries across the name servers read from `filename`, one per line")
+	flags.Float64Var(&requestsPerSecond, "requests-per-second", 0, "do at most `n` requests per seconds (e.g. 0.5)")
+	flags.IntVar(&burst, "burst", 1, "allow bursts of up to `n` requests while respecting --requests-per-second on average")
+
+	return cmd
+}
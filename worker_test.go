@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happal/taifun/dnstest"
+	"github.com/happal/taifun/producer"
+)
+
+func TestWorker(t *testing.T) {
+	zone, err := dnstest.NewZone("example.com",
+		"www.example.com. 300 IN A 192.0.2.1",
+		"mail.example.com. 300 IN A 192.0.2.2",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dnsServer, err := dnstest.NewServer(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = dnsServer.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	items := []string{"www", "mail", "ftp", "dev"}
+	coord, wait := startTestCoordinator(ctx, t, items, 3, time.Minute)
+
+	srv := httptest.NewServer(coord.Handler())
+	defer srv.Close()
+
+	w := NewWorker(srv.URL, "secret", "test", 2, []string{dnsServer.Addr}, producer.NewThrottle(0, 1))
+	err = w.Run(ctx, &testTerminal{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.batches != 2 || w.results != len(items) {
+		t.Errorf("wrong statistics, want 2 batches and %d results, got %d and %d", len(items), w.batches, w.results)
+	}
+
+	var found []string
+	for _, res := range wait() {
+		if len(res.Requests) != 1 {
+			t.Fatalf("wrong number of requests for %v: %d", res.Hostname, len(res.Requests))
+		}
+
+		if req := res.Requests[0]; !req.NotFound {
+			found = append(found, res.Hostname)
+		}
+	}
+	sort.Strings(found)
+
+	want := []string{"mail.example.com", "www.example.com"}
+	if strings.Join(found, ",") != strings.Join(want, ",") {
+		t.Errorf("wrong host names found, want %v, got %v", want, found)
+	}
+
+	if pos := coord.Position(); pos != len(items) {
+		t.Errorf("wrong position, want %d, got %d", len(items), pos)
+	}
+}
+
+func TestWorkerWrongToken(t *testing.T) {
+	coord := NewCoordinator(&testTerminal{}, Job{Hostname: "FUZZ.example.com.", RequestTypes: []string{"A"}}, "secret", 10, time.Minute)
+	srv := httptest.NewServer(coord.Handler())
+	defer srv.Close()
+
+	w := NewWorker(srv.URL, "wrong", "test", 1, []string{"127.0.0.1:53"}, producer.NewThrottle(0, 1))
+	err := w.Run(context.Background(), &testTerminal{})
+	if err == nil || !strings.Contains(err.Error(), "token") {
+		t.Errorf("wrong error returned: %v", err)
+	}
+}